@@ -0,0 +1,182 @@
+// (c) 2024, Ava Labs, Inc. All rights reserved.
+// See the file LICENSE for licensing terms.
+
+// preimages exports the preimages stored by a stopped node into a file, and
+// imports such a file, in the format used by go-ethereum.
+package main
+
+import (
+	"compress/gzip"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/ava-labs/avalanchego/database"
+	"github.com/ava-labs/avalanchego/database/leveldb"
+	"github.com/ava-labs/avalanchego/database/pebbledb"
+	"github.com/ava-labs/avalanchego/database/prefixdb"
+	"github.com/ava-labs/avalanchego/ids"
+	"github.com/ava-labs/avalanchego/utils/logging"
+	"github.com/ava-labs/coreth/cmd/utils"
+	"github.com/ava-labs/coreth/core"
+	"github.com/ava-labs/coreth/core/rawdb"
+	"github.com/ava-labs/coreth/internal/flags"
+	"github.com/ava-labs/coreth/plugin/evm"
+	"github.com/ethereum/go-ethereum/ethdb"
+	"github.com/ethereum/go-ethereum/log"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/urfave/cli/v2"
+)
+
+var (
+	// vmDBPrefix is the prefix under which avalanchego nests the database of
+	// each VM in the database of its chain.
+	vmDBPrefix = []byte("vm")
+	// ethDBPrefix is the prefix under which the VM nests the chain database.
+	ethDBPrefix = []byte("ethdb")
+)
+
+var (
+	dbDirFlag = &cli.StringFlag{
+		Name:     "db-dir",
+		Usage:    "Path to the versioned database directory of the node, e.g. ~/.avalanchego/db/mainnet/v1.4.5",
+		Required: true,
+	}
+	dbTypeFlag = &cli.StringFlag{
+		Name:  "db-type",
+		Usage: fmt.Sprintf("Type of the node database (%s or %s)", leveldb.Name, pebbledb.Name),
+		Value: leveldb.Name,
+	}
+	chainIDFlag = &cli.StringFlag{
+		Name:     "chain-id",
+		Usage:    "ID of the blockchain",
+		Required: true,
+	}
+)
+
+var app = flags.NewApp("C-Chain preimage tool")
+
+func init() {
+	app.Name = "preimages"
+	app.Commands = []*cli.Command{
+		{
+			Name:      "export",
+			Usage:     "Export the preimages of a stopped node, gzipped if the file name ends with .gz",
+			ArgsUsage: "<file>",
+			Flags:     []cli.Flag{dbDirFlag, dbTypeFlag, chainIDFlag},
+			Action:    exportPreimages,
+		},
+		{
+			Name:      "import",
+			Usage:     "Import a preimage file into a stopped node",
+			ArgsUsage: "<file>",
+			Flags:     []cli.Flag{dbDirFlag, dbTypeFlag, chainIDFlag},
+			Action:    importPreimages,
+		},
+	}
+}
+
+func openDatabase(c *cli.Context) (database.Database, error) {
+	var (
+		dir = c.String(dbDirFlag.Name)
+		reg = prometheus.NewRegistry()
+	)
+	switch dbType := c.String(dbTypeFlag.Name); dbType {
+	case leveldb.Name:
+		return leveldb.New(dir, nil, logging.NoLog{}, reg)
+	case pebbledb.Name:
+		return pebbledb.New(dir, nil, logging.NoLog{}, reg)
+	default:
+		return nil, fmt.Errorf("unknown database type %q", dbType)
+	}
+}
+
+// openChainDatabase opens the chain database of the chain given by the flags,
+// returning it along with the node database to close.
+func openChainDatabase(c *cli.Context) (ethdb.Database, database.Database) {
+	chainID, err := ids.FromString(c.String(chainIDFlag.Name))
+	if err != nil {
+		utils.Fatalf("Invalid chain ID: %v", err)
+	}
+	db, err := openDatabase(c)
+	if err != nil {
+		utils.Fatalf("Failed to open database: %v", err)
+	}
+	vmDB := prefixdb.New(vmDBPrefix, prefixdb.New(chainID[:], db))
+	return rawdb.NewDatabase(evm.Database{Database: prefixdb.NewNested(ethDBPrefix, vmDB)}), db
+}
+
+func exportPreimages(c *cli.Context) error {
+	if c.NArg() != 1 {
+		utils.Fatalf("This command requires the path of the preimage file")
+	}
+	chaindb, db := openChainDatabase(c)
+	defer db.Close()
+
+	out := c.Args().First()
+	f, err := os.OpenFile(out, os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0o600)
+	if err != nil {
+		utils.Fatalf("Failed to create preimage file: %v", err)
+	}
+	var (
+		writer io.Writer = f
+		gz     *gzip.Writer
+	)
+	if strings.HasSuffix(out, ".gz") {
+		gz = gzip.NewWriter(f)
+		writer = gz
+	}
+	count, err := core.ExportPreimages(chaindb, writer)
+	if gz != nil {
+		if closeErr := gz.Close(); err == nil {
+			err = closeErr
+		}
+	}
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		os.Remove(out)
+		utils.Fatalf("Failed to export preimages: %v", err)
+	}
+	log.Info("Exported preimages", "file", out, "count", count)
+	return nil
+}
+
+func importPreimages(c *cli.Context) error {
+	if c.NArg() != 1 {
+		utils.Fatalf("This command requires the path of the preimage file")
+	}
+	chaindb, db := openChainDatabase(c)
+	defer db.Close()
+
+	in := c.Args().First()
+	f, err := os.Open(in)
+	if err != nil {
+		utils.Fatalf("Failed to open preimage file: %v", err)
+	}
+	defer f.Close()
+
+	var reader io.Reader = f
+	if strings.HasSuffix(in, ".gz") {
+		if reader, err = gzip.NewReader(f); err != nil {
+			utils.Fatalf("Failed to open preimage file: %v", err)
+		}
+	}
+	count, err := core.ImportPreimages(chaindb, reader)
+	if err != nil {
+		utils.Fatalf("Failed to import preimages: %v", err)
+	}
+	log.Info("Imported preimages", "file", in, "count", count)
+	return nil
+}
+
+func main() {
+	log.SetDefault(log.NewLogger(log.NewTerminalHandlerWithLevel(os.Stderr, log.LevelInfo, true)))
+
+	if err := app.Run(os.Args); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+}
@@ -0,0 +1,389 @@
+// (c) 2024, Ava Labs, Inc. All rights reserved.
+// See the file LICENSE for licensing terms.
+
+// reexec re-executes accepted blocks from the database of a stopped node and
+// checks the state roots, receipt hashes and gas used against the headers,
+// reporting the first divergent transaction of a divergent block along with
+// its state diff. The node database is never written to.
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/ava-labs/avalanchego/database"
+	"github.com/ava-labs/avalanchego/database/leveldb"
+	"github.com/ava-labs/avalanchego/database/pebbledb"
+	"github.com/ava-labs/avalanchego/database/prefixdb"
+	"github.com/ava-labs/avalanchego/database/versiondb"
+	"github.com/ava-labs/avalanchego/ids"
+	"github.com/ava-labs/avalanchego/snow"
+	"github.com/ava-labs/avalanchego/utils/constants"
+	"github.com/ava-labs/avalanchego/utils/logging"
+	"github.com/ava-labs/coreth/cmd/utils"
+	"github.com/ava-labs/coreth/consensus/dummy"
+	"github.com/ava-labs/coreth/core"
+	"github.com/ava-labs/coreth/core/rawdb"
+	"github.com/ava-labs/coreth/core/state"
+	"github.com/ava-labs/coreth/core/types"
+	"github.com/ava-labs/coreth/core/vm"
+	"github.com/ava-labs/coreth/internal/flags"
+	"github.com/ava-labs/coreth/params"
+	"github.com/ava-labs/coreth/plugin/evm"
+	"github.com/ava-labs/coreth/trie"
+	"github.com/ava-labs/coreth/trie/triedb/hashdb"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/ethdb"
+	"github.com/ethereum/go-ethereum/log"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/urfave/cli/v2"
+)
+
+var (
+	// vmDBPrefix is the prefix under which avalanchego nests the database of
+	// each VM in the database of its chain.
+	vmDBPrefix = []byte("vm")
+	// ethDBPrefix is the prefix under which the VM nests the chain database.
+	ethDBPrefix = []byte("ethdb")
+)
+
+// mainnetAVAXAssetID is the ID of the AVAX asset on mainnet.
+const mainnetAVAXAssetID = "FvwEAhmxKfeiG8SnEvq42hc6whRyY3EFYAvebMqDNDGCgxN5Z"
+
+var (
+	dbDirFlag = &cli.StringFlag{
+		Name:     "db-dir",
+		Usage:    "Path to the versioned database directory of the node, e.g. ~/.avalanchego/db/mainnet/v1.4.5",
+		Required: true,
+	}
+	dbTypeFlag = &cli.StringFlag{
+		Name:  "db-type",
+		Usage: fmt.Sprintf("Type of the node database (%s or %s)", leveldb.Name, pebbledb.Name),
+		Value: leveldb.Name,
+	}
+	chainIDFlag = &cli.StringFlag{
+		Name:     "chain-id",
+		Usage:    "ID of the blockchain",
+		Required: true,
+	}
+	ancientDirFlag = &cli.StringFlag{
+		Name:  "ancient-dir",
+		Usage: "Path to the ancient store of the chain if the node enabled ancient-store-enabled, i.e. the \"ancient\" directory of the chain data directory",
+	}
+	networkIDFlag = &cli.UintFlag{
+		Name:  "network-id",
+		Usage: "ID of the network of the blockchain",
+		Value: uint(constants.MainnetID),
+	}
+	avaxAssetIDFlag = &cli.StringFlag{
+		Name:  "avax-asset-id",
+		Usage: "ID of the AVAX asset on the network of the blockchain",
+		Value: mainnetAVAXAssetID,
+	}
+	fromFlag = &cli.Uint64Flag{
+		Name:  "from",
+		Usage: "First block to re-execute",
+		Value: 1,
+	}
+	toFlag = &cli.Uint64Flag{
+		Name:  "to",
+		Usage: "Last block to re-execute (default: the last accepted block)",
+	}
+	freshFlag = &cli.BoolFlag{
+		Name: "fresh",
+		Usage: "Re-execute every block on a fresh state opened from the committed root of its parent, " +
+			"skipping the blocks whose parent state is not committed, instead of carrying the " +
+			"re-executed state over from the committed state preceding the first block",
+	}
+	maxDiffFlag = &cli.IntFlag{
+		Name:  "max-diff",
+		Usage: "Maximum number of accounts and storage slots reported in a state diff",
+		Value: 64,
+	}
+)
+
+var app = flags.NewApp("C-Chain block re-execution tool")
+
+func init() {
+	app.Name = "reexec"
+	app.Flags = []cli.Flag{
+		dbDirFlag, dbTypeFlag, chainIDFlag, ancientDirFlag, networkIDFlag, avaxAssetIDFlag,
+		fromFlag, toFlag, freshFlag, maxDiffFlag,
+	}
+	app.Action = reexec
+}
+
+func openDatabase(c *cli.Context) (database.Database, error) {
+	var (
+		dir = c.String(dbDirFlag.Name)
+		reg = prometheus.NewRegistry()
+	)
+	switch dbType := c.String(dbTypeFlag.Name); dbType {
+	case leveldb.Name:
+		return leveldb.New(dir, nil, logging.NoLog{}, reg)
+	case pebbledb.Name:
+		return pebbledb.New(dir, nil, logging.NoLog{}, reg)
+	default:
+		return nil, fmt.Errorf("unknown database type %q", dbType)
+	}
+}
+
+// reexecutor re-executes the accepted blocks of a chain database.
+type reexecutor struct {
+	chaindb   ethdb.Database
+	config    *params.ChainConfig
+	chain     *core.HeaderChain
+	processor *core.StateProcessor
+	triedb    *trie.Database
+	statedb   state.Database
+	maxDiff   int
+}
+
+// execution is the outcome of re-executing a block.
+type execution struct {
+	root        common.Hash
+	receiptHash common.Hash
+	gasUsed     uint64
+	receipts    types.Receipts
+}
+
+// diverged returns whether [exec] does not match the header of [block].
+func (exec *execution) diverged(block *types.Block) bool {
+	return exec.root != block.Root() || exec.receiptHash != block.ReceiptHash() || exec.gasUsed != block.GasUsed()
+}
+
+func reexec(c *cli.Context) error {
+	chainID, err := ids.FromString(c.String(chainIDFlag.Name))
+	if err != nil {
+		utils.Fatalf("Invalid chain ID: %v", err)
+	}
+	avaxAssetID, err := ids.FromString(c.String(avaxAssetIDFlag.Name))
+	if err != nil {
+		utils.Fatalf("Invalid AVAX asset ID: %v", err)
+	}
+	db, err := openDatabase(c)
+	if err != nil {
+		utils.Fatalf("Failed to open database: %v", err)
+	}
+	defer db.Close()
+
+	// Writes are kept in memory and discarded, so the node database is left
+	// untouched by the re-execution.
+	vmDB := prefixdb.New(vmDBPrefix, prefixdb.New(chainID[:], db))
+	kvdb := evm.Database{Database: prefixdb.NewNested(ethDBPrefix, versiondb.New(vmDB))}
+	chaindb := rawdb.NewDatabase(kvdb)
+	if ancient := c.String(ancientDirFlag.Name); ancient != "" {
+		if chaindb, err = rawdb.NewDatabaseWithFreezer(kvdb, ancient, "", true, 0); err != nil {
+			utils.Fatalf("Failed to open ancient store: %v", err)
+		}
+		defer chaindb.Close()
+	}
+	if scheme := rawdb.ReadStateScheme(chaindb); scheme == rawdb.PathScheme {
+		utils.Fatalf("Re-executing blocks is not supported with the %s state scheme", scheme)
+	}
+	genesisHash := rawdb.ReadCanonicalHash(chaindb, 0)
+	config := rawdb.ReadChainConfig(chaindb, genesisHash)
+	if config == nil {
+		utils.Fatalf("No chain config found for genesis %s", genesisHash)
+	}
+	config.SnowCtx = &snow.Context{
+		NetworkID:   uint32(c.Uint(networkIDFlag.Name)),
+		ChainID:     chainID,
+		AVAXAssetID: avaxAssetID,
+	}
+	engine := dummy.NewFakerWithCallbacks(evm.AcceptedBlockCallbacks(config.SnowCtx, config))
+	chain, err := core.NewHeaderChain(chaindb, config, core.DefaultCacheConfig, engine)
+	if err != nil {
+		utils.Fatalf("Failed to open chain: %v", err)
+	}
+	triedb := trie.NewDatabase(chaindb, &trie.Config{HashDB: hashdb.Defaults})
+	defer triedb.Close()
+	r := &reexecutor{
+		chaindb:   chaindb,
+		config:    config,
+		chain:     chain,
+		processor: core.NewStateProcessor(config, chain, engine),
+		triedb:    triedb,
+		statedb:   state.NewDatabaseWithNodeDB(chaindb, triedb),
+		maxDiff:   c.Int(maxDiffFlag.Name),
+	}
+
+	lastAccepted, err := evm.ReadLastAccepted(vmDB)
+	if err != nil {
+		utils.Fatalf("Failed to read last accepted block: %v", err)
+	}
+	last := rawdb.ReadHeaderNumber(chaindb, lastAccepted)
+	if last == nil {
+		utils.Fatalf("Last accepted block %s not found", lastAccepted)
+	}
+	from, to := c.Uint64(fromFlag.Name), *last
+	if c.IsSet(toFlag.Name) {
+		to = c.Uint64(toFlag.Name)
+	}
+	if from == 0 || from > to || to > *last {
+		utils.Fatalf("Invalid block range [%d, %d], last accepted block is %d", from, to, *last)
+	}
+	if c.Bool(freshFlag.Name) {
+		return r.reexecFresh(from, to)
+	}
+	return r.reexecChained(from, to)
+}
+
+// hasCommittedState returns whether the state [root] is committed to disk.
+func (r *reexecutor) hasCommittedState(root common.Hash) bool {
+	return root == types.EmptyRootHash || rawdb.HasLegacyTrieNode(r.chaindb, root)
+}
+
+// block returns the accepted block at [number].
+func (r *reexecutor) block(number uint64) (*types.Block, error) {
+	hash := rawdb.ReadCanonicalHash(r.chaindb, number)
+	if hash == (common.Hash{}) {
+		return nil, fmt.Errorf("block %d not found", number)
+	}
+	block := rawdb.ReadBlock(r.chaindb, hash, number)
+	if block == nil {
+		return nil, fmt.Errorf("body of block %d not found, it may have been expired or moved to the ancient store", number)
+	}
+	return block, nil
+}
+
+// execute re-executes [block] on top of [statedb], the state of [parent].
+func (r *reexecutor) execute(block *types.Block, parent *types.Header, statedb *state.StateDB) (*execution, error) {
+	receipts, _, gasUsed, err := r.processor.Process(block, parent, statedb, vm.Config{})
+	if err != nil {
+		return nil, err
+	}
+	return &execution{
+		root:        statedb.IntermediateRoot(r.config.IsEIP158(block.Number())),
+		receiptHash: types.DeriveSha(receipts, trie.NewStackTrie(nil)),
+		gasUsed:     gasUsed,
+		receipts:    receipts,
+	}, nil
+}
+
+// reexecChained re-executes the blocks [from, to] in sequence, starting from
+// the committed state the closest before [from]. It stops at the first
+// divergent block, since the following ones are executed on its state.
+func (r *reexecutor) reexecChained(from, to uint64) error {
+	start := from - 1
+	parent := r.chain.GetHeaderByNumber(start)
+	for parent != nil && !r.hasCommittedState(parent.Root) && start > 0 {
+		start--
+		parent = r.chain.GetHeaderByNumber(start)
+	}
+	if parent == nil || !r.hasCommittedState(parent.Root) {
+		return errors.New("no committed state found before the first block")
+	}
+	statedb, err := state.New(parent.Root, r.statedb, nil)
+	if err != nil {
+		return fmt.Errorf("failed to open state of block %d: %w", start, err)
+	}
+	log.Info("Re-executing blocks", "from", from, "to", to, "committed", start)
+
+	var (
+		begin    = time.Now()
+		logged   = time.Now()
+		prevRoot common.Hash
+	)
+	for number := start + 1; number <= to; number++ {
+		block, err := r.block(number)
+		if err != nil {
+			return err
+		}
+		exec, err := r.execute(block, parent, statedb)
+		if err != nil {
+			r.reportFailure(block, parent, err)
+			return fmt.Errorf("block %d failed", number)
+		}
+		if exec.diverged(block) {
+			r.reportDivergence(block, parent, statedb, exec)
+			return fmt.Errorf("block %d diverged", number)
+		}
+		// Keep the state of the last block only in memory.
+		root, err := statedb.Commit(number, r.config.IsEIP158(block.Number()), true)
+		if err != nil {
+			return fmt.Errorf("failed to commit state of block %d: %w", number, err)
+		}
+		if root != prevRoot {
+			if prevRoot != (common.Hash{}) {
+				r.triedb.Dereference(prevRoot)
+			}
+			prevRoot = root
+		}
+		if statedb, err = state.New(root, r.statedb, nil); err != nil {
+			return fmt.Errorf("failed to open state of block %d: %w", number, err)
+		}
+		parent = block.Header()
+
+		if time.Since(logged) > 8*time.Second {
+			log.Info("Re-executing blocks", "number", number, "to", to, "elapsed", common.PrettyDuration(time.Since(begin)))
+			logged = time.Now()
+		}
+	}
+	log.Info("Re-executed blocks, no divergence found", "from", start+1, "to", to, "elapsed", common.PrettyDuration(time.Since(begin)))
+	return nil
+}
+
+// reexecFresh re-executes every block of [from, to] whose parent state is
+// committed on top of that state, reporting all the divergent blocks.
+func (r *reexecutor) reexecFresh(from, to uint64) error {
+	var (
+		begin     = time.Now()
+		logged    = time.Now()
+		checked   int
+		skipped   int
+		divergent int
+	)
+	for number := from; number <= to; number++ {
+		block, err := r.block(number)
+		if err != nil {
+			return err
+		}
+		parent := r.chain.GetHeader(block.ParentHash(), number-1)
+		if parent == nil {
+			return fmt.Errorf("block %d not found", number-1)
+		}
+		if !r.hasCommittedState(parent.Root) {
+			skipped++
+			continue
+		}
+		statedb, err := state.New(parent.Root, r.statedb, nil)
+		if err != nil {
+			return fmt.Errorf("failed to open state of block %d: %w", number-1, err)
+		}
+		checked++
+		exec, err := r.execute(block, parent, statedb)
+		switch {
+		case err != nil:
+			r.reportFailure(block, parent, err)
+			divergent++
+		case exec.diverged(block):
+			r.reportDivergence(block, parent, statedb, exec)
+			divergent++
+		}
+
+		if time.Since(logged) > 8*time.Second {
+			log.Info("Re-executing blocks", "number", number, "to", to, "checked", checked, "skipped", skipped, "elapsed", common.PrettyDuration(time.Since(begin)))
+			logged = time.Now()
+		}
+	}
+	log.Info("Re-executed blocks", "from", from, "to", to, "checked", checked, "skipped", skipped, "divergent", divergent, "elapsed", common.PrettyDuration(time.Since(begin)))
+	if checked == 0 {
+		return errors.New("no block with a committed parent state in range")
+	}
+	if divergent != 0 {
+		return fmt.Errorf("%d of %d blocks diverged", divergent, checked)
+	}
+	return nil
+}
+
+func main() {
+	log.SetDefault(log.NewLogger(log.NewTerminalHandlerWithLevel(os.Stderr, log.LevelInfo, true)))
+
+	if err := app.Run(os.Args); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+}
@@ -0,0 +1,338 @@
+// (c) 2024, Ava Labs, Inc. All rights reserved.
+// See the file LICENSE for licensing terms.
+
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"sort"
+
+	"github.com/ava-labs/coreth/core"
+	"github.com/ava-labs/coreth/core/rawdb"
+	"github.com/ava-labs/coreth/core/state"
+	"github.com/ava-labs/coreth/core/types"
+	"github.com/ava-labs/coreth/core/vm"
+	"github.com/ava-labs/coreth/eth/tracers"
+	"github.com/ava-labs/coreth/trie"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/rlp"
+
+	// Register the native tracers, including the state diff tracer.
+	_ "github.com/ava-labs/coreth/eth/tracers/native"
+)
+
+// reportFailure reports a block which could not be re-executed.
+func (r *reexecutor) reportFailure(block *types.Block, parent *types.Header, err error) {
+	fmt.Printf("Block %d (%s) failed: %v\n", block.NumberU64(), block.Hash(), err)
+}
+
+// reportDivergence reports a block whose re-execution [exec] on top of
+// [statedb] does not match its header: the mismatching fields, the first
+// transaction whose receipt differs from the stored one with its state diff,
+// and the state diff of the block if the expected state is committed.
+func (r *reexecutor) reportDivergence(block *types.Block, parent *types.Header, statedb *state.StateDB, exec *execution) {
+	fmt.Printf("Block %d (%s) diverged\n", block.NumberU64(), block.Hash())
+	if exec.root != block.Root() {
+		fmt.Printf("  state root:   expected %s, got %s\n", block.Root(), exec.root)
+	}
+	if exec.receiptHash != block.ReceiptHash() {
+		fmt.Printf("  receipt hash: expected %s, got %s\n", block.ReceiptHash(), exec.receiptHash)
+	}
+	if exec.gasUsed != block.GasUsed() {
+		fmt.Printf("  gas used:     expected %d, got %d\n", block.GasUsed(), exec.gasUsed)
+	}
+
+	stored := rawdb.ReadReceipts(r.chaindb, block.Hash(), block.NumberU64(), block.Time(), r.config)
+	switch index := firstDivergentReceipt(stored, exec.receipts); {
+	case len(block.Transactions()) == 0:
+	case stored == nil:
+		fmt.Println("  stored receipts not found, the divergent transaction cannot be pinpointed")
+	case index < 0:
+		fmt.Println("  all transaction receipts match the stored receipts")
+	default:
+		tx := block.Transactions()[index]
+		fmt.Printf("  first divergent transaction: %d (%s)\n", index, tx.Hash())
+		printReceiptDiff(stored[index], exec.receipts[index])
+		diff, err := r.traceStateDiff(block, parent, index)
+		if err != nil {
+			fmt.Printf("  failed to trace transaction: %v\n", err)
+		} else {
+			fmt.Printf("  transaction state diff:\n%s\n", diff)
+		}
+	}
+
+	if exec.root == block.Root() {
+		return
+	}
+	if !r.hasCommittedState(block.Root()) {
+		fmt.Println("  expected state is not committed, the block state diff is not available")
+		return
+	}
+	// Commit the re-executed state to memory to compare it with the expected one.
+	root, err := statedb.Commit(block.NumberU64(), r.config.IsEIP158(block.Number()), true)
+	if err != nil {
+		fmt.Printf("  failed to commit re-executed state: %v\n", err)
+		return
+	}
+	defer r.triedb.Dereference(root)
+	fmt.Println("  block state diff (expected -> got):")
+	if err := r.printStateDiff(block.Root(), root); err != nil {
+		fmt.Printf("  failed to diff states: %v\n", err)
+	}
+}
+
+// firstDivergentReceipt returns the index of the first receipt of [got] whose
+// consensus fields differ from [expected], -1 if they all match.
+func firstDivergentReceipt(expected, got types.Receipts) int {
+	for i := range got {
+		if i >= len(expected) {
+			return i
+		}
+		want, _ := expected[i].MarshalBinary()
+		have, _ := got[i].MarshalBinary()
+		if !bytes.Equal(want, have) {
+			return i
+		}
+	}
+	return -1
+}
+
+func printReceiptDiff(expected, got *types.Receipt) {
+	if expected.Status != got.Status {
+		fmt.Printf("    status:              expected %d, got %d\n", expected.Status, got.Status)
+	}
+	if expected.CumulativeGasUsed != got.CumulativeGasUsed {
+		fmt.Printf("    cumulative gas used: expected %d, got %d\n", expected.CumulativeGasUsed, got.CumulativeGasUsed)
+	}
+	if len(expected.Logs) != len(got.Logs) {
+		fmt.Printf("    logs:                expected %d, got %d\n", len(expected.Logs), len(got.Logs))
+	} else if expected.Bloom != got.Bloom {
+		fmt.Println("    logs:                contents differ")
+	}
+}
+
+// traceStateDiff re-executes the transactions of [block] up to [index] on top
+// of the state of [parent] and returns the state diff of the last one.
+func (r *reexecutor) traceStateDiff(block *types.Block, parent *types.Header, index int) (string, error) {
+	statedb, err := state.New(parent.Root, r.statedb, nil)
+	if err != nil {
+		return "", err
+	}
+	if err := core.ApplyUpgrades(r.config, &parent.Time, block, statedb); err != nil {
+		return "", err
+	}
+	var (
+		header   = block.Header()
+		signer   = types.MakeSigner(r.config, header.Number, header.Time)
+		blockCtx = core.NewEVMBlockContext(header, r.chain, nil)
+	)
+	for i, tx := range block.Transactions()[:index+1] {
+		msg, err := core.TransactionToMessage(tx, signer, header.BaseFee)
+		if err != nil {
+			return "", err
+		}
+		var (
+			config vm.Config
+			tracer tracers.Tracer
+		)
+		if i == index {
+			tracer, err = tracers.DefaultDirectory.New("stateDiffTracer", &tracers.Context{
+				BlockHash:   block.Hash(),
+				BlockNumber: block.Number(),
+				TxIndex:     i,
+				TxHash:      tx.Hash(),
+			}, nil)
+			if err != nil {
+				return "", err
+			}
+			config.Tracer = tracer
+		}
+		evm := vm.NewEVM(blockCtx, core.NewEVMTxContext(msg), statedb, r.config, config)
+		statedb.SetTxContext(tx.Hash(), i)
+		if _, err := core.ApplyMessage(evm, msg, new(core.GasPool).AddGas(tx.Gas())); err != nil {
+			return "", fmt.Errorf("transaction %d failed: %w", i, err)
+		}
+		statedb.Finalise(evm.ChainConfig().IsEIP158(block.Number()))
+		if tracer != nil {
+			result, err := tracer.GetResult()
+			if err != nil {
+				return "", err
+			}
+			var out bytes.Buffer
+			if err := json.Indent(&out, result, "    ", "  "); err != nil {
+				return "", err
+			}
+			return "    " + out.String(), nil
+		}
+	}
+	return "", nil
+}
+
+// leafDiff is a key whose value differs between two tries, nil if absent.
+type leafDiff struct {
+	key           common.Hash
+	expected, got []byte
+}
+
+// diffTries returns the keys whose values differ between the tries
+// [expected] and [got], sorted by key.
+func (r *reexecutor) diffTries(expected, got *trie.ID) ([]leafDiff, error) {
+	expectedTrie, err := trie.NewStateTrie(expected, r.triedb)
+	if err != nil {
+		return nil, err
+	}
+	gotTrie, err := trie.NewStateTrie(got, r.triedb)
+	if err != nil {
+		return nil, err
+	}
+	// leaves returns the leaves of [b] in nodes missing from [a].
+	leaves := func(a, b *trie.StateTrie) (map[common.Hash][]byte, error) {
+		aIt, err := a.NodeIterator(nil)
+		if err != nil {
+			return nil, err
+		}
+		bIt, err := b.NodeIterator(nil)
+		if err != nil {
+			return nil, err
+		}
+		diffIt, _ := trie.NewDifferenceIterator(aIt, bIt)
+		it := trie.NewIterator(diffIt)
+		values := make(map[common.Hash][]byte)
+		for it.Next() {
+			values[common.BytesToHash(it.Key)] = common.CopyBytes(it.Value)
+		}
+		return values, it.Err
+	}
+	expectedLeaves, err := leaves(gotTrie, expectedTrie)
+	if err != nil {
+		return nil, err
+	}
+	gotLeaves, err := leaves(expectedTrie, gotTrie)
+	if err != nil {
+		return nil, err
+	}
+	// A leaf moved to another node without changing is in both sets.
+	var diffs []leafDiff
+	for key, value := range expectedLeaves {
+		if other, ok := gotLeaves[key]; !ok || !bytes.Equal(value, other) {
+			diffs = append(diffs, leafDiff{key: key, expected: value, got: other})
+		}
+	}
+	for key, value := range gotLeaves {
+		if _, ok := expectedLeaves[key]; !ok {
+			diffs = append(diffs, leafDiff{key: key, got: value})
+		}
+	}
+	sort.Slice(diffs, func(i, j int) bool { return bytes.Compare(diffs[i].key[:], diffs[j].key[:]) < 0 })
+	return diffs, nil
+}
+
+// printStateDiff prints the accounts and storage slots differing between the
+// committed states [expected] and [got], up to maxDiff entries.
+func (r *reexecutor) printStateDiff(expected, got common.Hash) error {
+	accounts, err := r.diffTries(trie.StateTrieID(expected), trie.StateTrieID(got))
+	if err != nil {
+		return err
+	}
+	printed := 0
+	for _, diff := range accounts {
+		if printed >= r.maxDiff {
+			fmt.Printf("    ... truncated after %d entries\n", printed)
+			return nil
+		}
+		expectedAccount, err := decodeAccount(diff.expected)
+		if err != nil {
+			return err
+		}
+		gotAccount, err := decodeAccount(diff.got)
+		if err != nil {
+			return err
+		}
+		fmt.Printf("    account %s\n", r.describeKey(diff.key, common.AddressLength))
+		printed++
+		printField("nonce", expectedAccount, gotAccount, func(a *types.StateAccount) interface{} { return a.Nonce })
+		printField("balance", expectedAccount, gotAccount, func(a *types.StateAccount) interface{} { return a.Balance })
+		printField("code hash", expectedAccount, gotAccount, func(a *types.StateAccount) interface{} { return common.BytesToHash(a.CodeHash) })
+		printField("multicoin", expectedAccount, gotAccount, func(a *types.StateAccount) interface{} { return a.IsMultiCoin })
+		printField("storage root", expectedAccount, gotAccount, func(a *types.StateAccount) interface{} { return a.Root })
+
+		expectedRoot, gotRoot := types.EmptyRootHash, types.EmptyRootHash
+		if expectedAccount != nil {
+			expectedRoot = expectedAccount.Root
+		}
+		if gotAccount != nil {
+			gotRoot = gotAccount.Root
+		}
+		if expectedRoot == gotRoot {
+			continue
+		}
+		slots, err := r.diffTries(trie.StorageTrieID(expected, diff.key, expectedRoot), trie.StorageTrieID(got, diff.key, gotRoot))
+		if err != nil {
+			return err
+		}
+		for _, slot := range slots {
+			if printed >= r.maxDiff {
+				fmt.Printf("    ... truncated after %d entries\n", printed)
+				return nil
+			}
+			expectedValue, err := decodeSlot(slot.expected)
+			if err != nil {
+				return err
+			}
+			gotValue, err := decodeSlot(slot.got)
+			if err != nil {
+				return err
+			}
+			fmt.Printf("      slot %s: %s -> %s\n", r.describeKey(slot.key, common.HashLength), expectedValue, gotValue)
+			printed++
+		}
+	}
+	return nil
+}
+
+// describeKey returns the preimage of the hashed trie key [key] if it is
+// stored and has the expected length, and the hash otherwise.
+func (r *reexecutor) describeKey(key common.Hash, length int) string {
+	if preimage := rawdb.ReadPreimage(r.chaindb, key); len(preimage) == length {
+		return fmt.Sprintf("%#x", preimage)
+	}
+	return fmt.Sprintf("%s (hash)", key)
+}
+
+func decodeAccount(blob []byte) (*types.StateAccount, error) {
+	if blob == nil {
+		return nil, nil
+	}
+	account := new(types.StateAccount)
+	if err := rlp.DecodeBytes(blob, account); err != nil {
+		return nil, err
+	}
+	return account, nil
+}
+
+func decodeSlot(blob []byte) (string, error) {
+	if blob == nil {
+		return "absent", nil
+	}
+	_, content, _, err := rlp.Split(blob)
+	if err != nil {
+		return "", err
+	}
+	return common.BytesToHash(content).Hex(), nil
+}
+
+// printField prints the field of an account extracted by [get] if it differs
+// between [expected] and [got], either of which may be absent.
+func printField(name string, expected, got *types.StateAccount, get func(*types.StateAccount) interface{}) {
+	format := func(a *types.StateAccount) string {
+		if a == nil {
+			return "absent"
+		}
+		return fmt.Sprint(get(a))
+	}
+	if want, have := format(expected), format(got); want != have {
+		fmt.Printf("      %-13s %s -> %s\n", name+":", want, have)
+	}
+}
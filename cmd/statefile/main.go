@@ -0,0 +1,177 @@
+// (c) 2024, Ava Labs, Inc. All rights reserved.
+// See the file LICENSE for licensing terms.
+
+// statefile exports the state of a stopped node into a state file, which can
+// be imported by another node with the state-import-file option.
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/ava-labs/avalanchego/database"
+	"github.com/ava-labs/avalanchego/database/leveldb"
+	"github.com/ava-labs/avalanchego/database/pebbledb"
+	"github.com/ava-labs/avalanchego/database/prefixdb"
+	"github.com/ava-labs/avalanchego/ids"
+	"github.com/ava-labs/avalanchego/utils/logging"
+	"github.com/ava-labs/coreth/cmd/utils"
+	"github.com/ava-labs/coreth/internal/flags"
+	"github.com/ava-labs/coreth/plugin/evm"
+	"github.com/ava-labs/coreth/sync/statefile"
+	"github.com/ethereum/go-ethereum/log"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/urfave/cli/v2"
+)
+
+// vmDBPrefix is the prefix under which avalanchego nests the database of
+// each VM in the database of its chain.
+var vmDBPrefix = []byte("vm")
+
+var (
+	dbDirFlag = &cli.StringFlag{
+		Name:     "db-dir",
+		Usage:    "Path to the versioned database directory of the node, e.g. ~/.avalanchego/db/mainnet/v1.4.5",
+		Required: true,
+	}
+	dbTypeFlag = &cli.StringFlag{
+		Name:  "db-type",
+		Usage: fmt.Sprintf("Type of the node database (%s or %s)", leveldb.Name, pebbledb.Name),
+		Value: leveldb.Name,
+	}
+	chainIDFlag = &cli.StringFlag{
+		Name:     "chain-id",
+		Usage:    "ID of the blockchain to export",
+		Required: true,
+	}
+	commitIntervalFlag = &cli.Uint64Flag{
+		Name:  "commit-interval",
+		Usage: "Commit interval used by the node",
+		Value: 4096,
+	}
+	outFlag = &cli.StringFlag{
+		Name:     "out",
+		Usage:    "Path of the state file to write",
+		Required: true,
+	}
+)
+
+var app = flags.NewApp("C-Chain state file tool")
+
+func init() {
+	app.Name = "statefile"
+	app.Commands = []*cli.Command{
+		{
+			Name:   "export",
+			Usage:  "Export the state at the last committed height of a stopped node",
+			Flags:  []cli.Flag{dbDirFlag, dbTypeFlag, chainIDFlag, commitIntervalFlag, outFlag},
+			Action: export,
+		},
+		{
+			Name:      "inspect",
+			Usage:     "Verify the checksums of a state file and print its contents",
+			ArgsUsage: "<file>",
+			Action:    inspect,
+		},
+	}
+}
+
+func openDatabase(c *cli.Context) (database.Database, error) {
+	var (
+		dir = c.String(dbDirFlag.Name)
+		reg = prometheus.NewRegistry()
+	)
+	switch dbType := c.String(dbTypeFlag.Name); dbType {
+	case leveldb.Name:
+		return leveldb.New(dir, nil, logging.NoLog{}, reg)
+	case pebbledb.Name:
+		return pebbledb.New(dir, nil, logging.NoLog{}, reg)
+	default:
+		return nil, fmt.Errorf("unknown database type %q", dbType)
+	}
+}
+
+func export(c *cli.Context) error {
+	chainID, err := ids.FromString(c.String(chainIDFlag.Name))
+	if err != nil {
+		utils.Fatalf("Invalid chain ID: %v", err)
+	}
+	db, err := openDatabase(c)
+	if err != nil {
+		utils.Fatalf("Failed to open database: %v", err)
+	}
+	defer db.Close()
+	vmDB := prefixdb.New(vmDBPrefix, prefixdb.New(chainID[:], db))
+
+	out := c.String(outFlag.Name)
+	f, err := os.OpenFile(out, os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0o600)
+	if err != nil {
+		utils.Fatalf("Failed to create state file: %v", err)
+	}
+	header, err := evm.ExportState(vmDB, c.Uint64(commitIntervalFlag.Name), f)
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		os.Remove(out)
+		utils.Fatalf("Failed to export state: %v", err)
+	}
+	log.Info("Exported state", "file", out, "height", header.BlockNumber, "hash", header.BlockHash, "root", header.Root, "atomicRoot", header.AtomicRoot)
+	return nil
+}
+
+func inspect(c *cli.Context) error {
+	if c.NArg() != 1 {
+		utils.Fatalf("This command requires the path of a state file")
+	}
+	f, err := os.Open(c.Args().First())
+	if err != nil {
+		utils.Fatalf("Failed to open state file: %v", err)
+	}
+	defer f.Close()
+
+	r, err := statefile.NewReader(f)
+	if err != nil {
+		utils.Fatalf("Failed to read state file: %v", err)
+	}
+	header := r.Header()
+	fmt.Printf("Version:     %d\n", statefile.Version)
+	fmt.Printf("Block:       %d (%s)\n", header.BlockNumber, header.BlockHash)
+	fmt.Printf("Root:        %s\n", header.Root)
+	fmt.Printf("Atomic root: %s\n", header.AtomicRoot)
+
+	var (
+		kinds   []statefile.Kind
+		chunks  = make(map[statefile.Kind]int)
+		records = make(map[statefile.Kind]int)
+	)
+	for {
+		kind, batch, err := r.Next()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			utils.Fatalf("Failed to read state file: %v", err)
+		}
+		if chunks[kind] == 0 {
+			kinds = append(kinds, kind)
+		}
+		chunks[kind]++
+		records[kind] += len(batch)
+	}
+	for _, kind := range kinds {
+		fmt.Printf("%-12s %d records in %d chunks\n", kind.String()+":", records[kind], chunks[kind])
+	}
+	return nil
+}
+
+func main() {
+	log.SetDefault(log.NewLogger(log.NewTerminalHandlerWithLevel(os.Stderr, log.LevelInfo, true)))
+
+	if err := app.Run(os.Args); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+}
@@ -0,0 +1,42 @@
+// (c) 2024, Ava Labs, Inc. All rights reserved.
+// See the file LICENSE for licensing terms.
+
+package dummy
+
+import (
+	"math/big"
+
+	"github.com/ava-labs/coreth/core/state"
+	"github.com/ava-labs/coreth/core/types"
+	"github.com/ethereum/go-ethereum/common"
+)
+
+// ExtraStateTransfer is a credit or debit of an account balance applied by an
+// [ExtraStateTx].
+type ExtraStateTransfer struct {
+	Address common.Address
+	AssetID common.Hash // Empty for the native asset, set for multicoin assets
+	Amount  *big.Int    // Denominated in wei for the native asset
+	Debit   bool
+}
+
+// ExtraStateTx is a transaction of a block changing the EVM state outside of
+// the EVM, such as an atomic import or export transaction. The changes of all
+// such transactions are applied by [OnExtraStateChange] after the transactions
+// of the block are executed.
+type ExtraStateTx struct {
+	ID        common.Hash
+	Transfers []ExtraStateTransfer
+
+	// Apply applies the changes of the transaction to [statedb].
+	Apply func(statedb *state.StateDB) error
+}
+
+// ExtraStateTxs returns the transactions of [block] changing the EVM state
+// outside of the EVM, in the order their changes are applied.
+func (self *DummyEngine) ExtraStateTxs(block *types.Block) ([]*ExtraStateTx, error) {
+	if self.cb.OnExtraStateTxs == nil {
+		return nil, nil
+	}
+	return self.cb.OnExtraStateTxs(block)
+}
@@ -43,6 +43,7 @@ import (
 	"github.com/ava-labs/coreth/consensus/misc/eip4844"
 	"github.com/ava-labs/coreth/core/rawdb"
 	"github.com/ava-labs/coreth/core/state"
+	"github.com/ava-labs/coreth/core/state/sizestats"
 	"github.com/ava-labs/coreth/core/state/snapshot"
 	"github.com/ava-labs/coreth/core/types"
 	"github.com/ava-labs/coreth/core/vm"
@@ -166,8 +167,11 @@ type CacheConfig struct {
 	AcceptedCacheSize               int     // Depth of accepted headers cache and accepted logs cache at the accepted tip
 	TxLookupLimit                   uint64  // Number of recent blocks for which to maintain transaction lookup indices
 	SkipTxIndexing                  bool    // Whether to skip transaction indexing
+	HistoryExpiry                   uint64  // Number of recent blocks for which to keep block bodies and receipts, 0 keeps all
 	StateHistory                    uint64  // Number of blocks from head whose state histories are reserved.
 	StateScheme                     string  // Scheme used to store ethereum states and merkle tree nodes on top
+	StateSizeStats                  bool    // Whether to maintain the storage size of every account
+	StateSizeStatsRetention         uint64  // Number of recent blocks for which to keep the storage changes of the accounts, 0 keeps all
 
 	SnapshotNoBuild bool // Whether the background generation is allowed
 	SnapshotWait    bool // Wait for snapshot construction on startup. TODO(karalabe): This is a dirty hack for testing, nuke it
@@ -271,6 +275,10 @@ type BlockChain struct {
 	processor Processor // Block transaction processor interface
 	vmConfig  vm.Config
 
+	// replayProcessor re-executes accepted blocks outside of block
+	// verification, see SetReplayEngine.
+	replayProcessor Processor
+
 	lastAccepted *types.Block // Prevents reorgs past this height
 
 	senderCacher *TxSenderCacher
@@ -317,6 +325,12 @@ type BlockChain struct {
 
 	// [txIndexTailLock] is used to synchronize the updating of the tx index tail.
 	txIndexTailLock sync.Mutex
+
+	// [historyTailLock] is used to synchronize the updating of the history tail.
+	historyTailLock sync.Mutex
+
+	// [sizeStats] maintains the storage size of every account if enabled.
+	sizeStats *sizestats.Tracker
 }
 
 // NewBlockChain returns a fully initialised block chain using information
@@ -370,6 +384,7 @@ func NewBlockChain(
 	bc.stateCache = state.NewDatabaseWithNodeDB(bc.db, bc.triedb)
 	bc.validator = NewBlockValidator(chainConfig, bc, engine)
 	bc.processor = NewStateProcessor(chainConfig, bc, engine)
+	bc.replayProcessor = bc.processor
 
 	bc.hc, err = NewHeaderChain(db, chainConfig, cacheConfig, engine)
 	if err != nil {
@@ -429,6 +444,18 @@ func NewBlockChain(
 		latestStateSynced := rawdb.GetLatestSyncPerformed(bc.db)
 		bc.setTxIndexTail(latestStateSynced)
 	}
+	// if history expiry is disabled, we don't need to repair the history tail.
+	if bc.cacheConfig.HistoryExpiry != 0 {
+		latestStateSynced := rawdb.GetLatestSyncPerformed(bc.db)
+		bc.setHistoryTail(latestStateSynced)
+	}
+
+	if bc.cacheConfig.StateSizeStats {
+		bc.sizeStats, err = sizestats.New(bc.db, sizestats.Config{Retention: bc.cacheConfig.StateSizeStatsRetention}, bc.lastAccepted)
+		if err != nil {
+			return nil, err
+		}
+	}
 
 	// Start processing accepted blocks effects in the background
 	go bc.startAcceptor()
@@ -451,6 +478,25 @@ func NewBlockChain(
 			bc.maintainTxIndex(headCh)
 		}()
 	}
+
+	// Start history pruner if required.
+	if bc.cacheConfig.HistoryExpiry != 0 {
+		bc.wg.Add(1)
+		var (
+			headCh = make(chan ChainEvent, 1) // Buffered to avoid locking up the event feed
+			sub    = bc.SubscribeChainAcceptedEvent(headCh)
+		)
+		go func() {
+			defer bc.wg.Done()
+			if sub == nil {
+				log.Warn("could not create chain accepted subscription to prune history")
+				return
+			}
+			defer sub.Unsubscribe()
+
+			bc.maintainHistory(headCh)
+		}()
+	}
 	return bc, nil
 }
 
@@ -534,6 +580,82 @@ func (bc *BlockChain) maintainTxIndex(headCh <-chan ChainEvent) {
 	}
 }
 
+// pruneHistory deletes the bodies and receipts of the blocks more than
+// [HistoryExpiry] blocks behind [head].
+func (bc *BlockChain) pruneHistory(tail uint64, head uint64, done chan struct{}) {
+	bc.historyTailLock.Lock()
+	defer func() {
+		bc.historyTailLock.Unlock()
+		close(done)
+		bc.wg.Done()
+	}()
+
+	historyExpiry := bc.cacheConfig.HistoryExpiry
+	if head-historyExpiry+1 >= tail {
+		// Prune a part of stale history and forward history tail to HEAD-limit
+		rawdb.PruneHistory(bc.db, tail, head-historyExpiry+1, bc.quit)
+	}
+}
+
+// maintainHistory is responsible for the deletion of the block bodies and
+// receipts which fall out of the [HistoryExpiry] window. Block headers and
+// canonical hashes are always kept.
+// Invariant: If HistoryExpiry is 0, it means all history will be preserved.
+// Meaning that this function should never be called.
+func (bc *BlockChain) maintainHistory(headCh <-chan ChainEvent) {
+	historyExpiry := bc.cacheConfig.HistoryExpiry
+
+	// If the user just enabled history expiry, write the new tail and remove
+	// anything older.
+	if rawdb.ReadHistoryTail(bc.db) == nil {
+		rawdb.WriteHistoryTail(bc.db, 0)
+	}
+
+	var (
+		done   chan struct{} // Non-nil if background pruning routine is active.
+		latest uint64        // Number of the latest accepted block seen.
+		pruned uint64        // Number of the accepted block the last pruning run was launched for.
+	)
+	log.Info("Initialized history pruner", "limit", historyExpiry)
+
+	prune := func() {
+		if latest < historyExpiry || latest <= pruned {
+			return
+		}
+		done = make(chan struct{})
+		// Note: tail will not be nil since it is initialized in this function.
+		tail := rawdb.ReadHistoryTail(bc.db)
+		pruned = latest
+		bc.wg.Add(1)
+		go bc.pruneHistory(*tail, latest, done)
+	}
+
+	// Launch the initial processing if chain is not empty. This resumes any
+	// pruning interrupted by a shutdown from the persisted history tail.
+	latest = bc.LastAcceptedBlock().NumberU64()
+	prune()
+
+	for {
+		select {
+		case head := <-headCh:
+			latest = head.Block.NumberU64()
+			if done == nil {
+				prune()
+			}
+		case <-done:
+			done = nil
+			// Catch up with the blocks accepted while pruning was running.
+			prune()
+		case <-bc.quit:
+			if done != nil {
+				log.Info("Waiting background history pruner to exit")
+				<-done
+			}
+			return
+		}
+	}
+}
+
 // writeBlockAcceptedIndices writes any indices that must be persisted for accepted block.
 // This includes the following:
 // - transaction lookup indices
@@ -633,9 +755,18 @@ func (bc *BlockChain) startAcceptor() {
 		start := time.Now()
 		acceptorQueueGauge.Dec(1)
 
-		if err := bc.flattenSnapshot(func() error {
-			return bc.stateManager.AcceptTrie(next)
-		}, next.Hash()); err != nil {
+		flatten := func() error {
+			return bc.flattenSnapshot(func() error {
+				return bc.stateManager.AcceptTrie(next)
+			}, next.Hash())
+		}
+		// The state size statistics read the storage of the destructed
+		// accounts before the snapshot is flattened.
+		if bc.sizeStats != nil {
+			if err := bc.sizeStats.Accept(next, flatten); err != nil {
+				log.Crit("unable to apply state size statistics from acceptor", "blockHash", next.Hash(), "err", err)
+			}
+		} else if err := flatten(); err != nil {
 			log.Crit("unable to flatten snapshot from acceptor", "blockHash", next.Hash(), "err", err)
 		}
 
@@ -1000,6 +1131,11 @@ func (bc *BlockChain) stopWithoutSaving() {
 	bc.stopAcceptor()
 	log.Info("Acceptor queue drained", "t", time.Since(start))
 
+	if bc.sizeStats != nil {
+		log.Info("Stopping state size statistics")
+		bc.sizeStats.Stop()
+	}
+
 	// Stop senderCacher's goroutines
 	log.Info("Shutting down sender cacher")
 	bc.senderCacher.Shutdown()
@@ -1041,6 +1177,15 @@ func (bc *BlockChain) Stop() {
 	log.Info("Blockchain stopped")
 }
 
+// SetReplayEngine sets the consensus engine used to re-execute accepted blocks
+// outside of block verification, such as when backfilling preimages or
+// regenerating historical states. Since the blocks are already accepted, the
+// engine must only apply their state changes. Defaults to the engine of the
+// chain. It must be called before the chain is used.
+func (bc *BlockChain) SetReplayEngine(engine consensus.Engine) {
+	bc.replayProcessor = NewStateProcessor(bc.chainConfig, bc, engine)
+}
+
 // SetPreference attempts to update the head block to be the provided block and
 // emits a ChainHeadEvent if successful. This function will handle all reorg
 // side effects, if necessary.
@@ -1157,6 +1302,9 @@ func (bc *BlockChain) Reject(block *types.Block) error {
 			log.Error("unable to discard snap from rejected block", "block", block.Hash(), "number", block.NumberU64(), "root", block.Root())
 		}
 	}
+	if bc.sizeStats != nil {
+		bc.sizeStats.Reject(block.Hash())
+	}
 
 	// Remove the block since its data is no longer needed
 	batch := bc.db.NewBatch()
@@ -1238,6 +1386,9 @@ func (bc *BlockChain) writeBlockWithState(block *types.Block, receipts []*types.
 	// If snapshots are enabled, call CommitWithSnaps to explicitly create a snapshot
 	// diff layer for the block.
 	var err error
+	if bc.sizeStats != nil {
+		state.TrackStorageSizes()
+	}
 	if bc.snaps == nil {
 		_, err = state.Commit(block.NumberU64(), bc.chainConfig.IsEIP158(block.Number()), true)
 	} else {
@@ -1246,6 +1397,9 @@ func (bc *BlockChain) writeBlockWithState(block *types.Block, receipts []*types.
 	if err != nil {
 		return err
 	}
+	if bc.sizeStats != nil {
+		bc.sizeStats.Commit(block.Hash(), state.StorageSizeChanges())
+	}
 	// If node is running in path mode, skip explicit gc operation
 	// which is unnecessary in this mode.
 	if bc.triedb.Scheme() == rawdb.PathScheme {
@@ -1264,6 +1418,9 @@ func (bc *BlockChain) writeBlockWithState(block *types.Block, receipts []*types.
 				log.Debug("failed to discard snapshot after being unable to insert block trie", "block", block.Hash(), "root", block.Root())
 			}
 		}
+		if bc.sizeStats != nil {
+			bc.sizeStats.Reject(block.Hash())
+		}
 		return err
 	}
 	return nil
@@ -2132,6 +2289,24 @@ func (bc *BlockChain) gatherBlockRootsAboveLastAccepted() map[common.Hash]struct
 	return blockRoots
 }
 
+// ProcessingStateRoots returns the state roots of the blocks above the last
+// accepted block, including the blocks accepted by consensus which have not
+// been processed by the acceptor yet.
+func (bc *BlockChain) ProcessingStateRoots() []common.Hash {
+	var roots []common.Hash
+	for height := bc.LastAcceptedBlock().NumberU64() + 1; ; height++ {
+		blockHashes := rawdb.ReadAllHashes(bc.db, height)
+		if len(blockHashes) == 0 {
+			return roots
+		}
+		for _, blockHash := range blockHashes {
+			if block := bc.GetBlockByHash(blockHash); block != nil {
+				roots = append(roots, block.Root())
+			}
+		}
+	}
+}
+
 // TODO: split extras to blockchain_extra.go
 
 // ResetToStateSyncedBlock reinitializes the state of the blockchain
@@ -2163,6 +2338,10 @@ func (bc *BlockChain) ResetToStateSyncedBlock(block *types.Block) error {
 	if bc.cacheConfig.TxLookupLimit != 0 {
 		bc.setTxIndexTail(block.NumberU64())
 	}
+	// if history expiry is disabled, we don't need to repair the history tail.
+	if bc.cacheConfig.HistoryExpiry != 0 {
+		bc.setHistoryTail(block.NumberU64())
+	}
 
 	// Update all in-memory chain markers
 	bc.lastAccepted = block
@@ -2170,6 +2349,14 @@ func (bc *BlockChain) ResetToStateSyncedBlock(block *types.Block) error {
 	bc.currentBlock.Store(block.Header())
 	bc.hc.SetCurrentHeader(block.Header())
 
+	// The path-based trie database holds the layers of the state before the
+	// sync, so reset it to the synced state persisted on disk.
+	if bc.triedb.Scheme() == rawdb.PathScheme {
+		if err := bc.triedb.Enable(block.Root()); err != nil {
+			return fmt.Errorf("failed to reset trie database to synced root %s: %w", block.Root(), err)
+		}
+	}
+
 	lastAcceptedHash := block.Hash()
 	bc.stateCache = state.NewDatabaseWithNodeDB(bc.db, bc.triedb)
 
@@ -2213,3 +2400,21 @@ func (bc *BlockChain) setTxIndexTail(newTail uint64) error {
 	}
 	return nil
 }
+
+// setHistoryTail moves the history tail forward to [newTail], since the blocks
+// before a state synced block have no bodies or receipts.
+func (bc *BlockChain) setHistoryTail(newTail uint64) {
+	bc.historyTailLock.Lock()
+	defer bc.historyTailLock.Unlock()
+
+	tailP := rawdb.ReadHistoryTail(bc.db)
+	var tailV uint64
+	if tailP != nil {
+		tailV = *tailP
+	}
+
+	if newTail > tailV {
+		log.Info("Repairing history tail", "old", tailV, "new", newTail)
+		rawdb.WriteHistoryTail(bc.db, newTail)
+	}
+}
@@ -30,6 +30,7 @@ import (
 	"github.com/ava-labs/coreth/consensus"
 	"github.com/ava-labs/coreth/core/rawdb"
 	"github.com/ava-labs/coreth/core/state"
+	"github.com/ava-labs/coreth/core/state/sizestats"
 	"github.com/ava-labs/coreth/core/state/snapshot"
 	"github.com/ava-labs/coreth/core/types"
 	"github.com/ava-labs/coreth/core/vm"
@@ -251,6 +252,12 @@ func (bc *BlockChain) Snapshots() *snapshot.Tree {
 	return bc.snaps
 }
 
+// StateSizeStats returns the state size statistics, or nil if they are
+// disabled.
+func (bc *BlockChain) StateSizeStats() *sizestats.Tracker {
+	return bc.sizeStats
+}
+
 // Validator returns the current validator.
 func (bc *BlockChain) Validator() Validator {
 	return bc.validator
@@ -261,6 +268,12 @@ func (bc *BlockChain) Processor() Processor {
 	return bc.processor
 }
 
+// ReplayProcessor returns the processor re-executing accepted blocks, see
+// SetReplayEngine.
+func (bc *BlockChain) ReplayProcessor() Processor {
+	return bc.replayProcessor
+}
+
 // StateCache returns the caching database underpinning the blockchain instance.
 func (bc *BlockChain) StateCache() state.Database {
 	return bc.stateCache
@@ -345,3 +358,9 @@ func (bc *BlockChain) GetLogs(hash common.Hash, number uint64) [][]*types.Log {
 	logs = bc.collectUnflattenedLogs(block, false)
 	return logs
 }
+
+// IsHistoryPruned returns whether the body and receipts of the block at
+// [number] have been deleted by history expiry.
+func (bc *BlockChain) IsHistoryPruned(number uint64) bool {
+	return rawdb.IsHistoryPruned(bc.db, number)
+}
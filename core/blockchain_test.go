@@ -8,11 +8,13 @@ import (
 	"math/big"
 	"os"
 	"testing"
+	"time"
 
 	"github.com/ava-labs/coreth/consensus/dummy"
 	"github.com/ava-labs/coreth/core/rawdb"
 	"github.com/ava-labs/coreth/core/state"
 	"github.com/ava-labs/coreth/core/state/pruner"
+	"github.com/ava-labs/coreth/core/state/sizestats"
 	"github.com/ava-labs/coreth/core/types"
 	"github.com/ava-labs/coreth/core/vm"
 	"github.com/ava-labs/coreth/eth/tracers/logger"
@@ -645,6 +647,149 @@ func getTail(limit uint64, lastAccepted uint64) *uint64 {
 	return &tail
 }
 
+func TestHistoryExpiry(t *testing.T) {
+	// Configure and generate a sample block chain
+	require := require.New(t)
+	var (
+		key1, _ = crypto.HexToECDSA("b71c71a67e1177ad4e901695e1b4b9ee17ae16c6668d313eac2f96dbcda3f291")
+		key2, _ = crypto.HexToECDSA("8a1f9a8f95be41cd7ccb6168179afb4504aefe388d1e14474d32c45c72ce7b7a")
+		addr1   = crypto.PubkeyToAddress(key1.PublicKey)
+		addr2   = crypto.PubkeyToAddress(key2.PublicKey)
+		funds   = big.NewInt(10000000000000)
+		gspec   = &Genesis{
+			Config: &params.ChainConfig{HomesteadBlock: new(big.Int)},
+			Alloc:  GenesisAlloc{addr1: {Balance: funds}},
+		}
+		signer = types.LatestSigner(gspec.Config)
+	)
+	genDb, blocks, _, err := GenerateChainWithGenesis(gspec, dummy.NewFakerWithCallbacks(TestCallbacks), 10, 10, func(i int, block *BlockGen) {
+		tx, err := types.SignTx(types.NewTransaction(block.TxNonce(addr1), addr2, big.NewInt(10000), params.TxGas, nil, nil), signer, key1)
+		require.NoError(err)
+		block.AddTx(tx)
+	})
+	require.NoError(err)
+
+	blocks2, _, err := GenerateChain(gspec.Config, blocks[len(blocks)-1], dummy.NewFakerWithCallbacks(TestCallbacks), genDb, 2, 10, func(i int, block *BlockGen) {
+		tx, err := types.SignTx(types.NewTransaction(block.TxNonce(addr1), addr2, big.NewInt(10000), params.TxGas, nil, nil), signer, key1)
+		require.NoError(err)
+		block.AddTx(tx)
+	})
+	require.NoError(err)
+
+	conf := &CacheConfig{
+		TrieCleanLimit:            256,
+		TrieDirtyLimit:            256,
+		TrieDirtyCommitTarget:     20,
+		TriePrefetcherParallelism: 4,
+		Pruning:                   true,
+		CommitInterval:            4096,
+		SnapshotLimit:             256,
+		SnapshotNoBuild:           true, // Ensure the test errors if snapshot initialization fails
+		AcceptorQueueLimit:        64,
+		HistoryExpiry:             4,
+	}
+
+	// checkHistory checks that the bodies and receipts of the blocks before
+	// [tail] are pruned, while their headers and canonical hashes are kept.
+	checkHistory := func(db ethdb.Database, blocks []*types.Block, tail uint64) {
+		require.Eventually(func() bool {
+			number := rawdb.ReadHistoryTail(db)
+			return number != nil && *number == tail
+		}, 10*time.Second, 10*time.Millisecond)
+
+		genesis := rawdb.ReadCanonicalHash(db, 0)
+		require.True(rawdb.HasBody(db, genesis, 0))
+		for _, block := range blocks {
+			hash, number := block.Hash(), block.NumberU64()
+			require.Equal(hash, rawdb.ReadCanonicalHash(db, number))
+			require.True(rawdb.HasHeader(db, hash, number))
+
+			pruned := number < tail
+			require.Equal(pruned, rawdb.IsHistoryPruned(db, number))
+			require.Equal(!pruned, rawdb.HasBody(db, hash, number))
+			require.Equal(!pruned, rawdb.HasReceipts(db, hash, number))
+		}
+	}
+
+	chainDB := rawdb.NewMemoryDatabase()
+	chain, err := createAndInsertChain(chainDB, conf, gspec, blocks, common.Hash{}, nil)
+	require.NoError(err)
+	checkHistory(chainDB, blocks, 7)
+	require.True(chain.IsHistoryPruned(6))
+	require.False(chain.IsHistoryPruned(7))
+	chain.Stop()
+
+	// Restart the chain and check the history tail follows the last accepted block.
+	chain, err = createAndInsertChain(chainDB, conf, gspec, blocks2, chain.CurrentHeader().Hash(), nil)
+	require.NoError(err)
+	checkHistory(chainDB, append(blocks, blocks2...), 9)
+	chain.Stop()
+}
+
+func TestStateSizeStats(t *testing.T) {
+	require := require.New(t)
+	var (
+		key1, _  = crypto.HexToECDSA("b71c71a67e1177ad4e901695e1b4b9ee17ae16c6668d313eac2f96dbcda3f291")
+		addr1    = crypto.PubkeyToAddress(key1.PublicKey)
+		contract = common.HexToAddress("0x1000")
+		funds    = big.NewInt(10000000000000)
+		gspec    = &Genesis{
+			Config: &params.ChainConfig{HomesteadBlock: new(big.Int)},
+			Alloc: GenesisAlloc{
+				addr1: {Balance: funds},
+				// SSTORE(NUMBER, 1)
+				contract: {
+					Balance: new(big.Int),
+					Code:    []byte{byte(vm.PUSH1), 0x1, byte(vm.NUMBER), byte(vm.SSTORE), byte(vm.STOP)},
+					Storage: map[common.Hash]common.Hash{{31: 0xf0}: {31: 0x1}, {31: 0xf1}: {31: 0x1}},
+				},
+			},
+		}
+		signer = types.LatestSigner(gspec.Config)
+	)
+	_, blocks, _, err := GenerateChainWithGenesis(gspec, dummy.NewFakerWithCallbacks(TestCallbacks), 5, 10, func(i int, block *BlockGen) {
+		tx, err := types.SignTx(types.NewTransaction(block.TxNonce(addr1), contract, new(big.Int), 100_000, nil, nil), signer, key1)
+		require.NoError(err)
+		block.AddTx(tx)
+	})
+	require.NoError(err)
+
+	conf := &CacheConfig{
+		TrieCleanLimit:            256,
+		TrieDirtyLimit:            256,
+		TrieDirtyCommitTarget:     20,
+		TriePrefetcherParallelism: 4,
+		Pruning:                   true,
+		CommitInterval:            4096,
+		SnapshotLimit:             256,
+		SnapshotWait:              true,
+		AcceptorQueueLimit:        64,
+		StateSizeStats:            true,
+	}
+	chain, err := createAndInsertChain(rawdb.NewMemoryDatabase(), conf, gspec, blocks, common.Hash{}, nil)
+	require.NoError(err)
+	defer chain.Stop()
+
+	// Each block adds a slot holding 1 to the contract.
+	slotSize := uint64(state.StorageSlotOverhead + 1)
+	require.Eventually(func() bool {
+		stats, err := chain.StateSizeStats().Stats(1)
+		require.NoError(err)
+		return !stats.Backfilling
+	}, 10*time.Second, 10*time.Millisecond)
+	stats, err := chain.StateSizeStats().Stats(1)
+	require.NoError(err)
+	require.Equal(uint64(5), stats.Number)
+	require.Equal(uint64(2), stats.Accounts) // The test callbacks store a multicoin balance
+	require.Equal(&contract, stats.Largest[0].Address)
+	require.Equal(uint64(7), stats.Largest[0].Slots)
+	require.Equal(7*slotSize, stats.Largest[0].Bytes)
+
+	growth, err := chain.StateSizeStats().Growth(2, 5, 1)
+	require.NoError(err)
+	require.Equal(sizestats.AccountGrowth{Address: contract, SlotsAdded: 4, BytesAdded: 4 * slotSize}, growth.Largest[0])
+}
+
 func TestTransactionSkipIndexing(t *testing.T) {
 	// Configure and generate a sample block chain
 	require := require.New(t)
@@ -38,6 +38,10 @@ var (
 
 	// ErrNoGenesis is returned when there is no Genesis Block.
 	ErrNoGenesis = errors.New("genesis not found in chain")
+
+	// ErrHistoryPruned is returned when the body or receipts of a block have
+	// been deleted by history expiry.
+	ErrHistoryPruned = errors.New("history pruned")
 )
 
 // List of evm-call-message pre-checking errors. All state transition messages will
@@ -55,3 +55,37 @@ type ChainSideEvent struct {
 }
 
 type ChainHeadEvent struct{ Block *types.Block }
+
+// TxLifecycleStatus is the state a pooled transaction transitioned into.
+type TxLifecycleStatus string
+
+const (
+	TxLifecycleReplaced TxLifecycleStatus = "replaced" // Replaced by a transaction with the same nonce
+	TxLifecycleDropped  TxLifecycleStatus = "dropped"  // Removed from the pool without being included
+	TxLifecyclePromoted TxLifecycleStatus = "promoted" // Moved from the queued to the pending set
+	TxLifecycleIncluded TxLifecycleStatus = "included" // Removed from the pool after being included in a block
+)
+
+// TxDropReason describes why a transaction was dropped from the pool.
+type TxDropReason string
+
+const (
+	TxDropUnderpriced       TxDropReason = "underpriced"
+	TxDropLifetimeExpired   TxDropReason = "lifetime expired"
+	TxDropAccountSlots      TxDropReason = "account slots"
+	TxDropNonceTooLow       TxDropReason = "nonce too low"
+	TxDropInsufficientFunds TxDropReason = "insufficient funds"
+	TxDropPoolOverflow      TxDropReason = "pool overflow"
+)
+
+// TxLifecycleChange describes a single transaction state change in the pool.
+type TxLifecycleChange struct {
+	Hash        common.Hash
+	Status      TxLifecycleStatus
+	Replacement common.Hash  // Hash of the replacing transaction if Status is TxLifecycleReplaced
+	Reason      TxDropReason // Reason of the drop if Status is TxLifecycleDropped
+}
+
+// TxLifecycleEvent is posted when a batch of pooled transactions are replaced,
+// dropped, promoted or included.
+type TxLifecycleEvent struct{ Changes []TxLifecycleChange }
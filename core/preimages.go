@@ -0,0 +1,215 @@
+// (c) 2024, Ava Labs, Inc. All rights reserved.
+// See the file LICENSE for licensing terms.
+
+package core
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/ava-labs/coreth/core/rawdb"
+	"github.com/ava-labs/coreth/core/state"
+	"github.com/ava-labs/coreth/trie"
+	"github.com/ava-labs/coreth/trie/triedb/hashdb"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/ethdb"
+	"github.com/ethereum/go-ethereum/log"
+	"github.com/ethereum/go-ethereum/rlp"
+)
+
+const (
+	// preimageImportBatch is the number of imported preimages written to the
+	// database at once.
+	preimageImportBatch = 1024
+
+	// preimageLogInterval is the interval between two progress logs of the
+	// preimage export, import and backfill.
+	preimageLogInterval = 8 * time.Second
+)
+
+// ErrPreimageBackfillInterrupted is returned by BackfillPreimages when it is
+// interrupted before reaching the last block.
+var ErrPreimageBackfillInterrupted = errors.New("preimage backfill interrupted")
+
+// ExportPreimages writes every preimage stored in [db] to [w] as a stream of
+// RLP encoded byte strings, the format used by go-ethereum, and returns the
+// number of preimages written.
+func ExportPreimages(db ethdb.Iteratee, w io.Writer) (uint64, error) {
+	var (
+		count  uint64
+		start  = time.Now()
+		logged = time.Now()
+		err    error
+	)
+	iterErr := rawdb.IteratePreimages(db, func(_ common.Hash, preimage []byte) bool {
+		if err = rlp.Encode(w, preimage); err != nil {
+			return false
+		}
+		count++
+		if time.Since(logged) > preimageLogInterval {
+			log.Info("Exporting preimages", "count", count, "elapsed", common.PrettyDuration(time.Since(start)))
+			logged = time.Now()
+		}
+		return true
+	})
+	if err != nil {
+		return count, err
+	}
+	if iterErr != nil {
+		return count, iterErr
+	}
+	log.Info("Exported preimages", "count", count, "elapsed", common.PrettyDuration(time.Since(start)))
+	return count, nil
+}
+
+// ImportPreimages stores the preimages read from [r], in the format written by
+// ExportPreimages, and returns the number of preimages read. The hash of each
+// preimage is computed rather than trusted.
+func ImportPreimages(db ethdb.KeyValueStore, r io.Reader) (uint64, error) {
+	var (
+		count     uint64
+		start     = time.Now()
+		logged    = time.Now()
+		stream    = rlp.NewStream(r, 0)
+		preimages = make(map[common.Hash][]byte)
+	)
+	flush := func() error {
+		batch := db.NewBatch()
+		rawdb.WritePreimages(batch, preimages)
+		preimages = make(map[common.Hash][]byte)
+		return batch.Write()
+	}
+	for {
+		preimage, err := stream.Bytes()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return count, fmt.Errorf("preimage %d: %w", count, err)
+		}
+		preimages[crypto.Keccak256Hash(preimage)] = preimage
+		count++
+		if len(preimages) >= preimageImportBatch {
+			if err := flush(); err != nil {
+				return count, err
+			}
+		}
+		if time.Since(logged) > preimageLogInterval {
+			log.Info("Importing preimages", "count", count, "elapsed", common.PrettyDuration(time.Since(start)))
+			logged = time.Now()
+		}
+	}
+	if err := flush(); err != nil {
+		return count, err
+	}
+	log.Info("Imported preimages", "count", count, "elapsed", common.PrettyDuration(time.Since(start)))
+	return count, nil
+}
+
+// BackfillPreimages re-executes the accepted blocks [from, to] and stores the
+// preimages of the addresses and storage keys they access, along with the
+// SHA3 preimages seen by the VM, which are missing from the database. It
+// returns the number of preimages stored.
+//
+// With the hash scheme, the blocks are re-executed on top of the state of the
+// parent of [from], which must be committed to disk. With the path scheme,
+// the state of the parent of every block must be available. The blocks are
+// re-executed by the replay processor of the chain.
+//
+// [onBlock] is called after each block with the number of preimages stored so
+// far. The backfill stops with ErrPreimageBackfillInterrupted when
+// [interrupt] is closed.
+func (bc *BlockChain) BackfillPreimages(from, to uint64, interrupt <-chan struct{}, onBlock func(number, count uint64)) (uint64, error) {
+	if from == 0 || from > to {
+		return 0, fmt.Errorf("invalid block range [%d, %d]", from, to)
+	}
+	if last := bc.LastAcceptedBlock().NumberU64(); to > last {
+		return 0, fmt.Errorf("block %d is after the last accepted block %d", to, last)
+	}
+	parent := bc.GetBlockByNumber(from - 1)
+	if parent == nil {
+		return 0, fmt.Errorf("block %d not found", from-1)
+	}
+	// With the hash scheme, the re-executed states are committed to a private
+	// database, holding the state of the last block only.
+	var (
+		sdb      = bc.stateCache
+		triedb   *trie.Database
+		prevRoot common.Hash
+	)
+	if bc.triedb.Scheme() == rawdb.HashScheme {
+		triedb = trie.NewDatabase(bc.db, &trie.Config{HashDB: hashdb.Defaults})
+		defer triedb.Close()
+		sdb = state.NewDatabaseWithNodeDB(bc.db, triedb)
+	}
+	var (
+		count    uint64
+		start    = time.Now()
+		logged   = time.Now()
+		vmConfig = bc.vmConfig
+	)
+	vmConfig.EnablePreimageRecording = true
+	for number := from; number <= to; number++ {
+		select {
+		case <-interrupt:
+			return count, ErrPreimageBackfillInterrupted
+		default:
+		}
+		block := bc.GetBlockByNumber(number)
+		if block == nil {
+			return count, fmt.Errorf("block %d not found", number)
+		}
+		statedb, err := state.New(parent.Root(), sdb, nil)
+		if err != nil {
+			return count, fmt.Errorf("could not fetch state of block %d: %w", parent.NumberU64(), err)
+		}
+		if _, _, _, err := bc.replayProcessor.Process(block, parent.Header(), statedb, vmConfig); err != nil {
+			return count, fmt.Errorf("failed to re-process block %d: %w", number, err)
+		}
+		if root := statedb.IntermediateRoot(bc.chainConfig.IsEIP158(block.Number())); root != block.Root() {
+			return count, fmt.Errorf("re-processed block %d has root %s, expected %s", number, root, block.Root())
+		}
+		// Store the missing preimages before moving to the next state.
+		missing := make(map[common.Hash][]byte)
+		for _, preimages := range []map[common.Hash][]byte{statedb.Preimages(), statedb.KeyPreimages()} {
+			for hash, preimage := range preimages {
+				if _, ok := missing[hash]; !ok && len(rawdb.ReadPreimage(bc.db, hash)) == 0 {
+					missing[hash] = preimage
+				}
+			}
+		}
+		if len(missing) != 0 {
+			batch := bc.db.NewBatch()
+			rawdb.WritePreimages(batch, missing)
+			if err := batch.Write(); err != nil {
+				return count, err
+			}
+			count += uint64(len(missing))
+		}
+		if triedb != nil {
+			if _, err := statedb.Commit(number, bc.chainConfig.IsEIP158(block.Number()), true); err != nil {
+				return count, fmt.Errorf("failed to commit state of block %d: %w", number, err)
+			}
+			if root := block.Root(); root != prevRoot {
+				if prevRoot != (common.Hash{}) {
+					triedb.Dereference(prevRoot)
+				}
+				prevRoot = root
+			}
+		}
+		parent = block
+
+		if onBlock != nil {
+			onBlock(number, count)
+		}
+		if time.Since(logged) > preimageLogInterval {
+			log.Info("Backfilling preimages", "number", number, "to", to, "count", count, "elapsed", common.PrettyDuration(time.Since(start)))
+			logged = time.Now()
+		}
+	}
+	log.Info("Backfilled preimages", "from", from, "to", to, "count", count, "elapsed", common.PrettyDuration(time.Since(start)))
+	return count, nil
+}
@@ -0,0 +1,120 @@
+// (c) 2024, Ava Labs, Inc. All rights reserved.
+// See the file LICENSE for licensing terms.
+
+package core
+
+import (
+	"bytes"
+	"math/big"
+	"testing"
+
+	"github.com/ava-labs/coreth/consensus/dummy"
+	"github.com/ava-labs/coreth/core/rawdb"
+	"github.com/ava-labs/coreth/core/types"
+	"github.com/ava-labs/coreth/core/vm"
+	"github.com/ava-labs/coreth/params"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/stretchr/testify/require"
+)
+
+func TestExportImportPreimages(t *testing.T) {
+	require := require.New(t)
+	db := rawdb.NewMemoryDatabase()
+	preimages := make(map[common.Hash][]byte)
+	for i := 0; i < 2*preimageImportBatch+1; i++ {
+		preimage := big.NewInt(int64(i)).Bytes()
+		preimages[crypto.Keccak256Hash(preimage)] = preimage
+	}
+	rawdb.WritePreimages(db, preimages)
+
+	var buf bytes.Buffer
+	count, err := ExportPreimages(db, &buf)
+	require.NoError(err)
+	require.Equal(uint64(len(preimages)), count)
+
+	imported := rawdb.NewMemoryDatabase()
+	count, err = ImportPreimages(imported, &buf)
+	require.NoError(err)
+	require.Equal(uint64(len(preimages)), count)
+	for hash, preimage := range preimages {
+		require.Equal(preimage, rawdb.ReadPreimage(imported, hash))
+	}
+
+	// A truncated file fails the import.
+	buf.Reset()
+	_, err = ExportPreimages(db, &buf)
+	require.NoError(err)
+	_, err = ImportPreimages(rawdb.NewMemoryDatabase(), bytes.NewReader(buf.Bytes()[:buf.Len()-1]))
+	require.Error(err)
+}
+
+func TestBackfillPreimages(t *testing.T) {
+	require := require.New(t)
+	var (
+		key1, _  = crypto.HexToECDSA("b71c71a67e1177ad4e901695e1b4b9ee17ae16c6668d313eac2f96dbcda3f291")
+		addr1    = crypto.PubkeyToAddress(key1.PublicKey)
+		contract = common.HexToAddress("0x1000")
+		funds    = big.NewInt(10000000000000)
+		gspec    = &Genesis{
+			Config: &params.ChainConfig{HomesteadBlock: new(big.Int)},
+			Alloc: GenesisAlloc{
+				addr1: {Balance: funds},
+				// SSTORE(NUMBER, KECCAK256(0, 32))
+				contract: {
+					Balance: new(big.Int),
+					Code: []byte{
+						byte(vm.PUSH1), 0x20, byte(vm.PUSH1), 0x0, byte(vm.KECCAK256),
+						byte(vm.NUMBER), byte(vm.SSTORE), byte(vm.STOP),
+					},
+				},
+			},
+		}
+		signer = types.LatestSigner(gspec.Config)
+	)
+	_, blocks, _, err := GenerateChainWithGenesis(gspec, dummy.NewFakerWithCallbacks(TestCallbacks), 4, 10, func(i int, block *BlockGen) {
+		tx, err := types.SignTx(types.NewTransaction(block.TxNonce(addr1), contract, new(big.Int), 100_000, nil, nil), signer, key1)
+		require.NoError(err)
+		block.AddTx(tx)
+	})
+	require.NoError(err)
+
+	db := rawdb.NewMemoryDatabase()
+	chain, err := createAndInsertChain(db, DefaultCacheConfig, gspec, blocks, common.Hash{}, nil)
+	require.NoError(err)
+	defer chain.Stop()
+
+	slot := func(number uint64) []byte { return common.BigToHash(new(big.Int).SetUint64(number)).Bytes() }
+	zero := make([]byte, 32)
+	for _, preimage := range [][]byte{addr1[:], contract[:], slot(2), zero} {
+		require.Empty(rawdb.ReadPreimage(db, crypto.Keccak256Hash(preimage)))
+	}
+
+	_, err = chain.BackfillPreimages(3, 5, nil, nil)
+	require.ErrorContains(err, "after the last accepted block")
+	// The state of block 1 is only in memory.
+	_, err = chain.BackfillPreimages(2, 4, nil, nil)
+	require.ErrorContains(err, "could not fetch state of block 1")
+
+	var numbers []uint64
+	count, err := chain.BackfillPreimages(1, 3, nil, func(number, _ uint64) {
+		numbers = append(numbers, number)
+	})
+	require.NoError(err)
+	require.NotZero(count)
+	require.Equal([]uint64{1, 2, 3}, numbers)
+	for _, preimage := range [][]byte{addr1[:], contract[:], slot(1), slot(2), slot(3), zero} {
+		require.Equal(preimage, rawdb.ReadPreimage(db, crypto.Keccak256Hash(preimage)))
+	}
+	require.Empty(rawdb.ReadPreimage(db, crypto.Keccak256Hash(slot(4))))
+
+	// Backfilling again finds no missing preimage.
+	count, err = chain.BackfillPreimages(1, 3, nil, nil)
+	require.NoError(err)
+	require.Zero(count)
+
+	interrupt := make(chan struct{})
+	close(interrupt)
+	_, err = chain.BackfillPreimages(1, 4, interrupt, nil)
+	require.ErrorIs(err, ErrPreimageBackfillInterrupted)
+}
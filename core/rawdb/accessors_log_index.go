@@ -0,0 +1,140 @@
+// (c) 2024, Ava Labs, Inc. All rights reserved.
+// See the file LICENSE for licensing terms.
+
+package rawdb
+
+import (
+	"encoding/binary"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/ethdb"
+	"github.com/ethereum/go-ethereum/log"
+)
+
+// LogIndexTopic is a log topic at a position (0 to 3) of the topics of a log.
+type LogIndexTopic struct {
+	Topic    common.Hash
+	Position uint8
+}
+
+// LogIndexEntry holds the indexes, within the block with Number, of the logs
+// matching an address or a topic of the log index.
+type LogIndexEntry struct {
+	Number  uint64
+	Indexes []uint32
+}
+
+// ReadLogIndexRange retrieves the range of blocks [tail, next) covered by the
+// log index. It returns false if the index was never initialized.
+func ReadLogIndexRange(db ethdb.KeyValueReader) (uint64, uint64, bool) {
+	data, _ := db.Get(logIndexRangeKey)
+	if len(data) != 16 {
+		return 0, 0, false
+	}
+	return binary.BigEndian.Uint64(data[:8]), binary.BigEndian.Uint64(data[8:]), true
+}
+
+// WriteLogIndexRange stores the range of blocks [tail, next) covered by the log
+// index.
+func WriteLogIndexRange(db ethdb.KeyValueWriter, tail, next uint64) {
+	if err := db.Put(logIndexRangeKey, append(encodeBlockNumber(tail), encodeBlockNumber(next)...)); err != nil {
+		log.Crit("Failed to store the log index range", "err", err)
+	}
+}
+
+// WriteLogIndexBlock stores the indexes of the logs of the block with [number]
+// by the address emitting them and by their topics.
+func WriteLogIndexBlock(db ethdb.KeyValueWriter, number uint64, addresses map[common.Address][]uint32, topics map[LogIndexTopic][]uint32) {
+	for address, indexes := range addresses {
+		if err := db.Put(logIndexAddressKey(address, number), encodeLogIndexes(indexes)); err != nil {
+			log.Crit("Failed to store log index address", "err", err)
+		}
+	}
+	for topic, indexes := range topics {
+		if err := db.Put(logIndexTopicKey(topic.Topic, topic.Position, number), encodeLogIndexes(indexes)); err != nil {
+			log.Crit("Failed to store log index topic", "err", err)
+		}
+	}
+}
+
+// LogIndexIterator iterates over the entries of the log index for an address
+// or a topic, in ascending block order, without loading them all in memory.
+type LogIndexIterator struct {
+	it     ethdb.Iterator
+	prefix []byte
+	to     uint64
+	entry  LogIndexEntry
+}
+
+// NewLogIndexAddressIterator returns an iterator over the indexed logs emitted
+// by [address] within the blocks [from, to].
+func NewLogIndexAddressIterator(db ethdb.Iteratee, address common.Address, from, to uint64) *LogIndexIterator {
+	prefix := append(append([]byte{}, logIndexAddressPrefix...), address.Bytes()...)
+	return newLogIndexIterator(db, prefix, from, to)
+}
+
+// NewLogIndexTopicIterator returns an iterator over the indexed logs with
+// [topic] at [position] within the blocks [from, to].
+func NewLogIndexTopicIterator(db ethdb.Iteratee, topic common.Hash, position uint8, from, to uint64) *LogIndexIterator {
+	prefix := append(append(append([]byte{}, logIndexTopicPrefix...), topic.Bytes()...), position)
+	return newLogIndexIterator(db, prefix, from, to)
+}
+
+func newLogIndexIterator(db ethdb.Iteratee, prefix []byte, from, to uint64) *LogIndexIterator {
+	return &LogIndexIterator{
+		it:     db.NewIterator(prefix, encodeBlockNumber(from)),
+		prefix: prefix,
+		to:     to,
+	}
+}
+
+// Next moves the iterator to the next entry. It returns false once the
+// iterator is exhausted.
+func (it *LogIndexIterator) Next() bool {
+	for it.it.Next() {
+		key, value := it.it.Key(), it.it.Value()
+		if len(key) != len(it.prefix)+8 || len(value)%4 != 0 {
+			continue
+		}
+		number := binary.BigEndian.Uint64(key[len(it.prefix):])
+		if number > it.to {
+			return false
+		}
+		indexes := make([]uint32, len(value)/4)
+		for i := range indexes {
+			indexes[i] = binary.BigEndian.Uint32(value[4*i:])
+		}
+		it.entry = LogIndexEntry{Number: number, Indexes: indexes}
+		return true
+	}
+	return false
+}
+
+// Entry returns the current entry of the iterator.
+func (it *LogIndexIterator) Entry() LogIndexEntry {
+	return it.entry
+}
+
+// Release releases the resources held by the iterator.
+func (it *LogIndexIterator) Release() {
+	it.it.Release()
+}
+
+// DeleteLogIndex removes the log index and its range from the database.
+func DeleteLogIndex(db ethdb.KeyValueStore) error {
+	if err := ClearPrefix(db, logIndexAddressPrefix, len(logIndexAddressPrefix)+common.AddressLength+8); err != nil {
+		return err
+	}
+	if err := ClearPrefix(db, logIndexTopicPrefix, len(logIndexTopicPrefix)+common.HashLength+1+8); err != nil {
+		return err
+	}
+	return db.Delete(logIndexRangeKey)
+}
+
+func encodeLogIndexes(indexes []uint32) []byte {
+	enc := make([]byte, 0, 4*len(indexes))
+	for _, index := range indexes {
+		enc = binary.BigEndian.AppendUint32(enc, index)
+	}
+	return enc
+}
@@ -220,6 +220,26 @@ func DeleteOfflinePruning(db ethdb.KeyValueStore) error {
 	return DeleteTimeMarker(db, offlinePruningKey)
 }
 
+// ReadOnlinePruningProgress retrieves the key from which an interrupted online
+// pruning sweep resumes, or nil if no sweep is in progress.
+func ReadOnlinePruningProgress(db ethdb.KeyValueReader) []byte {
+	data, _ := db.Get(onlinePruningProgressKey)
+	return data
+}
+
+// WriteOnlinePruningProgress stores the key from which an interrupted online
+// pruning sweep resumes.
+func WriteOnlinePruningProgress(db ethdb.KeyValueWriter, next []byte) {
+	if err := db.Put(onlinePruningProgressKey, next); err != nil {
+		log.Crit("Failed to store the online pruning progress", "err", err)
+	}
+}
+
+// DeleteOnlinePruningProgress deletes the progress of the online pruning sweep.
+func DeleteOnlinePruningProgress(db ethdb.KeyValueWriter) error {
+	return db.Delete(onlinePruningProgressKey)
+}
+
 // WritePopulateMissingTries writes a marker for the current attempt to populate
 // missing tries.
 func WritePopulateMissingTries(db ethdb.KeyValueStore) error {
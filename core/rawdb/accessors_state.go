@@ -51,6 +51,20 @@ func WritePreimages(db ethdb.KeyValueWriter, preimages map[common.Hash][]byte) {
 	preimageHitCounter.Inc(int64(len(preimages)))
 }
 
+// IteratePreimages calls [fn] with every preimage stored in [db], until [fn]
+// returns false.
+func IteratePreimages(db ethdb.Iteratee, fn func(hash common.Hash, preimage []byte) bool) error {
+	it := NewKeyLengthIterator(db.NewIterator(PreimagePrefix, nil), len(PreimagePrefix)+common.HashLength)
+	defer it.Release()
+
+	for it.Next() {
+		if !fn(common.BytesToHash(it.Key()[len(PreimagePrefix):]), it.Value()) {
+			break
+		}
+	}
+	return it.Error()
+}
+
 // ReadCode retrieves the contract code of the provided code hash.
 func ReadCode(db ethdb.KeyValueReader, hash common.Hash) []byte {
 	// Try with the prefixed code scheme first and only. The legacy scheme was never used in coreth.
@@ -0,0 +1,196 @@
+// (c) 2024, Ava Labs, Inc. All rights reserved.
+// See the file LICENSE for licensing terms.
+
+package rawdb
+
+import (
+	"encoding/binary"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/ethdb"
+	"github.com/ethereum/go-ethereum/log"
+	"github.com/ethereum/go-ethereum/rlp"
+)
+
+// StateSizeProgress is the last block applied to the state size statistics,
+// the totals of the statistics and the progress of their backfill.
+type StateSizeProgress struct {
+	Number uint64
+	Hash   common.Hash
+
+	// Backfilling is set until the storage of every account of the state of
+	// the block has been counted. The accounts before Marker are counted, and
+	// Partial holds the slots counted so far of the account at Marker if it
+	// also holds a slot hash.
+	Backfilling bool
+	Marker      []byte
+	Partial     StateSizeAccount
+
+	Accounts uint64 // Accounts with a non-empty storage
+	Slots    uint64
+	Bytes    uint64
+}
+
+// StateSizeAccount is the storage size of an account. The address is zero if
+// it is unknown.
+type StateSizeAccount struct {
+	Address common.Address
+	Slots   uint64
+	Bytes   uint64
+}
+
+// StateSizeChange is the change of the storage of an account in a block.
+type StateSizeChange struct {
+	Address      common.Address
+	SlotsAdded   uint64
+	SlotsRemoved uint64
+	BytesAdded   uint64
+	BytesRemoved uint64
+}
+
+// ReadStateSizeProgress retrieves the progress of the state size statistics,
+// or nil if they were never initialized.
+func ReadStateSizeProgress(db ethdb.KeyValueReader) *StateSizeProgress {
+	data, _ := db.Get(stateSizeProgressKey)
+	if len(data) == 0 {
+		return nil
+	}
+	var progress StateSizeProgress
+	if err := rlp.DecodeBytes(data, &progress); err != nil {
+		log.Error("Invalid state size progress", "err", err)
+		return nil
+	}
+	return &progress
+}
+
+// WriteStateSizeProgress stores the progress of the state size statistics.
+func WriteStateSizeProgress(db ethdb.KeyValueWriter, progress *StateSizeProgress) {
+	data, err := rlp.EncodeToBytes(progress)
+	if err != nil {
+		log.Crit("Failed to encode state size progress", "err", err)
+	}
+	if err := db.Put(stateSizeProgressKey, data); err != nil {
+		log.Crit("Failed to store state size progress", "err", err)
+	}
+}
+
+// ReadStateSizeAccount retrieves the storage size of the account with
+// [accountHash], or nil if its storage is empty or not counted.
+func ReadStateSizeAccount(db ethdb.KeyValueReader, accountHash common.Hash) *StateSizeAccount {
+	data, _ := db.Get(stateSizeAccountKey(accountHash))
+	if len(data) == 0 {
+		return nil
+	}
+	var account StateSizeAccount
+	if err := rlp.DecodeBytes(data, &account); err != nil {
+		log.Error("Invalid state size account", "hash", accountHash, "err", err)
+		return nil
+	}
+	return &account
+}
+
+// WriteStateSizeAccount stores the storage size of the account with
+// [accountHash].
+func WriteStateSizeAccount(db ethdb.KeyValueWriter, accountHash common.Hash, account *StateSizeAccount) {
+	data, err := rlp.EncodeToBytes(account)
+	if err != nil {
+		log.Crit("Failed to encode state size account", "err", err)
+	}
+	if err := db.Put(stateSizeAccountKey(accountHash), data); err != nil {
+		log.Crit("Failed to store state size account", "err", err)
+	}
+}
+
+// DeleteStateSizeAccount removes the storage size of the account with
+// [accountHash].
+func DeleteStateSizeAccount(db ethdb.KeyValueWriter, accountHash common.Hash) {
+	if err := db.Delete(stateSizeAccountKey(accountHash)); err != nil {
+		log.Crit("Failed to delete state size account", "err", err)
+	}
+}
+
+// IterateStateSizeAccounts calls [fn] with the storage size of the accounts
+// from [start] in ascending account hash order, until [fn] returns false.
+func IterateStateSizeAccounts(db ethdb.Iteratee, start []byte, fn func(accountHash common.Hash, account *StateSizeAccount) bool) error {
+	it := NewKeyLengthIterator(db.NewIterator(stateSizeAccountPrefix, start), len(stateSizeAccountPrefix)+common.HashLength)
+	defer it.Release()
+
+	for it.Next() {
+		var account StateSizeAccount
+		if err := rlp.DecodeBytes(it.Value(), &account); err != nil {
+			return err
+		}
+		if !fn(common.BytesToHash(it.Key()[len(stateSizeAccountPrefix):]), &account) {
+			break
+		}
+	}
+	return it.Error()
+}
+
+// WriteStateSizeChanges stores the storage changes of the accounts in the
+// block with [number].
+func WriteStateSizeChanges(db ethdb.KeyValueWriter, number uint64, changes []StateSizeChange) {
+	data, err := rlp.EncodeToBytes(changes)
+	if err != nil {
+		log.Crit("Failed to encode state size changes", "err", err)
+	}
+	if err := db.Put(stateSizeBlockKey(number), data); err != nil {
+		log.Crit("Failed to store state size changes", "err", err)
+	}
+}
+
+// IterateStateSizeChanges calls [fn] with the storage changes of the accounts
+// in the blocks [from, to] in ascending block order. Blocks without stored
+// changes are skipped.
+func IterateStateSizeChanges(db ethdb.Iteratee, from, to uint64, fn func(number uint64, changes []StateSizeChange)) error {
+	it := NewKeyLengthIterator(db.NewIterator(stateSizeBlockPrefix, encodeBlockNumber(from)), len(stateSizeBlockPrefix)+8)
+	defer it.Release()
+
+	for it.Next() {
+		number := binary.BigEndian.Uint64(it.Key()[len(stateSizeBlockPrefix):])
+		if number > to {
+			break
+		}
+		var changes []StateSizeChange
+		if err := rlp.DecodeBytes(it.Value(), &changes); err != nil {
+			return err
+		}
+		fn(number, changes)
+	}
+	return it.Error()
+}
+
+// DeleteStateSizeChanges removes the storage changes stored for the blocks
+// before [to].
+func DeleteStateSizeChanges(db ethdb.KeyValueStore, to uint64) error {
+	it := NewKeyLengthIterator(db.NewIterator(stateSizeBlockPrefix, nil), len(stateSizeBlockPrefix)+8)
+	defer it.Release()
+
+	batch := db.NewBatch()
+	for it.Next() {
+		if binary.BigEndian.Uint64(it.Key()[len(stateSizeBlockPrefix):]) >= to {
+			break
+		}
+		if err := batch.Delete(common.CopyBytes(it.Key())); err != nil {
+			return err
+		}
+		if batch.ValueSize() > ethdb.IdealBatchSize {
+			if err := batch.Write(); err != nil {
+				return err
+			}
+			batch.Reset()
+		}
+	}
+	if err := it.Error(); err != nil {
+		return err
+	}
+	return batch.Write()
+}
+
+// DeleteStateSizeChangesAt removes the storage changes stored for the block
+// with [number].
+func DeleteStateSizeChangesAt(db ethdb.KeyValueWriter, number uint64) {
+	if err := db.Delete(stateSizeBlockKey(number)); err != nil {
+		log.Crit("Failed to delete state size changes", "err", err)
+	}
+}
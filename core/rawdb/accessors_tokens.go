@@ -0,0 +1,249 @@
+// (c) 2024, Ava Labs, Inc. All rights reserved.
+// See the file LICENSE for licensing terms.
+
+package rawdb
+
+import (
+	"encoding/binary"
+	"math/big"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/ethdb"
+	"github.com/ethereum/go-ethereum/log"
+	"github.com/ethereum/go-ethereum/rlp"
+)
+
+// TokenStandard identifies the standard of an indexed token.
+type TokenStandard byte
+
+const (
+	TokenERC20  TokenStandard = iota + 1 // Fungible token, transfers carry an amount
+	TokenERC721                          // Non-fungible token, transfers carry a token id
+)
+
+// TokenTransfer is an indexed Transfer event of an ERC-20 or ERC-721 token.
+type TokenTransfer struct {
+	Token    common.Address
+	Standard TokenStandard
+	From     common.Address
+	To       common.Address
+	Value    *big.Int // Amount for ERC-20 tokens, token id for ERC-721 tokens
+	TxHash   common.Hash
+}
+
+// TokenTransferPosition locates an indexed token transfer by the number of
+// its block and the index of its log within the block.
+type TokenTransferPosition struct {
+	Number uint64
+	Index  uint32
+}
+
+// TokenBalance is the indexed balance of a holder in a token. For ERC-721
+// tokens, the balance is the number of tokens owned.
+type TokenBalance struct {
+	Address  common.Address // Token or holder, depending on the lookup
+	Standard TokenStandard
+	Balance  *big.Int
+}
+
+// encodeTokenTransferPosition encodes a token transfer position as the block
+// number (uint64 big endian) followed by the log index (uint32 big endian).
+func encodeTokenTransferPosition(number uint64, index uint32) []byte {
+	enc := make([]byte, 12)
+	binary.BigEndian.PutUint64(enc, number)
+	binary.BigEndian.PutUint32(enc[8:], index)
+	return enc
+}
+
+// ReadTokenIndexRange retrieves the range of blocks [first, next) covered by
+// the token transfer index. It returns false if the index was never
+// initialized.
+func ReadTokenIndexRange(db ethdb.KeyValueReader) (uint64, uint64, bool) {
+	data, _ := db.Get(tokenIndexRangeKey)
+	if len(data) != 16 {
+		return 0, 0, false
+	}
+	return binary.BigEndian.Uint64(data[:8]), binary.BigEndian.Uint64(data[8:]), true
+}
+
+// WriteTokenIndexRange stores the range of blocks [first, next) covered by the
+// token transfer index.
+func WriteTokenIndexRange(db ethdb.KeyValueWriter, first, next uint64) {
+	if err := db.Put(tokenIndexRangeKey, append(encodeBlockNumber(first), encodeBlockNumber(next)...)); err != nil {
+		log.Crit("Failed to store the token index range", "err", err)
+	}
+}
+
+// WriteTokenTransfer stores the token transfer emitted by the log with [index]
+// in the block with [number], indexing it by the token and both parties. The
+// zero address, used for mints and burns, is not indexed.
+func WriteTokenTransfer(db ethdb.KeyValueWriter, number uint64, index uint32, transfer *TokenTransfer) {
+	data, err := rlp.EncodeToBytes(transfer)
+	if err != nil {
+		log.Crit("Failed to encode token transfer", "err", err)
+	}
+	if err := db.Put(tokenTransferKey(number, index), data); err != nil {
+		log.Crit("Failed to store token transfer", "err", err)
+	}
+	for _, address := range []common.Address{transfer.Token, transfer.From, transfer.To} {
+		if address == (common.Address{}) {
+			continue
+		}
+		if err := db.Put(tokenAddressTransferKey(address, number, index), nil); err != nil {
+			log.Crit("Failed to store token transfer index", "err", err)
+		}
+	}
+}
+
+// ReadTokenTransfer retrieves the token transfer at [position], or nil if it
+// was not indexed.
+func ReadTokenTransfer(db ethdb.KeyValueReader, position TokenTransferPosition) *TokenTransfer {
+	data, _ := db.Get(tokenTransferKey(position.Number, position.Index))
+	if len(data) == 0 {
+		return nil
+	}
+	transfer := new(TokenTransfer)
+	if err := rlp.DecodeBytes(data, transfer); err != nil {
+		log.Error("Invalid token transfer RLP", "number", position.Number, "index", position.Index, "err", err)
+		return nil
+	}
+	return transfer
+}
+
+// ReadTokenAddressTransfers returns the positions of up to [limit] token
+// transfers of [address], either as token or as party, in ascending order
+// starting at [start]. It also returns the position of the next transfer, if
+// any.
+func ReadTokenAddressTransfers(db ethdb.Iteratee, address common.Address, start TokenTransferPosition, limit int) ([]TokenTransferPosition, *TokenTransferPosition) {
+	prefix := append(append([]byte{}, tokenAddressTransferPrefix...), address.Bytes()...)
+	var positions []TokenTransferPosition
+	next := iterateTokenIndex(db, prefix, encodeTokenTransferPosition(start.Number, start.Index), 12, limit, func(suffix, _ []byte) {
+		positions = append(positions, TokenTransferPosition{
+			Number: binary.BigEndian.Uint64(suffix),
+			Index:  binary.BigEndian.Uint32(suffix[8:]),
+		})
+	})
+	if next == nil {
+		return positions, nil
+	}
+	return positions, &TokenTransferPosition{Number: binary.BigEndian.Uint64(next), Index: binary.BigEndian.Uint32(next[8:])}
+}
+
+// ReadTokenBalance retrieves the indexed balance of [holder] in [token]. It
+// returns a zero balance if the holder has none.
+func ReadTokenBalance(db ethdb.KeyValueReader, holder, token common.Address) (TokenStandard, *big.Int) {
+	data, _ := db.Get(tokenBalanceKey(holder, token))
+	if len(data) == 0 {
+		return 0, new(big.Int)
+	}
+	return TokenStandard(data[0]), new(big.Int).SetBytes(data[1:])
+}
+
+// WriteTokenBalance stores the balance of [holder] in [token], indexing it by
+// both the holder and the token. A zero balance removes the holder from the
+// index.
+func WriteTokenBalance(db ethdb.KeyValueWriter, holder, token common.Address, standard TokenStandard, balance *big.Int) {
+	if balance.Sign() == 0 {
+		if err := db.Delete(tokenBalanceKey(holder, token)); err != nil {
+			log.Crit("Failed to delete token balance", "err", err)
+		}
+		if err := db.Delete(tokenHolderKey(token, holder)); err != nil {
+			log.Crit("Failed to delete token holder", "err", err)
+		}
+		return
+	}
+	data := append([]byte{byte(standard)}, balance.Bytes()...)
+	if err := db.Put(tokenBalanceKey(holder, token), data); err != nil {
+		log.Crit("Failed to store token balance", "err", err)
+	}
+	if err := db.Put(tokenHolderKey(token, holder), data); err != nil {
+		log.Crit("Failed to store token holder", "err", err)
+	}
+}
+
+// ReadTokenBalances returns up to [limit] non-zero token balances of [holder],
+// ordered by token address starting at [start]. It also returns the address
+// of the next token, if any.
+func ReadTokenBalances(db ethdb.Iteratee, holder, start common.Address, limit int) ([]TokenBalance, *common.Address) {
+	return readTokenBalances(db, tokenBalancePrefix, holder, start, limit)
+}
+
+// ReadTokenHolders returns up to [limit] holders of [token] with a non-zero
+// balance, ordered by holder address starting at [start]. It also returns the
+// address of the next holder, if any.
+func ReadTokenHolders(db ethdb.Iteratee, token, start common.Address, limit int) ([]TokenBalance, *common.Address) {
+	return readTokenBalances(db, tokenHolderPrefix, token, start, limit)
+}
+
+func readTokenBalances(db ethdb.Iteratee, prefix []byte, address, start common.Address, limit int) ([]TokenBalance, *common.Address) {
+	prefix = append(append([]byte{}, prefix...), address.Bytes()...)
+	var balances []TokenBalance
+	next := iterateTokenIndex(db, prefix, start.Bytes(), common.AddressLength, limit, func(suffix, value []byte) {
+		if len(value) == 0 {
+			return
+		}
+		balances = append(balances, TokenBalance{
+			Address:  common.BytesToAddress(suffix),
+			Standard: TokenStandard(value[0]),
+			Balance:  new(big.Int).SetBytes(value[1:]),
+		})
+	})
+	if next == nil {
+		return balances, nil
+	}
+	nextAddress := common.BytesToAddress(next)
+	return balances, &nextAddress
+}
+
+// WriteTokenOwned records whether [holder] owns the ERC-721 token [id] of
+// [token].
+func WriteTokenOwned(db ethdb.KeyValueWriter, holder, token common.Address, id common.Hash, owned bool) {
+	var err error
+	if owned {
+		err = db.Put(tokenOwnedKey(holder, token, id), nil)
+	} else {
+		err = db.Delete(tokenOwnedKey(holder, token, id))
+	}
+	if err != nil {
+		log.Crit("Failed to store token ownership", "err", err)
+	}
+}
+
+// ReadTokenOwned returns up to [limit] ids of the ERC-721 tokens of [token]
+// owned by [holder], in ascending order starting at [start]. It also returns
+// the next owned id, if any.
+func ReadTokenOwned(db ethdb.Iteratee, holder, token common.Address, start common.Hash, limit int) ([]common.Hash, *common.Hash) {
+	prefix := append(append(append([]byte{}, tokenOwnedPrefix...), holder.Bytes()...), token.Bytes()...)
+	var ids []common.Hash
+	next := iterateTokenIndex(db, prefix, start.Bytes(), common.HashLength, limit, func(suffix, _ []byte) {
+		ids = append(ids, common.BytesToHash(suffix))
+	})
+	if next == nil {
+		return ids, nil
+	}
+	nextID := common.BytesToHash(next)
+	return ids, &nextID
+}
+
+// iterateTokenIndex calls [fn] with the key suffix and value of up to [limit]
+// entries under [prefix] with a key suffix of [size] bytes, starting at
+// [start]. It returns the key suffix of the next entry, or nil if there is
+// none.
+func iterateTokenIndex(db ethdb.Iteratee, prefix, start []byte, size, limit int, fn func(suffix, value []byte)) []byte {
+	it := db.NewIterator(prefix, start)
+	defer it.Release()
+
+	count := 0
+	for it.Next() {
+		key := it.Key()
+		if len(key) != len(prefix)+size {
+			continue
+		}
+		if count == limit {
+			return common.CopyBytes(key[len(prefix):])
+		}
+		fn(key[len(prefix):], it.Value())
+		count++
+	}
+	return nil
+}
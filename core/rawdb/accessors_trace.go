@@ -0,0 +1,75 @@
+// (c) 2024, Ava Labs, Inc. All rights reserved.
+// See the file LICENSE for licensing terms.
+
+package rawdb
+
+import (
+	"encoding/binary"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/ethdb"
+	"github.com/ethereum/go-ethereum/log"
+)
+
+// TraceAddressFlags records the roles an address takes in the call traces of
+// a block.
+type TraceAddressFlags byte
+
+const (
+	TraceAddressFrom TraceAddressFlags = 1 << iota // Address initiated a call, create or self-destruct
+	TraceAddressTo                                 // Address received a call, was created or was refunded
+)
+
+// ReadTraceIndexRange retrieves the range of blocks [first, next) covered by
+// the call trace address index. It returns false if the index was never
+// initialized.
+func ReadTraceIndexRange(db ethdb.KeyValueReader) (uint64, uint64, bool) {
+	data, _ := db.Get(traceIndexRangeKey)
+	if len(data) != 16 {
+		return 0, 0, false
+	}
+	return binary.BigEndian.Uint64(data[:8]), binary.BigEndian.Uint64(data[8:]), true
+}
+
+// WriteTraceIndexRange stores the range of blocks [first, next) covered by the
+// call trace address index.
+func WriteTraceIndexRange(db ethdb.KeyValueWriter, first, next uint64) {
+	if err := db.Put(traceIndexRangeKey, append(encodeBlockNumber(first), encodeBlockNumber(next)...)); err != nil {
+		log.Crit("Failed to store the trace index range", "err", err)
+	}
+}
+
+// WriteTraceAddresses stores the addresses appearing in the call traces of the
+// block with [number], along with the roles they take in them.
+func WriteTraceAddresses(db ethdb.KeyValueWriter, number uint64, addresses map[common.Address]TraceAddressFlags) {
+	for address, flags := range addresses {
+		if err := db.Put(traceAddressKey(address, number), []byte{byte(flags)}); err != nil {
+			log.Crit("Failed to store trace address", "err", err)
+		}
+	}
+}
+
+// ReadTraceAddressBlocks returns the numbers of the blocks within [from, to]
+// whose call traces contain [address] in any of the roles in [flags], in
+// ascending order.
+func ReadTraceAddressBlocks(db ethdb.Iteratee, address common.Address, flags TraceAddressFlags, from, to uint64) []uint64 {
+	prefix := append(append([]byte{}, traceAddressPrefix...), address.Bytes()...)
+	it := db.NewIterator(prefix, encodeBlockNumber(from))
+	defer it.Release()
+
+	var numbers []uint64
+	for it.Next() {
+		key, value := it.Key(), it.Value()
+		if len(key) != len(prefix)+8 || len(value) != 1 {
+			continue
+		}
+		number := binary.BigEndian.Uint64(key[len(prefix):])
+		if number > to {
+			break
+		}
+		if TraceAddressFlags(value[0])&flags != 0 {
+			numbers = append(numbers, number)
+		}
+	}
+	return numbers
+}
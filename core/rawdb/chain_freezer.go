@@ -0,0 +1,307 @@
+// (c) 2024, Ava Labs, Inc.
+//
+// This file is a derived work, based on the go-ethereum library whose original
+// notices appear below.
+//
+// It is distributed under a license compatible with the licensing terms of the
+// original code from which it is derived.
+//
+// Much love to the original authors for their work.
+// **********
+// Copyright 2022 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package rawdb
+
+import (
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/ethdb"
+	"github.com/ethereum/go-ethereum/log"
+)
+
+const (
+	// freezerRecheckInterval is the frequency to check the key-value database for
+	// chain progression that might permit new blocks to be frozen into immutable
+	// storage.
+	freezerRecheckInterval = time.Minute
+
+	// freezerBatchLimit is the maximum number of blocks to freeze in one batch
+	// before doing an fsync and deleting it from the key-value store.
+	freezerBatchLimit = 30000
+)
+
+// chainFreezer is a wrapper of freezer with additional chain freezing feature.
+// The background thread will keep moving ancient chain segments from key-value
+// database to flat files for saving space on live database.
+//
+// Unlike go-ethereum, the freezing threshold is measured from the acceptor tip
+// rather than the head block, since only accepted blocks are final.
+type chainFreezer struct {
+	threshold atomic.Uint64 // Number of recent accepted blocks not to freeze
+
+	*Freezer
+	quit    chan struct{}
+	wg      sync.WaitGroup
+	trigger chan chan struct{} // Manual blocking freeze trigger, test determinism
+}
+
+// newChainFreezer initializes the freezer for ancient chain data.
+func newChainFreezer(datadir string, namespace string, readonly bool, threshold uint64) (*chainFreezer, error) {
+	freezer, err := NewChainFreezer(datadir, namespace, readonly)
+	if err != nil {
+		return nil, err
+	}
+	cf := chainFreezer{
+		Freezer: freezer,
+		quit:    make(chan struct{}),
+		trigger: make(chan chan struct{}),
+	}
+	cf.threshold.Store(threshold)
+	return &cf, nil
+}
+
+// Close closes the chain freezer instance and terminates the background thread.
+func (f *chainFreezer) Close() error {
+	select {
+	case <-f.quit:
+	default:
+		close(f.quit)
+	}
+	f.wg.Wait()
+	return f.Freezer.Close()
+}
+
+// freeze is a background thread that periodically checks the blockchain for any
+// accepted blocks and moves ancient data from the fast database into the freezer.
+//
+// This functionality is deliberately broken off from block acceptance to avoid
+// incurring additional data shuffling delays on the accept path.
+func (f *chainFreezer) freeze(db ethdb.KeyValueStore) {
+	var (
+		backoff   bool
+		triggered chan struct{} // Used in tests
+		nfdb      = &nofreezedb{KeyValueStore: db}
+	)
+	timer := time.NewTimer(freezerRecheckInterval)
+	defer timer.Stop()
+
+	for {
+		select {
+		case <-f.quit:
+			log.Info("Freezer shutting down")
+			return
+		default:
+		}
+		if backoff {
+			// If we were doing a manual trigger, notify it
+			if triggered != nil {
+				triggered <- struct{}{}
+				triggered = nil
+			}
+			select {
+			case <-timer.C:
+				backoff = false
+				timer.Reset(freezerRecheckInterval)
+			case triggered = <-f.trigger:
+				backoff = false
+			case <-f.quit:
+				return
+			}
+		}
+		// Retrieve the freezing threshold.
+		hash, err := ReadAcceptorTip(nfdb)
+		if err != nil {
+			log.Error("Failed to read acceptor tip", "err", err)
+			backoff = true
+			continue
+		}
+		if hash == (common.Hash{}) {
+			log.Debug("Acceptor tip unavailable") // new chain, empty database
+			backoff = true
+			continue
+		}
+		number := ReadHeaderNumber(nfdb, hash)
+		threshold := f.threshold.Load()
+		frozen := f.frozen.Load()
+
+		// State synced nodes and nodes which ran with history expiry are
+		// missing the oldest blocks, so an empty freezer starts at the first
+		// block available locally.
+		if frozen == 0 {
+			if first := firstFreezableBlock(nfdb); first > 0 {
+				if err := f.resetTail(first); err != nil {
+					log.Error("Failed to start freezer at first available block", "number", first, "err", err)
+					backoff = true
+					continue
+				}
+				log.Info("Starting freezer at first available block", "number", first)
+				frozen = first
+			}
+		}
+		switch {
+		case number == nil:
+			log.Error("Acceptor tip number unavailable", "hash", hash)
+			backoff = true
+			continue
+
+		case *number < threshold:
+			log.Debug("Acceptor tip not old enough to freeze", "number", *number, "hash", hash, "delay", threshold)
+			backoff = true
+			continue
+
+		case *number-threshold <= frozen:
+			log.Debug("Ancient blocks frozen already", "number", *number, "hash", hash, "frozen", frozen)
+			backoff = true
+			continue
+		}
+
+		// Seems we have data ready to be frozen, process in usable batches
+		var (
+			start    = time.Now()
+			first, _ = f.Ancients()
+			limit    = *number - threshold
+		)
+		if limit-first > freezerBatchLimit {
+			limit = first + freezerBatchLimit
+		}
+		ancients, err := f.freezeRange(nfdb, first, limit)
+		if err != nil {
+			log.Error("Error in block freeze operation", "err", err)
+			backoff = true
+			continue
+		}
+
+		// Batch of blocks have been frozen, flush them before wiping from the
+		// key-value store
+		if err := f.Sync(); err != nil {
+			log.Crit("Failed to flush frozen tables", "err", err)
+		}
+
+		// Wipe out all data from the active database
+		batch := db.NewBatch()
+		for i := 0; i < len(ancients); i++ {
+			// Always keep the genesis block in active database
+			if first+uint64(i) != 0 {
+				DeleteBlockWithoutNumber(batch, ancients[i], first+uint64(i))
+				DeleteCanonicalHash(batch, first+uint64(i))
+			}
+		}
+		if err := batch.Write(); err != nil {
+			log.Crit("Failed to delete frozen canonical blocks", "err", err)
+		}
+		batch.Reset()
+
+		// Wipe out any rejected blocks which were left behind below the frozen
+		// height. Since the frozen blocks are all accepted, there cannot be any
+		// descendants of these blocks left to process.
+		frozen = f.frozen.Load() // Needs reload after during freezeRange
+		for number := first; number < frozen; number++ {
+			// Always keep the genesis block in active database
+			if number != 0 {
+				for _, hash := range ReadAllHashes(db, number) {
+					log.Trace("Deleting rejected block", "number", number, "hash", hash)
+					DeleteBlock(batch, hash, number)
+				}
+			}
+		}
+		if err := batch.Write(); err != nil {
+			log.Crit("Failed to delete frozen side blocks", "err", err)
+		}
+		batch.Reset()
+
+		// Log something friendly for the user
+		context := []interface{}{
+			"blocks", frozen - first, "elapsed", common.PrettyDuration(time.Since(start)), "number", frozen - 1,
+		}
+		if n := len(ancients); n > 0 {
+			context = append(context, []interface{}{"hash", ancients[n-1]}...)
+		}
+		log.Debug("Deep froze chain segment", context...)
+
+		// Avoid database thrashing with tiny writes
+		if frozen-first < freezerBatchLimit {
+			backoff = true
+		}
+	}
+}
+
+func (f *chainFreezer) freezeRange(nfdb *nofreezedb, number, limit uint64) (hashes []common.Hash, err error) {
+	hashes = make([]common.Hash, 0, limit-number)
+
+	_, err = f.ModifyAncients(func(op ethdb.AncientWriteOp) error {
+		for ; number <= limit; number++ {
+			// Retrieve all the components of the canonical block.
+			hash := ReadCanonicalHash(nfdb, number)
+			if hash == (common.Hash{}) {
+				return fmt.Errorf("canonical hash missing, can't freeze block %d", number)
+			}
+			header := ReadHeaderRLP(nfdb, hash, number)
+			if len(header) == 0 {
+				return fmt.Errorf("block header missing, can't freeze block %d", number)
+			}
+			body := ReadBodyRLP(nfdb, hash, number)
+			if len(body) == 0 {
+				return fmt.Errorf("block body missing, can't freeze block %d", number)
+			}
+			receipts := ReadReceiptsRLP(nfdb, hash, number)
+			if len(receipts) == 0 {
+				return fmt.Errorf("block receipts missing, can't freeze block %d", number)
+			}
+
+			// Write to the batch.
+			if err := op.AppendRaw(ChainFreezerHashTable, number, hash[:]); err != nil {
+				return fmt.Errorf("can't write hash to Freezer: %v", err)
+			}
+			if err := op.AppendRaw(ChainFreezerHeaderTable, number, header); err != nil {
+				return fmt.Errorf("can't write header to Freezer: %v", err)
+			}
+			if err := op.AppendRaw(ChainFreezerBodiesTable, number, body); err != nil {
+				return fmt.Errorf("can't write body to Freezer: %v", err)
+			}
+			if err := op.AppendRaw(ChainFreezerReceiptTable, number, receipts); err != nil {
+				return fmt.Errorf("can't write receipts to Freezer: %v", err)
+			}
+
+			hashes = append(hashes, hash)
+		}
+		return nil
+	})
+
+	return hashes, err
+}
+
+// firstFreezableBlock returns the number of the first block available to be
+// frozen. State synced nodes are missing the blocks before the synced block,
+// and the receipts of the synced block itself, which was not executed locally.
+// Nodes which ran with history expiry are missing the bodies and receipts of
+// the blocks before the history tail.
+func firstFreezableBlock(db ethdb.Database) uint64 {
+	first := GetLatestSyncPerformed(db)
+	if first > 0 {
+		if hash := ReadCanonicalHash(db, first); hash == (common.Hash{}) || !HasReceipts(db, hash, first) {
+			first++
+		}
+	}
+	if tail := ReadHistoryTail(db); tail != nil {
+		first = max(first, *tail)
+	}
+	return first
+}
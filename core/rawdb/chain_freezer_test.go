@@ -0,0 +1,213 @@
+// (c) 2024, Ava Labs, Inc. All rights reserved.
+// See the file LICENSE for licensing terms.
+
+package rawdb
+
+import (
+	"math/big"
+	"testing"
+
+	"github.com/ava-labs/coreth/core/types"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/ethdb/memorydb"
+	"github.com/stretchr/testify/require"
+)
+
+func TestChainFreezer(t *testing.T) {
+	require := require.New(t)
+
+	kvdb := memorydb.New()
+	db, err := NewDatabaseWithFreezer(kvdb, t.TempDir(), "", false, 100)
+	require.NoError(err)
+	defer db.Close()
+
+	// Write an accepted chain with a rejected block at every height.
+	var (
+		blocks   []*types.Block
+		rejected []*types.Block
+		parent   common.Hash
+	)
+	for i := 0; i <= 10; i++ {
+		header := &types.Header{Number: big.NewInt(int64(i)), ParentHash: parent}
+		block := types.NewBlockWithHeader(header)
+		WriteBlock(db, block)
+		WriteCanonicalHash(db, block.Hash(), block.NumberU64())
+		WriteReceipts(db, block.Hash(), block.NumberU64(), types.Receipts{})
+		blocks = append(blocks, block)
+		parent = block.Hash()
+
+		if i > 0 {
+			side := types.NewBlockWithHeader(&types.Header{Number: big.NewInt(int64(i)), ParentHash: header.ParentHash, Extra: []byte{1}})
+			WriteBlock(db, side)
+			rejected = append(rejected, side)
+		}
+	}
+	require.NoError(WriteAcceptorTip(db, blocks[10].Hash()))
+
+	// Freeze all blocks at least 4 blocks behind the acceptor tip.
+	require.NoError(db.(*freezerdb).Freeze(4))
+	frozen, err := db.Ancients()
+	require.NoError(err)
+	require.EqualValues(7, frozen)
+
+	for _, block := range blocks {
+		var (
+			hash   = block.Hash()
+			number = block.NumberU64()
+		)
+		// The frozen blocks, other than the genesis, are removed from the
+		// key-value store.
+		inKV := number == 0 || number >= frozen
+		require.Equal(inKV, HasHeader(NewDatabase(kvdb), hash, number), "block %d", number)
+
+		// Reads are served transparently from either store.
+		require.Equal(hash, ReadCanonicalHash(db, number))
+		require.True(HasHeader(db, hash, number))
+		require.True(HasBody(db, hash, number))
+		require.True(HasReceipts(db, hash, number))
+		require.NotNil(ReadCanonicalBodyRLP(db, number))
+		require.NotNil(ReadRawReceipts(db, hash, number))
+		require.Equal(hash, ReadBlock(db, hash, number).Hash())
+		require.Equal(&number, ReadHeaderNumber(db, hash))
+	}
+	// Rejected blocks below the frozen height are deleted.
+	for _, block := range rejected {
+		require.Equal(block.NumberU64() >= frozen, HasHeader(db, block.Hash(), block.NumberU64()), "block %d", block.NumberU64())
+	}
+
+	// Nothing more is frozen until the acceptor tip advances.
+	require.NoError(db.(*freezerdb).Freeze(4))
+	frozen, err = db.Ancients()
+	require.NoError(err)
+	require.EqualValues(7, frozen)
+}
+
+func TestChainFreezerGenesisMismatch(t *testing.T) {
+	require := require.New(t)
+
+	ancient := t.TempDir()
+	db, err := NewDatabaseWithFreezer(memorydb.New(), ancient, "", false, 0)
+	require.NoError(err)
+	var parent common.Hash
+	for i := 0; i < 2; i++ {
+		block := types.NewBlockWithHeader(&types.Header{Number: big.NewInt(int64(i)), ParentHash: parent})
+		WriteBlock(db, block)
+		WriteCanonicalHash(db, block.Hash(), block.NumberU64())
+		WriteReceipts(db, block.Hash(), block.NumberU64(), types.Receipts{})
+		parent = block.Hash()
+	}
+	require.NoError(WriteAcceptorTip(db, parent))
+	require.NoError(db.(*freezerdb).Freeze(0))
+	frozen, err := db.Ancients()
+	require.NoError(err)
+	require.EqualValues(2, frozen)
+	require.NoError(db.Close())
+
+	// Opening the freezer with the key-value store of another chain fails.
+	kvdb := memorydb.New()
+	other := types.NewBlockWithHeader(&types.Header{Number: common.Big0, Extra: []byte{1}})
+	WriteCanonicalHash(kvdb, other.Hash(), 0)
+	_, err = NewDatabaseWithFreezer(kvdb, ancient, "", false, 0)
+	require.ErrorContains(err, "genesis mismatch")
+}
+
+func TestChainFreezerStateSynced(t *testing.T) {
+	require := require.New(t)
+
+	var (
+		kvdb    = memorydb.New()
+		ancient = t.TempDir()
+	)
+	db, err := NewDatabaseWithFreezer(kvdb, ancient, "", false, 0)
+	require.NoError(err)
+
+	// Write the genesis block and the blocks from the synced block 5, which
+	// has no receipts since it was not executed locally.
+	blocks := make(map[uint64]*types.Block)
+	var parent common.Hash
+	for i := 0; i <= 10; i++ {
+		block := types.NewBlockWithHeader(&types.Header{Number: big.NewInt(int64(i)), ParentHash: parent})
+		parent = block.Hash()
+		if i > 0 && i < 5 {
+			continue
+		}
+		WriteBlock(db, block)
+		WriteCanonicalHash(db, block.Hash(), block.NumberU64())
+		if i != 5 {
+			WriteReceipts(db, block.Hash(), block.NumberU64(), types.Receipts{})
+		}
+		blocks[block.NumberU64()] = block
+	}
+	require.NoError(WriteSyncPerformed(db, 5))
+	require.NoError(WriteAcceptorTip(db, blocks[10].Hash()))
+
+	// The freezer starts at the first block with receipts after the sync.
+	require.NoError(db.(*freezerdb).Freeze(2))
+	tail, err := db.Tail()
+	require.NoError(err)
+	require.EqualValues(6, tail)
+	frozen, err := db.Ancients()
+	require.NoError(err)
+	require.EqualValues(9, frozen)
+
+	for number, block := range blocks {
+		inKV := number < tail || number >= frozen
+		require.Equal(inKV, HasHeader(NewDatabase(kvdb), block.Hash(), number), "block %d", number)
+		require.Equal(block.Hash(), ReadCanonicalHash(db, number))
+		require.Equal(block.Hash(), ReadBlock(db, block.Hash(), number).Hash())
+	}
+	require.NoError(db.Close())
+
+	// The freezer starting after the genesis block is reopened.
+	db, err = NewDatabaseWithFreezer(kvdb, ancient, "", false, 0)
+	require.NoError(err)
+	defer db.Close()
+	tail, err = db.Tail()
+	require.NoError(err)
+	require.EqualValues(6, tail)
+	require.Equal(blocks[7].Hash(), ReadCanonicalHash(db, 7))
+}
+
+func TestChainFreezerHistoryExpired(t *testing.T) {
+	require := require.New(t)
+
+	kvdb := memorydb.New()
+	db, err := NewDatabaseWithFreezer(kvdb, t.TempDir(), "", false, 0)
+	require.NoError(err)
+	defer db.Close()
+
+	// Write a chain whose blocks before the history tail 4 had their bodies
+	// and receipts expired.
+	var (
+		blocks []*types.Block
+		parent common.Hash
+	)
+	for i := 0; i <= 10; i++ {
+		block := types.NewBlockWithHeader(&types.Header{Number: big.NewInt(int64(i)), ParentHash: parent})
+		WriteBlock(db, block)
+		WriteCanonicalHash(db, block.Hash(), block.NumberU64())
+		WriteReceipts(db, block.Hash(), block.NumberU64(), types.Receipts{})
+		if i > 0 && i < 4 {
+			DeleteBody(db, block.Hash(), block.NumberU64())
+			DeleteReceipts(db, block.Hash(), block.NumberU64())
+		}
+		blocks = append(blocks, block)
+		parent = block.Hash()
+	}
+	WriteHistoryTail(db, 4)
+	require.NoError(WriteAcceptorTip(db, blocks[10].Hash()))
+
+	// The freezer starts at the history tail.
+	require.NoError(db.(*freezerdb).Freeze(2))
+	tail, err := db.Tail()
+	require.NoError(err)
+	require.EqualValues(4, tail)
+	frozen, err := db.Ancients()
+	require.NoError(err)
+	require.EqualValues(9, frozen)
+
+	for _, block := range blocks[4:] {
+		require.True(HasBody(db, block.Hash(), block.NumberU64()), "block %d", block.NumberU64())
+		require.True(HasReceipts(db, block.Hash(), block.NumberU64()), "block %d", block.NumberU64())
+	}
+}
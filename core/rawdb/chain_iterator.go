@@ -310,3 +310,78 @@ func UnindexTransactions(db ethdb.Database, from uint64, to uint64, interrupt ch
 func unindexTransactionsForTesting(db ethdb.Database, from uint64, to uint64, interrupt chan struct{}, hook func(uint64) bool) {
 	unindexTransactions(db, from, to, interrupt, hook)
 }
+
+// pruneHistory deletes the bodies and receipts of all the blocks, both
+// canonical and rejected, in the range [from, to), while keeping their headers
+// and canonical hashes. The genesis block is always kept.
+//
+// The history tail is written periodically so that an interrupted run resumes
+// from where it left off.
+func pruneHistory(db ethdb.Database, from uint64, to uint64, interrupt chan struct{}, hook func(uint64) bool) {
+	// short circuit for invalid range
+	if from >= to {
+		return
+	}
+	var (
+		batch  = db.NewBatch()
+		start  = time.Now()
+		logged = start.Add(-7 * time.Second)
+		number = from
+	)
+loop:
+	for ; number < to; number++ {
+		select {
+		case <-interrupt:
+			break loop
+		default:
+		}
+		// For testing
+		if hook != nil && !hook(number) {
+			break
+		}
+		// Always keep the genesis block
+		if number != 0 {
+			for _, hash := range ReadAllHashes(db, number) {
+				DeleteBody(batch, hash, number)
+				DeleteReceipts(batch, hash, number)
+			}
+		}
+		// A batch counts the size of deletion as '1', so we need to flush more
+		// often than that.
+		if (number-from+1)%1000 == 0 {
+			WriteHistoryTail(batch, number+1)
+			if err := batch.Write(); err != nil {
+				log.Crit("Failed writing batch to db", "error", err)
+				return
+			}
+			batch.Reset()
+		}
+		// If we've spent too much time already, notify the user of what we're doing
+		if time.Since(logged) > 8*time.Second {
+			log.Info("Pruning block history", "blocks", number-from, "total", to-from, "elapsed", common.PrettyDuration(time.Since(start)))
+			logged = time.Now()
+		}
+	}
+	// Flush the new history tail and the last deletions.
+	WriteHistoryTail(batch, number)
+	if err := batch.Write(); err != nil {
+		log.Crit("Failed writing batch to db", "error", err)
+		return
+	}
+	select {
+	case <-interrupt:
+		log.Debug("Block history pruning interrupted", "blocks", number-from, "tail", number, "elapsed", common.PrettyDuration(time.Since(start)))
+	default:
+		log.Debug("Pruned block history", "blocks", number-from, "tail", number, "elapsed", common.PrettyDuration(time.Since(start)))
+	}
+}
+
+// PruneHistory deletes the bodies and receipts of the blocks in the range
+// [from, to), keeping their headers and canonical hashes. The from is included
+// while to is excluded.
+//
+// There is a passed channel, the whole procedure will be interrupted if any
+// signal received.
+func PruneHistory(db ethdb.Database, from uint64, to uint64, interrupt chan struct{}) {
+	pruneHistory(db, from, to, interrupt, nil)
+}
@@ -216,3 +216,59 @@ func TestIndexTransactions(t *testing.T) {
 	verify(8, 11, true, 8)
 	verify(0, 8, false, 8)
 }
+
+func TestPruneHistory(t *testing.T) {
+	// Construct test chain db
+	chainDb := NewMemoryDatabase()
+
+	var blocks []*types.Block
+	for i := uint64(0); i <= 10; i++ {
+		block := types.NewBlockWithHeader(&types.Header{Number: big.NewInt(int64(i))})
+		WriteBlock(chainDb, block)
+		WriteCanonicalHash(chainDb, block.Hash(), block.NumberU64())
+		WriteReceipts(chainDb, block.Hash(), block.NumberU64(), types.Receipts{})
+		blocks = append(blocks, block)
+	}
+	// verify checks whether the bodies and receipts in the range [from, to)
+	// are expected, and that the headers are always kept.
+	verify := func(from, to int, exist bool, tail uint64) {
+		for i := from; i < to; i++ {
+			hash, number := blocks[i].Hash(), blocks[i].NumberU64()
+			if !HasHeader(chainDb, hash, number) || ReadCanonicalHash(chainDb, number) != hash {
+				t.Fatalf("Block header %d missing", i)
+			}
+			if HasBody(chainDb, hash, number) != exist || HasReceipts(chainDb, hash, number) != exist {
+				t.Fatalf("Block body and receipts %d existence mismatch, want %t", i, exist)
+			}
+			if IsHistoryPruned(chainDb, number) == (exist || number == 0) {
+				t.Fatalf("Block %d pruned status mismatch", i)
+			}
+		}
+		number := ReadHistoryTail(chainDb)
+		if number == nil || *number != tail {
+			t.Fatalf("History tail mismatch")
+		}
+	}
+	PruneHistory(chainDb, 1, 4, nil)
+	verify(1, 4, false, 4)
+	verify(4, 11, true, 4)
+
+	// Testing corner cases
+	signal := make(chan struct{})
+	var once sync.Once
+	pruneHistory(chainDb, 4, 11, signal, func(n uint64) bool {
+		if n >= 7 {
+			once.Do(func() {
+				close(signal)
+			})
+			return false
+		}
+		return true
+	})
+	verify(1, 7, false, 7)
+	verify(7, 11, true, 7)
+
+	// Resume from the persisted tail.
+	PruneHistory(chainDb, *ReadHistoryTail(chainDb), 11, nil)
+	verify(1, 11, false, 11)
+}
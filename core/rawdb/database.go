@@ -42,6 +42,54 @@ import (
 	"github.com/olekukonko/tablewriter"
 )
 
+// freezerdb is a database wrapper that enables freezer data retrievals.
+type freezerdb struct {
+	ancientRoot string
+	ethdb.KeyValueStore
+	ethdb.AncientStore
+}
+
+// AncientDatadir returns the path of root ancient directory.
+func (frdb *freezerdb) AncientDatadir() (string, error) {
+	return frdb.ancientRoot, nil
+}
+
+// Close implements io.Closer, closing both the fast key-value store as well as
+// the slow ancient tables.
+func (frdb *freezerdb) Close() error {
+	var errs []error
+	if err := frdb.AncientStore.Close(); err != nil {
+		errs = append(errs, err)
+	}
+	if err := frdb.KeyValueStore.Close(); err != nil {
+		errs = append(errs, err)
+	}
+	if len(errs) != 0 {
+		return fmt.Errorf("%v", errs)
+	}
+	return nil
+}
+
+// Freeze is a helper method used for external testing to trigger and block until
+// a freeze cycle completes, without having to sleep for a minute to trigger the
+// automatic background run.
+func (frdb *freezerdb) Freeze(threshold uint64) error {
+	if frdb.AncientStore.(*chainFreezer).readonly {
+		return errReadOnly
+	}
+	// Set the freezer threshold to a temporary value
+	defer func(old uint64) {
+		frdb.AncientStore.(*chainFreezer).threshold.Store(old)
+	}(frdb.AncientStore.(*chainFreezer).threshold.Load())
+	frdb.AncientStore.(*chainFreezer).threshold.Store(threshold)
+
+	// Trigger a freeze cycle and block until it's done
+	trigger := make(chan struct{}, 1)
+	frdb.AncientStore.(*chainFreezer).trigger <- trigger
+	<-trigger
+	return nil
+}
+
 // nofreezedb is a database wrapper that disables freezer data retrievals.
 type nofreezedb struct {
 	ethdb.KeyValueStore
@@ -130,6 +178,61 @@ func NewDatabase(db ethdb.KeyValueStore) ethdb.Database {
 	return &nofreezedb{KeyValueStore: db}
 }
 
+// NewDatabaseWithFreezer creates a high level database on top of a given key-
+// value data store with a freezer moving accepted chain segments older than
+// [threshold] blocks behind the acceptor tip into cold storage. The ancient
+// chain data is stored in the "chain" folder of [ancient].
+func NewDatabaseWithFreezer(db ethdb.KeyValueStore, ancient string, namespace string, readonly bool, threshold uint64) (ethdb.Database, error) {
+	// Create the idle freezer instance
+	frdb, err := newChainFreezer(filepath.Join(ancient, ChainFreezerName), namespace, readonly, threshold)
+	if err != nil {
+		return nil, err
+	}
+	// Since the freezer is stored separately from the key-value database, ensure
+	// that we don't serve up conflicting data by combining a freezer and a
+	// key-value store of different chains, or with a gap between the two.
+	//
+	// The key-value store of state synced nodes is missing the blocks before
+	// the synced block, so their freezer starts after the genesis block.
+	if kvgenesis, _ := db.Get(headerHashKey(0)); len(kvgenesis) > 0 {
+		if frozen, _ := frdb.Ancients(); frozen > 0 {
+			tail, _ := frdb.Tail()
+			if tail == 0 {
+				frgenesis, err := frdb.Ancient(ChainFreezerHashTable, 0)
+				if err != nil {
+					frdb.Close()
+					return nil, fmt.Errorf("failed to retrieve genesis from ancient %v", err)
+				} else if !bytes.Equal(kvgenesis, frgenesis) {
+					frdb.Close()
+					return nil, fmt.Errorf("genesis mismatch: %#x (database) != %#x (ancients)", kvgenesis, frgenesis)
+				}
+			}
+			// Key-value store and freezer belong to the same network. Ensure that they
+			// are contiguous, otherwise we might end up with a non-functional freezer.
+			if kvhash, _ := db.Get(headerHashKey(frozen)); len(kvhash) == 0 {
+				tip, _ := ReadAcceptorTip(db)
+				if number := ReadHeaderNumber(db, tip); number != nil && *number >= frozen {
+					frdb.Close()
+					return nil, fmt.Errorf("gap in the chain between ancients [#%d - #%d] and database [#%d]", tail, frozen-1, *number)
+				}
+			}
+		}
+	}
+	// Freezer is consistent with the key-value database, permit combining the two
+	if !frdb.readonly {
+		frdb.wg.Add(1)
+		go func() {
+			frdb.freeze(db)
+			frdb.wg.Done()
+		}()
+	}
+	return &freezerdb{
+		ancientRoot:   ancient,
+		KeyValueStore: db,
+		AncientStore:  frdb,
+	}, nil
+}
+
 // NewMemoryDatabase creates an ephemeral in-memory key-value database without a
 // freezer moving immutable chain segments into cold storage.
 func NewMemoryDatabase() ethdb.Database {
@@ -295,6 +398,7 @@ func InspectDatabase(db ethdb.Database, keyPrefix, keyStart []byte) error {
 		storageTries    stat
 		codes           stat
 		txLookups       stat
+		traceAddresses  stat
 		accountSnaps    stat
 		storageSnaps    stat
 		preimages       stat
@@ -360,6 +464,8 @@ func InspectDatabase(db ethdb.Database, keyPrefix, keyStart []byte) error {
 			bloomBits.Add(size)
 		case bytes.HasPrefix(key, BloomBitsIndexPrefix):
 			bloomBits.Add(size)
+		case bytes.HasPrefix(key, traceAddressPrefix) && len(key) == (len(traceAddressPrefix)+common.AddressLength+8):
+			traceAddresses.Add(size)
 		case bytes.HasPrefix(key, syncStorageTriesPrefix) && len(key) == syncStorageTriesKeyLength:
 			syncProgress.Add(size)
 		case bytes.HasPrefix(key, syncSegmentsPrefix) && len(key) == syncSegmentsKeyLength:
@@ -374,7 +480,7 @@ func InspectDatabase(db ethdb.Database, keyPrefix, keyStart []byte) error {
 				databaseVersionKey, headHeaderKey, headBlockKey,
 				snapshotRootKey, snapshotBlockHashKey, snapshotGeneratorKey,
 				uncleanShutdownKey, syncRootKey, txIndexTailKey,
-				persistentStateIDKey, trieJournalKey,
+				persistentStateIDKey, trieJournalKey, traceIndexRangeKey,
 			} {
 				if bytes.Equal(key, meta) {
 					metadata.Add(size)
@@ -400,6 +506,7 @@ func InspectDatabase(db ethdb.Database, keyPrefix, keyStart []byte) error {
 		{"Key-Value store", "Block number->hash", numHashPairings.Size(), numHashPairings.Count()},
 		{"Key-Value store", "Block hash->number", hashNumPairings.Size(), hashNumPairings.Count()},
 		{"Key-Value store", "Transaction index", txLookups.Size(), txLookups.Count()},
+		{"Key-Value store", "Trace address index", traceAddresses.Size(), traceAddresses.Count()},
 		{"Key-Value store", "Bloombit index", bloomBits.Size(), bloomBits.Count()},
 		{"Key-Value store", "Contract codes", codes.Size(), codes.Count()},
 		{"Key-Value store", "Hash trie nodes", legacyTries.Size(), legacyTries.Count()},
@@ -66,12 +66,18 @@ var (
 	// txIndexTailKey tracks the oldest block whose transactions have been indexed.
 	txIndexTailKey = []byte("TransactionIndexTail")
 
+	// historyTailKey tracks the oldest block whose body and receipts are kept.
+	historyTailKey = []byte("HistoryTail")
+
 	// uncleanShutdownKey tracks the list of local crashes
 	uncleanShutdownKey = []byte("unclean-shutdown") // config prefix for the db
 
 	// offlinePruningKey tracks runs of offline pruning
 	offlinePruningKey = []byte("OfflinePruning")
 
+	// onlinePruningProgressKey tracks the next key to be swept by online pruning.
+	onlinePruningProgressKey = []byte("OnlinePruningProgress")
+
 	// populateMissingTriesKey tracks runs of trie backfills
 	populateMissingTriesKey = []byte("PopulateMissingTries")
 
@@ -82,6 +88,18 @@ var (
 	// acceptorTipKey tracks the tip of the last accepted block that has been fully processed.
 	acceptorTipKey = []byte("AcceptorTipKey")
 
+	// traceIndexRangeKey tracks the range of blocks covered by the call trace address index.
+	traceIndexRangeKey = []byte("TraceIndexRange")
+
+	// tokenIndexRangeKey tracks the range of blocks covered by the token transfer index.
+	tokenIndexRangeKey = []byte("TokenIndexRange")
+
+	// logIndexRangeKey tracks the range of blocks covered by the log index.
+	logIndexRangeKey = []byte("LogIndexRange")
+
+	// stateSizeProgressKey tracks the last block and the backfill progress of the state size statistics.
+	stateSizeProgressKey = []byte("StateSizeProgress")
+
 	// Data item prefixes (use single byte to avoid mixing data types, avoid `i`, used for indexes).
 	headerPrefix       = []byte("h") // headerPrefix + num (uint64 big endian) + hash -> header
 	headerHashSuffix   = []byte("n") // headerPrefix + num (uint64 big endian) + headerHashSuffix -> hash
@@ -101,6 +119,20 @@ var (
 	trieNodeStoragePrefix = []byte("O") // trieNodeStoragePrefix + accountHash + hexPath -> trie node
 	stateIDPrefix         = []byte("L") // stateIDPrefix + state root -> state id
 
+	traceAddressPrefix = []byte("ta") // traceAddressPrefix + address + num (uint64 big endian) -> trace address flags
+
+	tokenTransferPrefix        = []byte("kt") // tokenTransferPrefix + num (uint64 big endian) + log index (uint32 big endian) -> token transfer
+	tokenAddressTransferPrefix = []byte("ka") // tokenAddressTransferPrefix + address + num (uint64 big endian) + log index (uint32 big endian) -> empty
+	tokenBalancePrefix         = []byte("kb") // tokenBalancePrefix + holder + token -> token standard + balance
+	tokenHolderPrefix          = []byte("kh") // tokenHolderPrefix + token + holder -> token standard + balance
+	tokenOwnedPrefix           = []byte("kn") // tokenOwnedPrefix + holder + token + token id -> empty
+
+	logIndexAddressPrefix = []byte("xa") // logIndexAddressPrefix + address + num (uint64 big endian) -> log indexes (uint32 big endian)
+	logIndexTopicPrefix   = []byte("xt") // logIndexTopicPrefix + topic + position + num (uint64 big endian) -> log indexes (uint32 big endian)
+
+	stateSizeAccountPrefix = []byte("za") // stateSizeAccountPrefix + account hash -> account storage size
+	stateSizeBlockPrefix   = []byte("zb") // stateSizeBlockPrefix + num (uint64 big endian) -> storage size changes of the block
+
 	PreimagePrefix = []byte("secure-key-")      // PreimagePrefix + hash -> preimage
 	configPrefix   = []byte("ethereum-config-") // config prefix for the db
 
@@ -171,6 +203,56 @@ func blockReceiptsKey(number uint64, hash common.Hash) []byte {
 	return append(append(blockReceiptsPrefix, encodeBlockNumber(number)...), hash.Bytes()...)
 }
 
+// traceAddressKey = traceAddressPrefix + address + num (uint64 big endian)
+func traceAddressKey(address common.Address, number uint64) []byte {
+	return append(append(append([]byte{}, traceAddressPrefix...), address.Bytes()...), encodeBlockNumber(number)...)
+}
+
+// tokenTransferKey = tokenTransferPrefix + num (uint64 big endian) + log index (uint32 big endian)
+func tokenTransferKey(number uint64, index uint32) []byte {
+	return append(append([]byte{}, tokenTransferPrefix...), encodeTokenTransferPosition(number, index)...)
+}
+
+// tokenAddressTransferKey = tokenAddressTransferPrefix + address + num (uint64 big endian) + log index (uint32 big endian)
+func tokenAddressTransferKey(address common.Address, number uint64, index uint32) []byte {
+	return append(append(append([]byte{}, tokenAddressTransferPrefix...), address.Bytes()...), encodeTokenTransferPosition(number, index)...)
+}
+
+// tokenBalanceKey = tokenBalancePrefix + holder + token
+func tokenBalanceKey(holder, token common.Address) []byte {
+	return append(append(append([]byte{}, tokenBalancePrefix...), holder.Bytes()...), token.Bytes()...)
+}
+
+// tokenHolderKey = tokenHolderPrefix + token + holder
+func tokenHolderKey(token, holder common.Address) []byte {
+	return append(append(append([]byte{}, tokenHolderPrefix...), token.Bytes()...), holder.Bytes()...)
+}
+
+// tokenOwnedKey = tokenOwnedPrefix + holder + token + token id
+func tokenOwnedKey(holder, token common.Address, id common.Hash) []byte {
+	return append(append(append(append([]byte{}, tokenOwnedPrefix...), holder.Bytes()...), token.Bytes()...), id.Bytes()...)
+}
+
+// logIndexAddressKey = logIndexAddressPrefix + address + num (uint64 big endian)
+func logIndexAddressKey(address common.Address, number uint64) []byte {
+	return append(append(append([]byte{}, logIndexAddressPrefix...), address.Bytes()...), encodeBlockNumber(number)...)
+}
+
+// logIndexTopicKey = logIndexTopicPrefix + topic + position + num (uint64 big endian)
+func logIndexTopicKey(topic common.Hash, position uint8, number uint64) []byte {
+	return append(append(append(append([]byte{}, logIndexTopicPrefix...), topic.Bytes()...), position), encodeBlockNumber(number)...)
+}
+
+// stateSizeAccountKey = stateSizeAccountPrefix + account hash
+func stateSizeAccountKey(accountHash common.Hash) []byte {
+	return append(append([]byte{}, stateSizeAccountPrefix...), accountHash.Bytes()...)
+}
+
+// stateSizeBlockKey = stateSizeBlockPrefix + num (uint64 big endian)
+func stateSizeBlockKey(number uint64) []byte {
+	return append(append([]byte{}, stateSizeBlockPrefix...), encodeBlockNumber(number)...)
+}
+
 // txLookupKey = txLookupPrefix + hash
 func txLookupKey(hash common.Hash) []byte {
 	return append(txLookupPrefix, hash.Bytes()...)
@@ -0,0 +1,694 @@
+// (c) 2024, Ava Labs, Inc. All rights reserved.
+// See the file LICENSE for licensing terms.
+
+package pruner
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/ava-labs/coreth/core/rawdb"
+	"github.com/ava-labs/coreth/core/types"
+	"github.com/ava-labs/coreth/metrics"
+	"github.com/ava-labs/coreth/trie"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/ethdb"
+	"github.com/ethereum/go-ethereum/log"
+	"github.com/ethereum/go-ethereum/rlp"
+	"golang.org/x/time/rate"
+)
+
+const (
+	// onlineBloomFilePrefix is the filename prefix of the state bloom filter
+	// of an online pruning session. It differs from [stateBloomFilePrefix] so
+	// the offline pruner never picks up the bloom of an online session.
+	onlineBloomFilePrefix = "onlinebloom"
+
+	// maxMarkAttempts is the number of times marking the live states is
+	// retried when their nodes are dereferenced from memory while marking.
+	maxMarkAttempts = 5
+)
+
+// sweepBatchSize is the maximum number of trie nodes deleted at once while
+// holding the sweep lock, which blocks the trie database from flushing.
+var sweepBatchSize = 1024
+
+var (
+	onlinePruningMarkedCounter     = metrics.NewRegisteredCounter("state/pruner/online/marked", nil)
+	onlinePruningSweptCounter      = metrics.NewRegisteredCounter("state/pruner/online/swept", nil)
+	onlinePruningSweptBytesCounter = metrics.NewRegisteredCounter("state/pruner/online/swept/bytes", nil)
+	onlinePruningProgressGauge     = metrics.NewRegisteredGaugeFloat64("state/pruner/online/progress", nil)
+
+	errOnlinePrunerNotStarted = errors.New("online pruner is not started")
+	errOnlinePruningRunning   = errors.New("online pruning is already running")
+	errOnlinePrunerStopped    = errors.New("online pruner stopped")
+	errOnlinePruningHeld      = errors.New("online pruning is held by a state reader")
+)
+
+// Phases of an online pruning session reported by [OnlinePruner.Status].
+const (
+	OnlinePruningIdle     = "idle"
+	OnlinePruningMarking  = "marking"
+	OnlinePruningSweeping = "sweeping"
+)
+
+// OnlineConfig includes the configurations for online pruning.
+type OnlineConfig struct {
+	Datadir   string  // The directory holding the state bloom of an interrupted session
+	BloomSize uint64  // The Megabytes of memory allocated to bloom-filter
+	SweepRate float64 // The maximum number of trie nodes deleted per second, unlimited if zero
+}
+
+// OnlineChain is the chain whose stale state is pruned by the online pruner.
+type OnlineChain interface {
+	TrieDB() *trie.Database
+	Genesis() *types.Block
+	LastAcceptedBlock() *types.Block
+	GetBlockByNumber(number uint64) *types.Block
+	ProcessingStateRoots() []common.Hash
+}
+
+// OnlineStatus is the progress of the current or last online pruning session.
+type OnlineStatus struct {
+	Phase      string      `json:"phase"`
+	Root       common.Hash `json:"root"`
+	Marked     uint64      `json:"marked"`
+	Swept      uint64      `json:"swept"`
+	SweptBytes uint64      `json:"sweptBytes"`
+	Progress   float64     `json:"progress"`
+	Error      string      `json:"error,omitempty"`
+}
+
+// OnlinePruner deletes the stale hash-scheme trie nodes in the background
+// while the chain keeps accepting blocks. A pruning session works in two
+// phases:
+//
+//   - marking: every trie node of the last committed state is added to a
+//     bloom filter, along with the nodes of the genesis state, the last
+//     accepted state and the processing states that are not part of it.
+//   - sweeping: the database is iterated and every trie node that is not in
+//     the bloom filter is deleted, in rate-limited batches.
+//
+// Every node flushed to disk by the trie database during a session is added
+// to the bloom filter before it is written, so the nodes of the states
+// accepted during a session are never deleted. The bloom filter is persisted
+// before sweeping starts and the sweep progress is persisted with each batch,
+// so an interrupted session is resumed when the pruner is started again.
+type OnlinePruner struct {
+	config OnlineConfig
+	db     ethdb.Database
+	chain  OnlineChain
+	triedb *trie.Database
+
+	// sweepLock is held while recording the nodes flushed by the trie database
+	// into the bloom, and while the sweep checks and deletes a batch of nodes,
+	// so that a node can never be deleted after it is recorded.
+	sweepLock sync.Mutex
+	bloom     *stateBloom
+
+	marked  atomic.Uint64 // Nodes marked by the running session
+	status  OnlineStatus
+	started bool
+	running bool
+	holds   int // Number of readers preventing sessions from starting
+	lock    sync.RWMutex
+
+	quit chan struct{}
+	wg   sync.WaitGroup
+}
+
+// NewOnlinePruner creates the online pruner of [chain]. The pruner must be
+// started before sessions can run.
+func NewOnlinePruner(db ethdb.Database, chain OnlineChain, config OnlineConfig) (*OnlinePruner, error) {
+	triedb := chain.TrieDB()
+	if scheme := triedb.Scheme(); scheme != rawdb.HashScheme {
+		return nil, fmt.Errorf("online pruning is not supported with the %s state scheme", scheme)
+	}
+	if config.Datadir == "" {
+		return nil, errors.New("online pruning requires a data directory")
+	}
+	return &OnlinePruner{
+		config: config,
+		db:     db,
+		chain:  chain,
+		triedb: triedb,
+		status: OnlineStatus{Phase: OnlinePruningIdle},
+		quit:   make(chan struct{}),
+	}, nil
+}
+
+// Start allows sessions to run and resumes the session interrupted by the
+// last shutdown, if any. It must only be called once the chain state is
+// complete, after state sync.
+func (p *OnlinePruner) Start() error {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	if p.started {
+		return nil
+	}
+	p.started = true
+
+	path, root, err := findOnlineBloomFilter(p.config.Datadir)
+	if err != nil {
+		return err
+	}
+	if path == "" {
+		// A sweep cannot be in progress without its bloom filter.
+		return rawdb.DeleteOnlinePruningProgress(p.db)
+	}
+	bloom, err := NewStateBloomFromDisk(path)
+	if err != nil {
+		log.Warn("Discarding unreadable online pruning bloom filter", "path", path, "err", err)
+		return p.discard()
+	}
+	log.Info("Resuming online pruning", "root", root, "path", path)
+	p.launch(bloom, root, true)
+	return nil
+}
+
+// Prune starts a new pruning session in the background.
+func (p *OnlinePruner) Prune() error {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	switch {
+	case !p.started:
+		return errOnlinePrunerNotStarted
+	case p.running:
+		return errOnlinePruningRunning
+	case p.holds > 0:
+		return errOnlinePruningHeld
+	}
+	select {
+	case <-p.quit:
+		return errOnlinePrunerStopped
+	default:
+	}
+	root, err := p.committedRoot()
+	if err != nil {
+		return err
+	}
+	bloom, err := newStateBloomWithSize(p.config.BloomSize)
+	if err != nil {
+		return err
+	}
+	log.Info("Starting online pruning", "root", root)
+	p.launch(bloom, root, false)
+	return nil
+}
+
+// Hold prevents new sessions from starting until the returned function is
+// called, so that the caller can read a state which is not kept by pruning
+// from a background goroutine. It fails if the pruner is not started, since
+// starting it may resume a session, or if a session is running.
+func (p *OnlinePruner) Hold() (func(), error) {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	switch {
+	case !p.started:
+		return nil, errOnlinePrunerNotStarted
+	case p.running:
+		return nil, errOnlinePruningRunning
+	}
+	p.holds++
+
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			p.lock.Lock()
+			p.holds--
+			p.lock.Unlock()
+		})
+	}, nil
+}
+
+// Status returns the progress of the current or last pruning session.
+func (p *OnlinePruner) Status() OnlineStatus {
+	p.lock.RLock()
+	defer p.lock.RUnlock()
+
+	status := p.status
+	status.Marked = p.marked.Load()
+	return status
+}
+
+// Stop interrupts the running session, which is resumed by the next Start.
+func (p *OnlinePruner) Stop() {
+	p.lock.Lock()
+	close(p.quit)
+	p.lock.Unlock()
+
+	p.wg.Wait()
+}
+
+// launch runs a session pruning all the state except [root] and the live
+// states with [bloom]. If [resumed], the nodes of [root] are already in
+// [bloom]. Assumes the lock is held.
+func (p *OnlinePruner) launch(bloom *stateBloom, root common.Hash, resumed bool) {
+	p.running = true
+	p.status = OnlineStatus{Phase: OnlinePruningMarking, Root: root}
+	p.marked.Store(0)
+	p.bloom = bloom
+
+	p.wg.Add(1)
+	go func() {
+		defer p.wg.Done()
+
+		err := p.run(root, resumed)
+		if err := p.triedb.SetWriteHook(nil); err != nil {
+			log.Error("Failed to unregister online pruning write hook", "err", err)
+		}
+		// A flush may still hold the hook after it is unregistered.
+		p.sweepLock.Lock()
+		p.bloom = nil
+		p.sweepLock.Unlock()
+
+		p.lock.Lock()
+		defer p.lock.Unlock()
+
+		p.running = false
+		p.status.Phase = OnlinePruningIdle
+		switch {
+		case errors.Is(err, errOnlinePrunerStopped):
+			log.Info("Online pruning interrupted", "root", root)
+		case err != nil:
+			log.Error("Online pruning failed", "root", root, "err", err)
+			p.status.Error = err.Error()
+			if err := p.discard(); err != nil {
+				log.Error("Failed to discard online pruning session", "err", err)
+			}
+		}
+	}()
+}
+
+// run marks the state of the session and sweeps the database.
+func (p *OnlinePruner) run(root common.Hash, resumed bool) error {
+	start := time.Now()
+
+	// Record the flushed nodes before marking, so the nodes written after the
+	// live states are marked are never missed.
+	if err := p.triedb.SetWriteHook(p.record); err != nil {
+		return err
+	}
+	if !resumed {
+		if err := p.markState(common.Hash{}, root); err != nil {
+			return fmt.Errorf("failed to mark committed state %s: %w", root, err)
+		}
+		filterName := onlineBloomFilterName(p.config.Datadir, root)
+		log.Info("Writing state bloom to disk", "name", filterName)
+		if err := p.bloom.Commit(filterName, filterName+stateBloomFileTempSuffix); err != nil {
+			return err
+		}
+	}
+	if err := p.markLive(root); err != nil {
+		return err
+	}
+	log.Info("Marked live state for online pruning", "root", root, "nodes", p.Status().Marked, "elapsed", common.PrettyDuration(time.Since(start)))
+
+	p.lock.Lock()
+	p.status.Phase = OnlinePruningSweeping
+	p.lock.Unlock()
+
+	if err := p.sweep(); err != nil {
+		return err
+	}
+	if err := p.discard(); err != nil {
+		return err
+	}
+	status := p.Status()
+	log.Info("Online pruning successful", "root", root, "nodes", status.Swept, "size", common.StorageSize(status.SweptBytes), "elapsed", common.PrettyDuration(time.Since(start)))
+	return nil
+}
+
+// record adds [hash] to the bloom before the trie database flushes the node.
+func (p *OnlinePruner) record(hash common.Hash) {
+	p.sweepLock.Lock()
+	defer p.sweepLock.Unlock()
+
+	if p.bloom != nil {
+		p.bloom.Put(hash.Bytes(), nil)
+	}
+}
+
+// committedRoot returns the root of the last accepted state which is
+// entirely on disk.
+func (p *OnlinePruner) committedRoot() (common.Hash, error) {
+	for number := p.chain.LastAcceptedBlock().NumberU64(); ; number-- {
+		block := p.chain.GetBlockByNumber(number)
+		if block == nil {
+			break
+		}
+		// The presence of the root indicates the presence of the entire trie,
+		// since the trie database flushes children before their parents.
+		if rawdb.HasLegacyTrieNode(p.db, block.Root()) {
+			return block.Root(), nil
+		}
+		if number == 0 {
+			break
+		}
+	}
+	return common.Hash{}, errors.New("no committed state found")
+}
+
+// markLive marks the nodes of the genesis, last accepted and processing
+// states that are not part of the committed state with [root]. These nodes
+// may have been flushed to disk before the session started recording the
+// flushed nodes.
+func (p *OnlinePruner) markLive(root common.Hash) error {
+	var err error
+	for attempt := 0; attempt < maxMarkAttempts; attempt++ {
+		if err = p.markLiveOnce(root); err == nil {
+			return nil
+		}
+		if errors.Is(err, errOnlinePrunerStopped) {
+			return err
+		}
+		// The last accepted state may be dereferenced from memory while it is
+		// marked, so retry with the states that are live now.
+		log.Debug("Retrying to mark live state for online pruning", "attempt", attempt, "err", err)
+	}
+	return fmt.Errorf("failed to mark live state: %w", err)
+}
+
+func (p *OnlinePruner) markLiveOnce(root common.Hash) error {
+	if genesis := p.chain.Genesis(); genesis != nil {
+		// The genesis state is not available after state sync.
+		if err := p.markState(root, genesis.Root()); err != nil && !isMissingRoot(err) {
+			return err
+		}
+	}
+	if err := p.markState(root, p.chain.LastAcceptedBlock().Root()); err != nil {
+		return err
+	}
+	for _, processing := range p.chain.ProcessingStateRoots() {
+		// The state of a rejected block is removed from memory.
+		if err := p.markState(root, processing); err != nil && !isMissingRoot(err) {
+			return err
+		}
+	}
+	return nil
+}
+
+// markState adds all the trie nodes of the state with [root] into the bloom,
+// skipping the subtries shared with the state with [base] if it is non-empty.
+func (p *OnlinePruner) markState(base, root common.Hash) error {
+	if base == root {
+		return nil
+	}
+	t, err := trie.NewStateTrie(trie.StateTrieID(root), p.triedb)
+	if err != nil {
+		return &missingRootError{err: err}
+	}
+	var baseTrie *trie.StateTrie
+	if base != (common.Hash{}) {
+		if baseTrie, err = trie.NewStateTrie(trie.StateTrieID(base), p.triedb); err != nil {
+			return err
+		}
+	}
+	it, err := p.nodeIterator(t, baseTrie)
+	if err != nil {
+		return err
+	}
+	for it.Next(true) {
+		if err := p.markNode(it.Hash()); err != nil {
+			return err
+		}
+		if !it.Leaf() {
+			continue
+		}
+		var acc types.StateAccount
+		if err := rlp.DecodeBytes(it.LeafBlob(), &acc); err != nil {
+			return err
+		}
+		if acc.Root == types.EmptyRootHash {
+			continue
+		}
+		owner := common.BytesToHash(it.LeafKey())
+		if err := p.markStorage(base, baseTrie, root, owner, acc.Root); err != nil {
+			return err
+		}
+	}
+	return it.Error()
+}
+
+// markStorage adds the trie nodes of the storage trie of [owner] in the state
+// with [root] into the bloom, skipping the subtries shared with the storage
+// trie of [owner] in the state of [baseTrie] if it is non-nil.
+func (p *OnlinePruner) markStorage(base common.Hash, baseTrie *trie.StateTrie, root, owner, storageRoot common.Hash) error {
+	t, err := trie.NewStateTrie(trie.StorageTrieID(root, owner, storageRoot), p.triedb)
+	if err != nil {
+		return err
+	}
+	var baseStorage *trie.StateTrie
+	if baseTrie != nil {
+		baseAcc, err := baseTrie.GetAccountByHash(owner)
+		if err != nil {
+			return err
+		}
+		if baseAcc != nil && baseAcc.Root == storageRoot {
+			return nil
+		}
+		if baseAcc != nil && baseAcc.Root != types.EmptyRootHash {
+			if baseStorage, err = trie.NewStateTrie(trie.StorageTrieID(base, owner, baseAcc.Root), p.triedb); err != nil {
+				return err
+			}
+		}
+	}
+	it, err := p.nodeIterator(t, baseStorage)
+	if err != nil {
+		return err
+	}
+	for it.Next(true) {
+		if err := p.markNode(it.Hash()); err != nil {
+			return err
+		}
+	}
+	return it.Error()
+}
+
+// nodeIterator returns an iterator over the nodes of [t] which are not in
+// [base] if it is non-nil.
+func (p *OnlinePruner) nodeIterator(t, base *trie.StateTrie) (trie.NodeIterator, error) {
+	it, err := t.NodeIterator(nil)
+	if err != nil || base == nil {
+		return it, err
+	}
+	baseIt, err := base.NodeIterator(nil)
+	if err != nil {
+		return nil, err
+	}
+	it, _ = trie.NewDifferenceIterator(baseIt, it)
+	return it, nil
+}
+
+// markNode adds [hash] into the bloom, unless it is the empty hash of an
+// embedded node.
+func (p *OnlinePruner) markNode(hash common.Hash) error {
+	if hash == (common.Hash{}) {
+		return nil
+	}
+	select {
+	case <-p.quit:
+		return errOnlinePrunerStopped
+	default:
+	}
+	p.bloom.Put(hash.Bytes(), nil)
+	p.marked.Add(1)
+	onlinePruningMarkedCounter.Inc(1)
+	return nil
+}
+
+// sweep deletes all the trie nodes not in the bloom, starting from the
+// persisted progress of an interrupted sweep.
+func (p *OnlinePruner) sweep() error {
+	var (
+		next    = rawdb.ReadOnlinePruningProgress(p.db)
+		limiter *rate.Limiter
+		logged  = time.Now()
+	)
+	if p.config.SweepRate > 0 {
+		limiter = rate.NewLimiter(rate.Limit(p.config.SweepRate), sweepBatchSize)
+	}
+	for {
+		keys, done, err := p.sweepCandidates(next)
+		if err != nil {
+			return err
+		}
+		if len(keys) > 0 {
+			if limiter != nil {
+				if err := p.wait(limiter, len(keys)); err != nil {
+					return err
+				}
+			}
+			// Appending a zero byte yields the key right after the last one.
+			next = append(common.CopyBytes(keys[len(keys)-1]), 0)
+			if err := p.sweepBatch(keys, next); err != nil {
+				return err
+			}
+		}
+		if done {
+			return nil
+		}
+		if time.Since(logged) > 8*time.Second {
+			status := p.Status()
+			log.Info("Pruning state data online", "nodes", status.Swept, "size", common.StorageSize(status.SweptBytes), "progress", fmt.Sprintf("%.2f%%", status.Progress*100))
+			logged = time.Now()
+		}
+		select {
+		case <-p.quit:
+			return errOnlinePrunerStopped
+		default:
+		}
+	}
+}
+
+// sweepCandidates returns up to [sweepBatchSize] trie node keys from [start]
+// which are not in the bloom, and whether the end of the database is reached.
+func (p *OnlinePruner) sweepCandidates(start []byte) ([][]byte, bool, error) {
+	it := p.db.NewIterator(nil, start)
+	defer it.Release()
+
+	var keys [][]byte
+	for it.Next() {
+		key := it.Key()
+		if len(key) != common.HashLength || p.bloom.Contain(key) {
+			continue
+		}
+		keys = append(keys, common.CopyBytes(key))
+		if len(keys) == sweepBatchSize {
+			return keys, false, it.Error()
+		}
+	}
+	if err := it.Error(); err != nil {
+		return nil, false, fmt.Errorf("failed to iterate db during online pruning: %w", err)
+	}
+	return keys, true, nil
+}
+
+// sweepBatch deletes the nodes of [keys] that are still not in the bloom and
+// persists [next] as the sweep progress.
+func (p *OnlinePruner) sweepBatch(keys [][]byte, next []byte) error {
+	p.sweepLock.Lock()
+	defer p.sweepLock.Unlock()
+
+	var (
+		batch = p.db.NewBatch()
+		count uint64
+		size  uint64
+	)
+	for _, key := range keys {
+		// The node may have been flushed since it was selected.
+		if p.bloom.Contain(key) {
+			continue
+		}
+		blob, err := p.db.Get(key)
+		if err != nil {
+			continue // Already deleted
+		}
+		if err := batch.Delete(key); err != nil {
+			return err
+		}
+		count++
+		size += uint64(len(key) + len(blob))
+	}
+	rawdb.WriteOnlinePruningProgress(batch, next)
+	if err := batch.Write(); err != nil {
+		return err
+	}
+	progress := float64(binary.BigEndian.Uint64(keys[len(keys)-1][:8])) / math.MaxUint64
+	onlinePruningSweptCounter.Inc(int64(count))
+	onlinePruningSweptBytesCounter.Inc(int64(size))
+	onlinePruningProgressGauge.Update(progress)
+
+	p.lock.Lock()
+	p.status.Swept += count
+	p.status.SweptBytes += size
+	p.status.Progress = progress
+	p.lock.Unlock()
+	return nil
+}
+
+// wait blocks until [limiter] allows deleting [n] nodes or the pruner stops.
+func (p *OnlinePruner) wait(limiter *rate.Limiter, n int) error {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	go func() {
+		select {
+		case <-p.quit:
+			cancel()
+		case <-ctx.Done():
+		}
+	}()
+	if err := limiter.WaitN(ctx, n); err != nil {
+		return errOnlinePrunerStopped
+	}
+	return nil
+}
+
+// discard deletes the persisted bloom and sweep progress of the session.
+func (p *OnlinePruner) discard() error {
+	path, _, err := findOnlineBloomFilter(p.config.Datadir)
+	if err != nil {
+		return err
+	}
+	// Delete the progress first, so a sweep is never resumed from the
+	// progress of another session.
+	if err := rawdb.DeleteOnlinePruningProgress(p.db); err != nil {
+		return err
+	}
+	if path == "" {
+		return nil
+	}
+	return os.RemoveAll(path)
+}
+
+// missingRootError is returned when the root of a state to mark is missing,
+// which happens for the states that are no longer live.
+type missingRootError struct {
+	err error
+}
+
+func (e *missingRootError) Error() string { return e.err.Error() }
+func (e *missingRootError) Unwrap() error { return e.err }
+
+func isMissingRoot(err error) bool {
+	var missing *missingRootError
+	return errors.As(err, &missing)
+}
+
+func onlineBloomFilterName(datadir string, hash common.Hash) string {
+	return filepath.Join(datadir, fmt.Sprintf("%s.%s.%s", onlineBloomFilePrefix, hash.Hex(), stateBloomFileSuffix))
+}
+
+func findOnlineBloomFilter(datadir string) (string, common.Hash, error) {
+	var (
+		path string
+		root common.Hash
+	)
+	if err := filepath.Walk(datadir, func(file string, info os.FileInfo, err error) error {
+		if info == nil || info.IsDir() {
+			return nil
+		}
+		name := filepath.Base(file)
+		if strings.HasPrefix(name, onlineBloomFilePrefix) && strings.HasSuffix(name, stateBloomFileSuffix) {
+			path = file
+			root = common.HexToHash(name[len(onlineBloomFilePrefix)+1 : len(name)-len(stateBloomFileSuffix)-1])
+		}
+		return nil
+	}); err != nil {
+		return "", common.Hash{}, err
+	}
+	return path, root, nil
+}
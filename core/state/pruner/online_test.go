@@ -0,0 +1,286 @@
+// (c) 2024, Ava Labs, Inc. All rights reserved.
+// See the file LICENSE for licensing terms.
+
+package pruner
+
+import (
+	"math/big"
+	"testing"
+	"time"
+
+	"github.com/ava-labs/coreth/core/rawdb"
+	"github.com/ava-labs/coreth/core/state"
+	"github.com/ava-labs/coreth/core/types"
+	"github.com/ava-labs/coreth/trie"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/ethdb"
+	"github.com/ethereum/go-ethereum/rlp"
+	"github.com/stretchr/testify/require"
+)
+
+type testOnlineChain struct {
+	triedb     *trie.Database
+	accepted   []*types.Block
+	processing []common.Hash
+}
+
+func (c *testOnlineChain) TrieDB() *trie.Database              { return c.triedb }
+func (c *testOnlineChain) Genesis() *types.Block               { return c.accepted[0] }
+func (c *testOnlineChain) LastAcceptedBlock() *types.Block     { return c.accepted[len(c.accepted)-1] }
+func (c *testOnlineChain) ProcessingStateRoots() []common.Hash { return c.processing }
+
+func (c *testOnlineChain) GetBlockByNumber(number uint64) *types.Block {
+	if number >= uint64(len(c.accepted)) {
+		return nil
+	}
+	return c.accepted[number]
+}
+
+// testOnlineState is a chain whose states, in order, are the genesis state,
+// a stale state, the last committed state, the last accepted state, which
+// is partially flushed to disk, and a processing state.
+type testOnlineState struct {
+	db    ethdb.Database
+	chain *testOnlineChain
+	live  []common.Hash
+
+	// staleNodes are the nodes which are only part of the stale state.
+	staleNodes map[common.Hash]struct{}
+}
+
+func newTestOnlineState(t *testing.T) *testOnlineState {
+	db := rawdb.NewMemoryDatabase()
+	triedb := trie.NewDatabase(db, trie.HashDefaults)
+	sdb := state.NewDatabaseWithNodeDB(db, triedb)
+
+	var (
+		root  = types.EmptyRootHash
+		roots []common.Hash
+		chain = &testOnlineChain{triedb: triedb}
+		addrs = []common.Address{{1}, {2}, {3}}
+	)
+	next := func(n int) common.Hash {
+		statedb, err := state.New(root, sdb, nil)
+		require.NoError(t, err)
+		for i, addr := range addrs {
+			statedb.SetBalance(addr, big.NewInt(int64(n*10+i+1)))
+			for slot := 0; slot < 16; slot++ {
+				statedb.SetState(addr, common.Hash{byte(slot)}, common.Hash{byte(n), byte(slot + 1)})
+			}
+		}
+		root, err = statedb.Commit(uint64(n), false, true)
+		require.NoError(t, err)
+		roots = append(roots, root)
+		return root
+	}
+	for i := 0; i < 3; i++ {
+		require.NoError(t, triedb.Commit(next(i), false))
+	}
+	next(3)
+	// Flush part of the last accepted state before the session starts.
+	_, dirty, _ := triedb.Size()
+	require.NoError(t, triedb.Cap(dirty/2))
+	require.False(t, rawdb.HasLegacyTrieNode(db, roots[3]))
+	processing := next(4)
+
+	for i, root := range roots[:4] {
+		chain.accepted = append(chain.accepted, types.NewBlockWithHeader(&types.Header{Number: big.NewInt(int64(i)), Root: root}))
+	}
+	chain.processing = []common.Hash{processing}
+	s := &testOnlineState{
+		db:    db,
+		chain: chain,
+		live:  []common.Hash{roots[0], roots[2], roots[3], processing},
+	}
+	s.staleNodes = s.nodes(t, roots[1])
+	for _, root := range s.live {
+		for hash := range s.nodes(t, root) {
+			delete(s.staleNodes, hash)
+		}
+	}
+	require.NotEmpty(t, s.staleNodes)
+	return s
+}
+
+// nodes returns the hashes of all the trie nodes of the state with [root].
+func (s *testOnlineState) nodes(t *testing.T, root common.Hash) map[common.Hash]struct{} {
+	nodes := make(map[common.Hash]struct{})
+	collect := func(id *trie.ID) []*trie.ID {
+		tr, err := trie.NewStateTrie(id, s.chain.triedb)
+		require.NoError(t, err)
+		it, err := tr.NodeIterator(nil)
+		require.NoError(t, err)
+
+		var storage []*trie.ID
+		for it.Next(true) {
+			if it.Hash() != (common.Hash{}) {
+				nodes[it.Hash()] = struct{}{}
+			}
+			if it.Leaf() && id.Owner == (common.Hash{}) {
+				var acc types.StateAccount
+				require.NoError(t, rlp.DecodeBytes(it.LeafBlob(), &acc))
+				if acc.Root != types.EmptyRootHash {
+					storage = append(storage, trie.StorageTrieID(root, common.BytesToHash(it.LeafKey()), acc.Root))
+				}
+			}
+		}
+		require.NoError(t, it.Error())
+		return storage
+	}
+	for _, id := range collect(trie.StateTrieID(root)) {
+		collect(id)
+	}
+	return nodes
+}
+
+// assertPruned checks that the live states are complete and that the nodes
+// only part of the stale state are deleted.
+func (s *testOnlineState) assertPruned(t *testing.T) {
+	for _, root := range s.live {
+		s.nodes(t, root)
+	}
+	for hash := range s.staleNodes {
+		require.False(t, rawdb.HasLegacyTrieNode(s.db, hash), "stale node %s not pruned", hash)
+	}
+}
+
+func waitOnlinePruning(t *testing.T, p *OnlinePruner, cond func(OnlineStatus) bool) OnlineStatus {
+	var status OnlineStatus
+	require.Eventually(t, func() bool {
+		status = p.Status()
+		return cond(status)
+	}, 10*time.Second, 10*time.Millisecond)
+	return status
+}
+
+func TestOnlinePruning(t *testing.T) {
+	s := newTestOnlineState(t)
+
+	p, err := NewOnlinePruner(s.db, s.chain, OnlineConfig{Datadir: t.TempDir(), BloomSize: 1})
+	require.NoError(t, err)
+	defer p.Stop()
+
+	require.ErrorIs(t, p.Prune(), errOnlinePrunerNotStarted)
+	require.NoError(t, p.Start())
+	require.NoError(t, p.Prune())
+
+	status := waitOnlinePruning(t, p, func(status OnlineStatus) bool {
+		return status.Phase == OnlinePruningIdle
+	})
+	require.Empty(t, status.Error)
+	require.Equal(t, s.chain.accepted[2].Root(), status.Root)
+	require.NotZero(t, status.Marked)
+	require.NotZero(t, status.Swept)
+	require.Nil(t, rawdb.ReadOnlinePruningProgress(s.db))
+	s.assertPruned(t)
+}
+
+func TestOnlinePruningHold(t *testing.T) {
+	s := newTestOnlineState(t)
+
+	defer func(size int) { sweepBatchSize = size }(sweepBatchSize)
+	sweepBatchSize = 1
+
+	// Throttle the sweep to keep the session running.
+	p, err := NewOnlinePruner(s.db, s.chain, OnlineConfig{Datadir: t.TempDir(), BloomSize: 1, SweepRate: 1})
+	require.NoError(t, err)
+	defer p.Stop()
+
+	_, err = p.Hold()
+	require.ErrorIs(t, err, errOnlinePrunerNotStarted)
+	require.NoError(t, p.Start())
+
+	// Sessions cannot start while the pruner is held.
+	release, err := p.Hold()
+	require.NoError(t, err)
+	release2, err := p.Hold()
+	require.NoError(t, err)
+	require.ErrorIs(t, p.Prune(), errOnlinePruningHeld)
+	release()
+	release()
+	require.ErrorIs(t, p.Prune(), errOnlinePruningHeld)
+	release2()
+
+	// The pruner cannot be held while a session is running.
+	require.NoError(t, p.Prune())
+	_, err = p.Hold()
+	require.ErrorIs(t, err, errOnlinePruningRunning)
+}
+
+func TestOnlinePruningRecordsFlushedNodes(t *testing.T) {
+	s := newTestOnlineState(t)
+
+	p, err := NewOnlinePruner(s.db, s.chain, OnlineConfig{Datadir: t.TempDir(), BloomSize: 1})
+	require.NoError(t, err)
+	defer p.Stop()
+
+	// Nodes flushed while the session runs must be kept even if they are not
+	// part of the marked state.
+	root := s.chain.processing[0]
+	flushed := make(map[common.Hash]struct{})
+	for hash := range s.nodes(t, root) {
+		if !rawdb.HasLegacyTrieNode(s.db, hash) {
+			flushed[hash] = struct{}{}
+		}
+	}
+	require.NotEmpty(t, flushed)
+
+	p.bloom, err = newStateBloomWithSize(1)
+	require.NoError(t, err)
+	require.NoError(t, s.chain.triedb.SetWriteHook(p.record))
+	require.NoError(t, s.chain.triedb.Commit(root, false))
+	require.NoError(t, s.chain.triedb.SetWriteHook(nil))
+
+	keys, done, err := p.sweepCandidates(nil)
+	require.NoError(t, err)
+	require.True(t, done)
+	for _, key := range keys {
+		require.NotContains(t, flushed, common.BytesToHash(key))
+	}
+	require.NoError(t, p.sweepBatch(keys, nil))
+	for hash := range flushed {
+		require.True(t, rawdb.HasLegacyTrieNode(s.db, hash))
+	}
+}
+
+func TestOnlinePruningResume(t *testing.T) {
+	s := newTestOnlineState(t)
+	datadir := t.TempDir()
+
+	defer func(size int) { sweepBatchSize = size }(sweepBatchSize)
+	sweepBatchSize = 1
+
+	// Throttle the sweep to interrupt it after the first deleted node.
+	p, err := NewOnlinePruner(s.db, s.chain, OnlineConfig{Datadir: datadir, BloomSize: 1, SweepRate: 1})
+	require.NoError(t, err)
+	require.NoError(t, p.Start())
+	require.NoError(t, p.Prune())
+	waitOnlinePruning(t, p, func(status OnlineStatus) bool {
+		return status.Swept > 0
+	})
+	p.Stop()
+
+	status := p.Status()
+	require.Equal(t, OnlinePruningIdle, status.Phase)
+	require.Empty(t, status.Error)
+	require.NotNil(t, rawdb.ReadOnlinePruningProgress(s.db))
+	path, root, err := findOnlineBloomFilter(datadir)
+	require.NoError(t, err)
+	require.NotEmpty(t, path)
+	require.Equal(t, s.chain.accepted[2].Root(), root)
+
+	// Starting again resumes the sweep from where it was interrupted.
+	p, err = NewOnlinePruner(s.db, s.chain, OnlineConfig{Datadir: datadir, BloomSize: 1})
+	require.NoError(t, err)
+	defer p.Stop()
+	require.NoError(t, p.Start())
+	status = waitOnlinePruning(t, p, func(status OnlineStatus) bool {
+		return status.Phase == OnlinePruningIdle
+	})
+	require.Empty(t, status.Error)
+	require.Nil(t, rawdb.ReadOnlinePruningProgress(s.db))
+	path, _, err = findOnlineBloomFilter(datadir)
+	require.NoError(t, err)
+	require.Empty(t, path)
+	s.assertPruned(t)
+}
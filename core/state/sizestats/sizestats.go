@@ -0,0 +1,656 @@
+// (c) 2024, Ava Labs, Inc. All rights reserved.
+// See the file LICENSE for licensing terms.
+
+// Package sizestats maintains the number of storage slots and the approximate
+// storage size of every account, to find the contracts driving state growth.
+package sizestats
+
+import (
+	"bytes"
+	"container/heap"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/ava-labs/coreth/core/rawdb"
+	"github.com/ava-labs/coreth/core/state"
+	"github.com/ava-labs/coreth/core/state/snapshot"
+	"github.com/ava-labs/coreth/core/types"
+	"github.com/ava-labs/coreth/metrics"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/ethdb"
+	"github.com/ethereum/go-ethereum/log"
+)
+
+// backfillBatchSize is the maximum number of snapshot entries counted by the
+// backfill at once, while blocking the accepted blocks from being applied.
+var backfillBatchSize = 10_000
+
+const (
+	// backfillRetryInterval is the interval at which the backfill checks again
+	// whether the snapshot holds the state of the last applied block.
+	backfillRetryInterval = 5 * time.Second
+
+	// logInterval is the interval between two backfill progress logs.
+	logInterval = 8 * time.Second
+)
+
+var (
+	accountsGauge = metrics.NewRegisteredGauge("state/size/accounts", nil)
+	slotsGauge    = metrics.NewRegisteredGauge("state/size/slots", nil)
+	bytesGauge    = metrics.NewRegisteredGauge("state/size/bytes", nil)
+
+	errInvalidRange = errors.New("invalid block range")
+)
+
+// Config includes the configurations of the state size statistics.
+type Config struct {
+	Retention uint64 // Number of recent blocks whose storage changes are kept to report growth, 0 keeps all
+}
+
+// Tracker maintains the storage size of every account of the last accepted
+// state, along with the storage changes of each accepted block.
+//
+// The storage changes of a block are computed from the mutated slots and their
+// original values when its state is committed, and applied when the block is
+// accepted. When the statistics do not follow the accepted blocks, because
+// they were just enabled, after state sync or after an unclean shutdown, they
+// are backfilled from the snapshot in the background, in account hash order.
+// Until the backfill is complete, the accepted changes are only applied to
+// the accounts already counted.
+type Tracker struct {
+	config Config
+	db     ethdb.Database
+
+	pending     map[common.Hash][]state.StorageSizeChange // Changes of the blocks committed but not accepted or rejected yet
+	pendingLock sync.Mutex
+
+	// lock is held while the accepted blocks are applied, which includes
+	// flattening their snapshot, and while the backfill reads the snapshot,
+	// so the backfill reads the state of the last applied block.
+	progress rawdb.StateSizeProgress
+	lock     sync.RWMutex
+
+	logged time.Time
+	wake   chan struct{}
+	quit   chan struct{}
+	wg     sync.WaitGroup
+}
+
+// New creates the state size statistics of the chain with [lastAccepted] and
+// starts backfilling them if they do not follow [lastAccepted].
+func New(db ethdb.Database, config Config, lastAccepted *types.Block) (*Tracker, error) {
+	t := &Tracker{
+		config:  config,
+		db:      db,
+		pending: make(map[common.Hash][]state.StorageSizeChange),
+		wake:    make(chan struct{}, 1),
+		quit:    make(chan struct{}),
+	}
+	if progress := rawdb.ReadStateSizeProgress(db); progress != nil && progress.Hash == lastAccepted.Hash() {
+		t.progress = *progress
+	} else {
+		t.reset(lastAccepted)
+	}
+	if number := lastAccepted.NumberU64(); config.Retention != 0 && number >= config.Retention {
+		if err := rawdb.DeleteStateSizeChanges(db, number-config.Retention+1); err != nil {
+			return nil, fmt.Errorf("failed to delete expired state size changes: %w", err)
+		}
+	}
+	t.updateMetrics()
+
+	t.wg.Add(1)
+	go t.backfill()
+	return t, nil
+}
+
+// Stop interrupts the backfill, which is resumed by the next tracker.
+func (t *Tracker) Stop() {
+	close(t.quit)
+	t.wg.Wait()
+}
+
+// Commit records the storage [changes] committed by the block with [hash],
+// to be applied if the block is accepted.
+func (t *Tracker) Commit(hash common.Hash, changes []state.StorageSizeChange) {
+	if changes == nil {
+		changes = []state.StorageSizeChange{}
+	}
+	t.pendingLock.Lock()
+	defer t.pendingLock.Unlock()
+
+	t.pending[hash] = changes
+}
+
+// Reject discards the storage changes of the block with [hash].
+func (t *Tracker) Reject(hash common.Hash) {
+	t.pendingLock.Lock()
+	defer t.pendingLock.Unlock()
+
+	delete(t.pending, hash)
+}
+
+// Accept applies the storage changes of [block] once [flatten] has flattened
+// its snapshot to disk. The statistics are backfilled again if the changes of
+// [block] are unknown or the parent of [block] is not the last applied block.
+func (t *Tracker) Accept(block *types.Block, flatten func() error) error {
+	t.pendingLock.Lock()
+	changes, ok := t.pending[block.Hash()]
+	delete(t.pending, block.Hash())
+	t.pendingLock.Unlock()
+
+	t.lock.Lock()
+	defer t.lock.Unlock()
+
+	// The previous storage of the destructed accounts is read before the
+	// snapshot of the parent is flattened.
+	applicable := ok && t.progress.Hash == block.ParentHash()
+	var destructed map[common.Hash]rawdb.StateSizeAccount
+	if applicable {
+		destructed = t.destructedSizes(block.ParentHash(), changes)
+	}
+	if err := flatten(); err != nil {
+		return err
+	}
+	if !applicable {
+		log.Info("Backfilling state size statistics", "number", block.NumberU64(), "hash", block.Hash(), "last", t.progress.Number)
+		t.reset(block)
+		return nil
+	}
+	return t.apply(block, changes, destructed)
+}
+
+// counted returns whether the storage of the account with [accountHash] is
+// counted by the statistics. Assumes the lock is held.
+func (t *Tracker) counted(accountHash common.Hash) bool {
+	if !t.progress.Backfilling {
+		return true
+	}
+	marker := t.progress.Marker
+	if len(marker) > common.HashLength {
+		marker = marker[:common.HashLength]
+	}
+	return bytes.Compare(accountHash[:], marker) < 0
+}
+
+// destructedSizes returns the storage size of the accounts destructed by
+// [changes] before the block with [parent] is flattened. Assumes the lock is
+// held.
+func (t *Tracker) destructedSizes(parent common.Hash, changes []state.StorageSizeChange) map[common.Hash]rawdb.StateSizeAccount {
+	sizes := make(map[common.Hash]rawdb.StateSizeAccount)
+	for _, change := range changes {
+		if !change.Destructed {
+			continue
+		}
+		accountHash := crypto.Keccak256Hash(change.Address[:])
+		switch {
+		case t.counted(accountHash):
+			if account := rawdb.ReadStateSizeAccount(t.db, accountHash); account != nil {
+				sizes[accountHash] = *account
+			}
+		case rawdb.ReadSnapshotBlockHash(t.db) == parent:
+			var size rawdb.StateSizeAccount
+			budget := math.MaxInt
+			if _, _, err := countStorage(t.db, accountHash, nil, &size, &budget); err != nil {
+				log.Warn("Failed to count destructed storage", "address", change.Address, "err", err)
+				continue
+			}
+			sizes[accountHash] = size
+		}
+	}
+	return sizes
+}
+
+// apply writes the storage [changes] of the accepted [block]. Assumes the lock
+// is held.
+func (t *Tracker) apply(block *types.Block, changes []state.StorageSizeChange, destructed map[common.Hash]rawdb.StateSizeAccount) error {
+	var (
+		batch    = t.db.NewBatch()
+		progress = t.progress
+		records  = make([]rawdb.StateSizeChange, 0, len(changes))
+	)
+	for _, change := range changes {
+		accountHash := crypto.Keccak256Hash(change.Address[:])
+		record := rawdb.StateSizeChange{
+			Address:      change.Address,
+			SlotsAdded:   change.SlotsAdded,
+			SlotsRemoved: change.SlotsRemoved,
+			BytesAdded:   change.BytesAdded,
+			BytesRemoved: change.BytesRemoved,
+		}
+		if change.Destructed {
+			size := destructed[accountHash]
+			record.SlotsRemoved += size.Slots
+			record.BytesRemoved += size.Bytes
+		}
+		records = append(records, record)
+
+		if !t.counted(accountHash) {
+			// The slots counted so far of a partially counted account may
+			// have changed, so it is counted again from its first slot.
+			if len(progress.Marker) > common.HashLength && bytes.Equal(progress.Marker[:common.HashLength], accountHash[:]) {
+				progress.Marker, progress.Partial = accountHash.Bytes(), rawdb.StateSizeAccount{}
+			}
+			continue
+		}
+		var prev rawdb.StateSizeAccount
+		if account := rawdb.ReadStateSizeAccount(t.db, accountHash); account != nil {
+			prev = *account
+		}
+		next := rawdb.StateSizeAccount{
+			Address: change.Address,
+			Slots:   sub(prev.Slots+record.SlotsAdded, record.SlotsRemoved),
+			Bytes:   sub(prev.Bytes+record.BytesAdded, record.BytesRemoved),
+		}
+		if change.Destructed {
+			// The removed storage may not match the counted storage.
+			next.Slots, next.Bytes = change.SlotsAdded, change.BytesAdded
+		}
+		removeTotals(&progress, prev)
+		addTotals(&progress, next)
+		if next.Slots == 0 {
+			rawdb.DeleteStateSizeAccount(batch, accountHash)
+		} else {
+			rawdb.WriteStateSizeAccount(batch, accountHash, &next)
+		}
+	}
+	number := block.NumberU64()
+	if len(records) != 0 {
+		rawdb.WriteStateSizeChanges(batch, number, records)
+	}
+	if t.config.Retention != 0 && number >= t.config.Retention {
+		rawdb.DeleteStateSizeChangesAt(batch, number-t.config.Retention)
+	}
+	progress.Number, progress.Hash = number, block.Hash()
+	rawdb.WriteStateSizeProgress(batch, &progress)
+	if err := batch.Write(); err != nil {
+		return fmt.Errorf("failed to write state size statistics: %w", err)
+	}
+	t.progress = progress
+	t.updateMetrics()
+	if progress.Backfilling {
+		t.signal()
+	}
+	return nil
+}
+
+// reset starts backfilling the statistics of the state of [block]. The stale
+// statistics of the accounts are replaced as the backfill progresses. Assumes
+// the lock is held.
+func (t *Tracker) reset(block *types.Block) {
+	t.progress = rawdb.StateSizeProgress{
+		Number:      block.NumberU64(),
+		Hash:        block.Hash(),
+		Backfilling: true,
+	}
+	rawdb.WriteStateSizeProgress(t.db, &t.progress)
+	t.updateMetrics()
+	t.signal()
+}
+
+func (t *Tracker) signal() {
+	select {
+	case t.wake <- struct{}{}:
+	default:
+	}
+}
+
+func (t *Tracker) updateMetrics() {
+	accountsGauge.Update(int64(t.progress.Accounts))
+	slotsGauge.Update(int64(t.progress.Slots))
+	bytesGauge.Update(int64(t.progress.Bytes))
+}
+
+// backfill counts the storage of the accounts from the snapshot whenever the
+// statistics are reset.
+func (t *Tracker) backfill() {
+	defer t.wg.Done()
+
+	for {
+		more, err := t.backfillBatch()
+		if err != nil {
+			log.Error("Failed to backfill state size statistics", "err", err)
+		}
+		if more && err == nil {
+			select {
+			case <-t.quit:
+				return
+			default:
+			}
+			continue
+		}
+		select {
+		case <-t.quit:
+			return
+		case <-t.wake:
+		case <-time.After(backfillRetryInterval):
+		}
+	}
+}
+
+// backfillBatch counts the storage of the next accounts from the snapshot. It
+// returns whether more accounts remain to be counted.
+func (t *Tracker) backfillBatch() (bool, error) {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+
+	if !t.progress.Backfilling {
+		return false, nil
+	}
+	// The snapshot on disk must be complete and hold the state of the last
+	// applied block.
+	if rawdb.ReadSnapshotBlockHash(t.db) != t.progress.Hash || !snapshot.IsGenerated(t.db) {
+		return false, nil
+	}
+	var (
+		progress = t.progress
+		budget   = backfillBatchSize
+		start    = progress.Marker
+		end      []byte // Exclusive end of the accounts counted by the batch, nil if all are counted
+		sizes    = make(map[common.Hash]rawdb.StateSizeAccount)
+	)
+	if len(start) > common.HashLength {
+		start = start[:common.HashLength]
+	}
+	it := rawdb.NewKeyLengthIterator(t.db.NewIterator(rawdb.SnapshotAccountPrefix, start), len(rawdb.SnapshotAccountPrefix)+common.HashLength)
+	defer it.Release()
+
+	for it.Next() {
+		accountHash := common.BytesToHash(it.Key()[len(rawdb.SnapshotAccountPrefix):])
+		if budget <= 0 {
+			end = accountHash.Bytes()
+			progress.Marker, progress.Partial = end, rawdb.StateSizeAccount{}
+			break
+		}
+		var (
+			size      rawdb.StateSizeAccount
+			slotStart []byte
+		)
+		if len(progress.Marker) > common.HashLength && bytes.Equal(progress.Marker[:common.HashLength], accountHash[:]) {
+			size, slotStart = progress.Partial, progress.Marker[common.HashLength:]
+		}
+		complete, next, err := countStorage(t.db, accountHash, slotStart, &size, &budget)
+		if err != nil {
+			return false, err
+		}
+		if !complete {
+			end = accountHash.Bytes()
+			progress.Marker, progress.Partial = append(accountHash.Bytes(), next...), size
+			break
+		}
+		budget--
+		if size.Slots != 0 {
+			sizes[accountHash] = size
+		}
+	}
+	if err := it.Error(); err != nil {
+		return false, err
+	}
+
+	// Replace the stale statistics of the accounts counted by the batch.
+	batch := t.db.NewBatch()
+	addresses := make(map[common.Hash]common.Address)
+	err := rawdb.IterateStateSizeAccounts(t.db, start, func(accountHash common.Hash, account *rawdb.StateSizeAccount) bool {
+		if end != nil && bytes.Compare(accountHash[:], end) >= 0 {
+			return false
+		}
+		addresses[accountHash] = account.Address
+		rawdb.DeleteStateSizeAccount(batch, accountHash)
+		return true
+	})
+	if err != nil {
+		return false, err
+	}
+	for accountHash, size := range sizes {
+		size.Address = addresses[accountHash]
+		if size.Address == (common.Address{}) {
+			if preimage := rawdb.ReadPreimage(t.db, accountHash); len(preimage) == common.AddressLength {
+				size.Address = common.BytesToAddress(preimage)
+			}
+		}
+		rawdb.WriteStateSizeAccount(batch, accountHash, &size)
+		addTotals(&progress, size)
+	}
+	if end == nil {
+		progress.Backfilling, progress.Marker, progress.Partial = false, nil, rawdb.StateSizeAccount{}
+	}
+	rawdb.WriteStateSizeProgress(batch, &progress)
+	if err := batch.Write(); err != nil {
+		return false, err
+	}
+	t.progress = progress
+	t.updateMetrics()
+
+	if !progress.Backfilling {
+		log.Info("Backfilled state size statistics", "number", progress.Number, "accounts", progress.Accounts, "slots", progress.Slots, "size", common.StorageSize(progress.Bytes))
+	} else if time.Since(t.logged) > logInterval {
+		log.Info("Backfilling state size statistics", "number", progress.Number, "progress", markerProgress(progress.Marker), "accounts", progress.Accounts, "slots", progress.Slots)
+		t.logged = time.Now()
+	}
+	return progress.Backfilling, nil
+}
+
+// countStorage adds the slots of the account with [accountHash] from [start]
+// in the snapshot to [size], until [budget] slots are counted. If the storage
+// is not entirely counted, it returns the hash of the next slot to count.
+func countStorage(db ethdb.Iteratee, accountHash common.Hash, start []byte, size *rawdb.StateSizeAccount, budget *int) (bool, []byte, error) {
+	prefix := append(append([]byte{}, rawdb.SnapshotStoragePrefix...), accountHash.Bytes()...)
+	it := rawdb.NewKeyLengthIterator(db.NewIterator(prefix, start), len(prefix)+common.HashLength)
+	defer it.Release()
+
+	for it.Next() {
+		if *budget <= 0 {
+			return false, common.CopyBytes(it.Key()[len(prefix):]), nil
+		}
+		size.Slots++
+		size.Bytes += state.StorageSlotOverhead + uint64(len(it.Value()))
+		*budget--
+	}
+	return true, nil, it.Error()
+}
+
+func addTotals(progress *rawdb.StateSizeProgress, account rawdb.StateSizeAccount) {
+	if account.Slots != 0 {
+		progress.Accounts++
+	}
+	progress.Slots += account.Slots
+	progress.Bytes += account.Bytes
+}
+
+func removeTotals(progress *rawdb.StateSizeProgress, account rawdb.StateSizeAccount) {
+	if account.Slots != 0 {
+		progress.Accounts = sub(progress.Accounts, 1)
+	}
+	progress.Slots = sub(progress.Slots, account.Slots)
+	progress.Bytes = sub(progress.Bytes, account.Bytes)
+}
+
+// sub returns a - b, or 0 if b is larger than a.
+func sub(a, b uint64) uint64 {
+	if b > a {
+		return 0
+	}
+	return a - b
+}
+
+// markerProgress returns the fraction of the account hash space before
+// [marker].
+func markerProgress(marker []byte) float64 {
+	var prefix [8]byte
+	copy(prefix[:], marker)
+	return float64(binary.BigEndian.Uint64(prefix[:])) / math.Pow(2, 64)
+}
+
+// AccountSize is the storage size of an account.
+type AccountSize struct {
+	Address     *common.Address `json:"address"` // Nil if unknown
+	AccountHash common.Hash     `json:"accountHash"`
+	Slots       uint64          `json:"slots"`
+	Bytes       uint64          `json:"bytes"`
+}
+
+// Stats are the state size statistics of the last applied block.
+type Stats struct {
+	Number           uint64        `json:"number"`
+	Hash             common.Hash   `json:"hash"`
+	Accounts         uint64        `json:"accounts"` // Accounts with a non-empty storage
+	Slots            uint64        `json:"slots"`
+	Bytes            uint64        `json:"bytes"`
+	Backfilling      bool          `json:"backfilling"`
+	BackfillProgress float64       `json:"backfillProgress"`
+	Largest          []AccountSize `json:"largest"` // Accounts with the largest storage, in descending size
+}
+
+// Stats returns the totals of the statistics and the [count] accounts with
+// the largest storage. While backfilling, the totals and the accounts only
+// cover the accounts counted so far.
+func (t *Tracker) Stats(count int) (*Stats, error) {
+	t.lock.RLock()
+	progress := t.progress
+	t.lock.RUnlock()
+
+	stats := &Stats{
+		Number:      progress.Number,
+		Hash:        progress.Hash,
+		Accounts:    progress.Accounts,
+		Slots:       progress.Slots,
+		Bytes:       progress.Bytes,
+		Backfilling: progress.Backfilling,
+		Largest:     []AccountSize{},
+	}
+	var end []byte
+	if progress.Backfilling {
+		stats.BackfillProgress = markerProgress(progress.Marker)
+		end = progress.Marker
+		if len(end) > common.HashLength {
+			end = end[:common.HashLength]
+		}
+	}
+	if count <= 0 {
+		return stats, nil
+	}
+	largest := &accountSizeHeap{}
+	err := rawdb.IterateStateSizeAccounts(t.db, nil, func(accountHash common.Hash, account *rawdb.StateSizeAccount) bool {
+		if progress.Backfilling && bytes.Compare(accountHash[:], end) >= 0 {
+			return false
+		}
+		size := AccountSize{AccountHash: accountHash, Slots: account.Slots, Bytes: account.Bytes}
+		if account.Address != (common.Address{}) {
+			address := account.Address
+			size.Address = &address
+		}
+		if largest.Len() < count {
+			heap.Push(largest, size)
+		} else if size.Bytes > (*largest)[0].Bytes {
+			(*largest)[0] = size
+			heap.Fix(largest, 0)
+		}
+		return true
+	})
+	if err != nil {
+		return nil, err
+	}
+	for largest.Len() > 0 {
+		stats.Largest = append(stats.Largest, heap.Pop(largest).(AccountSize))
+	}
+	for i, j := 0, len(stats.Largest)-1; i < j; i, j = i+1, j-1 {
+		stats.Largest[i], stats.Largest[j] = stats.Largest[j], stats.Largest[i]
+	}
+	return stats, nil
+}
+
+// accountSizeHeap is a min-heap of account sizes by bytes.
+type accountSizeHeap []AccountSize
+
+func (h accountSizeHeap) Len() int           { return len(h) }
+func (h accountSizeHeap) Less(i, j int) bool { return h[i].Bytes < h[j].Bytes }
+func (h accountSizeHeap) Swap(i, j int)      { h[i], h[j] = h[j], h[i] }
+func (h *accountSizeHeap) Push(x any)        { *h = append(*h, x.(AccountSize)) }
+func (h *accountSizeHeap) Pop() any {
+	old := *h
+	x := old[len(old)-1]
+	*h = old[:len(old)-1]
+	return x
+}
+
+// AccountGrowth is the storage growth of an account over a range of blocks.
+type AccountGrowth struct {
+	Address      common.Address `json:"address"`
+	SlotsAdded   uint64         `json:"slotsAdded"`
+	SlotsRemoved uint64         `json:"slotsRemoved"`
+	BytesAdded   uint64         `json:"bytesAdded"`
+	BytesRemoved uint64         `json:"bytesRemoved"`
+}
+
+func (g *AccountGrowth) add(change *rawdb.StateSizeChange) {
+	g.SlotsAdded += change.SlotsAdded
+	g.SlotsRemoved += change.SlotsRemoved
+	g.BytesAdded += change.BytesAdded
+	g.BytesRemoved += change.BytesRemoved
+}
+
+// growth returns the net number of bytes added.
+func (g *AccountGrowth) growth() int64 {
+	return int64(g.BytesAdded) - int64(g.BytesRemoved)
+}
+
+// Growth is the storage growth of the state over a range of blocks.
+type Growth struct {
+	From    uint64          `json:"from"`
+	To      uint64          `json:"to"`
+	Total   AccountGrowth   `json:"total"`   // Growth of all the accounts, with a zero address
+	Largest []AccountGrowth `json:"largest"` // Accounts with the largest net growth in bytes, in descending growth
+}
+
+// Growth returns the storage growth of the state over the blocks [from, to]
+// and the [count] accounts with the largest growth. Only the blocks accepted
+// while the statistics are enabled are covered, and only the last
+// [Config.Retention] blocks are kept.
+func (t *Tracker) Growth(from, to uint64, count int) (*Growth, error) {
+	t.lock.RLock()
+	last := t.progress.Number
+	t.lock.RUnlock()
+
+	if from > to || to > last {
+		return nil, fmt.Errorf("%w [%d, %d], last block is %d", errInvalidRange, from, to, last)
+	}
+	if t.config.Retention != 0 && last >= t.config.Retention && from <= last-t.config.Retention {
+		return nil, fmt.Errorf("%w [%d, %d], storage changes are only kept for the last %d blocks", errInvalidRange, from, to, t.config.Retention)
+	}
+	growth := &Growth{From: from, To: to, Largest: []AccountGrowth{}}
+	accounts := make(map[common.Address]*AccountGrowth)
+	err := rawdb.IterateStateSizeChanges(t.db, from, to, func(_ uint64, changes []rawdb.StateSizeChange) {
+		for i := range changes {
+			change := &changes[i]
+			account := accounts[change.Address]
+			if account == nil {
+				account = &AccountGrowth{Address: change.Address}
+				accounts[change.Address] = account
+			}
+			account.add(change)
+			growth.Total.add(change)
+		}
+	})
+	if err != nil {
+		return nil, err
+	}
+	for _, account := range accounts {
+		growth.Largest = append(growth.Largest, *account)
+	}
+	sort.Slice(growth.Largest, func(i, j int) bool {
+		a, b := &growth.Largest[i], &growth.Largest[j]
+		if a.growth() != b.growth() {
+			return a.growth() > b.growth()
+		}
+		return bytes.Compare(a.Address[:], b.Address[:]) < 0
+	})
+	if len(growth.Largest) > count {
+		growth.Largest = growth.Largest[:max(count, 0)]
+	}
+	return growth, nil
+}
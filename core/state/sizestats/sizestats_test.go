@@ -0,0 +1,180 @@
+// (c) 2024, Ava Labs, Inc. All rights reserved.
+// See the file LICENSE for licensing terms.
+
+package sizestats
+
+import (
+	"math/big"
+	"testing"
+	"time"
+
+	"github.com/ava-labs/coreth/core/rawdb"
+	"github.com/ava-labs/coreth/core/state"
+	"github.com/ava-labs/coreth/core/state/snapshot"
+	"github.com/ava-labs/coreth/core/types"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/ethdb"
+	"github.com/stretchr/testify/require"
+)
+
+// testSlotSize is the size of the slots written by writeTestStorage.
+const testSlotSize = state.StorageSlotOverhead + 2
+
+// newTestBlock creates a block with [number] on top of [parent].
+func newTestBlock(number int64, parent common.Hash) *types.Block {
+	return types.NewBlockWithHeader(&types.Header{Number: big.NewInt(number), ParentHash: parent})
+}
+
+// writeTestStorage writes [slots] slots for [address] to the snapshot,
+// replacing its previous storage.
+func writeTestStorage(db ethdb.KeyValueStore, address common.Address, slots int) {
+	accountHash := crypto.Keccak256Hash(address[:])
+	rawdb.WriteAccountSnapshot(db, accountHash, []byte{0x01})
+	rawdb.WritePreimages(db, map[common.Hash][]byte{accountHash: address[:]})
+	it := db.NewIterator(append(rawdb.SnapshotStoragePrefix, accountHash[:]...), nil)
+	for it.Next() {
+		rawdb.DeleteStorageSnapshot(db, accountHash, common.BytesToHash(it.Key()[len(rawdb.SnapshotStoragePrefix)+common.HashLength:]))
+	}
+	it.Release()
+	for i := 0; i < slots; i++ {
+		rawdb.WriteStorageSnapshot(db, accountHash, crypto.Keccak256Hash([]byte{byte(i)}), []byte{0x81, byte(i)})
+	}
+}
+
+// newTestSnapshot creates a snapshot of the state of [block] in which the
+// account with address {i} has i slots.
+func newTestSnapshot(accounts int, block common.Hash) ethdb.Database {
+	db := rawdb.NewMemoryDatabase()
+	for i := 0; i < accounts; i++ {
+		writeTestStorage(db, common.Address{byte(i)}, i)
+	}
+	rawdb.WriteSnapshotBlockHash(db, block)
+	snapshot.ResetSnapshotGeneration(db)
+	return db
+}
+
+func waitForBackfill(t *testing.T, tracker *Tracker) *Stats {
+	var stats *Stats
+	require.Eventually(t, func() bool {
+		var err error
+		stats, err = tracker.Stats(3)
+		require.NoError(t, err)
+		return !stats.Backfilling
+	}, 10*time.Second, time.Millisecond)
+	return stats
+}
+
+func TestBackfill(t *testing.T) {
+	defer func(size int) { backfillBatchSize = size }(backfillBatchSize)
+	backfillBatchSize = 4
+
+	require := require.New(t)
+	genesis := newTestBlock(0, common.Hash{})
+	db := newTestSnapshot(10, genesis.Hash())
+	tracker, err := New(db, Config{}, genesis)
+	require.NoError(err)
+	defer func() { tracker.Stop() }()
+
+	stats := waitForBackfill(t, tracker)
+	require.Equal(uint64(9), stats.Accounts)
+	require.Equal(uint64(45), stats.Slots)
+	require.Equal(uint64(45*testSlotSize), stats.Bytes)
+	require.Len(stats.Largest, 3)
+	for i, size := range stats.Largest {
+		address := common.Address{byte(9 - i)}
+		require.Equal(&address, size.Address)
+		require.Equal(uint64(9-i), size.Slots)
+		require.Equal(uint64(9-i)*testSlotSize, size.Bytes)
+	}
+
+	// The statistics are backfilled again if they do not follow the last
+	// accepted block, and the stale accounts are replaced.
+	tracker.Stop()
+	block := newTestBlock(1, genesis.Hash())
+	writeTestStorage(db, common.Address{9}, 0)
+	writeTestStorage(db, common.Address{10}, 20)
+	rawdb.WriteSnapshotBlockHash(db, block.Hash())
+	tracker, err = New(db, Config{}, block)
+	require.NoError(err)
+
+	stats = waitForBackfill(t, tracker)
+	require.Equal(uint64(9), stats.Accounts)
+	require.Equal(uint64(56), stats.Slots)
+	require.Equal(uint64(20), stats.Largest[0].Slots)
+	require.Equal(uint64(8), stats.Largest[1].Slots)
+	require.Nil(rawdb.ReadStateSizeAccount(db, crypto.Keccak256Hash(common.Address{9}.Bytes())))
+}
+
+func TestAccept(t *testing.T) {
+	require := require.New(t)
+	genesis := newTestBlock(0, common.Hash{})
+	db := newTestSnapshot(4, genesis.Hash())
+	tracker, err := New(db, Config{Retention: 2}, genesis)
+	require.NoError(err)
+	defer tracker.Stop()
+	waitForBackfill(t, tracker)
+
+	// flatten moves the snapshot on disk to [block].
+	flatten := func(block *types.Block) func() error {
+		return func() error {
+			rawdb.WriteSnapshotBlockHash(db, block.Hash())
+			return nil
+		}
+	}
+	// Account {1} grows, {2} shrinks, {3} is destructed and recreated with one
+	// slot and {4} is created.
+	block1 := newTestBlock(1, genesis.Hash())
+	changes := []state.StorageSizeChange{
+		{Address: common.Address{1}, SlotsAdded: 2, BytesAdded: 2*testSlotSize + 10},
+		{Address: common.Address{2}, SlotsRemoved: 1, BytesRemoved: testSlotSize},
+		{Address: common.Address{3}, Destructed: true, SlotsAdded: 1, BytesAdded: testSlotSize},
+		{Address: common.Address{4}, SlotsAdded: 1, BytesAdded: testSlotSize},
+	}
+	tracker.Commit(block1.Hash(), changes)
+	tracker.Commit(newTestBlock(1, common.Hash{0x01}).Hash(), changes)
+	tracker.Reject(newTestBlock(1, common.Hash{0x01}).Hash())
+	require.NoError(tracker.Accept(block1, flatten(block1)))
+	require.Empty(tracker.pending)
+
+	stats, err := tracker.Stats(10)
+	require.NoError(err)
+	require.False(stats.Backfilling)
+	require.Equal(uint64(1), stats.Number)
+	require.Equal(uint64(4), stats.Accounts)
+	require.Equal(uint64(3+1+1+1), stats.Slots)
+	require.Equal(uint64(6*testSlotSize+10), stats.Bytes)
+	require.Equal(uint64(3*testSlotSize+10), stats.Largest[0].Bytes)
+
+	growth, err := tracker.Growth(1, 1, 1)
+	require.NoError(err)
+	require.Equal(AccountGrowth{SlotsAdded: 4, SlotsRemoved: 4, BytesAdded: 4*testSlotSize + 10, BytesRemoved: 4 * testSlotSize}, growth.Total)
+	require.Equal([]AccountGrowth{{Address: common.Address{1}, SlotsAdded: 2, BytesAdded: 2*testSlotSize + 10}}, growth.Largest)
+
+	// The changes are only kept for the last 2 blocks.
+	block2 := newTestBlock(2, block1.Hash())
+	tracker.Commit(block2.Hash(), nil)
+	require.NoError(tracker.Accept(block2, flatten(block2)))
+	block3 := newTestBlock(3, block2.Hash())
+	tracker.Commit(block3.Hash(), []state.StorageSizeChange{{Address: common.Address{4}, SlotsRemoved: 1, BytesRemoved: testSlotSize}})
+	require.NoError(tracker.Accept(block3, flatten(block3)))
+	_, err = tracker.Growth(1, 3, 10)
+	require.ErrorIs(err, errInvalidRange)
+	_, err = tracker.Growth(2, 4, 10)
+	require.ErrorIs(err, errInvalidRange)
+	growth, err = tracker.Growth(2, 3, 10)
+	require.NoError(err)
+	require.Equal(uint64(testSlotSize), growth.Total.BytesRemoved)
+	require.Equal(int64(-testSlotSize), growth.Largest[0].growth())
+	require.Nil(rawdb.ReadStateSizeAccount(db, crypto.Keccak256Hash(common.Address{4}.Bytes())))
+
+	// A block accepted without its changes resets the statistics, which are
+	// backfilled from the snapshot.
+	block4 := newTestBlock(4, block3.Hash())
+	writeTestStorage(db, common.Address{5}, 5)
+	require.NoError(tracker.Accept(block4, flatten(block4)))
+	stats = waitForBackfill(t, tracker)
+	require.Equal(uint64(4), stats.Number)
+	require.Equal(uint64(4), stats.Accounts)
+	require.Equal(uint64(1+2+3+5), stats.Slots)
+}
@@ -141,3 +141,17 @@ func loadSnapshot(diskdb ethdb.KeyValueStore, triedb *trie.Database, cache int,
 func ResetSnapshotGeneration(db ethdb.KeyValueWriter) {
 	journalProgress(db, nil, nil)
 }
+
+// IsGenerated returns whether the snapshot persisted in [db] is fully
+// generated.
+func IsGenerated(db ethdb.KeyValueReader) bool {
+	blob := rawdb.ReadSnapshotGenerator(db)
+	if len(blob) == 0 {
+		return false
+	}
+	var generator journalGenerator
+	if err := rlp.DecodeBytes(blob, &generator); err != nil {
+		return false
+	}
+	return generator.Done
+}
@@ -149,6 +149,10 @@ type StateDB struct {
 	AccountDeleted int
 	StorageDeleted int
 
+	// Storage size tracking, see TrackStorageSizes
+	trackStorageSizes  bool
+	storageSizeChanges []StorageSizeChange
+
 	// Testing hooks
 	onCommit func(states *triestate.Set) // Hook invoked when commit is performed
 }
@@ -301,6 +305,25 @@ func (s *StateDB) Preimages() map[common.Hash][]byte {
 	return s.preimages
 }
 
+// KeyPreimages returns the preimages of the hashed trie keys accessed through
+// the state: the addresses of the loaded accounts and the keys of the storage
+// slots read or written.
+func (s *StateDB) KeyPreimages() map[common.Hash][]byte {
+	preimages := make(map[common.Hash][]byte)
+	for addr, obj := range s.stateObjects {
+		preimages[obj.addrHash] = common.CopyBytes(addr[:])
+		for _, storage := range []Storage{obj.originStorage, obj.pendingStorage, obj.dirtyStorage} {
+			for key := range storage {
+				preimages[crypto.Keccak256Hash(key[:])] = common.CopyBytes(key[:])
+			}
+		}
+	}
+	for addr := range s.stateObjectsDestruct {
+		preimages[crypto.Keccak256Hash(addr[:])] = common.CopyBytes(addr[:])
+	}
+	return preimages
+}
+
 // AddRefund adds gas to the refund counter
 func (s *StateDB) AddRefund(gas uint64) {
 	s.journal.append(refundChange{prev: s.refund})
@@ -1405,6 +1428,9 @@ func (s *StateDB) commit(block uint64, deleteEmptyObjects bool, snaps *snapshot.
 			s.onCommit(set)
 		}
 	}
+	if s.trackStorageSizes {
+		s.storageSizeChanges = s.computeStorageSizeChanges()
+	}
 	// Clear all internal flags at the end of commit operation.
 	s.accounts = make(map[common.Hash][]byte)
 	s.storages = make(map[common.Hash]map[common.Hash][]byte)
@@ -0,0 +1,93 @@
+// (c) 2024, Ava Labs, Inc. All rights reserved.
+// See the file LICENSE for licensing terms.
+
+package state
+
+import (
+	"github.com/ava-labs/coreth/core/types"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/crypto"
+)
+
+// StorageSlotOverhead is the approximate size of a storage slot besides its
+// value, the hash of the slot key.
+const StorageSlotOverhead = common.HashLength
+
+// StorageSizeChange is the change of the storage of an account committed by a
+// state transition. The size of a slot is approximated by [StorageSlotOverhead]
+// plus the length of its encoded value.
+type StorageSizeChange struct {
+	Address common.Address
+
+	// Destructed is set if the previous storage of the account was deleted.
+	// The other fields then only account for the storage written afterwards.
+	Destructed bool
+
+	SlotsAdded   uint64
+	SlotsRemoved uint64
+	BytesAdded   uint64
+	BytesRemoved uint64
+}
+
+// TrackStorageSizes makes the next commit compute the changes of the storage
+// of the accounts, returned by [StateDB.StorageSizeChanges].
+func (s *StateDB) TrackStorageSizes() {
+	s.trackStorageSizes = true
+}
+
+// StorageSizeChanges returns the storage changes of the accounts computed by
+// the last commit, if [StateDB.TrackStorageSizes] was called before it.
+func (s *StateDB) StorageSizeChanges() []StorageSizeChange {
+	return s.storageSizeChanges
+}
+
+// computeStorageSizeChanges computes the storage changes of the accounts from
+// the mutated slots and their original values. It must be called before the
+// state changes are cleared by commit.
+func (s *StateDB) computeStorageSizeChanges() []StorageSizeChange {
+	var changes []StorageSizeChange
+	destructed := make(map[common.Address]struct{})
+	for addr, prev := range s.stateObjectsDestruct {
+		if prev == nil || prev.Root == types.EmptyRootHash {
+			continue
+		}
+		destructed[addr] = struct{}{}
+
+		change := StorageSizeChange{Address: addr, Destructed: true}
+		for _, value := range s.storages[crypto.Keccak256Hash(addr[:])] {
+			if len(value) != 0 {
+				change.SlotsAdded++
+				change.BytesAdded += StorageSlotOverhead + uint64(len(value))
+			}
+		}
+		changes = append(changes, change)
+	}
+	for addr, origin := range s.storagesOrigin {
+		if _, ok := destructed[addr]; ok {
+			continue
+		}
+		var (
+			change  = StorageSizeChange{Address: addr}
+			storage = s.storages[crypto.Keccak256Hash(addr[:])]
+		)
+		for slot, prev := range origin {
+			value := storage[slot]
+			switch {
+			case len(prev) == 0 && len(value) != 0:
+				change.SlotsAdded++
+				change.BytesAdded += StorageSlotOverhead + uint64(len(value))
+			case len(prev) != 0 && len(value) == 0:
+				change.SlotsRemoved++
+				change.BytesRemoved += StorageSlotOverhead + uint64(len(prev))
+			case len(value) > len(prev):
+				change.BytesAdded += uint64(len(value) - len(prev))
+			default:
+				change.BytesRemoved += uint64(len(prev) - len(value))
+			}
+		}
+		if change.SlotsAdded != 0 || change.SlotsRemoved != 0 || change.BytesAdded != 0 || change.BytesRemoved != 0 {
+			changes = append(changes, change)
+		}
+	}
+	return changes
+}
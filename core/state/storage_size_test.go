@@ -0,0 +1,74 @@
+// (c) 2024, Ava Labs, Inc. All rights reserved.
+// See the file LICENSE for licensing terms.
+
+package state
+
+import (
+	"testing"
+
+	"github.com/ava-labs/coreth/core/rawdb"
+	"github.com/ava-labs/coreth/core/types"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/rlp"
+	"github.com/stretchr/testify/require"
+)
+
+// slotSize returns the approximate size of a slot holding [value].
+func slotSize(value common.Hash) uint64 {
+	encoded, _ := rlp.EncodeToBytes(common.TrimLeftZeroes(value[:]))
+	return StorageSlotOverhead + uint64(len(encoded))
+}
+
+func TestStorageSizeChanges(t *testing.T) {
+	require := require.New(t)
+	var (
+		db    = NewDatabase(rawdb.NewMemoryDatabase())
+		a     = common.Address{0x0a}
+		b     = common.Address{0x0b}
+		c     = common.Address{0x0c}
+		small = common.Hash{31: 0x01}
+		large = common.Hash{0: 0x01, 31: 0x02}
+	)
+	state, err := New(types.EmptyRootHash, db, nil)
+	require.NoError(err)
+	state.SetState(a, common.Hash{31: 1}, small)
+	state.SetState(a, common.Hash{31: 2}, small)
+	state.SetState(b, common.Hash{31: 1}, large)
+	state.SetNonce(c, 1)
+
+	state.TrackStorageSizes()
+	root, err := state.Commit(1, false, false)
+	require.NoError(err)
+	require.ElementsMatch([]StorageSizeChange{
+		{Address: a, SlotsAdded: 2, BytesAdded: 2 * slotSize(small)},
+		{Address: b, SlotsAdded: 1, BytesAdded: slotSize(large)},
+	}, state.StorageSizeChanges())
+
+	// The changes are not computed unless requested.
+	state, err = New(root, db, nil)
+	require.NoError(err)
+	state.SetState(a, common.Hash{31: 3}, small)
+	root, err = state.Commit(2, false, false)
+	require.NoError(err)
+	require.Empty(state.StorageSizeChanges())
+
+	// Clear a slot, grow another, destruct an account and add a slot to an
+	// account without storage. Rewriting a slot with its value is not a change.
+	state, err = New(root, db, nil)
+	require.NoError(err)
+	state.SetState(a, common.Hash{31: 1}, common.Hash{})
+	state.SetState(a, common.Hash{31: 2}, large)
+	state.SetState(a, common.Hash{31: 3}, small)
+	state.SelfDestruct(b)
+	state.Finalise(false)
+	state.SetState(c, common.Hash{31: 1}, small)
+
+	state.TrackStorageSizes()
+	_, err = state.Commit(3, false, false)
+	require.NoError(err)
+	require.ElementsMatch([]StorageSizeChange{
+		{Address: a, SlotsRemoved: 1, BytesAdded: slotSize(large) - slotSize(small), BytesRemoved: slotSize(small)},
+		{Address: b, Destructed: true},
+		{Address: c, SlotsAdded: 1, BytesAdded: slotSize(small)},
+	}, state.StorageSizeChanges())
+}
@@ -0,0 +1,361 @@
+// (c) 2024, Ava Labs, Inc. All rights reserved.
+// See the file LICENSE for licensing terms.
+
+// Package verifier checks that a state trie stored in the database is
+// complete and uncorrupted.
+package verifier
+
+import (
+	"errors"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/ava-labs/coreth/core/rawdb"
+	"github.com/ava-labs/coreth/core/types"
+	"github.com/ava-labs/coreth/trie"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/common/lru"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/ethdb"
+	"github.com/ethereum/go-ethereum/log"
+	"github.com/ethereum/go-ethereum/rlp"
+)
+
+const (
+	// maxProblems is the maximum number of problems listed in the status.
+	// Further problems are only counted.
+	maxProblems = 1000
+
+	// statusInterval is the number of trie nodes verified between two
+	// updates of the status.
+	statusInterval = 1000
+
+	// logInterval is the interval between two progress logs.
+	logInterval = 8 * time.Second
+
+	// verifiedCacheSize is the number of verified code hashes, and of
+	// verified storage roots with the hash scheme, remembered so that shared
+	// code and storage tries are verified once.
+	verifiedCacheSize = 100_000
+)
+
+var (
+	errVerificationRunning = errors.New("state verification is already running")
+	errNotRunning          = errors.New("state verification is not running")
+	errVerifierStopped     = errors.New("state verifier stopped")
+	errCancelled           = errors.New("state verification cancelled")
+)
+
+// Kinds of the problems reported by a verification.
+const (
+	MissingNode    = "missing node"
+	CorruptNode    = "corrupt node"
+	InvalidAccount = "invalid account"
+	MissingCode    = "missing code"
+	CorruptCode    = "corrupt code"
+)
+
+// Problem is a missing or corrupt part of the verified state.
+type Problem struct {
+	Kind  string        `json:"kind"`
+	Owner common.Hash   `json:"owner"` // Hash of the account owning the storage trie or code, zero for the main trie
+	Path  hexutil.Bytes `json:"path"`  // Nibble path of the node in its trie
+	Hash  common.Hash   `json:"hash"`  // Hash of the node or code
+	Error string        `json:"error"`
+}
+
+// Status is the progress of the current or last verification.
+type Status struct {
+	Running      bool        `json:"running"`
+	Trie         string      `json:"trie"`
+	Root         common.Hash `json:"root"`
+	Nodes        uint64      `json:"nodes"`
+	Bytes        uint64      `json:"bytes"`
+	Leaves       uint64      `json:"leaves"` // Leaves of the main trie, the accounts of a state trie
+	Slots        uint64      `json:"slots"`
+	Codes        uint64      `json:"codes"`
+	Progress     float64     `json:"progress"`
+	Problems     []Problem   `json:"problems"`
+	ProblemCount uint64      `json:"problemCount"`
+	Cancelled    bool        `json:"cancelled"`
+	Error        string      `json:"error,omitempty"`
+}
+
+// Verifier walks tries in the background, checking that every node is present
+// and hashes correctly. The walk continues past the problems found, which
+// are all reported in the status along with the progress. A single
+// verification runs at a time.
+type Verifier struct {
+	status Status
+	cancel chan struct{} // Closed to cancel the running verification
+	lock   sync.RWMutex
+
+	quit chan struct{}
+	wg   sync.WaitGroup
+}
+
+// New creates a verifier.
+func New() *Verifier {
+	return &Verifier{quit: make(chan struct{})}
+}
+
+// VerifyState starts verifying the state trie with [root] in [triedb] in the
+// background, along with the storage tries of its accounts and the presence
+// and hash of their code in [db].
+func (v *Verifier) VerifyState(db ethdb.KeyValueReader, triedb *trie.Database, root common.Hash) error {
+	return v.launch("state", root, func(s *session) error {
+		return s.verifyState(db, triedb, root)
+	})
+}
+
+// VerifyTrie starts verifying the trie with [root] in [triedb] in the
+// background. The values of the trie are not interpreted. [name] identifies
+// the trie in the status.
+func (v *Verifier) VerifyTrie(name string, triedb *trie.Database, root common.Hash) error {
+	return v.launch(name, root, func(s *session) error {
+		return trie.Verify(trie.TrieID(root), triedb, trie.VerifyCallbacks{
+			OnNode: func(path []byte, size int) error {
+				return s.onNode(common.Hash{}, path, size)
+			},
+			OnLeaf: func(key, value []byte) error {
+				s.status.Leaves++
+				return nil
+			},
+			OnInvalid: s.invalid,
+		})
+	})
+}
+
+// Status returns the progress of the current or last verification.
+func (v *Verifier) Status() Status {
+	v.lock.RLock()
+	defer v.lock.RUnlock()
+
+	return v.status
+}
+
+// Cancel interrupts the running verification.
+func (v *Verifier) Cancel() error {
+	v.lock.Lock()
+	defer v.lock.Unlock()
+
+	if !v.status.Running {
+		return errNotRunning
+	}
+	if v.cancel != nil {
+		close(v.cancel)
+		v.cancel = nil
+	}
+	return nil
+}
+
+// Stop interrupts the running verification and prevents new ones.
+func (v *Verifier) Stop() {
+	v.lock.Lock()
+	close(v.quit)
+	v.lock.Unlock()
+
+	v.wg.Wait()
+}
+
+// launch runs [verify] in the background for the trie [name] with [root].
+func (v *Verifier) launch(name string, root common.Hash, verify func(s *session) error) error {
+	v.lock.Lock()
+	defer v.lock.Unlock()
+
+	if v.status.Running {
+		return errVerificationRunning
+	}
+	select {
+	case <-v.quit:
+		return errVerifierStopped
+	default:
+	}
+	v.cancel = make(chan struct{})
+	v.status = Status{Running: true, Trie: name, Root: root}
+	s := &session{
+		verifier: v,
+		cancel:   v.cancel,
+		status:   v.status,
+		start:    time.Now(),
+		logged:   time.Now(),
+	}
+	log.Info("Starting state verification", "trie", name, "root", root)
+
+	v.wg.Add(1)
+	go func() {
+		defer v.wg.Done()
+
+		err := verify(s)
+		switch {
+		case errors.Is(err, errCancelled):
+			log.Info("State verification cancelled", "trie", name, "root", root)
+			s.status.Cancelled = true
+		case errors.Is(err, errVerifierStopped):
+			log.Info("State verification interrupted", "trie", name, "root", root)
+			s.status.Cancelled = true
+		case err != nil:
+			log.Error("State verification failed", "trie", name, "root", root, "err", err)
+			s.status.Error = err.Error()
+		default:
+			s.status.Progress = 1
+			log.Info("State verification finished", "trie", name, "root", root, "nodes", s.status.Nodes, "size", common.StorageSize(s.status.Bytes), "problems", s.status.ProblemCount, "elapsed", common.PrettyDuration(time.Since(s.start)))
+		}
+		s.status.Running = false
+		s.publish()
+	}()
+	return nil
+}
+
+// session is a running verification. Its status is only accessed by the
+// goroutine running it, and is copied into the status of the verifier
+// periodically.
+type session struct {
+	verifier *Verifier
+	cancel   chan struct{}
+	status   Status
+	start    time.Time
+	logged   time.Time
+}
+
+// publish copies the status of the session into the status of the verifier.
+func (s *session) publish() {
+	status := s.status
+	status.Problems = slices.Clone(s.status.Problems)
+
+	s.verifier.lock.Lock()
+	s.verifier.status = status
+	s.verifier.lock.Unlock()
+}
+
+// onNode records the node of size [size] at [path] in the trie of [owner]
+// and returns an error if the verification is interrupted.
+func (s *session) onNode(owner common.Hash, path []byte, size int) error {
+	select {
+	case <-s.cancel:
+		return errCancelled
+	case <-s.verifier.quit:
+		return errVerifierStopped
+	default:
+	}
+	s.status.Nodes++
+	s.status.Bytes += uint64(size)
+	if owner == (common.Hash{}) {
+		s.status.Progress = pathProgress(path)
+	}
+	if s.status.Nodes%statusInterval == 0 {
+		s.publish()
+		if time.Since(s.logged) > logInterval {
+			log.Info("Verifying state", "trie", s.status.Trie, "root", s.status.Root, "nodes", s.status.Nodes, "size", common.StorageSize(s.status.Bytes), "progress", s.status.Progress, "problems", s.status.ProblemCount, "elapsed", common.PrettyDuration(time.Since(s.start)))
+			s.logged = time.Now()
+		}
+	}
+	return nil
+}
+
+// invalid records the missing or corrupt node reported by [err].
+func (s *session) invalid(err error) {
+	var (
+		missing *trie.MissingNodeError
+		corrupt *trie.CorruptNodeError
+	)
+	problem := Problem{Kind: MissingNode, Error: err.Error()}
+	switch {
+	case errors.As(err, &corrupt):
+		problem.Kind = CorruptNode
+		problem.Owner, problem.Path, problem.Hash = corrupt.Owner, corrupt.Path, corrupt.NodeHash
+	case errors.As(err, &missing):
+		problem.Owner, problem.Path, problem.Hash = missing.Owner, missing.Path, missing.NodeHash
+	}
+	s.report(problem)
+}
+
+func (s *session) report(problem Problem) {
+	s.status.ProblemCount++
+	if len(s.status.Problems) < maxProblems {
+		s.status.Problems = append(s.status.Problems, problem)
+		log.Warn("Found invalid state", "trie", s.status.Trie, "kind", problem.Kind, "owner", problem.Owner, "path", problem.Path, "hash", problem.Hash, "err", problem.Error)
+	}
+}
+
+// verifyState walks the state trie with [root], the storage trie of every
+// account and checks the code of every contract.
+func (s *session) verifyState(db ethdb.KeyValueReader, triedb *trie.Database, root common.Hash) error {
+	var (
+		codes        = lru.NewBasicLRU[common.Hash, struct{}](verifiedCacheSize)
+		storageRoots = lru.NewBasicLRU[common.Hash, struct{}](verifiedCacheSize)
+		hashScheme   = triedb.Scheme() == rawdb.HashScheme
+	)
+	onAccount := func(key, value []byte) error {
+		s.status.Leaves++
+		accHash := common.BytesToHash(key)
+		var acc types.StateAccount
+		if err := rlp.DecodeBytes(value, &acc); err != nil {
+			s.report(Problem{Kind: InvalidAccount, Owner: accHash, Error: err.Error()})
+			return nil
+		}
+		if codeHash := common.BytesToHash(acc.CodeHash); codeHash != types.EmptyCodeHash && !codes.Contains(codeHash) {
+			s.verifyCode(db, accHash, codeHash)
+			codes.Add(codeHash, struct{}{})
+		}
+		if acc.Root == types.EmptyRootHash {
+			return nil
+		}
+		// With the hash scheme, the storage tries with the same root share
+		// their nodes.
+		if hashScheme && storageRoots.Contains(acc.Root) {
+			return nil
+		}
+		err := trie.Verify(trie.StorageTrieID(root, accHash, acc.Root), triedb, trie.VerifyCallbacks{
+			OnNode: func(path []byte, size int) error {
+				return s.onNode(accHash, path, size)
+			},
+			OnLeaf: func(key, value []byte) error {
+				s.status.Slots++
+				return nil
+			},
+			OnInvalid: s.invalid,
+		})
+		if err != nil {
+			return err
+		}
+		if hashScheme {
+			storageRoots.Add(acc.Root, struct{}{})
+		}
+		return nil
+	}
+	return trie.Verify(trie.StateTrieID(root), triedb, trie.VerifyCallbacks{
+		OnNode: func(path []byte, size int) error {
+			return s.onNode(common.Hash{}, path, size)
+		},
+		OnLeaf:    onAccount,
+		OnInvalid: s.invalid,
+	})
+}
+
+// verifyCode checks that the code with [codeHash] of the account [accHash] is
+// in [db].
+func (s *session) verifyCode(db ethdb.KeyValueReader, accHash, codeHash common.Hash) {
+	code := rawdb.ReadCode(db, codeHash)
+	switch {
+	case len(code) == 0:
+		s.report(Problem{Kind: MissingCode, Owner: accHash, Hash: codeHash, Error: "code not found"})
+	case crypto.Keccak256Hash(code) != codeHash:
+		s.report(Problem{Kind: CorruptCode, Owner: accHash, Hash: codeHash, Error: "code hash mismatch"})
+	default:
+		s.status.Codes++
+	}
+}
+
+// pathProgress returns the fraction of the key space before the nibble
+// [path].
+func pathProgress(path []byte) float64 {
+	var progress, unit float64 = 0, 1
+	for i := 0; i < len(path) && i < 8 && path[i] < 16; i++ {
+		unit /= 16
+		progress += float64(path[i]) * unit
+	}
+	return progress
+}
@@ -0,0 +1,170 @@
+// (c) 2024, Ava Labs, Inc. All rights reserved.
+// See the file LICENSE for licensing terms.
+
+package verifier
+
+import (
+	"math/rand"
+	"testing"
+	"time"
+
+	"github.com/ava-labs/coreth/accounts/keystore"
+	"github.com/ava-labs/coreth/core/rawdb"
+	"github.com/ava-labs/coreth/core/types"
+	"github.com/ava-labs/coreth/sync/syncutils"
+	"github.com/ava-labs/coreth/trie"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/ethdb"
+	"github.com/stretchr/testify/require"
+)
+
+// newTestState creates a state in which every other account has storage
+// and every third account has code.
+func newTestState(t *testing.T, numAccounts int) (ethdb.Database, *trie.Database, common.Hash, map[*keystore.Key]*types.StateAccount) {
+	db := rawdb.NewMemoryDatabase()
+	triedb := trie.NewDatabase(db, nil)
+	root, accounts := syncutils.FillAccounts(t, triedb, common.Hash{}, numAccounts, func(t *testing.T, index int, account types.StateAccount) types.StateAccount {
+		if index%2 == 0 {
+			account.Root, _, _ = syncutils.GenerateTrie(t, triedb, 16, common.HashLength)
+		}
+		if index%3 == 0 {
+			code := make([]byte, 256)
+			_, err := rand.Read(code)
+			require.NoError(t, err)
+			codeHash := crypto.Keccak256Hash(code)
+			rawdb.WriteCode(db, codeHash, code)
+			account.CodeHash = codeHash[:]
+		}
+		return account
+	})
+	return db, triedb, root, accounts
+}
+
+func waitForVerification(t *testing.T, v *Verifier) Status {
+	var status Status
+	require.Eventually(t, func() bool {
+		status = v.Status()
+		return !status.Running
+	}, 10*time.Second, time.Millisecond)
+	return status
+}
+
+func TestVerifyState(t *testing.T) {
+	require := require.New(t)
+	db, triedb, root, accounts := newTestState(t, 100)
+
+	v := New()
+	defer v.Stop()
+	require.NoError(v.VerifyState(db, triedb, root))
+	status := waitForVerification(t, v)
+	require.Empty(status.Error)
+	require.Empty(status.Problems)
+	require.Equal(root, status.Root)
+	require.Equal(uint64(100), status.Leaves)
+	require.Equal(uint64(50*16), status.Slots)
+	require.Equal(uint64(34), status.Codes)
+	require.Equal(float64(1), status.Progress)
+
+	// Delete the root of a storage trie, delete a code and corrupt another.
+	var (
+		storageOwner, missingOwner, corruptOwner common.Hash
+		storageRoot, missingCode, corruptCode    common.Hash
+	)
+	for account, acc := range accounts {
+		accHash := crypto.Keccak256Hash(account.Address[:])
+		codeHash := common.BytesToHash(acc.CodeHash)
+		switch {
+		case acc.Root != types.EmptyRootHash && storageRoot == (common.Hash{}):
+			storageOwner, storageRoot = accHash, acc.Root
+		case codeHash != types.EmptyCodeHash && missingCode == (common.Hash{}):
+			missingOwner, missingCode = accHash, codeHash
+		case codeHash != types.EmptyCodeHash && corruptCode == (common.Hash{}):
+			corruptOwner, corruptCode = accHash, codeHash
+		}
+	}
+	rawdb.DeleteLegacyTrieNode(db, storageRoot)
+	rawdb.DeleteCode(db, missingCode)
+	rawdb.WriteCode(db, corruptCode, []byte{0x00})
+
+	require.NoError(v.VerifyState(db, triedb, root))
+	status = waitForVerification(t, v)
+	require.Empty(status.Error)
+	require.Equal(uint64(3), status.ProblemCount)
+	require.ElementsMatch([]Problem{
+		{Kind: MissingNode, Owner: storageOwner, Hash: storageRoot},
+		{Kind: MissingCode, Owner: missingOwner, Hash: missingCode},
+		{Kind: CorruptCode, Owner: corruptOwner, Hash: corruptCode},
+	}, clearErrors(t, status.Problems))
+	require.Equal(uint64(100), status.Leaves)
+	require.Equal(uint64(49*16), status.Slots)
+	require.Equal(uint64(32), status.Codes)
+}
+
+func TestVerifyTrie(t *testing.T) {
+	require := require.New(t)
+	db := rawdb.NewMemoryDatabase()
+	triedb := trie.NewDatabase(db, nil)
+	root, _, _ := syncutils.GenerateTrie(t, triedb, 1000, common.HashLength)
+
+	v := New()
+	defer v.Stop()
+	require.NoError(v.VerifyTrie("atomic", triedb, root))
+	status := waitForVerification(t, v)
+	require.Empty(status.Error)
+	require.Empty(status.Problems)
+	require.Equal("atomic", status.Trie)
+	require.Equal(uint64(1000), status.Leaves)
+
+	// A missing root fails the verification.
+	rawdb.DeleteLegacyTrieNode(db, root)
+	require.NoError(v.VerifyTrie("atomic", triedb, root))
+	status = waitForVerification(t, v)
+	require.NotEmpty(status.Error)
+}
+
+func TestCancelVerification(t *testing.T) {
+	require := require.New(t)
+	v := New()
+	require.ErrorIs(v.Cancel(), errNotRunning)
+
+	// The session blocks until it is cancelled.
+	block := func(s *session) error {
+		select {
+		case <-s.cancel:
+		case <-s.verifier.quit:
+		}
+		return s.onNode(common.Hash{}, nil, 0)
+	}
+	require.NoError(v.launch("test", common.Hash{}, block))
+	require.ErrorIs(v.VerifyTrie("test", nil, common.Hash{}), errVerificationRunning)
+	require.NoError(v.Cancel())
+	require.NoError(v.Cancel())
+	status := waitForVerification(t, v)
+	require.True(status.Cancelled)
+	require.Empty(status.Error)
+
+	// Stopping the verifier interrupts the running session.
+	require.NoError(v.launch("test", common.Hash{}, block))
+	v.Stop()
+	require.True(v.Status().Cancelled)
+	require.False(v.Status().Running)
+	require.ErrorIs(v.VerifyTrie("test", nil, common.Hash{}), errVerifierStopped)
+}
+
+func TestPathProgress(t *testing.T) {
+	require.Equal(t, float64(0), pathProgress(nil))
+	require.Equal(t, 0.5, pathProgress([]byte{8}))
+	require.Equal(t, 0.5+1.0/256, pathProgress([]byte{8, 1, 16}))
+}
+
+// clearErrors checks that every problem has an error message and clears it.
+func clearErrors(t *testing.T, problems []Problem) []Problem {
+	cleared := make([]Problem, len(problems))
+	for i, problem := range problems {
+		require.NotEmpty(t, problem.Error)
+		problem.Error = ""
+		cleared[i] = problem
+	}
+	return cleared
+}
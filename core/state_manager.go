@@ -31,6 +31,7 @@ import (
 	"math/rand"
 	"time"
 
+	"github.com/ava-labs/coreth/core/rawdb"
 	"github.com/ava-labs/coreth/core/types"
 	"github.com/ethereum/go-ethereum/common"
 	"github.com/ethereum/go-ethereum/ethdb"
@@ -72,6 +73,18 @@ type TrieDB interface {
 }
 
 func NewTrieWriter(db TrieDB, config *CacheConfig) TrieWriter {
+	if config.StateScheme == rawdb.PathScheme {
+		// The path scheme cannot keep the historical states, so without
+		// pruning every accepted state is flattened to disk instead.
+		commitInterval := config.CommitInterval
+		if !config.Pruning {
+			commitInterval = 1
+		}
+		return &pathTrieWriter{
+			TrieDB:         db,
+			commitInterval: commitInterval,
+		}
+	}
 	if config.Pruning {
 		cm := &cappedMemoryTrieWriter{
 			TrieDB:           db,
@@ -202,3 +215,33 @@ func (cm *cappedMemoryTrieWriter) Shutdown() error {
 	// re-processing the state on the next startup.
 	return cm.TrieDB.Commit(last, true)
 }
+
+// pathTrieWriter handles the tries of a path-based trie database, which
+// keeps the recent state diffs in memory and persists the oldest ones on its
+// own, so there are no references to track. Rejected diffs are never flattened
+// to disk, since the layers not descending from the accepted state are dropped
+// when the state below them is persisted.
+type pathTrieWriter struct {
+	TrieDB
+	commitInterval uint64
+}
+
+func (p *pathTrieWriter) InsertTrie(block *types.Block) error { return nil }
+
+func (p *pathTrieWriter) AcceptTrie(block *types.Block) error {
+	// Flatten the accepted state to disk every [commitInterval] blocks, so at
+	// most [commitInterval] blocks are re-processed after an unclean shutdown.
+	if p.commitInterval == 0 || block.NumberU64()%p.commitInterval != 0 {
+		return nil
+	}
+	if err := p.TrieDB.Commit(block.Root(), true); err != nil {
+		return fmt.Errorf("failed to commit trie for block %s: %w", block.Hash().Hex(), err)
+	}
+	return nil
+}
+
+func (p *pathTrieWriter) RejectTrie(block *types.Block) error { return nil }
+
+// Shutdown is a no-op, since the in-memory diffs are journaled by the trie
+// database when the blockchain stops.
+func (p *pathTrieWriter) Shutdown() error { return nil }
@@ -7,6 +7,7 @@ import (
 	"math/big"
 	"testing"
 
+	"github.com/ava-labs/coreth/core/rawdb"
 	"github.com/ava-labs/coreth/core/types"
 
 	"github.com/ethereum/go-ethereum/common"
@@ -102,3 +103,54 @@ func TestNoPruningTrieWriter(t *testing.T) {
 		m.LastDereference = common.Hash{}
 	}
 }
+
+func TestPathTrieWriter(t *testing.T) {
+	m := &MockTrieDB{}
+	cacheConfig := &CacheConfig{Pruning: true, CommitInterval: 4096, StateScheme: rawdb.PathScheme}
+	w := NewTrieWriter(m, cacheConfig)
+	assert := assert.New(t)
+	for i := 1; i < int(cacheConfig.CommitInterval)+1; i++ {
+		bigI := big.NewInt(int64(i))
+		block := types.NewBlock(
+			&types.Header{
+				Root:   common.BigToHash(bigI),
+				Number: bigI,
+			},
+			nil, nil, nil, nil,
+		)
+
+		assert.NoError(w.InsertTrie(block))
+		assert.NoError(w.AcceptTrie(block))
+		if i < int(cacheConfig.CommitInterval) {
+			assert.Equal(common.Hash{}, m.LastCommit, "should not have committed block on accept")
+		} else {
+			assert.Equal(block.Root(), m.LastCommit, "should have committed block after CommitInterval")
+			m.LastCommit = common.Hash{}
+		}
+
+		assert.NoError(w.RejectTrie(block))
+		assert.Equal(common.Hash{}, m.LastDereference, "should not have dereferenced block")
+	}
+	assert.NoError(w.Shutdown())
+	assert.Equal(common.Hash{}, m.LastCommit, "should not have committed block on shutdown")
+}
+
+func TestPathTrieWriterNoPruning(t *testing.T) {
+	m := &MockTrieDB{}
+	w := NewTrieWriter(m, &CacheConfig{StateScheme: rawdb.PathScheme})
+	assert := assert.New(t)
+	for i := 1; i < tipBufferSize+1; i++ {
+		bigI := big.NewInt(int64(i))
+		block := types.NewBlock(
+			&types.Header{
+				Root:   common.BigToHash(bigI),
+				Number: bigI,
+			},
+			nil, nil, nil, nil,
+		)
+
+		assert.NoError(w.AcceptTrie(block))
+		assert.Equal(block.Root(), m.LastCommit, "should have committed block on accept")
+		m.LastCommit = common.Hash{}
+	}
+}
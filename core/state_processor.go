@@ -49,12 +49,19 @@ import (
 // StateProcessor implements Processor.
 type StateProcessor struct {
 	config *params.ChainConfig // Chain configuration options
-	bc     *BlockChain         // Canonical block chain
+	bc     ProcessorChain      // Canonical block chain
 	engine consensus.Engine    // Consensus engine used for block rewards
 }
 
+// ProcessorChain is the chain access needed to process blocks, provided by
+// BlockChain, or by HeaderChain to re-execute blocks without a BlockChain.
+type ProcessorChain interface {
+	ChainContext
+	consensus.ChainHeaderReader
+}
+
 // NewStateProcessor initialises a new StateProcessor.
-func NewStateProcessor(config *params.ChainConfig, bc *BlockChain, engine consensus.Engine) *StateProcessor {
+func NewStateProcessor(config *params.ChainConfig, bc ProcessorChain, engine consensus.Engine) *StateProcessor {
 	return &StateProcessor{
 		config: config,
 		bc:     bc,
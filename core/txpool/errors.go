@@ -64,4 +64,8 @@ var (
 	// ErrFutureReplacePending is returned if a future transaction replaces a pending
 	// one. Future transactions should only be able to replace other future transactions.
 	ErrFutureReplacePending = errors.New("future transaction tries to replace pending")
+
+	// ErrThrottled is returned if the sender or the origin peer of a transaction
+	// exceeded its submission rate or is temporarily throttled for spamming.
+	ErrThrottled = errors.New("transaction source throttled")
 )
@@ -0,0 +1,259 @@
+// (c) 2024, Ava Labs, Inc. All rights reserved.
+// See the file LICENSE for licensing terms.
+
+package legacypool
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/ava-labs/coreth/core/txpool"
+	"github.com/ava-labs/coreth/metrics"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/log"
+)
+
+const (
+	// rateLimitPenalty is the spam score added to a source for each transaction
+	// submitted above its allowed rate.
+	rateLimitPenalty = 1.0
+
+	// invalidTxPenalty is the spam score added to a source for each transaction
+	// rejected by the pool validation rules.
+	invalidTxPenalty = 0.5
+
+	// minTrackedScore is the spam score below which an idle source with a full
+	// token bucket is no longer tracked.
+	minTrackedScore = 0.01
+)
+
+var (
+	throttledSenderMeter = metrics.NewRegisteredMeter("txpool/admission/sender/throttled", nil)
+	throttledPeerMeter   = metrics.NewRegisteredMeter("txpool/admission/peer/throttled", nil)
+
+	throttledSendersGauge = metrics.NewRegisteredGauge("txpool/admission/sender/sources", nil)
+	throttledPeersGauge   = metrics.NewRegisteredGauge("txpool/admission/peer/sources", nil)
+)
+
+// sourceState tracks the submission rate and spam score of a single source of
+// transactions.
+type sourceState struct {
+	tokens    float64   // Transactions the source may still submit in a burst
+	score     float64   // Spam score, decaying over time
+	updated   time.Time // Time at which tokens and score were last refreshed
+	throttled time.Time // Time until which the source is throttled
+	trigger   float64   // Spam score at which the current throttling started
+}
+
+// admission is an optional admission control layer of the pool, limiting the
+// rate at which individual senders and peers may submit transactions. Sources
+// exceeding their rate, or submitting invalid transactions, accumulate a spam
+// score and are temporarily throttled when it crosses the configured threshold.
+type admission struct {
+	config Config
+	exempt map[common.Address]struct{} // Senders configured as locals, never throttled
+
+	senders map[common.Address]*sourceState
+	peers   map[string]*sourceState
+	lock    sync.Mutex
+}
+
+// newAdmission creates the admission control for the pool, or nil if neither
+// sender nor peer rate limiting is enabled.
+func newAdmission(config Config) *admission {
+	if config.SenderRateLimit <= 0 && config.PeerRateLimit <= 0 {
+		return nil
+	}
+	a := &admission{
+		config:  config,
+		exempt:  make(map[common.Address]struct{}),
+		senders: make(map[common.Address]*sourceState),
+		peers:   make(map[string]*sourceState),
+	}
+	for _, addr := range config.Locals {
+		a.exempt[addr] = struct{}{}
+	}
+	return a
+}
+
+// admit charges a transaction from [sender], optionally received from [peer],
+// against the rate limits of both sources. An error is returned if either of
+// them is throttled or out of allowance.
+func (a *admission) admit(sender common.Address, peer string, now time.Time) error {
+	a.lock.Lock()
+	defer a.lock.Unlock()
+
+	var peerState, senderState *sourceState
+	if peer != "" && a.config.PeerRateLimit > 0 {
+		peerState = a.peerState(peer, now)
+		if now.Before(peerState.throttled) {
+			throttledPeerMeter.Mark(1)
+			return fmt.Errorf("%w: peer %s", txpool.ErrThrottled, peer)
+		}
+	}
+	if _, ok := a.exempt[sender]; !ok && a.config.SenderRateLimit > 0 {
+		senderState = a.senderState(sender, now)
+		if now.Before(senderState.throttled) {
+			throttledSenderMeter.Mark(1)
+			return fmt.Errorf("%w: sender %s", txpool.ErrThrottled, sender.Hex())
+		}
+	}
+	if peerState != nil && peerState.tokens < 1 {
+		a.penalizePeer(peer, peerState, rateLimitPenalty, now)
+		throttledPeerMeter.Mark(1)
+		return fmt.Errorf("%w: peer %s exceeded rate limit", txpool.ErrThrottled, peer)
+	}
+	if senderState != nil && senderState.tokens < 1 {
+		a.penalizeSender(sender, senderState, rateLimitPenalty, now)
+		throttledSenderMeter.Mark(1)
+		return fmt.Errorf("%w: sender %s exceeded rate limit", txpool.ErrThrottled, sender.Hex())
+	}
+	if peerState != nil {
+		peerState.tokens--
+	}
+	if senderState != nil {
+		senderState.tokens--
+	}
+	return nil
+}
+
+// reject increases the spam score of the sources of a transaction that was
+// refused by the pool. A zero [sender] is used if it could not be recovered.
+func (a *admission) reject(sender common.Address, peer string, now time.Time) {
+	a.lock.Lock()
+	defer a.lock.Unlock()
+
+	if peer != "" && a.config.PeerRateLimit > 0 {
+		a.penalizePeer(peer, a.peerState(peer, now), invalidTxPenalty, now)
+	}
+	if _, ok := a.exempt[sender]; !ok && sender != (common.Address{}) && a.config.SenderRateLimit > 0 {
+		a.penalizeSender(sender, a.senderState(sender, now), invalidTxPenalty, now)
+	}
+}
+
+// senderState returns the refreshed state of [sender], creating it if needed.
+//
+// Note, this method assumes the admission lock is held!
+func (a *admission) senderState(sender common.Address, now time.Time) *sourceState {
+	state, ok := a.senders[sender]
+	if !ok {
+		state = &sourceState{tokens: float64(a.config.RateLimitBurst), updated: now}
+		a.senders[sender] = state
+	}
+	a.refresh(state, a.config.SenderRateLimit, now)
+	return state
+}
+
+// peerState returns the refreshed state of [peer], creating it if needed.
+//
+// Note, this method assumes the admission lock is held!
+func (a *admission) peerState(peer string, now time.Time) *sourceState {
+	state, ok := a.peers[peer]
+	if !ok {
+		state = &sourceState{tokens: float64(a.config.RateLimitBurst), updated: now}
+		a.peers[peer] = state
+	}
+	a.refresh(state, a.config.PeerRateLimit, now)
+	return state
+}
+
+// refresh refills the token bucket of a source at [rate] tokens per second and
+// decays its spam score, halving it every throttle duration.
+func (a *admission) refresh(state *sourceState, rate float64, now time.Time) {
+	elapsed := now.Sub(state.updated)
+	if elapsed <= 0 {
+		return
+	}
+	state.tokens = math.Min(float64(a.config.RateLimitBurst), state.tokens+elapsed.Seconds()*rate)
+	state.score *= math.Pow(0.5, float64(elapsed)/float64(a.config.ThrottleDuration))
+	state.updated = now
+}
+
+// penalizeSender adds [penalty] to the spam score of [sender], throttling it if
+// the score crosses the configured threshold.
+//
+// Note, this method assumes the admission lock is held!
+func (a *admission) penalizeSender(sender common.Address, state *sourceState, penalty float64, now time.Time) {
+	if a.penalize(state, penalty, now) {
+		log.Debug("Throttling transaction sender", "sender", sender, "score", state.trigger, "until", state.throttled)
+		throttledSendersGauge.Inc(1)
+	}
+}
+
+// penalizePeer adds [penalty] to the spam score of [peer], throttling it if
+// the score crosses the configured threshold.
+//
+// Note, this method assumes the admission lock is held!
+func (a *admission) penalizePeer(peer string, state *sourceState, penalty float64, now time.Time) {
+	if a.penalize(state, penalty, now) {
+		log.Debug("Throttling transaction peer", "peer", peer, "score", state.trigger, "until", state.throttled)
+		throttledPeersGauge.Inc(1)
+	}
+}
+
+// penalize adds [penalty] to the spam score of a source and reports whether
+// the source became throttled as a result.
+func (a *admission) penalize(state *sourceState, penalty float64, now time.Time) bool {
+	state.score += penalty
+	if state.score < a.config.SpamThreshold || now.Before(state.throttled) {
+		return false
+	}
+	// The source gets a clean slate once the throttling expires
+	state.throttled = now.Add(a.config.ThrottleDuration)
+	state.trigger = state.score
+	state.score = 0
+	return true
+}
+
+// cleanup stops tracking idle sources that are neither throttled nor carry a
+// meaningful spam score, and refreshes the throttled source gauges.
+func (a *admission) cleanup(now time.Time) {
+	a.lock.Lock()
+	defer a.lock.Unlock()
+
+	var senders, peers int64
+	for sender, state := range a.senders {
+		a.refresh(state, a.config.SenderRateLimit, now)
+		if now.Before(state.throttled) {
+			senders++
+		} else if state.score < minTrackedScore && state.tokens >= float64(a.config.RateLimitBurst) {
+			delete(a.senders, sender)
+		}
+	}
+	for peer, state := range a.peers {
+		a.refresh(state, a.config.PeerRateLimit, now)
+		if now.Before(state.throttled) {
+			peers++
+		} else if state.score < minTrackedScore && state.tokens >= float64(a.config.RateLimitBurst) {
+			delete(a.peers, peer)
+		}
+	}
+	throttledSendersGauge.Update(senders)
+	throttledPeersGauge.Update(peers)
+}
+
+// throttled returns the sources currently being throttled, ordered by the time
+// their throttling expires.
+func (a *admission) throttled(now time.Time) []txpool.ThrottledSource {
+	a.lock.Lock()
+	defer a.lock.Unlock()
+
+	var sources []txpool.ThrottledSource
+	for sender, state := range a.senders {
+		if now.Before(state.throttled) {
+			sources = append(sources, txpool.ThrottledSource{Kind: "sender", Source: sender.Hex(), Score: state.trigger, Until: state.throttled})
+		}
+	}
+	for peer, state := range a.peers {
+		if now.Before(state.throttled) {
+			sources = append(sources, txpool.ThrottledSource{Kind: "peer", Source: peer, Score: state.trigger, Until: state.throttled})
+		}
+	}
+	sort.Slice(sources, func(i, j int) bool {
+		return sources[i].Until.Before(sources[j].Until)
+	})
+	return sources
+}
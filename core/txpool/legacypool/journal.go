@@ -52,7 +52,8 @@ func (*devNull) Write(p []byte) (n int, err error) { return len(p), nil }
 func (*devNull) Close() error                      { return nil }
 
 // journal is a rotating log of transactions with the aim of storing locally
-// created transactions to allow non-executed ones to survive node restarts.
+// created (or, optionally, remote) transactions to allow non-executed ones to
+// survive node restarts.
 type journal struct {
 	path   string         // Filesystem path to store the transactions at
 	writer io.WriteCloser // Output stream to write new transactions into
@@ -122,7 +123,7 @@ func (journal *journal) load(add func([]*types.Transaction) []error) error {
 			batch = batch[:0]
 		}
 	}
-	log.Info("Loaded local transaction journal", "transactions", total, "dropped", dropped)
+	log.Info("Loaded transaction journal", "path", journal.path, "transactions", total, "dropped", dropped)
 
 	return failure
 }
@@ -174,7 +175,7 @@ func (journal *journal) rotate(all map[common.Address]types.Transactions) error
 		return err
 	}
 	journal.writer = sink
-	log.Info("Regenerated local transaction journal", "transactions", journaled, "accounts", len(all))
+	log.Info("Regenerated transaction journal", "path", journal.path, "transactions", journaled, "accounts", len(all))
 
 	return nil
 }
@@ -64,6 +64,10 @@ const (
 	//
 	// Note: the max contract size is 24KB
 	txMaxSize = 4 * txSlotSize // 128KB
+
+	// lifecycleChangesLimit is the maximum number of lifecycle changes buffered
+	// between two deliveries to subscribers. Older changes are discarded beyond it.
+	lifecycleChangesLimit = 4096
 )
 
 var (
@@ -107,6 +111,9 @@ var (
 	// dropBetweenReorgHistogram counts how many drops we experience between two reorg runs. It is expected
 	// that this number is pretty low, since txpool reorgs happen very frequently.
 	dropBetweenReorgHistogram = metrics.NewRegisteredHistogram("txpool/dropbetweenreorg", nil, metrics.NewExpDecaySample(1028, 0.015))
+	// lifecycleDiscardMeter counts the lifecycle changes discarded because too
+	// many were buffered between two deliveries.
+	lifecycleDiscardMeter = metrics.NewRegisteredMeter("txpool/lifecycle/discard", nil)
 
 	pendingGauge = metrics.NewRegisteredGauge("txpool/pending", nil)
 	queuedGauge  = metrics.NewRegisteredGauge("txpool/queued", nil)
@@ -139,7 +146,11 @@ type Config struct {
 	Locals    []common.Address // Addresses that should be treated by default as local
 	NoLocals  bool             // Whether local transaction handling should be disabled
 	Journal   string           // Journal of local transactions to survive node restarts
-	Rejournal time.Duration    // Time interval to regenerate the local transaction journal
+	Rejournal time.Duration    // Time interval to regenerate the local and remote transaction journals
+
+	RemoteJournal             string // Journal of remote transactions to survive node restarts
+	RemoteJournalSlots        uint64 // Maximum number of remote transactions snapshotted into the remote journal
+	RemoteJournalAccountSlots uint64 // Maximum number of remote transactions snapshotted per account
 
 	PriceLimit uint64 // Minimum gas price to enforce for acceptance into the pool
 	PriceBump  uint64 // Minimum price bump percentage to replace an already existing transaction (nonce)
@@ -150,6 +161,12 @@ type Config struct {
 	GlobalQueue  uint64 // Maximum number of non-executable transaction slots for all accounts
 
 	Lifetime time.Duration // Maximum amount of time non-executable transaction are queued
+
+	SenderRateLimit  float64       // Transactions per second accepted from a single sender (0 = unlimited)
+	PeerRateLimit    float64       // Transactions per second accepted from a single peer (0 = unlimited)
+	RateLimitBurst   uint64        // Number of transactions a sender or peer may submit in a burst
+	SpamThreshold    float64       // Spam score at which a sender or peer gets throttled
+	ThrottleDuration time.Duration // Amount of time a sender or peer remains throttled
 }
 
 // DefaultConfig contains the default configurations for the transaction pool.
@@ -159,6 +176,10 @@ var DefaultConfig = Config{
 	Journal:   "",
 	Rejournal: time.Hour,
 
+	RemoteJournal:             "",
+	RemoteJournalSlots:        4096 + 1024 + 1024, // global slots + global queue
+	RemoteJournalAccountSlots: 16 + 64,            // account slots + account queue
+
 	PriceLimit: 1,
 	PriceBump:  10,
 
@@ -168,6 +189,12 @@ var DefaultConfig = Config{
 	GlobalQueue:  1024,
 
 	Lifetime: 10 * time.Minute,
+
+	SenderRateLimit:  0,
+	PeerRateLimit:    0,
+	RateLimitBurst:   64,
+	SpamThreshold:    128,
+	ThrottleDuration: 5 * time.Minute,
 }
 
 // sanitize checks the provided user configurations and changes anything that's
@@ -178,6 +205,14 @@ func (config *Config) sanitize() Config {
 		log.Warn("Sanitizing invalid txpool journal time", "provided", conf.Rejournal, "updated", time.Second)
 		conf.Rejournal = time.Second
 	}
+	if conf.RemoteJournalSlots < 1 {
+		log.Warn("Sanitizing invalid txpool remote journal slots", "provided", conf.RemoteJournalSlots, "updated", DefaultConfig.RemoteJournalSlots)
+		conf.RemoteJournalSlots = DefaultConfig.RemoteJournalSlots
+	}
+	if conf.RemoteJournalAccountSlots < 1 {
+		log.Warn("Sanitizing invalid txpool remote journal account slots", "provided", conf.RemoteJournalAccountSlots, "updated", DefaultConfig.RemoteJournalAccountSlots)
+		conf.RemoteJournalAccountSlots = DefaultConfig.RemoteJournalAccountSlots
+	}
 	if conf.PriceLimit < 1 {
 		log.Warn("Sanitizing invalid txpool price limit", "provided", conf.PriceLimit, "updated", DefaultConfig.PriceLimit)
 		conf.PriceLimit = DefaultConfig.PriceLimit
@@ -206,6 +241,26 @@ func (config *Config) sanitize() Config {
 		log.Warn("Sanitizing invalid txpool lifetime", "provided", conf.Lifetime, "updated", DefaultConfig.Lifetime)
 		conf.Lifetime = DefaultConfig.Lifetime
 	}
+	if conf.SenderRateLimit < 0 {
+		log.Warn("Sanitizing invalid txpool sender rate limit", "provided", conf.SenderRateLimit, "updated", DefaultConfig.SenderRateLimit)
+		conf.SenderRateLimit = DefaultConfig.SenderRateLimit
+	}
+	if conf.PeerRateLimit < 0 {
+		log.Warn("Sanitizing invalid txpool peer rate limit", "provided", conf.PeerRateLimit, "updated", DefaultConfig.PeerRateLimit)
+		conf.PeerRateLimit = DefaultConfig.PeerRateLimit
+	}
+	if conf.RateLimitBurst < 1 {
+		log.Warn("Sanitizing invalid txpool rate limit burst", "provided", conf.RateLimitBurst, "updated", DefaultConfig.RateLimitBurst)
+		conf.RateLimitBurst = DefaultConfig.RateLimitBurst
+	}
+	if conf.SpamThreshold <= 0 {
+		log.Warn("Sanitizing invalid txpool spam threshold", "provided", conf.SpamThreshold, "updated", DefaultConfig.SpamThreshold)
+		conf.SpamThreshold = DefaultConfig.SpamThreshold
+	}
+	if conf.ThrottleDuration < time.Second {
+		log.Warn("Sanitizing invalid txpool throttle duration", "provided", conf.ThrottleDuration, "updated", DefaultConfig.ThrottleDuration)
+		conf.ThrottleDuration = DefaultConfig.ThrottleDuration
+	}
 	return conf
 }
 
@@ -217,14 +272,16 @@ func (config *Config) sanitize() Config {
 // current state) and future transactions. Transactions move between those
 // two states over time as they are received and processed.
 type LegacyPool struct {
-	config      Config
-	chainconfig *params.ChainConfig
-	chain       BlockChain
-	gasTip      atomic.Pointer[big.Int]
-	minimumFee  *big.Int
-	txFeed      event.Feed
-	signer      types.Signer
-	mu          sync.RWMutex
+	config         Config
+	chainconfig    *params.ChainConfig
+	chain          BlockChain
+	gasTip         atomic.Pointer[big.Int]
+	minimumFee     *big.Int
+	txFeed         event.Feed
+	lifecycleFeed  event.Feed
+	lifecycleScope event.SubscriptionScope
+	signer         types.Signer
+	mu             sync.RWMutex
 
 	// [currentStateLock] is required to allow concurrent access to address nonces
 	// and balances during reorgs and gossip handling.
@@ -237,8 +294,10 @@ type LegacyPool struct {
 	currentState  *state.StateDB               // Current state in the blockchain head
 	pendingNonces *noncer                      // Pending state tracking virtual nonces
 
-	locals  *accountSet // Set of local transaction to exempt from eviction rules
-	journal *journal    // Journal of local transaction to back up to disk
+	locals        *accountSet // Set of local transaction to exempt from eviction rules
+	journal       *journal    // Journal of local transaction to back up to disk
+	remoteJournal *journal    // Journal of remote transactions to periodically snapshot to disk
+	admission     *admission  // Per-sender and per-peer rate limiting, nil if disabled
 
 	reserve txpool.AddressReserver       // Address reserver to ensure exclusivity across subpools
 	pending map[common.Address]*list     // All currently processable transactions
@@ -256,6 +315,10 @@ type LegacyPool struct {
 	initDoneCh      chan struct{}  // is closed once the pool is initialized (for tests)
 
 	changesSinceReorg int // A counter for how many drops we've performed in-between reorg.
+
+	lifecycleChanges []core.TxLifecycleChange // Lifecycle changes not yet sent to subscribers
+	included         map[common.Hash]struct{} // Transactions included by the head change being processed
+	arrivals         map[common.Hash]struct{} // Transactions queued by add since the last reorg run
 }
 
 type txpoolResetRequest struct {
@@ -277,6 +340,7 @@ func New(config Config, chain BlockChain) *LegacyPool {
 		pending:             make(map[common.Address]*list),
 		queue:               make(map[common.Address]*list),
 		beats:               make(map[common.Address]time.Time),
+		arrivals:            make(map[common.Hash]struct{}),
 		all:                 newLookup(),
 		reqResetCh:          make(chan *txpoolResetRequest),
 		reqPromoteCh:        make(chan *accountSet),
@@ -296,6 +360,10 @@ func New(config Config, chain BlockChain) *LegacyPool {
 	if !config.NoLocals && config.Journal != "" {
 		pool.journal = newTxJournal(config.Journal)
 	}
+	if config.RemoteJournal != "" {
+		pool.remoteJournal = newTxJournal(config.RemoteJournal)
+	}
+	pool.admission = newAdmission(config)
 	return pool
 }
 
@@ -349,6 +417,20 @@ func (pool *LegacyPool) Init(gasTip *big.Int, head *types.Header, reserve txpool
 			log.Warn("Failed to rotate transaction journal", "err", err)
 		}
 	}
+	// If remote journaling is enabled, load the snapshotted remote transactions.
+	// These are revalidated against the current head state like any other
+	// remote transaction, so stale ones are dropped.
+	if pool.remoteJournal != nil {
+		if err := pool.remoteJournal.load(pool.addRemotesSync); err != nil {
+			log.Warn("Failed to load remote transaction journal", "err", err)
+		}
+		pool.mu.Lock()
+		remotes := pool.remotes()
+		pool.mu.Unlock()
+		if err := pool.remoteJournal.rotate(remotes); err != nil {
+			log.Warn("Failed to rotate remote transaction journal", "err", err)
+		}
+	}
 	pool.wg.Add(1)
 	go pool.loop()
 
@@ -409,12 +491,18 @@ func (pool *LegacyPool) loop() {
 					for _, tx := range list {
 						pool.removeTx(tx.Hash(), true, true)
 					}
+					pool.queueDropChanges(core.TxDropLifetimeExpired, list)
 					queuedEvictionMeter.Mark(int64(len(list)))
 				}
 			}
 			pool.mu.Unlock()
+			pool.sendLifecycleChanges()
+
+			if pool.admission != nil {
+				pool.admission.cleanup(time.Now())
+			}
 
-		// Handle local transaction journal rotation
+		// Handle local and remote transaction journal rotation
 		case <-journal.C:
 			if pool.journal != nil {
 				pool.mu.Lock()
@@ -423,6 +511,13 @@ func (pool *LegacyPool) loop() {
 				}
 				pool.mu.Unlock()
 			}
+			if pool.remoteJournal != nil {
+				pool.mu.Lock()
+				if err := pool.remoteJournal.rotate(pool.remotes()); err != nil {
+					log.Warn("Failed to rotate remote tx journal", "err", err)
+				}
+				pool.mu.Unlock()
+			}
 		}
 	}
 }
@@ -434,10 +529,19 @@ func (pool *LegacyPool) Close() error {
 	// Terminate the pool reorger and return
 	close(pool.reorgShutdownCh)
 	pool.wg.Wait()
+	pool.lifecycleScope.Close()
 
 	if pool.journal != nil {
 		pool.journal.close()
 	}
+	if pool.remoteJournal != nil {
+		pool.mu.Lock()
+		if err := pool.remoteJournal.rotate(pool.remotes()); err != nil {
+			log.Warn("Failed to rotate remote tx journal", "err", err)
+		}
+		pool.mu.Unlock()
+		pool.remoteJournal.close()
+	}
 	log.Info("Transaction pool stopped")
 	return nil
 }
@@ -459,9 +563,18 @@ func (pool *LegacyPool) SubscribeTransactions(ch chan<- core.NewTxsEvent, reorgs
 	return pool.txFeed.Subscribe(ch)
 }
 
+// SubscribeTxLifecycleEvent registers a subscription for transaction lifecycle
+// events, i.e. pooled transactions being replaced, dropped, promoted from the
+// queue or included in a block.
+func (pool *LegacyPool) SubscribeTxLifecycleEvent(ch chan<- core.TxLifecycleEvent) event.Subscription {
+	return pool.lifecycleScope.Track(pool.lifecycleFeed.Subscribe(ch))
+}
+
 // SetGasTip updates the minimum gas tip required by the transaction pool for a
 // new transaction, and drops all transactions below this threshold.
 func (pool *LegacyPool) SetGasTip(tip *big.Int) {
+	defer pool.sendLifecycleChanges()
+
 	pool.mu.Lock()
 	defer pool.mu.Unlock()
 
@@ -475,6 +588,7 @@ func (pool *LegacyPool) SetGasTip(tip *big.Int) {
 		for _, tx := range drop {
 			pool.removeTx(tx.Hash(), false, true)
 		}
+		pool.queueDropChanges(core.TxDropUnderpriced, drop)
 		pool.priced.Removed(len(drop))
 	}
 	log.Info("Legacy pool tip threshold updated", "tip", tip)
@@ -646,6 +760,41 @@ func (pool *LegacyPool) local() map[common.Address]types.Transactions {
 	return txs
 }
 
+// remotes retrieves the remote transactions to be snapshotted into the remote
+// journal, grouped by origin account and sorted by nonce. At most
+// RemoteJournalAccountSlots transactions are retained per account and at most
+// RemoteJournalSlots in total, preferring executable transactions over queued
+// ones. The returned transaction set is a copy and can be freely modified by
+// calling code.
+//
+// Note, this method assumes the pool lock is held!
+func (pool *LegacyPool) remotes() map[common.Address]types.Transactions {
+	var (
+		txs   = make(map[common.Address]types.Transactions)
+		total uint64
+	)
+	collect := func(lists map[common.Address]*list) {
+		for addr, list := range lists {
+			if pool.locals.contains(addr) {
+				continue
+			}
+			for _, tx := range list.Flatten() {
+				if total >= pool.config.RemoteJournalSlots {
+					return
+				}
+				if uint64(len(txs[addr])) >= pool.config.RemoteJournalAccountSlots {
+					break
+				}
+				txs[addr] = append(txs[addr], tx)
+				total++
+			}
+		}
+	}
+	collect(pool.pending)
+	collect(pool.queue)
+	return txs
+}
+
 // validateTxBasics checks whether a transaction is valid according to the consensus
 // rules, but does not check state-dependent validation such as sufficient balance.
 // This check is meant as an early check which only needs to be performed once,
@@ -825,6 +974,7 @@ func (pool *LegacyPool) add(tx *types.Transaction, local bool) (replaced bool, e
 
 			pool.changesSinceReorg += dropped
 		}
+		pool.queueDropChanges(core.TxDropUnderpriced, drop)
 	}
 
 	// Try to replace an existing transaction in the pending pool
@@ -840,6 +990,7 @@ func (pool *LegacyPool) add(tx *types.Transaction, local bool) (replaced bool, e
 			pool.all.Remove(old.Hash())
 			pool.priced.Removed(1)
 			pendingReplaceMeter.Mark(1)
+			pool.queueReplaceChange(old, tx)
 		}
 		pool.all.Add(tx, isLocal)
 		pool.priced.Put(tx, isLocal)
@@ -856,6 +1007,9 @@ func (pool *LegacyPool) add(tx *types.Transaction, local bool) (replaced bool, e
 	if err != nil {
 		return false, err
 	}
+	// New transactions pass through the queue on their way to the pending set,
+	// only report them as promoted if they had to wait for a later reorg
+	pool.arrivals[hash] = struct{}{}
 	// Mark local addresses and journal local transactions
 	if local && !pool.locals.contains(from) {
 		log.Info("Setting new local account", "address", from)
@@ -915,6 +1069,7 @@ func (pool *LegacyPool) enqueueTx(hash common.Hash, tx *types.Transaction, local
 		pool.all.Remove(old.Hash())
 		pool.priced.Removed(1)
 		queuedReplaceMeter.Mark(1)
+		pool.queueReplaceChange(old, tx)
 	} else {
 		// Nothing was replaced, bump the queued counter
 		queuedGauge.Inc(1)
@@ -964,6 +1119,7 @@ func (pool *LegacyPool) promoteTx(addr common.Address, hash common.Hash, tx *typ
 		pool.all.Remove(hash)
 		pool.priced.Removed(1)
 		pendingDiscardMeter.Mark(1)
+		pool.queueDropChanges(core.TxDropUnderpriced, []*types.Transaction{tx})
 		return false
 	}
 	// Otherwise discard any previous transaction and mark this
@@ -971,6 +1127,7 @@ func (pool *LegacyPool) promoteTx(addr common.Address, hash common.Hash, tx *typ
 		pool.all.Remove(old.Hash())
 		pool.priced.Removed(1)
 		pendingReplaceMeter.Mark(1)
+		pool.queueReplaceChange(old, tx)
 	} else {
 		// Nothing was replaced, bump the pending counter
 		pendingGauge.Inc(1)
@@ -1029,6 +1186,27 @@ func (pool *LegacyPool) addRemoteSync(tx *types.Transaction) error {
 // If sync is set, the method will block until all internal maintenance related
 // to the add is finished. Only use this during tests for determinism!
 func (pool *LegacyPool) Add(txs []*types.Transaction, local, sync bool) []error {
+	return pool.addFrom(txs, local, sync, "")
+}
+
+// AddFromPeer enqueues a batch of remote transactions received from [peer],
+// charging them against the rate limits of both the peer and their senders.
+func (pool *LegacyPool) AddFromPeer(txs []*types.Transaction, peer string, sync bool) []error {
+	return pool.addFrom(txs, false, sync, peer)
+}
+
+// Throttled returns the senders and peers currently throttled by the admission
+// control of the pool.
+func (pool *LegacyPool) Throttled() []txpool.ThrottledSource {
+	if pool.admission == nil {
+		return nil
+	}
+	return pool.admission.throttled(time.Now())
+}
+
+// addFrom enqueues a batch of transactions, optionally attributed to the peer
+// they were received from.
+func (pool *LegacyPool) addFrom(txs []*types.Transaction, local, sync bool, peer string) []error {
 	// Do not treat as local if local transactions have been disabled
 	local = local && !pool.config.NoLocals
 
@@ -1051,8 +1229,21 @@ func (pool *LegacyPool) Add(txs []*types.Transaction, local, sync bool) []error
 			errs[i] = err
 			log.Trace("Discarding invalid transaction", "hash", tx.Hash(), "err", err)
 			invalidTxMeter.Mark(1)
+			if pool.admission != nil {
+				from, _ := types.Sender(pool.signer, tx) // zero address if the signature is invalid
+				pool.admission.reject(from, peer, time.Now())
+			}
 			continue
 		}
+		// Charge the transaction against the rate limits of its sources
+		if pool.admission != nil {
+			from, _ := types.Sender(pool.signer, tx) // already validated
+			if err := pool.admission.admit(from, peer, time.Now()); err != nil {
+				errs[i] = err
+				log.Trace("Discarding throttled transaction", "hash", tx.Hash(), "err", err)
+				continue
+			}
+		}
 		// Accumulate all unknown transactions for deeper processing
 		news = append(news, tx)
 	}
@@ -1066,12 +1257,17 @@ func (pool *LegacyPool) Add(txs []*types.Transaction, local, sync bool) []error
 	pool.mu.Unlock()
 
 	var nilSlot = 0
-	for _, err := range newErrs {
+	for i, err := range newErrs {
 		for errs[nilSlot] != nil {
 			nilSlot++
 		}
 		errs[nilSlot] = err
 		nilSlot++
+
+		if err != nil && pool.admission != nil && !errors.Is(err, txpool.ErrAlreadyKnown) {
+			from, _ := types.Sender(pool.signer, news[i]) // already validated
+			pool.admission.reject(from, peer, time.Now())
+		}
 	}
 	// Reorg the pool internals if needed and return
 	done := pool.requestPromoteExecutables(dirtyAddrs)
@@ -1243,6 +1439,100 @@ func (pool *LegacyPool) queueTxEvent(tx *types.Transaction) {
 	}
 }
 
+// queueLifecycleChange records a transaction lifecycle change to be sent to
+// subscribers once the pool lock is released.
+//
+// Note, this method assumes the pool lock is held!
+func (pool *LegacyPool) queueLifecycleChange(change core.TxLifecycleChange) {
+	if pool.lifecycleScope.Count() == 0 {
+		return
+	}
+	if len(pool.lifecycleChanges) >= lifecycleChangesLimit {
+		pool.lifecycleChanges = pool.lifecycleChanges[1:]
+		lifecycleDiscardMeter.Mark(1)
+	}
+	pool.lifecycleChanges = append(pool.lifecycleChanges, change)
+}
+
+// queueReplaceChange records that [old] was replaced by [tx].
+//
+// Note, this method assumes the pool lock is held!
+func (pool *LegacyPool) queueReplaceChange(old, tx *types.Transaction) {
+	pool.queueLifecycleChange(core.TxLifecycleChange{
+		Hash:        old.Hash(),
+		Status:      core.TxLifecycleReplaced,
+		Replacement: tx.Hash(),
+	})
+}
+
+// queueDropChanges records that [txs] were dropped from the pool for [reason].
+//
+// Note, this method assumes the pool lock is held!
+func (pool *LegacyPool) queueDropChanges(reason core.TxDropReason, txs []*types.Transaction) {
+	for _, tx := range txs {
+		pool.queueLifecycleChange(core.TxLifecycleChange{
+			Hash:   tx.Hash(),
+			Status: core.TxLifecycleDropped,
+			Reason: reason,
+		})
+	}
+}
+
+// queueStaleChanges records the removal of [txs] whose nonce is below the
+// account nonce, reporting them as included if the head change being processed
+// included them and as dropped otherwise.
+//
+// Note, this method assumes the pool lock is held!
+func (pool *LegacyPool) queueStaleChanges(txs []*types.Transaction) {
+	for _, tx := range txs {
+		hash := tx.Hash()
+		if _, ok := pool.included[hash]; ok {
+			pool.queueLifecycleChange(core.TxLifecycleChange{Hash: hash, Status: core.TxLifecycleIncluded})
+			continue
+		}
+		pool.queueLifecycleChange(core.TxLifecycleChange{
+			Hash:   hash,
+			Status: core.TxLifecycleDropped,
+			Reason: core.TxDropNonceTooLow,
+		})
+	}
+}
+
+// sendLifecycleChanges sends all recorded lifecycle changes to subscribers. It
+// must be called without holding the pool lock, as sending may block.
+func (pool *LegacyPool) sendLifecycleChanges() {
+	pool.mu.Lock()
+	changes := pool.lifecycleChanges
+	pool.lifecycleChanges = nil
+	pool.mu.Unlock()
+
+	if len(changes) > 0 {
+		pool.lifecycleFeed.Send(core.TxLifecycleEvent{Changes: changes})
+	}
+}
+
+// includedTxs returns the hashes of the transactions included in the blocks
+// between [oldHead] (exclusive) and [newHead] (inclusive). Head changes that
+// are not a shallow extension of the old head are ignored.
+func (pool *LegacyPool) includedTxs(oldHead, newHead *types.Header) map[common.Hash]struct{} {
+	if oldHead == nil || newHead == nil {
+		return nil
+	}
+	oldNum, newNum := oldHead.Number.Uint64(), newHead.Number.Uint64()
+	if newNum <= oldNum || newNum-oldNum > 64 {
+		return nil
+	}
+	included := make(map[common.Hash]struct{})
+	block := pool.chain.GetBlock(newHead.Hash(), newNum)
+	for block != nil && block.NumberU64() > oldNum {
+		for _, tx := range block.Transactions() {
+			included[tx.Hash()] = struct{}{}
+		}
+		block = pool.chain.GetBlock(block.ParentHash(), block.NumberU64()-1)
+	}
+	return included
+}
+
 // scheduleReorgLoop schedules runs of reset and promoteExecutables. Code above should not
 // call those methods directly, but request them being run using requestReset and
 // requestPromoteExecutables instead.
@@ -1331,6 +1621,10 @@ func (pool *LegacyPool) runReorg(done chan struct{}, reset *txpoolResetRequest,
 	}
 	pool.mu.Lock()
 	if reset != nil {
+		// Track the transactions included by the head change, so that lifecycle
+		// events can tell included transactions apart from stale ones
+		pool.included = pool.includedTxs(reset.oldHead, reset.newHead)
+
 		// Reset from the old head to the new, rescheduling any reorged transactions
 		pool.reset(reset.oldHead, reset.newHead)
 
@@ -1378,8 +1672,16 @@ func (pool *LegacyPool) runReorg(done chan struct{}, reset *txpoolResetRequest,
 
 	dropBetweenReorgHistogram.Update(int64(pool.changesSinceReorg))
 	pool.changesSinceReorg = 0 // Reset change counter
+	pool.included = nil
+	// Transactions left in the queue have now waited for a reorg, so a later
+	// promotion is an actual queued to pending move
+	clear(pool.arrivals)
 	pool.mu.Unlock()
 
+	// Notify subsystems of the lifecycle changes, including any queued by
+	// additions since the previous run
+	pool.sendLifecycleChanges()
+
 	// Notify subsystems for newly added transactions
 	for _, tx := range promoted {
 		addr, _ := types.Sender(pool.signer, tx)
@@ -1521,6 +1823,7 @@ func (pool *LegacyPool) promoteExecutables(accounts []common.Address) []*types.T
 			hash := tx.Hash()
 			pool.all.Remove(hash)
 		}
+		pool.queueStaleChanges(forwards)
 		log.Trace("Removed old queued transactions", "count", len(forwards))
 		// Drop all transactions that are too costly (low balance or out of gas)
 		drops, _ := list.Filter(pool.currentState.GetBalance(addr), gasLimit)
@@ -1528,6 +1831,7 @@ func (pool *LegacyPool) promoteExecutables(accounts []common.Address) []*types.T
 			hash := tx.Hash()
 			pool.all.Remove(hash)
 		}
+		pool.queueDropChanges(core.TxDropInsufficientFunds, drops)
 		log.Trace("Removed unpayable queued transactions", "count", len(drops))
 		queuedNofundsMeter.Mark(int64(len(drops)))
 
@@ -1537,6 +1841,9 @@ func (pool *LegacyPool) promoteExecutables(accounts []common.Address) []*types.T
 			hash := tx.Hash()
 			if pool.promoteTx(addr, hash, tx) {
 				promoted = append(promoted, tx)
+				if _, ok := pool.arrivals[hash]; !ok {
+					pool.queueLifecycleChange(core.TxLifecycleChange{Hash: hash, Status: core.TxLifecyclePromoted})
+				}
 			}
 		}
 		log.Trace("Promoted queued transactions", "count", len(promoted))
@@ -1551,6 +1858,7 @@ func (pool *LegacyPool) promoteExecutables(accounts []common.Address) []*types.T
 				pool.all.Remove(hash)
 				log.Trace("Removed cap-exceeding queued transaction", "hash", hash)
 			}
+			pool.queueDropChanges(core.TxDropAccountSlots, caps)
 			queuedRateLimitMeter.Mark(int64(len(caps)))
 		}
 		// Mark all the items dropped as removed
@@ -1619,6 +1927,7 @@ func (pool *LegacyPool) truncatePending() {
 						pool.pendingNonces.setIfLower(offenders[i], tx.Nonce())
 						log.Trace("Removed fairness-exceeding pending transaction", "hash", hash)
 					}
+					pool.queueDropChanges(core.TxDropAccountSlots, caps)
 					pool.priced.Removed(len(caps))
 					pendingGauge.Dec(int64(len(caps)))
 					if pool.locals.contains(offenders[i]) {
@@ -1646,6 +1955,7 @@ func (pool *LegacyPool) truncatePending() {
 					pool.pendingNonces.setIfLower(addr, tx.Nonce())
 					log.Trace("Removed fairness-exceeding pending transaction", "hash", hash)
 				}
+				pool.queueDropChanges(core.TxDropAccountSlots, caps)
 				pool.priced.Removed(len(caps))
 				pendingGauge.Dec(int64(len(caps)))
 				if pool.locals.contains(addr) {
@@ -1686,9 +1996,11 @@ func (pool *LegacyPool) truncateQueue() {
 
 		// Drop all transactions if they are less than the overflow
 		if size := uint64(list.Len()); size <= drop {
-			for _, tx := range list.Flatten() {
+			txs := list.Flatten()
+			for _, tx := range txs {
 				pool.removeTx(tx.Hash(), true, true)
 			}
+			pool.queueDropChanges(core.TxDropPoolOverflow, txs)
 			drop -= size
 			queuedRateLimitMeter.Mark(int64(size))
 			continue
@@ -1697,6 +2009,7 @@ func (pool *LegacyPool) truncateQueue() {
 		txs := list.Flatten()
 		for i := len(txs) - 1; i >= 0 && drop > 0; i-- {
 			pool.removeTx(txs[i].Hash(), true, true)
+			pool.queueDropChanges(core.TxDropPoolOverflow, txs[i:i+1])
 			drop--
 			queuedRateLimitMeter.Mark(1)
 		}
@@ -1726,6 +2039,7 @@ func (pool *LegacyPool) demoteUnexecutables() {
 			pool.all.Remove(hash)
 			log.Trace("Removed old pending transaction", "hash", hash)
 		}
+		pool.queueStaleChanges(olds)
 		// Drop all transactions that are too costly (low balance or out of gas), and queue any invalids back for later
 		drops, invalids := list.Filter(pool.currentState.GetBalance(addr), gasLimit)
 		for _, tx := range drops {
@@ -1733,6 +2047,7 @@ func (pool *LegacyPool) demoteUnexecutables() {
 			log.Trace("Removed unpayable pending transaction", "hash", hash)
 			pool.all.Remove(hash)
 		}
+		pool.queueDropChanges(core.TxDropInsufficientFunds, drops)
 		pendingNofundsMeter.Mark(int64(len(drops)))
 
 		for _, tx := range invalids {
@@ -256,6 +256,35 @@ func validateEvents(events chan core.NewTxsEvent, count int) error {
 	return nil
 }
 
+// validateLifecycleEvents checks that exactly the expected transaction lifecycle
+// changes were fired on the pool's lifecycle feed, in order.
+func validateLifecycleEvents(events chan core.TxLifecycleEvent, expected []core.TxLifecycleChange) error {
+	var received []core.TxLifecycleChange
+
+	for len(received) < len(expected) {
+		select {
+		case ev := <-events:
+			received = append(received, ev.Changes...)
+		case <-time.After(10 * time.Second):
+			return fmt.Errorf("lifecycle change #%d not fired", len(received))
+		}
+	}
+	select {
+	case ev := <-events:
+		received = append(received, ev.Changes...)
+	case <-time.After(50 * time.Millisecond):
+	}
+	if len(received) != len(expected) {
+		return fmt.Errorf("lifecycle change count mismatch: have %d, want %d", len(received), len(expected))
+	}
+	for i := range expected {
+		if received[i] != expected[i] {
+			return fmt.Errorf("lifecycle change #%d mismatch: have %+v, want %+v", i, received[i], expected[i])
+		}
+	}
+	return nil
+}
+
 func deriveSender(tx *types.Transaction) (common.Address, error) {
 	return types.Sender(types.HomesteadSigner{}, tx)
 }
@@ -2484,6 +2513,137 @@ func testJournaling(t *testing.T, nolocals bool) {
 	pool.Close()
 }
 
+// TestRemoteJournaling tests that remote transactions are snapshotted into the
+// remote journal within the configured caps and revalidated when reloaded.
+func TestRemoteJournaling(t *testing.T) {
+	t.Parallel()
+
+	// Create a temporary file for the journal
+	file, err := os.CreateTemp("", "")
+	if err != nil {
+		t.Fatalf("failed to create temporary journal: %v", err)
+	}
+	journal := file.Name()
+	defer os.Remove(journal)
+
+	// Clean up the temporary file, we only need the path for now
+	file.Close()
+	os.Remove(journal)
+
+	// Create the original pool to inject transaction into the journal
+	statedb, _ := state.New(types.EmptyRootHash, state.NewDatabase(rawdb.NewMemoryDatabase()), nil)
+	blockchain := newTestBlockChain(params.TestChainConfig, 1000000, statedb, new(event.Feed))
+
+	config := testTxPoolConfig
+	config.RemoteJournal = journal
+	config.RemoteJournalAccountSlots = 3
+	config.Rejournal = time.Second
+
+	pool := New(config, blockchain)
+	pool.Init(new(big.Int).SetUint64(config.PriceLimit), blockchain.CurrentBlock(), makeAddressReserver())
+
+	// Create two remote accounts, one of which exceeds the per-account journal cap
+	first, _ := crypto.GenerateKey()
+	second, _ := crypto.GenerateKey()
+
+	testAddBalance(pool, crypto.PubkeyToAddress(first.PublicKey), big.NewInt(1000000000))
+	testAddBalance(pool, crypto.PubkeyToAddress(second.PublicKey), big.NewInt(1000000000))
+
+	for i := uint64(0); i < 4; i++ {
+		if err := pool.addRemoteSync(pricedTransaction(i, 100000, big.NewInt(1), first)); err != nil {
+			t.Fatalf("failed to add remote transaction %d: %v", i, err)
+		}
+	}
+	if err := pool.addRemoteSync(pricedTransaction(0, 100000, big.NewInt(1), second)); err != nil {
+		t.Fatalf("failed to add remote transaction: %v", err)
+	}
+	if err := pool.addRemoteSync(pricedTransaction(2, 100000, big.NewInt(1), second)); err != nil {
+		t.Fatalf("failed to add remote transaction: %v", err)
+	}
+	pending, queued := pool.Stats()
+	if pending != 5 {
+		t.Fatalf("pending transactions mismatched: have %d, want %d", pending, 5)
+	}
+	if queued != 1 {
+		t.Fatalf("queued transactions mismatched: have %d, want %d", queued, 1)
+	}
+	// Terminate the old pool, bump the first nonce, create a new pool and ensure
+	// the capped and still valid remote transactions survive
+	pool.Close()
+	statedb.SetNonce(crypto.PubkeyToAddress(first.PublicKey), 1)
+	blockchain = newTestBlockChain(params.TestChainConfig, 1000000, statedb, new(event.Feed))
+
+	pool = New(config, blockchain)
+	pool.Init(new(big.Int).SetUint64(config.PriceLimit), blockchain.CurrentBlock(), makeAddressReserver())
+
+	pending, queued = pool.Stats()
+	if pending != 3 {
+		t.Fatalf("pending transactions mismatched: have %d, want %d", pending, 3)
+	}
+	if queued != 1 {
+		t.Fatalf("queued transactions mismatched: have %d, want %d", queued, 1)
+	}
+	if pool.locals.contains(crypto.PubkeyToAddress(first.PublicKey)) {
+		t.Fatalf("reloaded remote account marked as local")
+	}
+	if err := validatePoolInternals(pool); err != nil {
+		t.Fatalf("pool internal state corrupted: %v", err)
+	}
+	pool.Close()
+}
+
+// TestTxLifecycleEvents tests that promotions, replacements and drops of pooled
+// transactions are reported on the lifecycle feed.
+func TestTxLifecycleEvents(t *testing.T) {
+	t.Parallel()
+
+	pool, key := setupPool()
+	defer pool.Close()
+
+	events := make(chan core.TxLifecycleEvent, 32)
+	sub := pool.SubscribeTxLifecycleEvent(events)
+	defer sub.Unsubscribe()
+
+	addr := crypto.PubkeyToAddress(key.PublicKey)
+	testAddBalance(pool, addr, big.NewInt(1000000000))
+
+	// Queue up a gapped transaction and fill the gap. Only the gapped one sat in
+	// the queue, the gap filling one is added straight to the pending set.
+	tx0 := pricedTransaction(0, 100000, big.NewInt(1), key)
+	tx1 := pricedTransaction(1, 100000, big.NewInt(1), key)
+	if err := pool.addRemoteSync(tx1); err != nil {
+		t.Fatalf("failed to add gapped transaction: %v", err)
+	}
+	if err := pool.addRemoteSync(tx0); err != nil {
+		t.Fatalf("failed to add gap filling transaction: %v", err)
+	}
+	if err := validateLifecycleEvents(events, []core.TxLifecycleChange{
+		{Hash: tx1.Hash(), Status: core.TxLifecyclePromoted},
+	}); err != nil {
+		t.Fatalf("promotion events mismatch: %v", err)
+	}
+	// Replace a pending transaction with a more expensive one
+	replacement := pricedTransaction(1, 100000, big.NewInt(2), key)
+	if err := pool.addRemoteSync(replacement); err != nil {
+		t.Fatalf("failed to replace pending transaction: %v", err)
+	}
+	if err := validateLifecycleEvents(events, []core.TxLifecycleChange{
+		{Hash: tx1.Hash(), Status: core.TxLifecycleReplaced, Replacement: replacement.Hash()},
+	}); err != nil {
+		t.Fatalf("replacement events mismatch: %v", err)
+	}
+	// Bump the account nonce outside of a block and ensure the stale transaction
+	// is reported as dropped rather than included
+	testSetNonce(pool, addr, 1)
+	<-pool.requestReset(nil, nil)
+
+	if err := validateLifecycleEvents(events, []core.TxLifecycleChange{
+		{Hash: tx0.Hash(), Status: core.TxLifecycleDropped, Reason: core.TxDropNonceTooLow},
+	}); err != nil {
+		t.Fatalf("drop events mismatch: %v", err)
+	}
+}
+
 // TestStatusCheck tests that the pool can correctly retrieve the
 // pending status of individual transactions.
 func TestStatusCheck(t *testing.T) {
@@ -2697,3 +2857,72 @@ func BenchmarkMultiAccountBatchInsert(b *testing.B) {
 		pool.addRemotesSync([]*types.Transaction{tx})
 	}
 }
+
+// TestAdmissionThrottling tests that senders and peers exceeding their rate
+// limits accumulate a spam score and get throttled once it crosses the threshold.
+func TestAdmissionThrottling(t *testing.T) {
+	t.Parallel()
+
+	statedb, _ := state.New(types.EmptyRootHash, state.NewDatabase(rawdb.NewMemoryDatabase()), nil)
+	blockchain := newTestBlockChain(params.TestChainConfig, 1000000, statedb, new(event.Feed))
+
+	config := testTxPoolConfig
+	config.SenderRateLimit = 0.001
+	config.PeerRateLimit = 0.001
+	config.RateLimitBurst = 2
+	config.SpamThreshold = 1.5
+	config.ThrottleDuration = time.Minute
+
+	pool := New(config, blockchain)
+	pool.Init(new(big.Int).SetUint64(config.PriceLimit), blockchain.CurrentBlock(), makeAddressReserver())
+	defer pool.Close()
+
+	keys := make([]*ecdsa.PrivateKey, 5)
+	for i := range keys {
+		keys[i], _ = crypto.GenerateKey()
+		testAddBalance(pool, crypto.PubkeyToAddress(keys[i].PublicKey), big.NewInt(1000000000))
+	}
+	// Exhaust the burst of the first sender, the next submissions exceed the
+	// rate limit and the second violation crosses the spam threshold
+	for i := uint64(0); i < 2; i++ {
+		if err := pool.addRemoteSync(pricedTransaction(i, 100000, big.NewInt(1), keys[0])); err != nil {
+			t.Fatalf("failed to add transaction %d within burst: %v", i, err)
+		}
+	}
+	for i := uint64(2); i < 5; i++ {
+		if err := pool.addRemoteSync(pricedTransaction(i, 100000, big.NewInt(1), keys[0])); !errors.Is(err, txpool.ErrThrottled) {
+			t.Fatalf("transaction %d error mismatch: have %v, want %v", i, err, txpool.ErrThrottled)
+		}
+	}
+	if err := pool.addRemoteSync(pricedTransaction(0, 100000, big.NewInt(1), keys[1])); err != nil {
+		t.Fatalf("failed to add transaction of unrelated sender: %v", err)
+	}
+	// Exhaust the burst of a peer with transactions from distinct senders
+	txs := []*types.Transaction{
+		pricedTransaction(0, 100000, big.NewInt(1), keys[2]),
+		pricedTransaction(0, 100000, big.NewInt(1), keys[3]),
+		pricedTransaction(0, 100000, big.NewInt(1), keys[4]),
+	}
+	errs := pool.AddFromPeer(txs, "peer", true)
+	if errs[0] != nil || errs[1] != nil {
+		t.Fatalf("failed to add transactions within peer burst: %v", errs)
+	}
+	if !errors.Is(errs[2], txpool.ErrThrottled) {
+		t.Fatalf("peer transaction error mismatch: have %v, want %v", errs[2], txpool.ErrThrottled)
+	}
+	// Only the first sender crossed the spam threshold
+	throttled := pool.Throttled()
+	if len(throttled) != 1 {
+		t.Fatalf("throttled sources mismatch: have %d, want %d", len(throttled), 1)
+	}
+	if source := crypto.PubkeyToAddress(keys[0].PublicKey).Hex(); throttled[0].Kind != "sender" || throttled[0].Source != source {
+		t.Fatalf("throttled source mismatch: have %s %s, want sender %s", throttled[0].Kind, throttled[0].Source, source)
+	}
+	pending, _ := pool.Stats()
+	if pending != 5 {
+		t.Fatalf("pending transactions mismatched: have %d, want %d", pending, 5)
+	}
+	if err := validatePoolInternals(pool); err != nil {
+		t.Fatalf("pool internal state corrupted: %v", err)
+	}
+}
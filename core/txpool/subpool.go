@@ -152,3 +152,31 @@ type SubPool interface {
 	// identified by their hashes.
 	Status(hash common.Hash) TxStatus
 }
+
+// LifecycleSubPool is implemented by subpools that report the lifecycle of
+// their transactions beyond entering the pool.
+type LifecycleSubPool interface {
+	// SubscribeTxLifecycleEvent subscribes to events of pooled transactions being
+	// replaced, dropped, promoted from the queue or included in a block.
+	SubscribeTxLifecycleEvent(ch chan<- core.TxLifecycleEvent) event.Subscription
+}
+
+// ThrottledSource is a transaction origin, either a sender account or a peer,
+// that is currently refused by the admission control of a subpool.
+type ThrottledSource struct {
+	Kind   string    `json:"kind"`   // Type of the source ("sender" or "peer")
+	Source string    `json:"source"` // Sender address or peer identifier
+	Score  float64   `json:"score"`  // Spam score that triggered the throttling
+	Until  time.Time `json:"until"`  // Time at which the throttling expires
+}
+
+// AdmissionSubPool is implemented by subpools that rate limit and score the
+// sources of submitted transactions.
+type AdmissionSubPool interface {
+	// AddFromPeer enqueues a batch of remote transactions received from the
+	// given peer, accounting them against both their senders and the peer.
+	AddFromPeer(txs []*types.Transaction, peer string, sync bool) []error
+
+	// Throttled returns the transaction sources currently being throttled.
+	Throttled() []ThrottledSource
+}
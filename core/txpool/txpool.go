@@ -322,6 +322,19 @@ func (p *TxPool) Get(hash common.Hash) *types.Transaction {
 // to the large transaction churn, add may postpone fully integrating the tx
 // to a later point to batch multiple ones together.
 func (p *TxPool) Add(txs []*types.Transaction, local bool, sync bool) []error {
+	return p.add(txs, local, sync, "")
+}
+
+// AddFromPeer enqueues a batch of remote transactions received from the given
+// peer. Subpools supporting admission control account the transactions against
+// the peer as well as their senders.
+func (p *TxPool) AddFromPeer(txs []*types.Transaction, peer string, sync bool) []error {
+	return p.add(txs, false, sync, peer)
+}
+
+// add splits the transactions between the subpools and pieces back the errors
+// in the original order. If peer is set, the transactions are attributed to it.
+func (p *TxPool) add(txs []*types.Transaction, local bool, sync bool, peer string) []error {
 	// Split the input transactions between the subpools. It shouldn't really
 	// happen that we receive merged batches, but better graceful than strange
 	// errors.
@@ -348,6 +361,10 @@ func (p *TxPool) Add(txs []*types.Transaction, local bool, sync bool) []error {
 	// back the errors into the original sort order.
 	errsets := make([][]error, len(p.subpools))
 	for i := 0; i < len(p.subpools); i++ {
+		if subpool, ok := p.subpools[i].(AdmissionSubPool); ok && peer != "" {
+			errsets[i] = subpool.AddFromPeer(txsets[i], peer, sync)
+			continue
+		}
 		errsets[i] = p.subpools[i].Add(txsets[i], local, sync)
 	}
 	errs := make([]error, len(txs))
@@ -426,6 +443,30 @@ func (p *TxPool) SubscribeTransactions(ch chan<- core.NewTxsEvent, reorgs bool)
 	return p.subs.Track(event.JoinSubscriptions(subs...))
 }
 
+// SubscribeTxLifecycleEvent registers a subscription for the lifecycle events
+// of pooled transactions, reported by the subpools that support them.
+func (p *TxPool) SubscribeTxLifecycleEvent(ch chan<- core.TxLifecycleEvent) event.Subscription {
+	var subs []event.Subscription
+	for _, subpool := range p.subpools {
+		if subpool, ok := subpool.(LifecycleSubPool); ok {
+			subs = append(subs, subpool.SubscribeTxLifecycleEvent(ch))
+		}
+	}
+	return p.subs.Track(event.JoinSubscriptions(subs...))
+}
+
+// Throttled returns the transaction sources currently throttled by the
+// admission control of the subpools.
+func (p *TxPool) Throttled() []ThrottledSource {
+	var sources []ThrottledSource
+	for _, subpool := range p.subpools {
+		if subpool, ok := subpool.(AdmissionSubPool); ok {
+			sources = append(sources, subpool.Throttled()...)
+		}
+	}
+	return sources
+}
+
 // SubscribeNewReorgEvent registers a subscription of NewReorgEvent and
 // starts sending event to the given channel.
 func (p *TxPool) SubscribeNewReorgEvent(ch chan<- core.NewTxPoolReorgEvent) event.Subscription {
@@ -27,7 +27,11 @@
 package eth
 
 import (
+	"context"
+
+	"github.com/ava-labs/coreth/eth/gasprice"
 	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
 )
 
 // EthereumAPI provides an API to access Ethereum full node-related information.
@@ -49,3 +53,42 @@ func (api *EthereumAPI) Etherbase() (common.Address, error) {
 func (api *EthereumAPI) Coinbase() (common.Address, error) {
 	return api.Etherbase()
 }
+
+// feeTierResult is a suggested fee tier, as returned by eth_suggestFees.
+type feeTierResult struct {
+	MaxFeePerGas         *hexutil.Big   `json:"maxFeePerGas"`
+	MaxPriorityFeePerGas *hexutil.Big   `json:"maxPriorityFeePerGas"`
+	InclusionBlocks      hexutil.Uint64 `json:"inclusionBlocks"`
+}
+
+// suggestFeesResult is the result of eth_suggestFees.
+type suggestFeesResult struct {
+	BaseFee  *hexutil.Big   `json:"baseFeePerGas"`
+	Slow     *feeTierResult `json:"slow"`
+	Standard *feeTierResult `json:"standard"`
+	Fast     *feeTierResult `json:"fast"`
+}
+
+func newFeeTierResult(tier *gasprice.FeeTier) *feeTierResult {
+	return &feeTierResult{
+		MaxFeePerGas:         (*hexutil.Big)(tier.MaxFeePerGas),
+		MaxPriorityFeePerGas: (*hexutil.Big)(tier.MaxPriorityFeePerGas),
+		InclusionBlocks:      hexutil.Uint64(tier.InclusionBlocks),
+	}
+}
+
+// SuggestFees returns slow, standard and fast suggestions for the fee fields of
+// dynamic fee transactions, along with the number of blocks each is expected
+// to be included within. It returns null if dynamic fees are not activated.
+func (api *EthereumAPI) SuggestFees(ctx context.Context) (*suggestFeesResult, error) {
+	fees, err := api.e.APIBackend.SuggestFees(ctx)
+	if err != nil || fees == nil {
+		return nil, err
+	}
+	return &suggestFeesResult{
+		BaseFee:  (*hexutil.Big)(fees.BaseFee),
+		Slow:     newFeeTierResult(fees.Slow),
+		Standard: newFeeTierResult(fees.Standard),
+		Fast:     newFeeTierResult(fees.Fast),
+	}, nil
+}
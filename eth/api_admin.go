@@ -35,7 +35,13 @@ import (
 	"strings"
 
 	"github.com/ava-labs/coreth/core"
+	"github.com/ava-labs/coreth/core/rawdb"
+	"github.com/ava-labs/coreth/core/state/pruner"
+	"github.com/ava-labs/coreth/core/state/verifier"
+	"github.com/ava-labs/coreth/core/txpool"
 	"github.com/ava-labs/coreth/core/types"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
 	"github.com/ethereum/go-ethereum/rlp"
 )
 
@@ -151,3 +157,188 @@ func (api *AdminAPI) ImportChain(file string) (bool, error) {
 	}
 	return true, nil
 }
+
+// ThrottledTxSources returns the transaction senders and peers currently
+// throttled by the admission control of the transaction pool.
+func (api *AdminAPI) ThrottledTxSources() []txpool.ThrottledSource {
+	sources := api.eth.TxPool().Throttled()
+	if sources == nil {
+		return []txpool.ThrottledSource{}
+	}
+	return sources
+}
+
+// RebuildLogIndex deletes the log index and restarts indexing from the last
+// accepted block, backfilling the previous blocks in the background. Range
+// filters fall back to bloom scanning until the index covers their range.
+func (api *AdminAPI) RebuildLogIndex() (bool, error) {
+	if api.eth.logIndexer == nil {
+		return false, errors.New("log index is not enabled")
+	}
+	if err := api.eth.logIndexer.Rebuild(); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// StartOnlinePruning starts pruning the stale state in the background while
+// the node keeps accepting blocks. The progress is reported by
+// OnlinePruningStatus. It fails while a state export is running.
+func (api *AdminAPI) StartOnlinePruning() (bool, error) {
+	if api.eth.onlinePruner == nil {
+		return false, errors.New("online pruning is not enabled")
+	}
+	if err := api.eth.onlinePruner.Prune(); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// OnlinePruningStatus returns the progress of the current or last online
+// pruning run.
+func (api *AdminAPI) OnlinePruningStatus() (*pruner.OnlineStatus, error) {
+	if api.eth.onlinePruner == nil {
+		return nil, errors.New("online pruning is not enabled")
+	}
+	status := api.eth.onlinePruner.Status()
+	return &status, nil
+}
+
+// VerifyState starts verifying in the background that the state with [root]
+// is complete and uncorrupted: every node of the account trie and of the
+// storage tries must be present and hash correctly, and the code of every
+// contract must be present. If [root] is nil, the last accepted state which is
+// committed to disk is verified. The progress and the missing or corrupt
+// nodes found are reported by VerifyStateStatus.
+func (api *AdminAPI) VerifyState(root *common.Hash) (bool, error) {
+	var stateRoot common.Hash
+	if root != nil {
+		stateRoot = *root
+		if !api.eth.blockchain.HasState(stateRoot) {
+			return false, fmt.Errorf("state %s is not available", stateRoot)
+		}
+	} else {
+		var err error
+		if stateRoot, err = api.committedStateRoot(); err != nil {
+			return false, err
+		}
+	}
+	if err := api.eth.stateVerifier.VerifyState(api.eth.chainDb, api.eth.blockchain.TrieDB(), stateRoot); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// VerifyStateStatus returns the progress of the current or last state
+// verification, along with the problems found.
+func (api *AdminAPI) VerifyStateStatus() verifier.Status {
+	return api.eth.stateVerifier.Status()
+}
+
+// CancelVerifyState interrupts the running state verification.
+func (api *AdminAPI) CancelVerifyState() (bool, error) {
+	if err := api.eth.stateVerifier.Cancel(); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// ExportPreimages exports every preimage stored in the database into a local
+// file, in the format used by go-ethereum. The file is gzipped if its name
+// ends with .gz.
+func (api *AdminAPI) ExportPreimages(file string) (hexutil.Uint64, error) {
+	if _, err := os.Stat(file); err == nil {
+		// File already exists. Allowing overwrite could be a DoS vector,
+		// since the 'file' may point to arbitrary paths on the drive.
+		return 0, errors.New("location would overwrite an existing file")
+	}
+	out, err := os.OpenFile(file, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0644)
+	if err != nil {
+		return 0, err
+	}
+	defer out.Close()
+
+	var writer io.Writer = out
+	if strings.HasSuffix(file, ".gz") {
+		writer = gzip.NewWriter(writer)
+		defer writer.(*gzip.Writer).Close()
+	}
+	count, err := core.ExportPreimages(api.eth.chainDb, writer)
+	return hexutil.Uint64(count), err
+}
+
+// ImportPreimages imports the preimages of a local file written by
+// ExportPreimages.
+func (api *AdminAPI) ImportPreimages(file string) (hexutil.Uint64, error) {
+	in, err := os.Open(file)
+	if err != nil {
+		return 0, err
+	}
+	defer in.Close()
+
+	var reader io.Reader = in
+	if strings.HasSuffix(file, ".gz") {
+		if reader, err = gzip.NewReader(reader); err != nil {
+			return 0, err
+		}
+	}
+	count, err := core.ImportPreimages(api.eth.chainDb, reader)
+	return hexutil.Uint64(count), err
+}
+
+// BackfillPreimages starts re-executing the accepted blocks [from, to] in the
+// background to store the missing preimages of the addresses, storage keys
+// and hashed data they access. [to] defaults to the last accepted block. With
+// the hash scheme, the state of the parent of [from] must be committed to
+// disk. The progress is reported by BackfillPreimagesStatus.
+func (api *AdminAPI) BackfillPreimages(from uint64, to *uint64) (bool, error) {
+	last := api.eth.blockchain.LastAcceptedBlock().NumberU64()
+	end := last
+	if to != nil {
+		end = *to
+	}
+	if from == 0 || from > end || end > last {
+		return false, fmt.Errorf("invalid block range [%d, %d], last accepted block is %d", from, end, last)
+	}
+	if err := api.eth.preimageBackfill.Start(from, end); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// BackfillPreimagesStatus returns the progress of the current or last
+// preimage backfill.
+func (api *AdminAPI) BackfillPreimagesStatus() PreimageBackfillStatus {
+	return api.eth.preimageBackfill.Status()
+}
+
+// CancelBackfillPreimages interrupts the running preimage backfill.
+func (api *AdminAPI) CancelBackfillPreimages() (bool, error) {
+	if err := api.eth.preimageBackfill.Cancel(); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// committedStateRoot returns the root of the last accepted state which is
+// entirely on disk. With the hash scheme, the nodes of the states which are
+// only in memory may be dereferenced while they are verified.
+func (api *AdminAPI) committedStateRoot() (common.Hash, error) {
+	bc := api.eth.blockchain
+	if bc.TrieDB().Scheme() != rawdb.HashScheme {
+		return bc.LastAcceptedBlock().Root(), nil
+	}
+	for number := bc.LastAcceptedBlock().NumberU64(); ; number-- {
+		block := bc.GetBlockByNumber(number)
+		if block == nil {
+			break
+		}
+		if rawdb.HasLegacyTrieNode(api.eth.chainDb, block.Root()) {
+			return block.Root(), nil
+		}
+		if number == 0 {
+			break
+		}
+	}
+	return common.Hash{}, errors.New("no committed state found")
+}
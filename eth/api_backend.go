@@ -48,10 +48,18 @@ import (
 	"github.com/ethereum/go-ethereum/common"
 	"github.com/ethereum/go-ethereum/ethdb"
 	"github.com/ethereum/go-ethereum/event"
+	"github.com/ethereum/go-ethereum/log"
 )
 
 var ErrUnfinalizedData = errors.New("cannot query unfinalized data")
 
+// regenStatePinTimeout bounds how long a regenerated state returned by the
+// backend is kept from eviction, since callers cannot release it explicitly
+// and the contexts of some of them are never done. The release of a state
+// whose context is not done yet is logged, since the state may still be read.
+// It must be kept in sync with the configuration docs.
+var regenStatePinTimeout = time.Minute
+
 // EthAPIBackend implements ethapi.Backend and tracers.Backend for full nodes
 type EthAPIBackend struct {
 	extRPCEnabled            bool
@@ -167,7 +175,11 @@ func (b *EthAPIBackend) BlockByNumber(ctx context.Context, number rpc.BlockNumbe
 		}
 	}
 
-	return b.eth.blockchain.GetBlockByNumber(uint64(number)), nil
+	block := b.eth.blockchain.GetBlockByNumber(uint64(number))
+	if block == nil && b.eth.blockchain.IsHistoryPruned(uint64(number)) {
+		return nil, core.ErrHistoryPruned
+	}
+	return block, nil
 }
 
 func (b *EthAPIBackend) BlockByHash(ctx context.Context, hash common.Hash) (*types.Block, error) {
@@ -177,6 +189,9 @@ func (b *EthAPIBackend) BlockByHash(ctx context.Context, hash common.Hash) (*typ
 
 	block := b.eth.blockchain.GetBlockByHash(hash)
 	if block == nil {
+		if header := b.eth.blockchain.GetHeaderByHash(hash); header != nil && b.eth.blockchain.IsHistoryPruned(header.Number.Uint64()) {
+			return nil, core.ErrHistoryPruned
+		}
 		return nil, nil
 	}
 
@@ -238,7 +253,7 @@ func (b *EthAPIBackend) StateAndHeaderByNumber(ctx context.Context, number rpc.B
 	if header == nil {
 		return nil, nil, errors.New("header not found")
 	}
-	stateDb, err := b.eth.BlockChain().StateAt(header.Root)
+	stateDb, err := b.stateAt(ctx, header)
 	if err != nil {
 		return nil, nil, err
 	}
@@ -260,7 +275,7 @@ func (b *EthAPIBackend) StateAndHeaderByNumberOrHash(ctx context.Context, blockN
 		if header == nil {
 			return nil, nil, errors.New("header for hash not found")
 		}
-		stateDb, err := b.eth.BlockChain().StateAt(header.Root)
+		stateDb, err := b.stateAt(ctx, header)
 		if err != nil {
 			return nil, nil, err
 		}
@@ -269,18 +284,57 @@ func (b *EthAPIBackend) StateAndHeaderByNumberOrHash(ctx context.Context, blockN
 	return nil, nil, errors.New("invalid arguments; neither block nor hash specified")
 }
 
+// stateAt returns the state of [header]. If it is not available and the
+// historical state regeneration is enabled, the state is regenerated and held
+// until [ctx] is done, for at most regenStatePinTimeout.
+func (b *EthAPIBackend) stateAt(ctx context.Context, header *types.Header) (*state.StateDB, error) {
+	stateDb, err := b.eth.BlockChain().StateAt(header.Root)
+	if err == nil || b.eth.stateRegen == nil {
+		return stateDb, err
+	}
+	block := b.eth.BlockChain().GetBlock(header.Hash(), header.Number.Uint64())
+	if block == nil {
+		return nil, err
+	}
+	stateDb, release, err := b.eth.stateAtBlock(ctx, block, b.eth.config.HistoricalStateRegenLimit, nil, false, false)
+	if err != nil {
+		return nil, err
+	}
+	pinCtx, cancel := context.WithTimeout(ctx, regenStatePinTimeout)
+	context.AfterFunc(pinCtx, func() {
+		cancel()
+		release()
+		// The state may still be in use, in which case reading trie nodes
+		// which were not loaded yet fails once they are evicted.
+		if ctx.Err() == nil {
+			log.Warn("Released regenerated state after pin timeout", "number", header.Number, "root", header.Root, "timeout", regenStatePinTimeout)
+		}
+	})
+	return stateDb, nil
+}
+
 func (b *EthAPIBackend) GetReceipts(ctx context.Context, hash common.Hash) (types.Receipts, error) {
 	if err := ctx.Err(); err != nil {
 		return nil, err
 	}
-	return b.eth.blockchain.GetReceiptsByHash(hash), nil
+	receipts := b.eth.blockchain.GetReceiptsByHash(hash)
+	if receipts == nil {
+		if header := b.eth.blockchain.GetHeaderByHash(hash); header != nil && b.eth.blockchain.IsHistoryPruned(header.Number.Uint64()) {
+			return nil, core.ErrHistoryPruned
+		}
+	}
+	return receipts, nil
 }
 
 func (b *EthAPIBackend) GetLogs(ctx context.Context, hash common.Hash, number uint64) ([][]*types.Log, error) {
 	if err := ctx.Err(); err != nil {
 		return nil, err
 	}
-	return b.eth.blockchain.GetLogs(hash, number), nil
+	logs := b.eth.blockchain.GetLogs(hash, number)
+	if logs == nil && b.eth.blockchain.IsHistoryPruned(number) {
+		return nil, core.ErrHistoryPruned
+	}
+	return logs, nil
 }
 
 func (b *EthAPIBackend) GetEVM(ctx context.Context, msg *core.Message, state *state.StateDB, header *types.Header, vmConfig *vm.Config, blockCtx *vm.BlockContext) *vm.EVM {
@@ -368,6 +422,13 @@ func (b *EthAPIBackend) GetTransaction(ctx context.Context, txHash common.Hash)
 	// Note: we only index transactions during Accept, so the below check against unfinalized queries is technically redundant, but
 	// we keep it for defense in depth.
 	tx, blockHash, blockNumber, index := rawdb.ReadTransaction(b.eth.ChainDb(), txHash)
+	if tx == nil {
+		// The transaction is still indexed if its block body was deleted by
+		// history expiry.
+		if number := rawdb.ReadTxLookupEntry(b.eth.ChainDb(), txHash); number != nil && b.eth.blockchain.IsHistoryPruned(*number) {
+			return nil, common.Hash{}, 0, 0, core.ErrHistoryPruned
+		}
+	}
 
 	// Respond as if the transaction does not exist if it is not yet in an
 	// accepted block. We explicitly choose not to error here to avoid breaking
@@ -403,6 +464,10 @@ func (b *EthAPIBackend) SubscribeNewTxsEvent(ch chan<- core.NewTxsEvent) event.S
 	return b.eth.txPool.SubscribeTransactions(ch, true)
 }
 
+func (b *EthAPIBackend) SubscribeTxLifecycleEvent(ch chan<- core.TxLifecycleEvent) event.Subscription {
+	return b.eth.txPool.SubscribeTxLifecycleEvent(ch)
+}
+
 func (b *EthAPIBackend) EstimateBaseFee(ctx context.Context) (*big.Int, error) {
 	return b.gpo.EstimateBaseFee(ctx)
 }
@@ -415,6 +480,10 @@ func (b *EthAPIBackend) SuggestGasTipCap(ctx context.Context) (*big.Int, error)
 	return b.gpo.SuggestTipCap(ctx)
 }
 
+func (b *EthAPIBackend) SuggestFees(ctx context.Context) (*gasprice.SuggestedFees, error) {
+	return b.gpo.SuggestFees(ctx)
+}
+
 func (b *EthAPIBackend) FeeHistory(ctx context.Context, blockCount uint64, lastBlock rpc.BlockNumber, rewardPercentiles []float64) (firstBlock *big.Int, reward [][]*big.Int, baseFee []*big.Int, gasUsedRatio []float64, err error) {
 	return b.gpo.FeeHistory(ctx, blockCount, lastBlock, rewardPercentiles)
 }
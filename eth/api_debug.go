@@ -34,6 +34,7 @@ import (
 
 	"github.com/ava-labs/coreth/core/rawdb"
 	"github.com/ava-labs/coreth/core/state"
+	"github.com/ava-labs/coreth/core/state/sizestats"
 	"github.com/ava-labs/coreth/core/types"
 	"github.com/ava-labs/coreth/internal/ethapi"
 	"github.com/ava-labs/coreth/rpc"
@@ -371,3 +372,57 @@ func (api *DebugAPI) GetAccessibleState(from, to rpc.BlockNumber) (uint64, error
 	}
 	return 0, errors.New("no state found")
 }
+
+const (
+	defaultStateSizeCount = 10
+	maxStateSizeCount     = 1000
+)
+
+// StateSizeStatsResult is the result of debug_stateSizeStats.
+type StateSizeStatsResult struct {
+	*sizestats.Stats
+	Growth *sizestats.Growth `json:"growth,omitempty"`
+}
+
+// StateSizeStats returns the storage size of the state of the last accepted
+// block and the [count] contracts with the largest storage. If [from] is set,
+// it also returns the storage growth over the blocks [from, to] and the
+// [count] contracts with the largest growth. [to] defaults to the last
+// accepted block.
+func (api *DebugAPI) StateSizeStats(count *int, from, to *rpc.BlockNumber) (*StateSizeStatsResult, error) {
+	tracker := api.eth.blockchain.StateSizeStats()
+	if tracker == nil {
+		return nil, errors.New("state size statistics are not enabled")
+	}
+	n := defaultStateSizeCount
+	if count != nil {
+		n = *count
+	}
+	if n < 0 || n > maxStateSizeCount {
+		return nil, fmt.Errorf("count must be in [0, %d], got %d", maxStateSizeCount, n)
+	}
+	stats, err := tracker.Stats(n)
+	if err != nil {
+		return nil, err
+	}
+	result := &StateSizeStatsResult{Stats: stats}
+	if from == nil {
+		return result, nil
+	}
+	// Negative block numbers refer to the last block applied to the statistics.
+	resolve := func(number rpc.BlockNumber) uint64 {
+		if number < 0 {
+			return stats.Number
+		}
+		return uint64(number)
+	}
+	end := stats.Number
+	if to != nil {
+		end = resolve(*to)
+	}
+	result.Growth, err = tracker.Growth(resolve(*from), end, n)
+	if err != nil {
+		return nil, err
+	}
+	return result, nil
+}
@@ -42,6 +42,7 @@ import (
 	"github.com/ava-labs/coreth/core/bloombits"
 	"github.com/ava-labs/coreth/core/rawdb"
 	"github.com/ava-labs/coreth/core/state/pruner"
+	"github.com/ava-labs/coreth/core/state/verifier"
 	"github.com/ava-labs/coreth/core/txpool"
 	"github.com/ava-labs/coreth/core/txpool/legacypool"
 	"github.com/ava-labs/coreth/core/types"
@@ -49,6 +50,7 @@ import (
 	"github.com/ava-labs/coreth/eth/ethconfig"
 	"github.com/ava-labs/coreth/eth/filters"
 	"github.com/ava-labs/coreth/eth/gasprice"
+	"github.com/ava-labs/coreth/eth/tokens"
 	"github.com/ava-labs/coreth/eth/tracers"
 	"github.com/ava-labs/coreth/internal/ethapi"
 	"github.com/ava-labs/coreth/internal/shutdowncheck"
@@ -99,6 +101,15 @@ type Ethereum struct {
 	bloomIndexer      *core.ChainIndexer             // Bloom indexer operating during block imports
 	closeBloomHandler chan struct{}
 
+	traceIndexer *tracers.TraceIndexer // Call trace address indexer, nil if disabled
+	tokenIndexer *tokens.Indexer       // Token transfer indexer, nil if disabled
+	logIndexer   *filters.LogIndexer   // Log address and topic indexer, nil if disabled
+
+	onlinePruner     *pruner.OnlinePruner // Background state pruner, nil if disabled
+	stateVerifier    *verifier.Verifier   // Background state trie verifier
+	preimageBackfill *preimageBackfiller  // Background preimage backfill
+	stateRegen       *stateRegenCache     // Regenerated historical states, nil if disabled
+
 	APIBackend *EthAPIBackend
 
 	miner     *miner.Miner
@@ -224,9 +235,12 @@ func New(
 			Preimages:                       config.Preimages,
 			AcceptedCacheSize:               config.AcceptedCacheSize,
 			TxLookupLimit:                   config.TxLookupLimit,
+			HistoryExpiry:                   config.HistoryExpiry,
 			SkipTxIndexing:                  config.SkipTxIndexing,
 			StateHistory:                    config.StateHistory,
 			StateScheme:                     scheme,
+			StateSizeStats:                  config.StateSizeStats,
+			StateSizeStatsRetention:         config.StateSizeStatsRetention,
 		}
 	)
 
@@ -242,6 +256,22 @@ func New(
 		return nil, err
 	}
 
+	if config.OnlinePruning {
+		eth.onlinePruner, err = pruner.NewOnlinePruner(chainDb, eth.blockchain, pruner.OnlineConfig{
+			Datadir:   config.OnlinePruningDataDirectory,
+			BloomSize: config.OnlinePruningBloomFilterSize,
+			SweepRate: config.OnlinePruningSweepRate,
+		})
+		if err != nil {
+			return nil, fmt.Errorf("failed to create online pruner: %w", err)
+		}
+	}
+	eth.stateVerifier = verifier.New()
+	eth.preimageBackfill = newPreimageBackfiller(eth.blockchain)
+	if config.HistoricalStateRegenLimit != 0 && eth.blockchain.TrieDB().Scheme() == rawdb.HashScheme {
+		eth.stateRegen = newStateRegenCache(chainDb, config.HistoricalStateCache)
+	}
+
 	eth.bloomIndexer.Start(eth.blockchain)
 
 	// Uncomment the following to enable the new blobpool
@@ -279,6 +309,16 @@ func New(
 		return nil, err
 	}
 
+	if config.TraceIndex {
+		eth.traceIndexer = tracers.NewTraceIndexer(eth.APIBackend)
+	}
+	if config.TokenIndex {
+		eth.tokenIndexer = tokens.NewIndexer(eth.APIBackend)
+	}
+	if config.LogIndex {
+		eth.logIndexer = filters.NewLogIndexer(eth.APIBackend)
+	}
+
 	// Start the RPC service
 	eth.netRPCService = ethapi.NewNetAPI(eth.NetVersion())
 
@@ -296,14 +336,20 @@ func (s *Ethereum) APIs() []rpc.API {
 	apis := ethapi.GetAPIs(s.APIBackend)
 
 	// Append tracing APIs
-	apis = append(apis, tracers.APIs(s.APIBackend)...)
+	apis = append(apis, tracers.APIs(s.APIBackend, s.traceIndexer)...)
+
+	// Append the token index APIs if enabled
+	if s.tokenIndexer != nil {
+		apis = append(apis, tokens.APIs(s.tokenIndexer)...)
+	}
 
 	// Add the APIs from the node
 	apis = append(apis, s.stackRPCs...)
 
 	// Create [filterSystem] with the log cache size set in the config.
 	filterSystem := filters.NewFilterSystem(s.APIBackend, filters.Config{
-		Timeout: 5 * time.Minute,
+		Timeout:    5 * time.Minute,
+		LogIndexer: s.logIndexer,
 	})
 
 	// Append all the local APIs and return
@@ -365,6 +411,10 @@ func (s *Ethereum) NetVersion() uint64               { return s.networkID }
 func (s *Ethereum) ArchiveMode() bool                { return !s.config.Pruning }
 func (s *Ethereum) BloomIndexer() *core.ChainIndexer { return s.bloomIndexer }
 
+// OnlinePruner returns the background state pruner, or nil if online pruning
+// is disabled.
+func (s *Ethereum) OnlinePruner() *pruner.OnlinePruner { return s.onlinePruner }
+
 // Start implements node.Lifecycle, starting all internal goroutines needed by the
 // Ethereum protocol implementation.
 func (s *Ethereum) Start() {
@@ -381,6 +431,20 @@ func (s *Ethereum) Start() {
 func (s *Ethereum) Stop() error {
 	s.bloomIndexer.Close()
 	close(s.closeBloomHandler)
+	if s.traceIndexer != nil {
+		s.traceIndexer.Stop()
+	}
+	if s.tokenIndexer != nil {
+		s.tokenIndexer.Stop()
+	}
+	if s.logIndexer != nil {
+		s.logIndexer.Stop()
+	}
+	if s.onlinePruner != nil {
+		s.onlinePruner.Stop()
+	}
+	s.stateVerifier.Stop()
+	s.preimageBackfill.Stop()
 	s.txPool.Close()
 	s.blockchain.Stop()
 	s.engine.Close()
@@ -62,6 +62,7 @@ func NewDefaultConfig() Config {
 		TrieDirtyCommitTarget:     20,
 		TriePrefetcherParallelism: 16,
 		SnapshotCache:             256,
+		HistoricalStateCache:      256,
 		AcceptedCacheSize:         32,
 		Miner:                     miner.Config{},
 		TxPool:                    legacypool.DefaultConfig,
@@ -151,6 +152,13 @@ type Config struct {
 	OfflinePruningBloomFilterSize uint64
 	OfflinePruningDataDirectory   string
 
+	// OnlinePruning enables pruning the stale state in the background while
+	// the node keeps accepting blocks.
+	OnlinePruning                bool
+	OnlinePruningBloomFilterSize uint64
+	OnlinePruningDataDirectory   string
+	OnlinePruningSweepRate       float64
+
 	// SkipUpgradeCheck disables checking that upgrades must take place before the last
 	// accepted block. Skipping this check is useful when a node operator does not update
 	// their node before the network upgrade and their node accepts blocks that have
@@ -166,6 +174,13 @@ type Config struct {
 	TransactionHistory uint64 `toml:",omitempty"` // The maximum number of blocks from head whose tx indices are reserved.
 	StateHistory       uint64 `toml:",omitempty"` // The maximum number of blocks from head whose state histories are reserved.
 
+	// HistoryExpiry is the maximum number of blocks from the last accepted
+	// block whose bodies and receipts are reserved. Headers and canonical
+	// hashes are always kept:
+	//  * 0:   means no limit
+	//  * N:   means N block limit [LAST-N+1, LAST] and delete older bodies and receipts
+	HistoryExpiry uint64 `toml:",omitempty"`
+
 	// State scheme represents the scheme used to store ethereum states and trie
 	// nodes on top. It can be 'hash', 'path', or none which means use the scheme
 	// consistent with persistent state.
@@ -175,4 +190,30 @@ type Config struct {
 	// This is useful for validators that don't need to index transactions.
 	// TxLookupLimit can be still used to control unindexing old transactions.
 	SkipTxIndexing bool
+
+	// TraceIndex enables indexing the addresses appearing in the call traces
+	// of accepted blocks, used to serve trace_filter.
+	TraceIndex bool
+
+	// TokenIndex enables indexing the ERC-20 and ERC-721 token transfers of
+	// accepted blocks, used to serve the tokens namespace.
+	TokenIndex bool
+
+	// LogIndex enables indexing the log addresses and topics of accepted
+	// blocks, used to serve range filters.
+	LogIndex bool
+
+	// StateSizeStats enables maintaining the storage size of every account,
+	// used to serve debug_stateSizeStats. StateSizeStatsRetention is the
+	// number of recent blocks whose storage changes are kept, 0 keeps all.
+	StateSizeStats          bool
+	StateSizeStatsRetention uint64 `toml:",omitempty"`
+
+	// HistoricalStateRegenLimit is the maximum number of blocks re-executed to
+	// regenerate a historical state, 0 disables the regeneration. A
+	// regenerated state is held for at most one minute by the request using
+	// it. HistoricalStateCache is the memory allowance (MB) of the regenerated
+	// states kept for later queries.
+	HistoricalStateRegenLimit uint64 `toml:",omitempty"`
+	HistoricalStateCache      int    `toml:",omitempty"`
 }
@@ -35,6 +35,7 @@ import (
 	"sync"
 	"time"
 
+	"github.com/ava-labs/coreth/core"
 	"github.com/ava-labs/coreth/core/types"
 	"github.com/ava-labs/coreth/interfaces"
 	"github.com/ava-labs/coreth/internal/ethapi"
@@ -42,6 +43,7 @@ import (
 	"github.com/ethereum/go-ethereum/common"
 	"github.com/ethereum/go-ethereum/common/hexutil"
 	"github.com/ethereum/go-ethereum/event"
+	"github.com/ethereum/go-ethereum/log"
 )
 
 var (
@@ -49,10 +51,17 @@ var (
 	errFilterNotFound    = errors.New("filter not found")
 	errInvalidBlockRange = errors.New("invalid block range params")
 	errExceedMaxTopics   = errors.New("exceed max topics")
+	errResumeOverflow    = errors.New("too many events accepted while sending the accepted history")
 )
 
-// The maximum number of topic criteria allowed, vm.LOG4 - vm.LOG0
-const maxTopics = 4
+const (
+	// The maximum number of topic criteria allowed, vm.LOG4 - vm.LOG0
+	maxTopics = 4
+
+	// maxResumeBuffered is the maximum number of events buffered by a resumed
+	// subscription while sending the accepted history, after which it fails.
+	maxResumeBuffered = 1024
+)
 
 // filter is a helper struct that holds meta information over the filter type
 // and associated subscription in the event system.
@@ -242,6 +251,57 @@ func (api *FilterAPI) NewAcceptedTransactions(ctx context.Context, fullTx *bool)
 	return rpcSub, nil
 }
 
+// TxLifecycleChange is the notification sent to txpoolEvents subscribers when
+// a pooled transaction is replaced, dropped, promoted or included.
+type TxLifecycleChange struct {
+	Hash        common.Hash            `json:"hash"`
+	Status      core.TxLifecycleStatus `json:"status"`
+	Replacement *common.Hash           `json:"replacement,omitempty"`
+	Reason      core.TxDropReason      `json:"reason,omitempty"`
+}
+
+// TxpoolEvents creates a subscription that is triggered each time a pooled
+// transaction is replaced (with the replacement hash), dropped (with the
+// reason), promoted from the queued to the pending set or included in a block.
+func (api *FilterAPI) TxpoolEvents(ctx context.Context) (*rpc.Subscription, error) {
+	notifier, supported := rpc.NotifierFromContext(ctx)
+	if !supported {
+		return &rpc.Subscription{}, rpc.ErrNotificationsUnsupported
+	}
+
+	rpcSub := notifier.CreateSubscription()
+
+	go func() {
+		changes := make(chan []core.TxLifecycleChange, 128)
+		txLifecycleSub := api.events.SubscribeTxLifecycle(changes)
+
+		for {
+			select {
+			case changes := <-changes:
+				for _, change := range changes {
+					notification := &TxLifecycleChange{
+						Hash:   change.Hash,
+						Status: change.Status,
+						Reason: change.Reason,
+					}
+					if change.Status == core.TxLifecycleReplaced {
+						notification.Replacement = &change.Replacement
+					}
+					notifier.Notify(rpcSub.ID, notification)
+				}
+			case <-rpcSub.Err():
+				txLifecycleSub.Unsubscribe()
+				return
+			case <-notifier.Closed():
+				txLifecycleSub.Unsubscribe()
+				return
+			}
+		}
+	}()
+
+	return rpcSub, nil
+}
+
 // NewBlockFilter creates a filter that fetches blocks that are imported into the chain.
 // It is part of the filter package since polling goes with eth_getFilterChanges.
 func (api *FilterAPI) NewBlockFilter() rpc.ID {
@@ -281,12 +341,22 @@ func (api *FilterAPI) NewBlockFilter() rpc.ID {
 	return headerSub.ID
 }
 
+// HeadsCriteria are the optional parameters of a newHeads subscription.
+type HeadsCriteria struct {
+	FromBlock *rpc.BlockNumber `json:"fromBlock"`
+}
+
 // NewHeads send a notification each time a new (header) block is appended to the chain.
-func (api *FilterAPI) NewHeads(ctx context.Context) (*rpc.Subscription, error) {
+// If [crit] specifies a block number to start from, the headers of the accepted blocks
+// from it on are sent first, followed by the headers of newly accepted blocks.
+func (api *FilterAPI) NewHeads(ctx context.Context, crit *HeadsCriteria) (*rpc.Subscription, error) {
 	notifier, supported := rpc.NotifierFromContext(ctx)
 	if !supported {
 		return &rpc.Subscription{}, rpc.ErrNotificationsUnsupported
 	}
+	if crit != nil && crit.FromBlock != nil && *crit.FromBlock >= 0 {
+		return api.resumeHeads(notifier, uint64(*crit.FromBlock))
+	}
 
 	rpcSub := notifier.CreateSubscription()
 
@@ -319,12 +389,141 @@ func (api *FilterAPI) NewHeads(ctx context.Context) (*rpc.Subscription, error) {
 	return rpcSub, nil
 }
 
+// resumeHeads creates a newHeads subscription resumed from the block [from]. The
+// headers of the accepted blocks from it on are sent in order, followed by the
+// headers of newly accepted blocks, without gaps or duplicates.
+func (api *FilterAPI) resumeHeads(notifier *rpc.Notifier, from uint64) (*rpc.Subscription, error) {
+	if err := api.checkResumeRange(from); err != nil {
+		return nil, err
+	}
+	var (
+		rpcSub     = notifier.CreateSubscription()
+		headers    = make(chan *types.Header)
+		headersSub = api.events.SubscribeAcceptedHeads(headers)
+		next       = from // Next block whose header is to be sent
+	)
+	// backfill sends the headers of the accepted blocks from next up to and
+	// including the last accepted block.
+	backfill := func(ctx context.Context) error {
+		for to := api.sys.backend.LastAcceptedBlock().NumberU64(); next <= to; next++ {
+			if err := ctx.Err(); err != nil {
+				return err
+			}
+			header, err := api.sys.backend.HeaderByNumber(ctx, rpc.BlockNumber(next))
+			if err != nil {
+				return err
+			}
+			if header == nil {
+				return fmt.Errorf("header %d not found", next)
+			}
+			if err := notifier.Notify(rpcSub.ID, header); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	// send sends the header of a newly accepted block, unless it was sent
+	// from the accepted history already.
+	send := func(h *types.Header) error {
+		number := h.Number.Uint64()
+		if number < next {
+			return nil
+		}
+		next = number + 1
+		return notifier.Notify(rpcSub.ID, h)
+	}
+
+	go func() {
+		defer headersSub.Unsubscribe()
+		runResumed(notifier, rpcSub, headers, backfill, send)
+	}()
+
+	return rpcSub, nil
+}
+
+// checkResumeRange checks that the accepted history from the block [from] can
+// be sent to a resumed subscription within the maximum number of blocks per
+// request.
+func (api *FilterAPI) checkResumeRange(from uint64) error {
+	lastAccepted := api.sys.backend.LastAcceptedBlock().NumberU64()
+	if maxBlocks := api.sys.backend.GetMaxBlocksPerRequest(); maxBlocks > 0 && from <= lastAccepted && int64(lastAccepted-from) >= maxBlocks {
+		return fmt.Errorf("requested too many blocks from %d to %d, maximum is set to %d", from, lastAccepted, maxBlocks)
+	}
+	return nil
+}
+
+// runResumed runs a subscription resumed from the accepted history. The
+// subscription to the newly accepted events on [events] is made before sending
+// the history with [backfill], so that no block is missed in between. Events
+// received while the history is sent are buffered rather than blocking the
+// event system, and passed to [send] once it is sent, followed by the events
+// received later on. Failures to send the history end the subscription,
+// reporting the error to the client.
+func runResumed[T any](notifier *rpc.Notifier, rpcSub *rpc.Subscription, events <-chan T, backfill func(context.Context) error, send func(T) error) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	var (
+		done     = make(chan error, 1)
+		buffered []T
+	)
+	go func() { done <- backfill(ctx) }()
+	for done != nil {
+		select {
+		case ev := <-events:
+			if len(buffered) == maxResumeBuffered {
+				cancel()
+				<-done
+				notifier.Fail(rpcSub.ID, errResumeOverflow)
+				return
+			}
+			buffered = append(buffered, ev)
+		case err := <-done:
+			if err != nil {
+				log.Debug("Failed to resume subscription", "id", rpcSub.ID, "err", err)
+				notifier.Fail(rpcSub.ID, err)
+				return
+			}
+			done = nil
+		case <-rpcSub.Err(): // client send an unsubscribe request
+			return
+		case <-notifier.Closed(): // connection dropped
+			return
+		}
+	}
+	for _, ev := range buffered {
+		if err := send(ev); err != nil {
+			return
+		}
+	}
+	buffered = nil
+
+	for {
+		select {
+		case ev := <-events:
+			if err := send(ev); err != nil {
+				return
+			}
+		case <-rpcSub.Err(): // client send an unsubscribe request
+			return
+		case <-notifier.Closed(): // connection dropped
+			return
+		}
+	}
+}
+
 // Logs creates a subscription that fires for all new log that match the given filter criteria.
+// If the criteria specify a block number to start from, and no block number to end at, the
+// matching logs of the accepted blocks from it on are sent first, followed by the matching
+// logs of newly accepted blocks.
 func (api *FilterAPI) Logs(ctx context.Context, crit FilterCriteria) (*rpc.Subscription, error) {
 	notifier, supported := rpc.NotifierFromContext(ctx)
 	if !supported {
 		return &rpc.Subscription{}, rpc.ErrNotificationsUnsupported
 	}
+	if crit.FromBlock != nil && crit.FromBlock.Sign() >= 0 && (crit.ToBlock == nil || crit.ToBlock.Int64() == rpc.LatestBlockNumber.Int64()) {
+		return api.resumeLogs(notifier, crit)
+	}
 
 	var (
 		rpcSub      = notifier.CreateSubscription()
@@ -366,6 +565,71 @@ func (api *FilterAPI) Logs(ctx context.Context, crit FilterCriteria) (*rpc.Subsc
 	return rpcSub, nil
 }
 
+// resumeLogs creates a logs subscription resumed from the block [crit.FromBlock].
+// The matching logs of the accepted blocks from it on are sent in order,
+// followed by the matching logs of newly accepted blocks, without gaps or
+// duplicates.
+func (api *FilterAPI) resumeLogs(notifier *rpc.Notifier, crit FilterCriteria) (*rpc.Subscription, error) {
+	if len(crit.Topics) > maxTopics {
+		return nil, errExceedMaxTopics
+	}
+	from := crit.FromBlock.Uint64()
+	if err := api.checkResumeRange(from); err != nil {
+		return nil, err
+	}
+	matchedLogs := make(chan []*types.Log)
+	logsSub, err := api.events.SubscribeAcceptedLogs(interfaces.FilterQuery(crit), matchedLogs)
+	if err != nil {
+		return nil, err
+	}
+	var (
+		rpcSub = notifier.CreateSubscription()
+		next   = from // Next block whose logs are to be sent
+	)
+	// backfill sends the matching logs of the accepted blocks from next up to
+	// and including the last accepted block.
+	backfill := func(ctx context.Context) error {
+		to := api.sys.backend.LastAcceptedBlock().NumberU64()
+		if to < next {
+			return nil
+		}
+		logs, err := api.sys.NewRangeFilter(int64(next), int64(to), crit.Addresses, crit.Topics).Logs(ctx)
+		if err != nil {
+			return err
+		}
+		for _, l := range logs {
+			if err := notifier.Notify(rpcSub.ID, l); err != nil {
+				return err
+			}
+		}
+		next = to + 1
+		return nil
+	}
+	// send sends the matching logs of a newly accepted block, unless they were
+	// sent from the accepted history already.
+	send := func(logs []*types.Log) error {
+		for _, l := range logs {
+			if l.BlockNumber < next {
+				continue
+			}
+			if err := notifier.Notify(rpcSub.ID, l); err != nil {
+				return err
+			}
+		}
+		if len(logs) > 0 {
+			next = max(next, logs[len(logs)-1].BlockNumber+1)
+		}
+		return nil
+	}
+
+	go func() {
+		defer logsSub.Unsubscribe()
+		runResumed(notifier, rpcSub, matchedLogs, backfill, send)
+	}()
+
+	return rpcSub, nil
+}
+
 // FilterCriteria represents a request to create a new filter.
 // Same as interfaces.FilterQuery but with UnmarshalJSON() method.
 type FilterCriteria interfaces.FilterQuery
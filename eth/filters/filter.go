@@ -193,11 +193,6 @@ func (f *Filter) Logs(ctx context.Context) ([]*types.Log, error) {
 		return nil, fmt.Errorf("begin block %d is greater than end block %d", f.begin, f.end)
 	}
 
-	// If the requested range of blocks exceeds the maximum number of blocks allowed by the backend
-	// return an error instead of searching for the logs.
-	if maxBlocks := f.sys.backend.GetMaxBlocksPerRequest(); f.end-f.begin >= maxBlocks && maxBlocks > 0 {
-		return nil, fmt.Errorf("requested too many blocks from %d to %d, maximum is set to %d", f.begin, f.end, maxBlocks)
-	}
 	// Gather all indexed logs, and finish with non indexed ones
 	logChan, errChan := f.rangeLogsAsync(ctx)
 	var logs []*types.Log
@@ -229,6 +224,21 @@ func (f *Filter) rangeLogsAsync(ctx context.Context) (chan *types.Log, chan erro
 			close(logChan)
 		}()
 
+		// Serve the range from the log index if it covers it
+		if indexer := f.sys.cfg.LogIndexer; indexer != nil {
+			if ok, err := f.logIndexLogs(ctx, indexer, logChan); ok {
+				errChan <- err
+				return
+			}
+		}
+
+		// If the requested range of blocks exceeds the maximum number of blocks allowed by the backend
+		// return an error instead of searching for the logs.
+		if maxBlocks := f.sys.backend.GetMaxBlocksPerRequest(); f.end-f.begin >= maxBlocks && maxBlocks > 0 {
+			errChan <- fmt.Errorf("requested too many blocks from %d to %d, maximum is set to %d", f.begin, f.end, maxBlocks)
+			return
+		}
+
 		// Gather all indexed logs, and finish with non indexed ones
 		var (
 			end            = uint64(f.end)
@@ -302,6 +312,42 @@ func (f *Filter) indexedLogs(ctx context.Context, end uint64, logChan chan *type
 	}
 }
 
+// logIndexLogs returns the logs matching the filter criteria within the blocks
+// found to hold matching logs by the log index. It returns false if the index
+// does not cover the range of the filter. The range served by the index is not
+// limited, so the maximum number of blocks allowed by the backend limits the
+// number of matching blocks loaded instead.
+func (f *Filter) logIndexLogs(ctx context.Context, indexer *LogIndexer, logChan chan *types.Log) (bool, error) {
+	var (
+		maxBlocks = f.sys.backend.GetMaxBlocksPerRequest()
+		loaded    int64
+	)
+	return indexer.matchingBlocks(uint64(f.begin), uint64(f.end), f.addresses, f.topics, func(number uint64) error {
+		if loaded++; maxBlocks > 0 && loaded > maxBlocks {
+			return fmt.Errorf("requested logs from too many blocks from %d to %d, maximum is set to %d", f.begin, f.end, maxBlocks)
+		}
+		header, err := f.sys.backend.HeaderByNumber(ctx, rpc.BlockNumber(number))
+		if err != nil {
+			return err
+		}
+		if header == nil {
+			return fmt.Errorf("header %d not found", number)
+		}
+		found, err := f.checkMatches(ctx, header)
+		if err != nil {
+			return err
+		}
+		for _, log := range found {
+			select {
+			case logChan <- log:
+			case <-ctx.Done():
+				return ctx.Err()
+			}
+		}
+		return nil
+	})
+}
+
 // unindexedLogs returns the logs matching the filter criteria based on raw block
 // iteration and bloom matching.
 func (f *Filter) unindexedLogs(ctx context.Context, end uint64, logChan chan *types.Log) error {
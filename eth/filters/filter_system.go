@@ -48,7 +48,8 @@ import (
 
 // Config represents the configuration of the filter system.
 type Config struct {
-	Timeout time.Duration // how long filters stay active (default: 5min)
+	Timeout    time.Duration // how long filters stay active (default: 5min)
+	LogIndexer *LogIndexer   // optional log index serving range filters
 }
 
 func (cfg Config) withDefaults() Config {
@@ -79,6 +80,8 @@ type Backend interface {
 
 	SubscribeAcceptedTransactionEvent(ch chan<- core.NewTxsEvent) event.Subscription
 
+	SubscribeTxLifecycleEvent(ch chan<- core.TxLifecycleEvent) event.Subscription
+
 	BloomStatus() (uint64, uint64)
 	ServiceFilter(ctx context.Context, session *bloombits.MatcherSession)
 
@@ -142,6 +145,9 @@ const (
 	BlocksSubscription
 	// AcceptedBlocksSubscription queries hashes for blocks that are accepted
 	AcceptedBlocksSubscription
+	// TxLifecycleSubscription queries for pooled transactions being replaced,
+	// dropped, promoted or included
+	TxLifecycleSubscription
 	// LastIndexSubscription keeps track of the last index
 	LastIndexSubscription
 )
@@ -165,6 +171,7 @@ type subscription struct {
 	logsCrit  interfaces.FilterQuery
 	logs      chan []*types.Log
 	txs       chan []*types.Transaction
+	txChanges chan []core.TxLifecycleChange
 	headers   chan *types.Header
 	installed chan struct{} // closed when the filter is installed
 	err       chan error    // closed when the filter is uninstalled
@@ -185,6 +192,7 @@ type EventSystem struct {
 	chainSub         event.Subscription // Subscription for new chain event
 	chainAcceptedSub event.Subscription // Subscription for new chain accepted event
 	txsAcceptedSub   event.Subscription // Subscription for new accepted txs
+	txLifecycleSub   event.Subscription // Subscription for tx lifecycle changes
 
 	// Channels
 	install         chan *subscription         // install filter for event notification
@@ -197,6 +205,7 @@ type EventSystem struct {
 	chainCh         chan core.ChainEvent       // Channel to receive new chain event
 	chainAcceptedCh chan core.ChainEvent       // Channel to receive new chain accepted event
 	txsAcceptedCh   chan core.NewTxsEvent      // Channel to receive new accepted txs
+	txLifecycleCh   chan core.TxLifecycleEvent // Channel to receive tx lifecycle changes
 }
 
 // NewEventSystem creates a new manager that listens for event on the given mux,
@@ -219,6 +228,7 @@ func NewEventSystem(sys *FilterSystem) *EventSystem {
 		chainCh:         make(chan core.ChainEvent, chainEvChanSize),
 		chainAcceptedCh: make(chan core.ChainEvent, chainEvChanSize),
 		txsAcceptedCh:   make(chan core.NewTxsEvent, txChanSize),
+		txLifecycleCh:   make(chan core.TxLifecycleEvent, txChanSize),
 	}
 
 	// Subscribe events
@@ -230,9 +240,10 @@ func NewEventSystem(sys *FilterSystem) *EventSystem {
 	m.chainAcceptedSub = m.backend.SubscribeChainAcceptedEvent(m.chainAcceptedCh)
 	m.pendingLogsSub = m.backend.SubscribePendingLogsEvent(m.pendingLogsCh)
 	m.txsAcceptedSub = m.backend.SubscribeAcceptedTransactionEvent(m.txsAcceptedCh)
+	m.txLifecycleSub = m.backend.SubscribeTxLifecycleEvent(m.txLifecycleCh)
 
 	// Make sure none of the subscriptions are empty
-	if m.txsSub == nil || m.logsSub == nil || m.logsAcceptedSub == nil || m.rmLogsSub == nil || m.chainSub == nil || m.chainAcceptedSub == nil || m.pendingLogsSub == nil || m.txsAcceptedSub == nil {
+	if m.txsSub == nil || m.logsSub == nil || m.logsAcceptedSub == nil || m.rmLogsSub == nil || m.chainSub == nil || m.chainAcceptedSub == nil || m.pendingLogsSub == nil || m.txsAcceptedSub == nil || m.txLifecycleSub == nil {
 		log.Crit("Subscribe for event system failed")
 	}
 
@@ -267,6 +278,7 @@ func (sub *Subscription) Unsubscribe() {
 				break uninstallLoop
 			case <-sub.f.logs:
 			case <-sub.f.txs:
+			case <-sub.f.txChanges:
 			case <-sub.f.headers:
 			}
 		}
@@ -482,6 +494,23 @@ func (es *EventSystem) SubscribeAcceptedTxs(txs chan []*types.Transaction) *Subs
 	return es.subscribe(sub)
 }
 
+// SubscribeTxLifecycle creates a subscription that writes the lifecycle changes
+// of pooled transactions, i.e. them being replaced, dropped, promoted or included.
+func (es *EventSystem) SubscribeTxLifecycle(changes chan []core.TxLifecycleChange) *Subscription {
+	sub := &subscription{
+		id:        rpc.NewID(),
+		typ:       TxLifecycleSubscription,
+		created:   time.Now(),
+		logs:      make(chan []*types.Log),
+		txs:       make(chan []*types.Transaction),
+		txChanges: changes,
+		headers:   make(chan *types.Header),
+		installed: make(chan struct{}),
+		err:       make(chan error),
+	}
+	return es.subscribe(sub)
+}
+
 type filterIndex map[Type]map[rpc.ID]*subscription
 
 func (es *EventSystem) handleLogs(filters filterIndex, ev []*types.Log) {
@@ -531,6 +560,12 @@ func (es *EventSystem) handleTxsEvent(filters filterIndex, ev core.NewTxsEvent,
 	}
 }
 
+func (es *EventSystem) handleTxLifecycleEvent(filters filterIndex, ev core.TxLifecycleEvent) {
+	for _, f := range filters[TxLifecycleSubscription] {
+		f.txChanges <- ev.Changes
+	}
+}
+
 func (es *EventSystem) handleChainEvent(filters filterIndex, ev core.ChainEvent) {
 	for _, f := range filters[BlocksSubscription] {
 		f.headers <- ev.Block.Header()
@@ -555,6 +590,7 @@ func (es *EventSystem) eventLoop() {
 		es.chainSub.Unsubscribe()
 		es.chainAcceptedSub.Unsubscribe()
 		es.txsAcceptedSub.Unsubscribe()
+		es.txLifecycleSub.Unsubscribe()
 	}()
 
 	index := make(filterIndex)
@@ -580,6 +616,8 @@ func (es *EventSystem) eventLoop() {
 			es.handleChainAcceptedEvent(index, ev)
 		case ev := <-es.txsAcceptedCh:
 			es.handleTxsEvent(index, ev, true)
+		case ev := <-es.txLifecycleCh:
+			es.handleTxLifecycleEvent(index, ev)
 
 		case f := <-es.install:
 			if f.typ == MinedAndPendingLogsSubscription {
@@ -616,6 +654,8 @@ func (es *EventSystem) eventLoop() {
 			return
 		case <-es.txsAcceptedSub.Err():
 			return
+		case <-es.txLifecycleSub.Err():
+			return
 		}
 	}
 }
@@ -42,11 +42,14 @@ import (
 	"github.com/ava-labs/coreth/core/bloombits"
 	"github.com/ava-labs/coreth/core/rawdb"
 	"github.com/ava-labs/coreth/core/types"
+	"github.com/ava-labs/coreth/core/vm"
 	"github.com/ava-labs/coreth/interfaces"
 	"github.com/ava-labs/coreth/internal/ethapi"
 	"github.com/ava-labs/coreth/params"
 	"github.com/ava-labs/coreth/rpc"
+	"github.com/ava-labs/coreth/trie"
 	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/crypto"
 	"github.com/ethereum/go-ethereum/ethdb"
 	"github.com/ethereum/go-ethereum/event"
 	"github.com/stretchr/testify/require"
@@ -57,11 +60,15 @@ type testBackend struct {
 	sections          uint64
 	txFeed            event.Feed
 	acceptedTxFeed    event.Feed
+	txLifecycleFeed   event.Feed
 	logsFeed          event.Feed
 	rmLogsFeed        event.Feed
 	pendingLogsFeed   event.Feed
 	chainFeed         event.Feed
 	chainAcceptedFeed event.Feed
+
+	maxBlocksPerRequest int64
+	headerHook          func(number uint64) // Called when a header is retrieved by number
 }
 
 func (b *testBackend) ChainConfig() *params.ChainConfig {
@@ -82,7 +89,7 @@ func (b *testBackend) IsAllowUnfinalizedQueries() bool {
 }
 
 func (b *testBackend) GetMaxBlocksPerRequest() int64 {
-	return 0
+	return b.maxBlocksPerRequest
 }
 
 func (b *testBackend) LastAcceptedBlock() *types.Block {
@@ -118,6 +125,9 @@ func (b *testBackend) HeaderByNumber(ctx context.Context, blockNr rpc.BlockNumbe
 	default:
 		num = uint64(blockNr)
 		hash = rawdb.ReadCanonicalHash(b.db, num)
+		if b.headerHook != nil {
+			b.headerHook(num)
+		}
 	}
 	return rawdb.ReadHeader(b.db, hash, num), nil
 }
@@ -155,6 +165,10 @@ func (b *testBackend) SubscribeNewTxsEvent(ch chan<- core.NewTxsEvent) event.Sub
 	return b.txFeed.Subscribe(ch)
 }
 
+func (b *testBackend) SubscribeTxLifecycleEvent(ch chan<- core.TxLifecycleEvent) event.Subscription {
+	return b.txLifecycleFeed.Subscribe(ch)
+}
+
 func (b *testBackend) SubscribeRemovedLogsEvent(ch chan<- core.RemovedLogsEvent) event.Subscription {
 	return b.rmLogsFeed.Subscribe(ch)
 }
@@ -336,6 +350,39 @@ func TestPendingTxFilter(t *testing.T) {
 	}
 }
 
+// TestTxLifecycleSubscription tests whether tx lifecycle subscriptions receive
+// the changes posted by the transaction pool.
+func TestTxLifecycleSubscription(t *testing.T) {
+	t.Parallel()
+
+	var (
+		db           = rawdb.NewMemoryDatabase()
+		backend, sys = newTestFilterSystem(t, db, Config{})
+		api          = NewFilterAPI(sys)
+
+		changes = []core.TxLifecycleChange{
+			{Hash: common.HexToHash("0x01"), Status: core.TxLifecyclePromoted},
+			{Hash: common.HexToHash("0x02"), Status: core.TxLifecycleReplaced, Replacement: common.HexToHash("0x03")},
+			{Hash: common.HexToHash("0x04"), Status: core.TxLifecycleDropped, Reason: core.TxDropUnderpriced},
+		}
+	)
+
+	ch := make(chan []core.TxLifecycleChange)
+	sub := api.events.SubscribeTxLifecycle(ch)
+	defer sub.Unsubscribe()
+
+	backend.txLifecycleFeed.Send(core.TxLifecycleEvent{Changes: changes})
+
+	select {
+	case got := <-ch:
+		if !reflect.DeepEqual(got, changes) {
+			t.Fatalf("lifecycle changes mismatch: have %v, want %v", got, changes)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("timeout waiting for lifecycle changes")
+	}
+}
+
 // TestPendingTxFilterFullTx tests whether pending tx filters retrieve all pending transactions that are posted to the event mux.
 func TestPendingTxFilterFullTx(t *testing.T) {
 	t.Parallel()
@@ -890,3 +937,231 @@ func TestGetLogsRegression(t *testing.T) {
 	_, err := api.GetLogs(context.Background(), test)
 	require.Error(t, err, "unknown block")
 }
+
+var logTestTopics = []common.Hash{{0x01}, {0x02}}
+
+// newLogTestChain creates a chain of 12 blocks, each calling a contract
+// emitting a log with logTestTopics[i%2] for block i, of which the first 10
+// are inserted into the database.
+func newLogTestChain(t *testing.T) (*testBackend, *FilterAPI, *core.BlockChain, []*types.Block) {
+	var (
+		db           = rawdb.NewMemoryDatabase()
+		backend, sys = newTestFilterSystem(t, db, Config{})
+		api          = NewFilterAPI(sys)
+		key, _       = crypto.GenerateKey()
+		addr         = crypto.PubkeyToAddress(key.PublicKey)
+		signer       = types.LatestSigner(params.TestChainConfig)
+		contract     = common.Address{0xfe}
+		gspec        = &core.Genesis{
+			Config: params.TestChainConfig,
+			Alloc: core.GenesisAlloc{
+				addr:     {Balance: big.NewInt(params.Ether)},
+				contract: {Balance: big.NewInt(0), Code: common.FromHex("0x60003560006000a1")}, // LOG1(0, 0, calldata[0:32])
+			},
+			BaseFee: big.NewInt(1),
+		}
+	)
+	_, err := gspec.Commit(db, trie.NewDatabase(db, nil))
+	require.NoError(t, err)
+	chain, _, err := core.GenerateChain(gspec.Config, gspec.ToBlock(), dummy.NewFaker(), db, 12, 10, func(i int, gen *core.BlockGen) {
+		tx, err := types.SignTx(types.NewTx(&types.LegacyTx{
+			Nonce:    uint64(i),
+			GasPrice: gen.BaseFee(),
+			Gas:      30000,
+			To:       &contract,
+			Data:     logTestTopics[(i+1)%2].Bytes(),
+		}), signer, key)
+		require.NoError(t, err)
+		gen.AddTx(tx)
+	})
+	require.NoError(t, err)
+	bc, err := core.NewBlockChain(db, core.DefaultCacheConfig, gspec, dummy.NewCoinbaseFaker(), vm.Config{}, gspec.ToBlock().Hash(), false)
+	require.NoError(t, err)
+	t.Cleanup(bc.Stop)
+	_, err = bc.InsertChain(chain[:10])
+	require.NoError(t, err)
+	return backend, api, bc, chain
+}
+
+func TestResumeLogsSubscription(t *testing.T) {
+	backend, api, bc, chain := newLogTestChain(t)
+
+	server := rpc.NewServer(0)
+	defer server.Stop()
+	require.NoError(t, server.RegisterName("eth", api))
+	client := rpc.DialInProc(server)
+	defer client.Close()
+
+	// Backfilling more blocks than allowed per request fails.
+	backend.maxBlocksPerRequest = 5
+	_, err := client.EthSubscribe(context.Background(), make(chan *types.Log), "logs", map[string]interface{}{"fromBlock": "0x3"})
+	require.ErrorContains(t, err, "requested too many blocks")
+	backend.maxBlocksPerRequest = 0
+
+	logs := make(chan *types.Log)
+	sub, err := client.EthSubscribe(context.Background(), logs, "logs", map[string]interface{}{"fromBlock": "0x3"})
+	require.NoError(t, err)
+	defer sub.Unsubscribe()
+
+	receive := func(number uint64) {
+		select {
+		case l := <-logs:
+			require.Equal(t, number, l.BlockNumber)
+		case <-time.After(5 * time.Second):
+			t.Fatalf("timeout waiting for logs of block %d", number)
+		}
+	}
+	// The logs of the accepted blocks from block 3 on are sent first.
+	for number := uint64(3); number <= 10; number++ {
+		receive(number)
+	}
+
+	// Logs already sent from the accepted history are not sent again.
+	backend.logsFeed.Send(bc.GetLogs(chain[9].Hash(), 10)[0])
+	_, err = bc.InsertChain(chain[10:])
+	require.NoError(t, err)
+	for _, block := range chain[10:] {
+		backend.logsFeed.Send(bc.GetLogs(block.Hash(), block.NumberU64())[0])
+	}
+	receive(11)
+	receive(12)
+	select {
+	case l := <-logs:
+		t.Fatalf("unexpected log of block %d", l.BlockNumber)
+	case <-time.After(100 * time.Millisecond):
+	}
+}
+
+func TestResumeHeadsSubscription(t *testing.T) {
+	backend, api, bc, chain := newLogTestChain(t)
+
+	server := rpc.NewServer(0)
+	defer server.Stop()
+	require.NoError(t, server.RegisterName("eth", api))
+	client := rpc.DialInProc(server)
+	defer client.Close()
+
+	headers := make(chan *types.Header)
+	sub, err := client.EthSubscribe(context.Background(), headers, "newHeads", map[string]interface{}{"fromBlock": "0x8"})
+	require.NoError(t, err)
+	defer sub.Unsubscribe()
+
+	receive := func(number uint64) {
+		select {
+		case h := <-headers:
+			require.Equal(t, number, h.Number.Uint64())
+		case <-time.After(5 * time.Second):
+			t.Fatalf("timeout waiting for header %d", number)
+		}
+	}
+	// The headers of the accepted blocks from block 8 on are sent first.
+	for number := uint64(8); number <= 10; number++ {
+		receive(number)
+	}
+
+	// Headers already sent from the accepted history are not sent again.
+	backend.chainAcceptedFeed.Send(core.ChainEvent{Block: chain[9], Hash: chain[9].Hash()})
+	_, err = bc.InsertChain(chain[10:])
+	require.NoError(t, err)
+	for _, block := range chain[10:] {
+		backend.chainAcceptedFeed.Send(core.ChainEvent{Block: block, Hash: block.Hash()})
+	}
+	receive(11)
+	receive(12)
+	select {
+	case h := <-headers:
+		t.Fatalf("unexpected header %d", h.Number)
+	case <-time.After(100 * time.Millisecond):
+	}
+}
+
+func TestResumeHeadsSubscriptionBuffering(t *testing.T) {
+	backend, api, bc, chain := newLogTestChain(t)
+
+	// Hold the history being sent at block 9.
+	var (
+		reached = make(chan struct{})
+		release = make(chan struct{})
+	)
+	backend.headerHook = func(number uint64) {
+		if number == 9 {
+			close(reached)
+			<-release
+		}
+	}
+	server := rpc.NewServer(0)
+	defer server.Stop()
+	require.NoError(t, server.RegisterName("eth", api))
+	client := rpc.DialInProc(server)
+	defer client.Close()
+
+	headers := make(chan *types.Header)
+	sub, err := client.EthSubscribe(context.Background(), headers, "newHeads", map[string]interface{}{"fromBlock": "0x8"})
+	require.NoError(t, err)
+	defer sub.Unsubscribe()
+
+	select {
+	case <-reached:
+	case <-time.After(5 * time.Second):
+		t.Fatal("timeout waiting for the history to be sent")
+	}
+	// Blocks accepted while the history is sent do not block the event system.
+	_, err = bc.InsertChain(chain[10:])
+	require.NoError(t, err)
+	sent := make(chan struct{})
+	go func() {
+		defer close(sent)
+		for _, block := range chain[10:] {
+			backend.chainAcceptedFeed.Send(core.ChainEvent{Block: block, Hash: block.Hash()})
+		}
+	}()
+	select {
+	case <-sent:
+	case <-time.After(5 * time.Second):
+		t.Fatal("accepted events blocked by the history being sent")
+	}
+	close(release)
+
+	// The history is followed by the headers accepted in the meantime.
+	for number := uint64(8); number <= 12; number++ {
+		select {
+		case h := <-headers:
+			require.Equal(t, number, h.Number.Uint64())
+		case <-time.After(5 * time.Second):
+			t.Fatalf("timeout waiting for header %d", number)
+		}
+	}
+}
+
+func TestResumeHeadsSubscriptionFailure(t *testing.T) {
+	backend, api, _, _ := newLogTestChain(t)
+
+	server := rpc.NewServer(0)
+	defer server.Stop()
+	require.NoError(t, server.RegisterName("eth", api))
+	client := rpc.DialInProc(server)
+	defer client.Close()
+
+	// The history cannot be sent past the missing block 9.
+	rawdb.DeleteCanonicalHash(backend.db, 9)
+
+	headers := make(chan *types.Header)
+	sub, err := client.EthSubscribe(context.Background(), headers, "newHeads", map[string]interface{}{"fromBlock": "0x8"})
+	require.NoError(t, err)
+	defer sub.Unsubscribe()
+
+	select {
+	case h := <-headers:
+		require.Equal(t, uint64(8), h.Number.Uint64())
+	case <-time.After(5 * time.Second):
+		t.Fatal("timeout waiting for header 8")
+	}
+	select {
+	case h := <-headers:
+		t.Fatalf("unexpected header %d", h.Number)
+	case err := <-sub.Err():
+		require.ErrorContains(t, err, "header 9 not found")
+	case <-time.After(5 * time.Second):
+		t.Fatal("timeout waiting for the subscription to fail")
+	}
+}
@@ -0,0 +1,465 @@
+// (c) 2024, Ava Labs, Inc. All rights reserved.
+// See the file LICENSE for licensing terms.
+
+package filters
+
+import (
+	"errors"
+	"math"
+	"sync"
+
+	"github.com/ava-labs/coreth/core"
+	"github.com/ava-labs/coreth/core/rawdb"
+	"github.com/ava-labs/coreth/core/types"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/ethdb"
+	"github.com/ethereum/go-ethereum/event"
+	"github.com/ethereum/go-ethereum/log"
+)
+
+// logIndexBackfillBlocks is the number of blocks backfilled by the log indexer
+// before checking for newly accepted blocks.
+const logIndexBackfillBlocks = 1024
+
+var errLogIndexerStopped = errors.New("log indexer stopped")
+
+// LogIndexBackend is the backend required to maintain the log index.
+type LogIndexBackend interface {
+	ChainDb() ethdb.Database
+	LastAcceptedBlock() *types.Block
+	SubscribeChainAcceptedEvent(ch chan<- core.ChainEvent) event.Subscription
+}
+
+// LogIndexer maintains an on-disk index mapping log addresses and topics to
+// the positions of the logs in accepted blocks, allowing range filters to only
+// load the blocks holding matching logs.
+//
+// Accepted blocks are indexed as they are accepted, while the blocks accepted
+// before the index was enabled are backfilled in the background, down to
+// genesis or to the first block missing from the database. The index covers
+// a contiguous range of blocks, which filters check before using it.
+type LogIndexer struct {
+	db ethdb.Database
+
+	tail, next uint64 // Range of indexed blocks [tail, next)
+	target     uint64 // Last accepted block to be indexed
+	backfilled bool   // Whether the backfill stopped, at genesis or at a missing block
+	lock       sync.RWMutex
+
+	// queryLock is held for reading by index queries and for writing while
+	// the index is deleted for a rebuild.
+	queryLock sync.RWMutex
+
+	acceptedCh chan core.ChainEvent
+	notify     chan struct{}   // Signals the indexing loop that the target advanced
+	rebuild    chan chan error // Requests the indexing loop to rebuild the index
+	sub        event.Subscription
+	quit       chan struct{}
+	wg         sync.WaitGroup
+}
+
+// NewLogIndexer creates the log indexer and starts indexing accepted blocks in
+// the background.
+func NewLogIndexer(backend LogIndexBackend) *LogIndexer {
+	db := backend.ChainDb()
+	lastAccepted := backend.LastAcceptedBlock().NumberU64()
+	tail, next, ok := rawdb.ReadLogIndexRange(db)
+	if !ok || next > lastAccepted+1 {
+		tail, next = lastAccepted+1, lastAccepted+1
+		rawdb.WriteLogIndexRange(db, tail, next)
+	}
+	i := &LogIndexer{
+		db:         db,
+		tail:       tail,
+		next:       next,
+		target:     lastAccepted,
+		acceptedCh: make(chan core.ChainEvent, 1),
+		notify:     make(chan struct{}, 1),
+		rebuild:    make(chan chan error),
+		quit:       make(chan struct{}),
+	}
+	i.sub = backend.SubscribeChainAcceptedEvent(i.acceptedCh)
+
+	log.Info("Initialized log indexer", "tail", tail, "next", next, "lastAccepted", lastAccepted)
+	i.wg.Add(2)
+	go i.eventLoop()
+	go i.indexLoop()
+	return i
+}
+
+// Range returns the range of blocks [tail, next) covered by the index.
+func (i *LogIndexer) Range() (uint64, uint64) {
+	i.lock.RLock()
+	defer i.lock.RUnlock()
+
+	return i.tail, i.next
+}
+
+// Rebuild deletes the index and restarts indexing from the last accepted
+// block, backfilling the previous blocks in the background.
+func (i *LogIndexer) Rebuild() error {
+	errCh := make(chan error)
+	select {
+	case i.rebuild <- errCh:
+		return <-errCh
+	case <-i.quit:
+		return errLogIndexerStopped
+	}
+}
+
+// Stop terminates the indexer, waiting for the blocks being indexed to finish.
+func (i *LogIndexer) Stop() {
+	i.sub.Unsubscribe()
+	close(i.quit)
+	i.wg.Wait()
+}
+
+// eventLoop advances the indexing target as blocks are accepted.
+func (i *LogIndexer) eventLoop() {
+	defer i.wg.Done()
+
+	for {
+		select {
+		case ev := <-i.acceptedCh:
+			i.lock.Lock()
+			i.target = max(i.target, ev.Block.NumberU64())
+			i.lock.Unlock()
+
+			select {
+			case i.notify <- struct{}{}:
+			default:
+			}
+		case <-i.sub.Err():
+			return
+		case <-i.quit:
+			return
+		}
+	}
+}
+
+// indexLoop indexes accepted blocks up to the indexing target, backfilling
+// the blocks before the indexed range whenever it is caught up.
+func (i *LogIndexer) indexLoop() {
+	defer i.wg.Done()
+
+	for {
+		i.indexPending()
+
+		i.lock.RLock()
+		backfilled := i.backfilled || i.tail == 0
+		i.lock.RUnlock()
+
+		if !backfilled {
+			i.backfill()
+			select {
+			case errCh := <-i.rebuild:
+				errCh <- i.reset()
+			case <-i.quit:
+				return
+			default:
+			}
+			continue
+		}
+		select {
+		case <-i.notify:
+		case errCh := <-i.rebuild:
+			errCh <- i.reset()
+		case <-i.quit:
+			return
+		}
+	}
+}
+
+// indexPending indexes all blocks after the indexed range up to and including
+// the indexing target.
+func (i *LogIndexer) indexPending() {
+	for {
+		i.lock.RLock()
+		tail, number, target := i.tail, i.next, i.target
+		i.lock.RUnlock()
+
+		if number > target {
+			return
+		}
+		select {
+		case <-i.quit:
+			return
+		default:
+		}
+		batch := i.db.NewBatch()
+		if !i.indexBlock(batch, number) {
+			// The indexed range must be contiguous, so restart it after a
+			// block that cannot be indexed.
+			log.Warn("Failed to index logs of missing block, restarting log index", "number", number)
+			tail = number + 1
+		}
+		rawdb.WriteLogIndexRange(batch, tail, number+1)
+		if err := batch.Write(); err != nil {
+			log.Crit("Failed to write log index", "number", number, "err", err)
+		}
+		i.lock.Lock()
+		i.tail, i.next = tail, number+1
+		i.lock.Unlock()
+	}
+}
+
+// backfill indexes up to logIndexBackfillBlocks blocks before the indexed
+// range, stopping at the first block missing from the database.
+func (i *LogIndexer) backfill() {
+	i.lock.RLock()
+	tail, next := i.tail, i.next
+	i.lock.RUnlock()
+
+	batch := i.db.NewBatch()
+	for n := 0; n < logIndexBackfillBlocks && tail > 0; n++ {
+		if !i.indexBlock(batch, tail-1) {
+			log.Info("Stopped log index backfill at missing block", "number", tail-1)
+			i.lock.Lock()
+			i.backfilled = true
+			i.lock.Unlock()
+			break
+		}
+		tail--
+		if batch.ValueSize() >= ethdb.IdealBatchSize {
+			i.writeBackfill(batch, tail, next)
+			batch.Reset()
+		}
+	}
+	i.writeBackfill(batch, tail, next)
+
+	if tail == 0 {
+		log.Info("Finished log index backfill", "next", next)
+	}
+}
+
+// writeBackfill writes [batch] of backfilled blocks, extending the indexed
+// range down to [tail].
+func (i *LogIndexer) writeBackfill(batch ethdb.Batch, tail, next uint64) {
+	rawdb.WriteLogIndexRange(batch, tail, next)
+	if err := batch.Write(); err != nil {
+		log.Crit("Failed to write log index", "tail", tail, "err", err)
+	}
+	i.lock.Lock()
+	i.tail = tail
+	i.lock.Unlock()
+}
+
+// indexBlock writes the positions of the logs of the accepted block with
+// [number] to [batch]. It returns false if the block is missing.
+func (i *LogIndexer) indexBlock(batch ethdb.KeyValueWriter, number uint64) bool {
+	hash := rawdb.ReadCanonicalHash(i.db, number)
+	if hash == (common.Hash{}) || !rawdb.HasReceipts(i.db, hash, number) {
+		return false
+	}
+	var (
+		addresses = make(map[common.Address][]uint32)
+		topics    = make(map[rawdb.LogIndexTopic][]uint32)
+		index     uint32
+	)
+	for _, logs := range rawdb.ReadLogs(i.db, hash, number) {
+		for _, l := range logs {
+			addresses[l.Address] = append(addresses[l.Address], index)
+			for position, topic := range l.Topics {
+				key := rawdb.LogIndexTopic{Topic: topic, Position: uint8(position)}
+				topics[key] = append(topics[key], index)
+			}
+			index++
+		}
+	}
+	rawdb.WriteLogIndexBlock(batch, number, addresses, topics)
+	return true
+}
+
+// reset deletes the index and restarts it after the last accepted block.
+func (i *LogIndexer) reset() error {
+	i.queryLock.Lock()
+	defer i.queryLock.Unlock()
+
+	log.Info("Rebuilding log index")
+	if err := rawdb.DeleteLogIndex(i.db); err != nil {
+		return err
+	}
+	i.lock.Lock()
+	defer i.lock.Unlock()
+
+	i.tail, i.next, i.backfilled = i.target+1, i.target+1, false
+	rawdb.WriteLogIndexRange(i.db, i.tail, i.next)
+	return nil
+}
+
+// covers returns whether the index can serve a query for the logs matching
+// [addresses] and [topics] within the blocks [begin, end]. The index cannot
+// serve queries matching all logs.
+func (i *LogIndexer) covers(begin, end uint64, addresses []common.Address, topics [][]common.Hash) bool {
+	if !hasLogIndexCriteria(addresses, topics) {
+		return false
+	}
+	tail, next := i.Range()
+	return tail <= begin && end < next
+}
+
+// matchingBlocks calls [fn] with the numbers of the blocks within [begin, end]
+// holding logs matching [addresses] and [topics], in ascending order, stopping
+// at the first error returned by [fn]. The index entries are streamed block by
+// block rather than loaded in memory. It returns false if the index does not
+// cover the query.
+func (i *LogIndexer) matchingBlocks(begin, end uint64, addresses []common.Address, topics [][]common.Hash, fn func(number uint64) error) (bool, error) {
+	i.queryLock.RLock()
+	defer i.queryLock.RUnlock()
+
+	if !i.covers(begin, end, addresses, topics) {
+		return false, nil
+	}
+	// Each criterion matches the logs of any of its alternatives, and a log
+	// matches the query if it matches all the criteria.
+	var criteria []*logIndexCriterion
+	defer func() {
+		for _, c := range criteria {
+			c.release()
+		}
+	}()
+	if len(addresses) > 0 {
+		c := new(logIndexCriterion)
+		for _, address := range addresses {
+			c.its = append(c.its, rawdb.NewLogIndexAddressIterator(i.db, address, begin, end))
+		}
+		criteria = append(criteria, c)
+	}
+	for position, sub := range topics {
+		if len(sub) == 0 {
+			continue
+		}
+		c := new(logIndexCriterion)
+		for _, topic := range sub {
+			c.its = append(c.its, rawdb.NewLogIndexTopicIterator(i.db, topic, uint8(position), begin, end))
+		}
+		criteria = append(criteria, c)
+	}
+	for _, c := range criteria {
+		if !c.init() {
+			return true, nil
+		}
+	}
+	for {
+		// Align all the criteria on the highest of their current blocks.
+		var target uint64
+		for _, c := range criteria {
+			target = max(target, c.number)
+		}
+		aligned := true
+		for _, c := range criteria {
+			if !c.seek(target) {
+				return true, nil
+			}
+			aligned = aligned && c.number == target
+		}
+		if !aligned {
+			continue
+		}
+		if matchesAll(criteria) {
+			if err := fn(target); err != nil {
+				return true, err
+			}
+		}
+		for _, c := range criteria {
+			if !c.next() {
+				return true, nil
+			}
+		}
+	}
+}
+
+// logIndexCriterion iterates over the blocks holding logs matching any of the
+// alternatives of a filter criterion, with the indexes of the matching logs
+// within the current block.
+type logIndexCriterion struct {
+	its     []*rawdb.LogIndexIterator
+	live    []bool // Whether each iterator is positioned on an entry
+	number  uint64
+	indexes map[uint32]struct{}
+}
+
+// init positions the iterators on their first entries and the criterion on
+// its first block. It returns false if no block matches the criterion.
+func (c *logIndexCriterion) init() bool {
+	c.live = make([]bool, len(c.its))
+	for n, it := range c.its {
+		c.live[n] = it.Next()
+	}
+	return c.next()
+}
+
+// next moves the criterion to the next block holding matching logs. It returns
+// false once all the alternatives are exhausted.
+func (c *logIndexCriterion) next() bool {
+	number, found := uint64(math.MaxUint64), false
+	for n, it := range c.its {
+		if c.live[n] && it.Entry().Number <= number {
+			number, found = it.Entry().Number, true
+		}
+	}
+	if !found {
+		return false
+	}
+	c.number = number
+	c.indexes = make(map[uint32]struct{})
+	for n, it := range c.its {
+		if !c.live[n] || it.Entry().Number != number {
+			continue
+		}
+		for _, index := range it.Entry().Indexes {
+			c.indexes[index] = struct{}{}
+		}
+		c.live[n] = it.Next()
+	}
+	return true
+}
+
+// seek moves the criterion to the first block holding matching logs at or
+// after [number]. It returns false once all the alternatives are exhausted.
+func (c *logIndexCriterion) seek(number uint64) bool {
+	for c.number < number {
+		if !c.next() {
+			return false
+		}
+	}
+	return true
+}
+
+func (c *logIndexCriterion) release() {
+	for _, it := range c.its {
+		it.Release()
+	}
+}
+
+// matchesAll returns whether a log of the current block matches all of the
+// [criteria], which must be aligned on the same block.
+func matchesAll(criteria []*logIndexCriterion) bool {
+	for index := range criteria[0].indexes {
+		found := true
+		for _, c := range criteria[1:] {
+			if _, ok := c.indexes[index]; !ok {
+				found = false
+				break
+			}
+		}
+		if found {
+			return true
+		}
+	}
+	return false
+}
+
+// hasLogIndexCriteria returns whether [addresses] or [topics] restrict the
+// matching logs.
+func hasLogIndexCriteria(addresses []common.Address, topics [][]common.Hash) bool {
+	if len(addresses) > 0 {
+		return true
+	}
+	for _, sub := range topics {
+		if len(sub) > 0 {
+			return true
+		}
+	}
+	return false
+}
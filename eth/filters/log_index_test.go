@@ -0,0 +1,109 @@
+// (c) 2024, Ava Labs, Inc. All rights reserved.
+// See the file LICENSE for licensing terms.
+
+package filters
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/ava-labs/coreth/core"
+	"github.com/ava-labs/coreth/core/rawdb"
+	"github.com/ava-labs/coreth/core/types"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLogIndexer(t *testing.T) {
+	backend, api, bc, chain := newLogTestChain(t)
+	contract := common.Address{0xfe}
+
+	// The blocks accepted before the index was enabled are backfilled.
+	indexer := NewLogIndexer(backend)
+	defer indexer.Stop()
+	requireRange := func(tail, next uint64) {
+		require.Eventually(t, func() bool {
+			haveTail, haveNext := indexer.Range()
+			return haveTail == tail && haveNext == next
+		}, 5*time.Second, 10*time.Millisecond)
+	}
+	requireRange(0, 11)
+
+	// Newly accepted blocks are indexed.
+	_, err := bc.InsertChain(chain[10:])
+	require.NoError(t, err)
+	for _, block := range chain[10:] {
+		backend.chainAcceptedFeed.Send(core.ChainEvent{Block: block, Hash: block.Hash()})
+	}
+	requireRange(0, 13)
+
+	blockNumbers := func(logs []*types.Log) []uint64 {
+		var numbers []uint64
+		for _, l := range logs {
+			numbers = append(numbers, l.BlockNumber)
+		}
+		return numbers
+	}
+	queries := []struct {
+		addresses []common.Address
+		topics    [][]common.Hash
+		want      []uint64
+	}{
+		{addresses: []common.Address{contract}, want: []uint64{1, 2, 3, 4, 5, 6, 7, 8, 9, 10, 11, 12}},
+		{addresses: []common.Address{contract}, topics: [][]common.Hash{{logTestTopics[0]}}, want: []uint64{2, 4, 6, 8, 10, 12}},
+		{topics: [][]common.Hash{{logTestTopics[1], {0xff}}}, want: []uint64{1, 3, 5, 7, 9, 11}},
+		{addresses: []common.Address{{0xff}}, topics: [][]common.Hash{{logTestTopics[1]}}},
+		{topics: [][]common.Hash{nil, {logTestTopics[1]}}},
+	}
+	for _, q := range queries {
+		// The same logs are found with and without the index.
+		api.sys.cfg.LogIndexer = nil
+		want, err := api.sys.NewRangeFilter(0, 12, q.addresses, q.topics).Logs(context.Background())
+		require.NoError(t, err)
+		require.Equal(t, q.want, blockNumbers(want))
+
+		api.sys.cfg.LogIndexer = indexer
+		require.Equal(t, q.want, matchingBlocks(t, indexer, q.addresses, q.topics))
+		have, err := api.sys.NewRangeFilter(0, 12, q.addresses, q.topics).Logs(context.Background())
+		require.NoError(t, err)
+		require.Equal(t, want, have)
+	}
+
+	// Ranges covered by the index are not limited by the maximum number of
+	// blocks per request, unlike queries matching all logs, but the number of
+	// blocks holding matching logs is.
+	backend.maxBlocksPerRequest = 6
+	_, err = api.sys.NewRangeFilter(0, 12, []common.Address{contract}, [][]common.Hash{{logTestTopics[0]}}).Logs(context.Background())
+	require.NoError(t, err)
+	_, err = api.sys.NewRangeFilter(0, 12, []common.Address{contract}, nil).Logs(context.Background())
+	require.ErrorContains(t, err, "requested logs from too many blocks")
+	_, err = api.sys.NewRangeFilter(0, 12, nil, nil).Logs(context.Background())
+	require.ErrorContains(t, err, "requested too many blocks")
+	backend.maxBlocksPerRequest = 0
+
+	// Blocks found by the index must be available.
+	rawdb.DeleteCanonicalHash(backend.db, 4)
+	_, err = api.sys.NewRangeFilter(0, 12, []common.Address{contract}, nil).Logs(context.Background())
+	require.ErrorContains(t, err, "header 4 not found")
+	rawdb.WriteCanonicalHash(backend.db, chain[3].Hash(), 4)
+
+	// Rebuilding the index restarts it after the last accepted block, and
+	// backfills it again.
+	require.NoError(t, indexer.Rebuild())
+	requireRange(0, 13)
+	require.Len(t, matchingBlocks(t, indexer, []common.Address{contract}, nil), 12)
+}
+
+// matchingBlocks returns the numbers of the blocks within [0, 12] holding logs
+// matching [addresses] and [topics] according to [indexer].
+func matchingBlocks(t *testing.T, indexer *LogIndexer, addresses []common.Address, topics [][]common.Hash) []uint64 {
+	var numbers []uint64
+	ok, err := indexer.matchingBlocks(0, 12, addresses, topics, func(number uint64) error {
+		numbers = append(numbers, number)
+		return nil
+	})
+	require.NoError(t, err)
+	require.True(t, ok)
+	return numbers
+}
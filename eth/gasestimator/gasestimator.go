@@ -54,7 +54,50 @@ type Options struct {
 	Header *types.Header       // Header defining the block context to execute in
 	State  *state.StateDB      // Pre-state on top of which to estimate the gas
 
+	// BlockContext optionally replaces the block context derived from Header,
+	// allowing callers to execute the estimation under overridden block fields.
+	BlockContext *vm.BlockContext
+
 	ErrorRatio float64 // Allowed overestimation ratio for faster estimation termination
+
+	Report *Report // Optional report to collect the course of the estimation into
+}
+
+// Cap sources bounding the highest gas limit attempted during estimation.
+const (
+	CapGasLimit = "gas-limit"   // Capped by the call or block gas limit
+	CapBalance  = "balance"     // Capped by the funds of the caller
+	CapGasCap   = "rpc-gas-cap" // Capped by the RPC gas cap of the node
+)
+
+// Iteration is a single execution of the call performed during estimation.
+type Iteration struct {
+	Gas    uint64 // Gas limit the call was executed with
+	Failed bool   // Whether the call failed with the given gas limit
+}
+
+// Report describes the course of a gas estimation, explaining its outcome.
+type Report struct {
+	Iterations []Iteration // Executions performed, in order
+
+	Allowance  uint64 // Highest gas limit that was allowed to be attempted
+	CappedBy   string // Source of the allowance (one of the Cap constants)
+	Failing    uint64 // Largest gas limit at which the call was observed to fail
+	Succeeding uint64 // Smallest gas limit at which the call was observed to succeed, 0 if none
+}
+
+// record adds an execution with [gas] to the report and updates the bounds.
+func (r *Report) record(gas uint64, failed bool) {
+	if r == nil {
+		return
+	}
+	r.Iterations = append(r.Iterations, Iteration{Gas: gas, Failed: failed})
+	if failed && gas > r.Failing {
+		r.Failing = gas
+	}
+	if !failed && (r.Succeeding == 0 || gas < r.Succeeding) {
+		r.Succeeding = gas
+	}
 }
 
 // Estimate returns the lowest possible gas limit that allows the transaction to
@@ -68,9 +111,13 @@ func Estimate(ctx context.Context, call *core.Message, opts *Options, gasCap uin
 	)
 	// Determine the highest gas limit can be used during the estimation.
 	hi = opts.Header.GasLimit
+	if opts.BlockContext != nil {
+		hi = opts.BlockContext.GasLimit
+	}
 	if call.GasLimit >= params.TxGas {
 		hi = call.GasLimit
 	}
+	cappedBy := CapGasLimit
 	// Normalize the max fee per gas the call is willing to spend.
 	var feeCap *big.Int
 	if call.GasFeeCap != nil {
@@ -102,12 +149,18 @@ func Estimate(ctx context.Context, call *core.Message, opts *Options, gasCap uin
 			log.Debug("Gas estimation capped by limited funds", "original", hi, "balance", balance,
 				"sent", transfer, "maxFeePerGas", feeCap, "fundable", allowance)
 			hi = allowance.Uint64()
+			cappedBy = CapBalance
 		}
 	}
 	// Recap the highest gas allowance with specified gascap.
 	if gasCap != 0 && hi > gasCap {
 		log.Debug("Caller gas above allowance, capping", "requested", hi, "cap", gasCap)
 		hi = gasCap
+		cappedBy = CapGasCap
+	}
+	if opts.Report != nil {
+		opts.Report.Allowance = hi
+		opts.Report.CappedBy = cappedBy
 	}
 	// If the transaction is a plain value transfer, short circuit estimation and
 	// directly try 21000. Returning 21000 without any execution is dangerous as
@@ -206,10 +259,12 @@ func execute(ctx context.Context, call *core.Message, opts *Options, gasLimit ui
 	result, err := run(ctx, call, opts)
 	if err != nil {
 		if errors.Is(err, core.ErrIntrinsicGas) {
+			opts.Report.record(gasLimit, true)
 			return true, nil, nil // Special case, raise gas limit
 		}
 		return true, nil, err // Bail out
 	}
+	opts.Report.record(gasLimit, result.Failed())
 	return result.Failed(), result, nil
 }
 
@@ -222,8 +277,12 @@ func run(ctx context.Context, call *core.Message, opts *Options) (*core.Executio
 		evmContext = core.NewEVMBlockContext(opts.Header, opts.Chain, nil)
 
 		dirtyState = opts.State.Copy()
-		evm        = vm.NewEVM(evmContext, msgContext, dirtyState, opts.Config, vm.Config{NoBaseFee: true})
 	)
+	if opts.BlockContext != nil {
+		evmContext = *opts.BlockContext
+	}
+	evm := vm.NewEVM(evmContext, msgContext, dirtyState, opts.Config, vm.Config{NoBaseFee: true})
+
 	// Monitor the outer context and interrupt the EVM upon cancellation. To avoid
 	// a dangling goroutine until the outer estimation finishes, create an internal
 	// context for the lifetime of this method call.
@@ -0,0 +1,155 @@
+// (c) 2024, Ava Labs, Inc. All rights reserved.
+// See the file LICENSE for licensing terms.
+
+package gasprice
+
+import (
+	"context"
+	"math"
+	"math/big"
+
+	"github.com/ava-labs/coreth/rpc"
+	"github.com/ethereum/go-ethereum/common"
+	cmath "github.com/ethereum/go-ethereum/common/math"
+	"golang.org/x/exp/slices"
+)
+
+// FeeTier is a suggested pair of dynamic fee transaction fields along with
+// the expected number of blocks for a transaction paying them to be included.
+type FeeTier struct {
+	MaxFeePerGas         *big.Int
+	MaxPriorityFeePerGas *big.Int
+	InclusionBlocks      uint64 // Expected inclusion within this many blocks
+}
+
+// SuggestedFees contains fee suggestions for transactions of differing
+// urgency, based on the tips required by and base fees of recent blocks.
+type SuggestedFees struct {
+	BaseFee  *big.Int // Estimated base fee of the next block
+	Slow     *FeeTier
+	Standard *FeeTier
+	Fast     *FeeTier
+}
+
+// SuggestFees returns slow, standard and fast fee suggestions. The standard
+// tier uses the configured percentile of the recent minimum required tips,
+// while the slow and fast tiers use the midpoints below and above it.
+//
+// The max fee of each tier covers the tier's tip and the base fee projected
+// over its expected inclusion window, following the recent base fee trend if
+// it is rising. If ApricotPhase3 has not been activated, it returns nil.
+func (oracle *Oracle) SuggestFees(ctx context.Context) (*SuggestedFees, error) {
+	baseFee, err := oracle.EstimateBaseFee(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if baseFee == nil {
+		return nil, nil
+	}
+	tips, baseFees, err := oracle.recentFees(ctx)
+	if err != nil {
+		return nil, err
+	}
+	slices.SortFunc(tips, func(a, b *big.Int) int { return a.Cmp(b) })
+
+	var (
+		growth = baseFeeGrowth(baseFees)
+		tier   = func(percentile int) *FeeTier {
+			tip := oracle.minPrice
+			if len(tips) > 0 {
+				tip = cmath.BigMax(tip, tips[(len(tips)-1)*percentile/100])
+			}
+			tip = new(big.Int).Set(cmath.BigMin(tip, oracle.maxPrice))
+
+			blocks := inclusionBlocks(tips, tip)
+			return &FeeTier{
+				MaxFeePerGas:         new(big.Int).Add(tip, projectBaseFee(baseFee, growth, blocks)),
+				MaxPriorityFeePerGas: tip,
+				InclusionBlocks:      blocks,
+			}
+		}
+	)
+	return &SuggestedFees{
+		BaseFee:  baseFee,
+		Slow:     tier(oracle.percentile / 2),
+		Standard: tier(oracle.percentile),
+		Fast:     tier(oracle.percentile + (100-oracle.percentile)/2),
+	}, nil
+}
+
+// recentFees returns the minimum required tips and the base fees of the blocks
+// considered for gas price estimation. The base fees are ordered from the
+// oldest block to the most recent one.
+func (oracle *Oracle) recentFees(ctx context.Context) ([]*big.Int, []*big.Int, error) {
+	head, err := oracle.backend.HeaderByNumber(ctx, rpc.LatestBlockNumber)
+	if err != nil {
+		return nil, nil, err
+	}
+	feeInfos, err := oracle.recentFeeInfos(ctx, head)
+	if err != nil {
+		return nil, nil, err
+	}
+	var (
+		tips     = make([]*big.Int, 0, len(feeInfos))
+		baseFees []*big.Int
+	)
+	for _, feeInfo := range feeInfos {
+		if feeInfo.tip != nil {
+			tips = append(tips, feeInfo.tip)
+		} else {
+			tips = append(tips, new(big.Int).Set(common.Big0))
+		}
+		if feeInfo.baseFee != nil {
+			baseFees = append(baseFees, feeInfo.baseFee)
+		}
+	}
+	slices.Reverse(baseFees)
+	return tips, baseFees, nil
+}
+
+// inclusionBlocks estimates the number of blocks until a transaction paying
+// [tip] is included, treating each block whose minimum required tip is met as
+// an independent inclusion opportunity. [tips] must be sorted ascending.
+func inclusionBlocks(tips []*big.Int, tip *big.Int) uint64 {
+	if len(tips) == 0 {
+		return 1
+	}
+	var eligible int
+	for eligible < len(tips) && tips[eligible].Cmp(tip) <= 0 {
+		eligible++
+	}
+	if eligible == 0 {
+		// No recent block would have included the transaction
+		return uint64(len(tips) + 1)
+	}
+	return uint64((len(tips) + eligible - 1) / eligible)
+}
+
+// baseFeeGrowth returns the average per-block growth factor of [baseFees],
+// ordered from oldest to newest. Falling base fees result in a factor of 1
+// so that suggestions never assume the base fee will drop.
+func baseFeeGrowth(baseFees []*big.Int) float64 {
+	if len(baseFees) < 2 || baseFees[0].Sign() <= 0 {
+		return 1
+	}
+	ratio, _ := new(big.Float).Quo(
+		new(big.Float).SetInt(baseFees[len(baseFees)-1]),
+		new(big.Float).SetInt(baseFees[0]),
+	).Float64()
+	if ratio <= 1 {
+		return 1
+	}
+	return math.Pow(ratio, 1/float64(len(baseFees)-1))
+}
+
+// projectBaseFee returns [baseFee] compounded by [growth] for [blocks] blocks.
+func projectBaseFee(baseFee *big.Int, growth float64, blocks uint64) *big.Int {
+	if growth <= 1 {
+		return new(big.Int).Set(baseFee)
+	}
+	projected, _ := new(big.Float).Mul(
+		new(big.Float).SetInt(baseFee),
+		big.NewFloat(math.Pow(growth, float64(blocks))),
+	).Int(nil)
+	return projected
+}
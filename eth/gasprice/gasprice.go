@@ -317,29 +317,15 @@ func (oracle *Oracle) suggestDynamicFees(ctx context.Context) (*big.Int, *big.In
 	if headHash == lastHead {
 		return new(big.Int).Set(lastPrice), new(big.Int).Set(lastBaseFee), nil
 	}
+	feeInfos, err := oracle.recentFeeInfos(ctx, head)
+	if err != nil {
+		return new(big.Int).Set(lastPrice), new(big.Int).Set(lastBaseFee), err
+	}
 	var (
-		latestBlockNumber     = head.Number.Uint64()
-		lowerBlockNumberLimit = uint64(0)
-		currentTime           = oracle.clock.Unix()
-		tipResults            []*big.Int
-		baseFeeResults        []*big.Int
+		tipResults     = make([]*big.Int, 0, len(feeInfos))
+		baseFeeResults = make([]*big.Int, 0, len(feeInfos))
 	)
-
-	if uint64(oracle.checkBlocks) <= latestBlockNumber {
-		lowerBlockNumberLimit = latestBlockNumber - uint64(oracle.checkBlocks)
-	}
-
-	// Process block headers in the range calculated for this gas price estimation.
-	for i := latestBlockNumber; i > lowerBlockNumberLimit; i-- {
-		feeInfo, err := oracle.getFeeInfo(ctx, i)
-		if err != nil {
-			return new(big.Int).Set(lastPrice), new(big.Int).Set(lastBaseFee), err
-		}
-
-		if feeInfo.timestamp+oracle.maxLookbackSeconds < currentTime {
-			break
-		}
-
+	for _, feeInfo := range feeInfos {
 		if feeInfo.tip != nil {
 			tipResults = append(tipResults, feeInfo.tip)
 		} else {
@@ -379,6 +365,36 @@ func (oracle *Oracle) suggestDynamicFees(ctx context.Context) (*big.Int, *big.In
 	return new(big.Int).Set(price), new(big.Int).Set(baseFee), nil
 }
 
+// recentFeeInfos returns the fee info of the blocks considered for gas price
+// estimation, that is the [checkBlocks] blocks up to [head] produced within
+// [maxLookbackSeconds], ordered from the most recent block to the oldest one.
+func (oracle *Oracle) recentFeeInfos(ctx context.Context, head *types.Header) ([]*feeInfo, error) {
+	var (
+		latestBlockNumber     = head.Number.Uint64()
+		lowerBlockNumberLimit = uint64(0)
+		currentTime           = oracle.clock.Unix()
+		feeInfos              []*feeInfo
+	)
+
+	if uint64(oracle.checkBlocks) <= latestBlockNumber {
+		lowerBlockNumberLimit = latestBlockNumber - uint64(oracle.checkBlocks)
+	}
+
+	// Process block headers in the range calculated for this gas price estimation.
+	for i := latestBlockNumber; i > lowerBlockNumberLimit; i-- {
+		feeInfo, err := oracle.getFeeInfo(ctx, i)
+		if err != nil {
+			return nil, err
+		}
+
+		if feeInfo.timestamp+oracle.maxLookbackSeconds < currentTime {
+			break
+		}
+		feeInfos = append(feeInfos, feeInfo)
+	}
+	return feeInfos, nil
+}
+
 // getFeeInfo calculates the minimum required tip to be included in a given
 // block and returns the value as a feeInfo struct.
 func (oracle *Oracle) getFeeInfo(ctx context.Context, number uint64) (*feeInfo, error) {
@@ -393,3 +393,50 @@ func TestSuggestTipCapMaxBlocksSecondsLookback(t *testing.T) {
 		expectedTip:     big.NewInt(92_212_529_423),
 	}, timeCrunchOracleConfig())
 }
+
+func TestSuggestFees(t *testing.T) {
+	backend := newTestBackend(t, params.TestChainConfig, 3, common.Big0, testGenBlock(t, 55, 370))
+	defer backend.teardown()
+
+	oracle, err := NewOracle(backend, defaultOracleConfig())
+	require.NoError(t, err)
+	oracle.clock.Set(time.Unix(20, 0))
+
+	fees, err := oracle.SuggestFees(context.Background())
+	require.NoError(t, err)
+	require.NotNil(t, fees)
+
+	// The standard tier matches the single tip suggestion
+	tip, err := oracle.SuggestTipCap(context.Background())
+	require.NoError(t, err)
+	require.Zero(t, tip.Cmp(fees.Standard.MaxPriorityFeePerGas))
+
+	tiers := []*FeeTier{fees.Slow, fees.Standard, fees.Fast}
+	for i, tier := range tiers {
+		minFee := new(big.Int).Add(tier.MaxPriorityFeePerGas, fees.BaseFee)
+		require.GreaterOrEqual(t, tier.MaxFeePerGas.Cmp(minFee), 0, "tier %d max fee below tip and base fee", i)
+		require.GreaterOrEqual(t, tier.InclusionBlocks, uint64(1))
+		if i > 0 {
+			require.GreaterOrEqual(t, tier.MaxPriorityFeePerGas.Cmp(tiers[i-1].MaxPriorityFeePerGas), 0, "tier %d tip below slower tier", i)
+			require.LessOrEqual(t, tier.InclusionBlocks, tiers[i-1].InclusionBlocks, "tier %d slower than slower tier", i)
+		}
+	}
+}
+
+func TestInclusionBlocks(t *testing.T) {
+	tips := []*big.Int{big.NewInt(1), big.NewInt(2), big.NewInt(3), big.NewInt(4)}
+	tests := []struct {
+		tip  int64
+		want uint64
+	}{
+		{0, 5},
+		{1, 4},
+		{2, 2},
+		{3, 2},
+		{4, 1},
+		{5, 1},
+	}
+	for _, test := range tests {
+		require.Equal(t, test.want, inclusionBlocks(tips, big.NewInt(test.tip)), "tip %d", test.tip)
+	}
+}
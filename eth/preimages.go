@@ -0,0 +1,123 @@
+// (c) 2024, Ava Labs, Inc. All rights reserved.
+// See the file LICENSE for licensing terms.
+
+package eth
+
+import (
+	"errors"
+	"sync"
+
+	"github.com/ava-labs/coreth/core"
+	"github.com/ethereum/go-ethereum/log"
+)
+
+var (
+	errPreimageBackfillRunning    = errors.New("preimage backfill is already running")
+	errPreimageBackfillNotRunning = errors.New("preimage backfill is not running")
+	errPreimageBackfillerStopped  = errors.New("preimage backfiller stopped")
+)
+
+// PreimageBackfillStatus is the progress of the current or last preimage
+// backfill.
+type PreimageBackfillStatus struct {
+	Running   bool   `json:"running"`
+	From      uint64 `json:"from"`
+	To        uint64 `json:"to"`
+	Number    uint64 `json:"number"`    // Last block re-executed
+	Preimages uint64 `json:"preimages"` // Missing preimages stored so far
+	Cancelled bool   `json:"cancelled"`
+	Error     string `json:"error,omitempty"`
+}
+
+// preimageBackfiller runs a single preimage backfill at a time in the
+// background.
+type preimageBackfiller struct {
+	chain *core.BlockChain
+
+	status PreimageBackfillStatus
+	cancel chan struct{} // Closed to interrupt the running backfill
+	closed bool
+	lock   sync.Mutex
+	wg     sync.WaitGroup
+}
+
+func newPreimageBackfiller(chain *core.BlockChain) *preimageBackfiller {
+	return &preimageBackfiller{chain: chain}
+}
+
+// Start backfills the preimages of the blocks [from, to] in the background.
+func (b *preimageBackfiller) Start(from, to uint64) error {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+
+	if b.status.Running {
+		return errPreimageBackfillRunning
+	}
+	if b.closed {
+		return errPreimageBackfillerStopped
+	}
+	cancel := make(chan struct{})
+	b.cancel = cancel
+	b.status = PreimageBackfillStatus{Running: true, From: from, To: to}
+
+	b.wg.Add(1)
+	go func() {
+		defer b.wg.Done()
+
+		count, err := b.chain.BackfillPreimages(from, to, cancel, func(number, count uint64) {
+			b.lock.Lock()
+			b.status.Number, b.status.Preimages = number, count
+			b.lock.Unlock()
+		})
+
+		b.lock.Lock()
+		defer b.lock.Unlock()
+		b.status.Running = false
+		b.status.Preimages = count
+		b.cancel = nil
+		switch {
+		case errors.Is(err, core.ErrPreimageBackfillInterrupted):
+			b.status.Cancelled = true
+		case err != nil:
+			log.Error("Preimage backfill failed", "from", from, "to", to, "err", err)
+			b.status.Error = err.Error()
+		}
+	}()
+	return nil
+}
+
+// Status returns the progress of the current or last backfill.
+func (b *preimageBackfiller) Status() PreimageBackfillStatus {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+
+	return b.status
+}
+
+// Cancel interrupts the running backfill.
+func (b *preimageBackfiller) Cancel() error {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+
+	if !b.status.Running {
+		return errPreimageBackfillNotRunning
+	}
+	if b.cancel != nil {
+		close(b.cancel)
+		b.cancel = nil
+	}
+	return nil
+}
+
+// Stop interrupts the running backfill and prevents new ones.
+func (b *preimageBackfiller) Stop() {
+	b.lock.Lock()
+	b.closed = true
+	if b.cancel != nil {
+		close(b.cancel)
+		b.cancel = nil
+	}
+	b.lock.Unlock()
+
+	b.wg.Wait()
+}
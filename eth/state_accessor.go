@@ -48,7 +48,11 @@ import (
 var noopReleaser = tracers.StateReleaseFunc(func() {})
 
 func (eth *Ethereum) hashState(ctx context.Context, block *types.Block, reexec uint64, base *state.StateDB, readOnly bool, preferDisk bool) (statedb *state.StateDB, release tracers.StateReleaseFunc, err error) {
-	reexec = 0 // Do not support re-executing historical blocks to grab state
+	if eth.stateRegen == nil {
+		reexec = 0 // Do not support re-executing historical blocks to grab state
+	} else {
+		reexec = min(reexec, eth.config.HistoricalStateRegenLimit)
+	}
 	var (
 		current  *types.Block
 		database state.Database
@@ -87,6 +91,10 @@ func (eth *Ethereum) hashState(ctx context.Context, block *types.Block, reexec u
 		// The optional base statedb is given, mark the start point as parent block
 		statedb, database, triedb, report = base, base.Database(), base.Database().TrieDB(), false
 		current = eth.blockchain.GetBlock(block.ParentHash(), block.NumberU64()-1)
+	} else if eth.stateRegen != nil {
+		// Regenerate the state on top of the closest cached or committed state,
+		// keeping the regenerated states for later queries.
+		return eth.regenState(ctx, block, reexec)
 	} else {
 		// Otherwise, try to reexec blocks until we find a state or reach our limit
 		current = block
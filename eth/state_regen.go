@@ -0,0 +1,242 @@
+// (c) 2024, Ava Labs, Inc. All rights reserved.
+// See the file LICENSE for licensing terms.
+
+package eth
+
+import (
+	"container/list"
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/ava-labs/coreth/core/rawdb"
+	"github.com/ava-labs/coreth/core/state"
+	"github.com/ava-labs/coreth/core/types"
+	"github.com/ava-labs/coreth/core/vm"
+	"github.com/ava-labs/coreth/eth/tracers"
+	"github.com/ava-labs/coreth/metrics"
+	"github.com/ava-labs/coreth/trie"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/ethdb"
+	"github.com/ethereum/go-ethereum/log"
+)
+
+var (
+	stateRegenHitMeter         = metrics.NewRegisteredMeter("eth/stateregen/hit", nil)  // Requested states served from the cache
+	stateRegenMissMeter        = metrics.NewRegisteredMeter("eth/stateregen/miss", nil) // Requested states re-executed
+	stateRegenBlocksMeter      = metrics.NewRegisteredMeter("eth/stateregen/blocks", nil)
+	stateRegenTimer            = metrics.NewRegisteredTimer("eth/stateregen/time", nil)
+	stateRegenSavedBlocksMeter = metrics.NewRegisteredMeter("eth/stateregen/saved/blocks", nil)
+	stateRegenSavedTimeCounter = metrics.NewRegisteredCounter("eth/stateregen/saved/time", nil) // Nanoseconds
+	stateRegenSizeGauge        = metrics.NewRegisteredGauge("eth/stateregen/size", nil)
+	stateRegenRootsGauge       = metrics.NewRegisteredGauge("eth/stateregen/roots", nil)
+)
+
+// regenEntry is a regenerated state held by the cache.
+type regenEntry struct {
+	root   common.Hash
+	blocks uint64        // Blocks re-executed on top of a committed state to regenerate it
+	cost   time.Duration // Time spent re-executing these blocks
+}
+
+// stateRegenCache holds the historical states regenerated by re-executing
+// blocks on top of the nearest committed state, so later queries for the same
+// or nearby heights start from them.
+//
+// The states are committed to a trie database separate from the live one,
+// sharing the nodes common to several states. The cache holds a reference to
+// each state root it keeps and drops the least recently used ones once the
+// nodes exceed the memory limit. The states handed out to callers are
+// referenced again until released, so they are never evicted while in use.
+type stateRegenCache struct {
+	db       ethdb.Database
+	triedb   *trie.Database
+	database state.Database
+	limit    common.StorageSize
+
+	entries map[common.Hash]*list.Element // Values are *regenEntry
+	lru     *list.List                    // Most recently used at the front
+	lock    sync.Mutex
+}
+
+// newStateRegenCache creates a cache keeping up to [limit] MB of regenerated
+// states.
+func newStateRegenCache(db ethdb.Database, limit int) *stateRegenCache {
+	// The clean cache is disabled, the nodes read from disk are cached by the
+	// live trie database already.
+	triedb := trie.NewDatabase(db, trie.HashDefaults)
+	return &stateRegenCache{
+		db:       db,
+		triedb:   triedb,
+		database: state.NewDatabaseWithNodeDB(db, triedb),
+		limit:    common.StorageSize(limit) * 1024 * 1024,
+		entries:  make(map[common.Hash]*list.Element),
+		lru:      list.New(),
+	}
+}
+
+// acquire references the cached state [root] for the caller, which must
+// release it. It returns false if the state is not cached.
+func (c *stateRegenCache) acquire(root common.Hash) (regenEntry, bool) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	elem, ok := c.entries[root]
+	if !ok {
+		return regenEntry{}, false
+	}
+	c.lru.MoveToFront(elem)
+	c.triedb.Reference(root, common.Hash{})
+	return *elem.Value.(*regenEntry), true
+}
+
+// release drops a reference to the state [root] returned by acquire or held
+// by a caller of add.
+func (c *stateRegenCache) release(root common.Hash) {
+	c.triedb.Dereference(root)
+}
+
+// add caches a regenerated state, taking over the reference to its root
+// obtained when committing it, and evicts the least recently used states
+// beyond the memory limit.
+func (c *stateRegenCache) add(entry regenEntry) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	if elem, ok := c.entries[entry.root]; ok {
+		// The state is cached already, possibly regenerated concurrently or
+		// left unchanged by an empty block.
+		c.triedb.Dereference(entry.root)
+		c.lru.MoveToFront(elem)
+	} else {
+		c.entries[entry.root] = c.lru.PushFront(&entry)
+	}
+	// Keep the state just added even if it exceeds the limit by itself.
+	_, size, _ := c.triedb.Size()
+	for size > c.limit && c.lru.Len() > 1 {
+		evicted := c.lru.Remove(c.lru.Back()).(*regenEntry)
+		delete(c.entries, evicted.root)
+		c.triedb.Dereference(evicted.root)
+		_, size, _ = c.triedb.Size()
+	}
+	stateRegenSizeGauge.Update(int64(size))
+	stateRegenRootsGauge.Update(int64(c.lru.Len()))
+}
+
+// hasCommittedState returns whether the state [root] can be opened without
+// re-execution, that is whether it is committed to disk.
+func (c *stateRegenCache) hasCommittedState(root common.Hash) bool {
+	return root == types.EmptyRootHash || rawdb.HasLegacyTrieNode(c.db, root)
+}
+
+// regenState returns the state of [block], starting from the state cached or
+// committed to disk the closest to it, at most [reexec] blocks back, and
+// re-executing the blocks on top with the replay processor of the chain. The
+// states regenerated along the way are cached.
+func (eth *Ethereum) regenState(ctx context.Context, block *types.Block, reexec uint64) (*state.StateDB, tracers.StateReleaseFunc, error) {
+	var (
+		c       = eth.stateRegen
+		current = block
+		origin  = block.NumberU64()
+		base    regenEntry // Cached state the re-execution starts from, if any
+		cached  bool
+	)
+	for i := uint64(0); ; i++ {
+		if err := ctx.Err(); err != nil {
+			return nil, nil, err
+		}
+		if base, cached = c.acquire(current.Root()); cached || c.hasCommittedState(current.Root()) {
+			break
+		}
+		if i == reexec {
+			return nil, nil, fmt.Errorf("required historical state unavailable (reexec=%d)", reexec)
+		}
+		if current.NumberU64() == 0 {
+			return nil, nil, errors.New("genesis state is missing")
+		}
+		parent := eth.blockchain.GetBlock(current.ParentHash(), current.NumberU64()-1)
+		if parent == nil {
+			return nil, nil, fmt.Errorf("missing block %v %d", current.ParentHash(), current.NumberU64()-1)
+		}
+		current = parent
+	}
+	// pinned is the root referenced on behalf of this call, released once the
+	// next state is committed or, for the requested state, by the caller.
+	var pinned common.Hash
+	if cached {
+		pinned = current.Root()
+		stateRegenSavedBlocksMeter.Mark(int64(base.blocks))
+		stateRegenSavedTimeCounter.Inc(int64(base.cost))
+	}
+	unpin := func() {
+		if pinned != (common.Hash{}) {
+			c.release(pinned)
+			pinned = common.Hash{}
+		}
+	}
+	statedb, err := state.New(current.Root(), c.database, nil)
+	if err != nil {
+		unpin()
+		return nil, nil, err
+	}
+	if current == block {
+		if cached {
+			stateRegenHitMeter.Mark(1)
+		}
+		return statedb, unpin, nil
+	}
+	stateRegenMissMeter.Mark(1)
+
+	var (
+		start  = time.Now()
+		logged time.Time
+		blocks = base.blocks
+	)
+	for current.NumberU64() < origin {
+		if err := ctx.Err(); err != nil {
+			unpin()
+			return nil, nil, err
+		}
+		if time.Since(logged) > 8*time.Second {
+			log.Info("Regenerating historical state", "block", current.NumberU64()+1, "target", origin, "remaining", origin-current.NumberU64()-1, "elapsed", time.Since(start))
+			logged = time.Now()
+		}
+		parentHeader := current.Header()
+		next := current.NumberU64() + 1
+		if current = eth.blockchain.GetBlockByNumber(next); current == nil {
+			unpin()
+			return nil, nil, fmt.Errorf("block #%d not found", next)
+		}
+		if _, _, _, err := eth.blockchain.ReplayProcessor().Process(current, parentHeader, statedb, vm.Config{}); err != nil {
+			unpin()
+			return nil, nil, fmt.Errorf("processing block %d failed: %v", current.NumberU64(), err)
+		}
+		root, err := statedb.Commit(current.NumberU64(), eth.blockchain.Config().IsEIP158(current.Number()), true)
+		if err != nil {
+			unpin()
+			return nil, nil, fmt.Errorf("stateAtBlock commit failed, number %d root %v: %w",
+				current.NumberU64(), current.Root().Hex(), err)
+		}
+		blocks++
+		stateRegenBlocksMeter.Mark(1)
+
+		// Reference the new state before caching it, so it cannot be evicted
+		// while the next block is executed on top or, for the requested state,
+		// before the caller releases it.
+		unpin()
+		c.triedb.Reference(root, common.Hash{})
+		pinned = root
+		c.add(regenEntry{root: root, blocks: blocks, cost: base.cost + time.Since(start)})
+
+		if statedb, err = state.New(root, c.database, nil); err != nil {
+			unpin()
+			return nil, nil, fmt.Errorf("state reset after block %d failed: %v", current.NumberU64(), err)
+		}
+	}
+	stateRegenTimer.UpdateSince(start)
+	_, nodes, _ := c.triedb.Size()
+	log.Info("Historical state regenerated", "block", current.NumberU64(), "elapsed", time.Since(start), "nodes", nodes)
+	return statedb, unpin, nil
+}
@@ -0,0 +1,195 @@
+// (c) 2024, Ava Labs, Inc. All rights reserved.
+// See the file LICENSE for licensing terms.
+
+package eth
+
+import (
+	"context"
+	"math/big"
+	"testing"
+	"time"
+
+	"github.com/ava-labs/coreth/consensus/dummy"
+	"github.com/ava-labs/coreth/core"
+	"github.com/ava-labs/coreth/core/rawdb"
+	"github.com/ava-labs/coreth/core/types"
+	"github.com/ava-labs/coreth/core/vm"
+	"github.com/ava-labs/coreth/eth/ethconfig"
+	"github.com/ava-labs/coreth/params"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRegenState(t *testing.T) {
+	require := require.New(t)
+	var (
+		key, _ = crypto.HexToECDSA("b71c71a67e1177ad4e901695e1b4b9ee17ae16c6668d313eac2f96dbcda3f291")
+		addr   = crypto.PubkeyToAddress(key.PublicKey)
+		to     = common.HexToAddress("0x1000")
+		gspec  = &core.Genesis{
+			Config: params.TestChainConfig,
+			Alloc:  core.GenesisAlloc{addr: {Balance: big.NewInt(params.Ether)}},
+		}
+		signer = types.LatestSigner(gspec.Config)
+	)
+	// Every block transfers its number to [to], so the balance of [to] after
+	// block n is n(n+1)/2.
+	_, blocks, _, err := core.GenerateChainWithGenesis(gspec, dummy.NewCoinbaseFaker(), 64, 10, func(i int, block *core.BlockGen) {
+		tx, err := types.SignTx(types.NewTransaction(block.TxNonce(addr), to, big.NewInt(int64(i+1)), params.TxGas, block.BaseFee(), nil), signer, key)
+		require.NoError(err)
+		block.AddTx(tx)
+	})
+	require.NoError(err)
+
+	db := rawdb.NewMemoryDatabase()
+	chain, err := core.NewBlockChain(db, core.DefaultCacheConfig, gspec, dummy.NewCoinbaseFaker(), vm.Config{}, common.Hash{}, false)
+	require.NoError(err)
+	defer chain.Stop()
+	_, err = chain.InsertChain(blocks)
+	require.NoError(err)
+	for _, block := range blocks {
+		require.NoError(chain.Accept(block))
+	}
+	chain.DrainAcceptorQueue()
+
+	eth := &Ethereum{
+		blockchain: chain,
+		config:     &ethconfig.Config{HistoricalStateRegenLimit: 16},
+		stateRegen: newStateRegenCache(db, 16),
+	}
+	balanceAt := func(number uint64) uint64 {
+		block := chain.GetBlockByNumber(number)
+		_, err := chain.StateAt(block.Root())
+		require.Error(err, "state of block %d should be pruned", number)
+
+		statedb, release, err := eth.stateAtBlock(context.Background(), block, 128, nil, true, false)
+		require.NoError(err)
+		defer release()
+		return statedb.GetBalance(to).Uint64()
+	}
+	require.Equal(uint64(15), balanceAt(5))
+	require.Len(eth.stateRegen.entries, 5)
+
+	// The cached state is served without re-execution, and the next states
+	// are regenerated on top of it.
+	require.Equal(uint64(15), balanceAt(5))
+	require.Len(eth.stateRegen.entries, 5)
+	require.Equal(uint64(28), balanceAt(7))
+	require.Len(eth.stateRegen.entries, 7)
+
+	// The regeneration is limited to HistoricalStateRegenLimit blocks from the
+	// closest cached or committed state.
+	_, _, err = eth.stateAtBlock(context.Background(), chain.GetBlockByNumber(30), 128, nil, true, false)
+	require.ErrorContains(err, "required historical state unavailable (reexec=16)")
+	require.Equal(uint64(253), balanceAt(22))
+	require.Equal(uint64(465), balanceAt(30))
+}
+
+func TestRegenStateEviction(t *testing.T) {
+	require := require.New(t)
+	var (
+		key, _ = crypto.HexToECDSA("b71c71a67e1177ad4e901695e1b4b9ee17ae16c6668d313eac2f96dbcda3f291")
+		addr   = crypto.PubkeyToAddress(key.PublicKey)
+		gspec  = &core.Genesis{
+			Config: params.TestChainConfig,
+			Alloc:  core.GenesisAlloc{addr: {Balance: big.NewInt(params.Ether)}},
+		}
+		signer = types.LatestSigner(gspec.Config)
+	)
+	_, blocks, _, err := core.GenerateChainWithGenesis(gspec, dummy.NewCoinbaseFaker(), 8, 10, func(i int, block *core.BlockGen) {
+		tx, err := types.SignTx(types.NewTransaction(block.TxNonce(addr), common.Address{byte(i + 1)}, big.NewInt(1), params.TxGas, block.BaseFee(), nil), signer, key)
+		require.NoError(err)
+		block.AddTx(tx)
+	})
+	require.NoError(err)
+
+	db := rawdb.NewMemoryDatabase()
+	chain, err := core.NewBlockChain(db, core.DefaultCacheConfig, gspec, dummy.NewCoinbaseFaker(), vm.Config{}, common.Hash{}, false)
+	require.NoError(err)
+	defer chain.Stop()
+	_, err = chain.InsertChain(blocks)
+	require.NoError(err)
+
+	// Without memory allowance, only the last regenerated state is cached.
+	eth := &Ethereum{
+		blockchain: chain,
+		config:     &ethconfig.Config{HistoricalStateRegenLimit: 16},
+		stateRegen: newStateRegenCache(db, 0),
+	}
+	statedb, release, err := eth.stateAtBlock(context.Background(), blocks[3], 128, nil, false, false)
+	require.NoError(err)
+	require.Len(eth.stateRegen.entries, 1)
+
+	// A state in use is not dropped when it is evicted.
+	_, releaseNext, err := eth.stateAtBlock(context.Background(), blocks[7], 128, nil, false, false)
+	require.NoError(err)
+	require.Len(eth.stateRegen.entries, 1)
+	require.NotContains(eth.stateRegen.entries, blocks[3].Root())
+	require.NoError(statedb.Error())
+	require.Equal(big.NewInt(1), statedb.GetBalance(common.Address{4}))
+	require.NoError(statedb.Error())
+
+	release()
+	releaseNext()
+	_, size, _ := eth.stateRegen.triedb.Size()
+	require.NotZero(size)
+	_, err = chain.StateAt(blocks[3].Root())
+	require.NoError(err, "the live state must be left untouched")
+}
+
+func TestRegenStatePinTimeout(t *testing.T) {
+	require := require.New(t)
+	var (
+		key, _ = crypto.HexToECDSA("b71c71a67e1177ad4e901695e1b4b9ee17ae16c6668d313eac2f96dbcda3f291")
+		addr   = crypto.PubkeyToAddress(key.PublicKey)
+		gspec  = &core.Genesis{
+			Config: params.TestChainConfig,
+			Alloc:  core.GenesisAlloc{addr: {Balance: big.NewInt(params.Ether)}},
+		}
+		signer = types.LatestSigner(gspec.Config)
+	)
+	_, blocks, _, err := core.GenerateChainWithGenesis(gspec, dummy.NewCoinbaseFaker(), 64, 10, func(i int, block *core.BlockGen) {
+		tx, err := types.SignTx(types.NewTransaction(block.TxNonce(addr), common.Address{byte(i + 1)}, big.NewInt(1), params.TxGas, block.BaseFee(), nil), signer, key)
+		require.NoError(err)
+		block.AddTx(tx)
+	})
+	require.NoError(err)
+
+	db := rawdb.NewMemoryDatabase()
+	chain, err := core.NewBlockChain(db, core.DefaultCacheConfig, gspec, dummy.NewCoinbaseFaker(), vm.Config{}, common.Hash{}, false)
+	require.NoError(err)
+	defer chain.Stop()
+	_, err = chain.InsertChain(blocks)
+	require.NoError(err)
+	for _, block := range blocks {
+		require.NoError(chain.Accept(block))
+	}
+	chain.DrainAcceptorQueue()
+
+	defer func(timeout time.Duration) { regenStatePinTimeout = timeout }(regenStatePinTimeout)
+	regenStatePinTimeout = 100 * time.Millisecond
+
+	// Without memory allowance, only the last regenerated state is cached.
+	eth := &Ethereum{
+		blockchain: chain,
+		config:     &ethconfig.Config{HistoricalStateRegenLimit: 16},
+		stateRegen: newStateRegenCache(db, 0),
+	}
+	backend := &EthAPIBackend{eth: eth}
+
+	// The state returned for a context which is never done stays pinned after
+	// its eviction, until the pin times out.
+	_, err = backend.stateAt(context.Background(), blocks[3].Header())
+	require.NoError(err)
+	_, release, err := eth.stateAtBlock(context.Background(), blocks[7], 128, nil, false, false)
+	require.NoError(err)
+	defer release()
+	require.NotContains(eth.stateRegen.entries, blocks[3].Root())
+
+	_, pinned, _ := eth.stateRegen.triedb.Size()
+	require.Eventually(func() bool {
+		_, size, _ := eth.stateRegen.triedb.Size()
+		return size < pinned
+	}, 5*time.Second, 10*time.Millisecond)
+}
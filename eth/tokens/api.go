@@ -0,0 +1,270 @@
+// (c) 2024, Ava Labs, Inc. All rights reserved.
+// See the file LICENSE for licensing terms.
+
+package tokens
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+
+	"github.com/ava-labs/coreth/core/rawdb"
+	"github.com/ava-labs/coreth/rpc"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+)
+
+const (
+	// defaultPageLimit is the number of results returned per page if the
+	// limit is not specified.
+	defaultPageLimit = 100
+
+	// maxPageLimit is the maximum number of results returned per page.
+	maxPageLimit = 1000
+)
+
+var errInvalidCursor = errors.New("invalid cursor")
+
+// standardNames are the names of the token standards in the API results.
+var standardNames = map[rawdb.TokenStandard]string{
+	rawdb.TokenERC20:  "ERC20",
+	rawdb.TokenERC721: "ERC721",
+}
+
+// PageOptions selects a page of the results of a token index query. The
+// cursor is the next cursor returned with the previous page, and is omitted
+// for the first page.
+type PageOptions struct {
+	Limit  hexutil.Uint64 `json:"limit"`
+	Cursor hexutil.Bytes  `json:"cursor"`
+}
+
+// limit returns the page limit requested by [opts], capped at maxPageLimit.
+func (opts *PageOptions) limit() (int, error) {
+	if opts == nil || opts.Limit == 0 {
+		return defaultPageLimit, nil
+	}
+	if opts.Limit > maxPageLimit {
+		return 0, fmt.Errorf("page limit %d exceeds maximum of %d", opts.Limit, maxPageLimit)
+	}
+	return int(opts.Limit), nil
+}
+
+// cursor returns the cursor of [opts], checking that it has [size] bytes.
+func (opts *PageOptions) cursor(size int) ([]byte, error) {
+	if opts == nil || len(opts.Cursor) == 0 {
+		return make([]byte, size), nil
+	}
+	if len(opts.Cursor) != size {
+		return nil, errInvalidCursor
+	}
+	return opts.Cursor, nil
+}
+
+// IndexedRange is the range of blocks covered by the index.
+type IndexedRange struct {
+	First hexutil.Uint64 `json:"first"`
+	Last  hexutil.Uint64 `json:"last"`
+}
+
+// TokenBalance is the balance of a holder in a token. For ERC-721 tokens, the
+// balance is the number of tokens owned, which are listed by GetTokenIDs.
+type TokenBalance struct {
+	Token    common.Address `json:"token"`
+	Standard string         `json:"standard"`
+	Balance  *hexutil.Big   `json:"balance"`
+}
+
+// BalancesPage is a page of the token balances of a holder.
+type BalancesPage struct {
+	Balances []TokenBalance `json:"balances"`
+	Next     hexutil.Bytes  `json:"next,omitempty"`
+}
+
+// TokenIDsPage is a page of the ids of the ERC-721 tokens owned by a holder.
+type TokenIDsPage struct {
+	TokenIDs []*hexutil.Big `json:"tokenIds"`
+	Next     hexutil.Bytes  `json:"next,omitempty"`
+}
+
+// TokenHolder is a holder of a token along with its balance.
+type TokenHolder struct {
+	Holder   common.Address `json:"holder"`
+	Standard string         `json:"standard"`
+	Balance  *hexutil.Big   `json:"balance"`
+}
+
+// HoldersPage is a page of the holders of a token.
+type HoldersPage struct {
+	Holders []TokenHolder `json:"holders"`
+	Next    hexutil.Bytes `json:"next,omitempty"`
+}
+
+// TokenTransfer is a transfer of an ERC-20 amount or of an ERC-721 token.
+type TokenTransfer struct {
+	Token       common.Address `json:"token"`
+	Standard    string         `json:"standard"`
+	From        common.Address `json:"from"`
+	To          common.Address `json:"to"`
+	Value       *hexutil.Big   `json:"value,omitempty"`
+	TokenID     *hexutil.Big   `json:"tokenId,omitempty"`
+	BlockNumber hexutil.Uint64 `json:"blockNumber"`
+	TxHash      common.Hash    `json:"transactionHash"`
+	LogIndex    hexutil.Uint   `json:"logIndex"`
+}
+
+// TransfersPage is a page of the token transfers of an address.
+type TransfersPage struct {
+	Transfers []TokenTransfer `json:"transfers"`
+	Next      hexutil.Bytes   `json:"next,omitempty"`
+}
+
+// API provides the tokens namespace, serving the token transfer index.
+type API struct {
+	indexer *Indexer
+}
+
+// NewAPI creates a new tokens API served by [indexer].
+func NewAPI(indexer *Indexer) *API {
+	return &API{indexer: indexer}
+}
+
+// IndexedRange returns the range of blocks covered by the index. Transfers and
+// balances only reflect the blocks within the range.
+func (api *API) IndexedRange(ctx context.Context) (*IndexedRange, error) {
+	first, next := api.indexer.Range()
+	if next == first {
+		return nil, errors.New("no blocks indexed yet")
+	}
+	return &IndexedRange{First: hexutil.Uint64(first), Last: hexutil.Uint64(next - 1)}, nil
+}
+
+// GetBalances returns the non-zero token balances of [holder], ordered by
+// token address.
+func (api *API) GetBalances(ctx context.Context, holder common.Address, opts *PageOptions) (*BalancesPage, error) {
+	limit, err := opts.limit()
+	if err != nil {
+		return nil, err
+	}
+	start, err := opts.cursor(common.AddressLength)
+	if err != nil {
+		return nil, err
+	}
+	balances, next := rawdb.ReadTokenBalances(api.indexer.db, holder, common.BytesToAddress(start), limit)
+	page := &BalancesPage{Balances: make([]TokenBalance, 0, len(balances))}
+	for _, b := range balances {
+		page.Balances = append(page.Balances, TokenBalance{
+			Token:    b.Address,
+			Standard: standardNames[b.Standard],
+			Balance:  (*hexutil.Big)(b.Balance),
+		})
+	}
+	if next != nil {
+		page.Next = next.Bytes()
+	}
+	return page, nil
+}
+
+// GetTokenIDs returns the ids of the tokens of the ERC-721 [token] owned by
+// [holder], in ascending order.
+func (api *API) GetTokenIDs(ctx context.Context, holder, token common.Address, opts *PageOptions) (*TokenIDsPage, error) {
+	limit, err := opts.limit()
+	if err != nil {
+		return nil, err
+	}
+	start, err := opts.cursor(common.HashLength)
+	if err != nil {
+		return nil, err
+	}
+	ids, next := rawdb.ReadTokenOwned(api.indexer.db, holder, token, common.BytesToHash(start), limit)
+	page := &TokenIDsPage{TokenIDs: make([]*hexutil.Big, 0, len(ids))}
+	for _, id := range ids {
+		page.TokenIDs = append(page.TokenIDs, (*hexutil.Big)(id.Big()))
+	}
+	if next != nil {
+		page.Next = next.Bytes()
+	}
+	return page, nil
+}
+
+// GetHolders returns the holders of [token] with a non-zero balance, ordered
+// by holder address.
+func (api *API) GetHolders(ctx context.Context, token common.Address, opts *PageOptions) (*HoldersPage, error) {
+	limit, err := opts.limit()
+	if err != nil {
+		return nil, err
+	}
+	start, err := opts.cursor(common.AddressLength)
+	if err != nil {
+		return nil, err
+	}
+	holders, next := rawdb.ReadTokenHolders(api.indexer.db, token, common.BytesToAddress(start), limit)
+	page := &HoldersPage{Holders: make([]TokenHolder, 0, len(holders))}
+	for _, h := range holders {
+		page.Holders = append(page.Holders, TokenHolder{
+			Holder:   h.Address,
+			Standard: standardNames[h.Standard],
+			Balance:  (*hexutil.Big)(h.Balance),
+		})
+	}
+	if next != nil {
+		page.Next = next.Bytes()
+	}
+	return page, nil
+}
+
+// GetTransfers returns the token transfers involving [address], either as the
+// token or as a party, in the order they were accepted.
+func (api *API) GetTransfers(ctx context.Context, address common.Address, opts *PageOptions) (*TransfersPage, error) {
+	limit, err := opts.limit()
+	if err != nil {
+		return nil, err
+	}
+	start, err := opts.cursor(12)
+	if err != nil {
+		return nil, err
+	}
+	db := api.indexer.db
+	positions, next := rawdb.ReadTokenAddressTransfers(db, address, rawdb.TokenTransferPosition{
+		Number: binary.BigEndian.Uint64(start),
+		Index:  binary.BigEndian.Uint32(start[8:]),
+	}, limit)
+	page := &TransfersPage{Transfers: make([]TokenTransfer, 0, len(positions))}
+	for _, position := range positions {
+		transfer := rawdb.ReadTokenTransfer(db, position)
+		if transfer == nil {
+			continue
+		}
+		result := TokenTransfer{
+			Token:       transfer.Token,
+			Standard:    standardNames[transfer.Standard],
+			From:        transfer.From,
+			To:          transfer.To,
+			BlockNumber: hexutil.Uint64(position.Number),
+			TxHash:      transfer.TxHash,
+			LogIndex:    hexutil.Uint(position.Index),
+		}
+		if transfer.Standard == rawdb.TokenERC721 {
+			result.TokenID = (*hexutil.Big)(transfer.Value)
+		} else {
+			result.Value = (*hexutil.Big)(transfer.Value)
+		}
+		page.Transfers = append(page.Transfers, result)
+	}
+	if next != nil {
+		page.Next = binary.BigEndian.AppendUint32(binary.BigEndian.AppendUint64(nil, next.Number), next.Index)
+	}
+	return page, nil
+}
+
+// APIs returns the collection of RPC services the tokens package offers.
+func APIs(indexer *Indexer) []rpc.API {
+	return []rpc.API{
+		{
+			Namespace: "tokens",
+			Service:   NewAPI(indexer),
+			Name:      "tokens",
+		},
+	}
+}
@@ -0,0 +1,306 @@
+// (c) 2024, Ava Labs, Inc. All rights reserved.
+// See the file LICENSE for licensing terms.
+
+// Package tokens implements an index of the ERC-20 and ERC-721 token transfers
+// of accepted blocks, and the tokens RPC namespace serving it.
+package tokens
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/big"
+	"sync"
+	"time"
+
+	"github.com/ava-labs/coreth/core"
+	"github.com/ava-labs/coreth/core/rawdb"
+	"github.com/ava-labs/coreth/core/types"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/ethdb"
+	"github.com/ethereum/go-ethereum/event"
+	"github.com/ethereum/go-ethereum/log"
+)
+
+// transferTopic is the topic of the Transfer event shared by ERC-20 and
+// ERC-721 tokens. ERC-20 tokens index the parties and log the amount, while
+// ERC-721 tokens also index the token id.
+var transferTopic = crypto.Keccak256Hash([]byte("Transfer(address,address,uint256)"))
+
+// indexRetryDelay is the time after which indexing a block that failed to be
+// indexed is retried, unless a newly accepted block triggers it earlier.
+const indexRetryDelay = 30 * time.Second
+
+// Backend is the backend required to index the token transfers of accepted
+// blocks.
+type Backend interface {
+	ChainDb() ethdb.Database
+	LastAcceptedBlock() *types.Block
+	GetReceipts(ctx context.Context, hash common.Hash) (types.Receipts, error)
+	SubscribeChainAcceptedEvent(ch chan<- core.ChainEvent) event.Subscription
+}
+
+// Indexer maintains an on-disk index of the ERC-20 and ERC-721 transfers of
+// accepted blocks, by token and by party, along with the resulting balances
+// of the token holders.
+//
+// The index covers a contiguous range of blocks, starting at the first block
+// available locally when the index was first enabled, which is the state
+// synced block on state synced nodes. The range only advances past a block once
+// it is indexed, a block failing to be indexed is retried until it succeeds.
+//
+// Balances are derived from the indexed transfers, so they are only accurate
+// for tokens whose supply is entirely minted through Transfer events within
+// the indexed range. Since only accepted blocks are indexed, the index never
+// needs to be reorganized.
+type Indexer struct {
+	backend Backend
+	db      ethdb.Database
+
+	first, next uint64 // Range of indexed blocks [first, next)
+	target      uint64 // Last accepted block to be indexed
+	lock        sync.RWMutex
+
+	acceptedCh chan core.ChainEvent
+	notify     chan struct{} // Signals the indexing loop that the target advanced
+	sub        event.Subscription
+	quit       chan struct{}
+	wg         sync.WaitGroup
+}
+
+// NewIndexer creates the token transfer indexer and starts indexing accepted
+// blocks in the background, catching up from the first available block on
+// first start.
+func NewIndexer(backend Backend) *Indexer {
+	db := backend.ChainDb()
+	lastAccepted := backend.LastAcceptedBlock().NumberU64()
+	available := firstAvailableBlock(db)
+	first, next, ok := rawdb.ReadTokenIndexRange(db)
+	if !ok || next < available || next > lastAccepted+1 {
+		if ok {
+			log.Warn("Restarting token transfer index not contiguous with the available blocks", "first", first, "next", next, "available", available)
+		}
+		first, next = available, available
+		rawdb.WriteTokenIndexRange(db, first, next)
+	}
+	i := &Indexer{
+		backend:    backend,
+		db:         db,
+		first:      first,
+		next:       next,
+		target:     lastAccepted,
+		acceptedCh: make(chan core.ChainEvent, 1),
+		notify:     make(chan struct{}, 1),
+		quit:       make(chan struct{}),
+	}
+	i.sub = backend.SubscribeChainAcceptedEvent(i.acceptedCh)
+
+	log.Info("Initialized token transfer indexer", "first", first, "next", next, "lastAccepted", lastAccepted)
+	i.wg.Add(2)
+	go i.eventLoop()
+	go i.indexLoop()
+	return i
+}
+
+// Range returns the range of blocks [first, next) covered by the index.
+func (i *Indexer) Range() (uint64, uint64) {
+	i.lock.RLock()
+	defer i.lock.RUnlock()
+
+	return i.first, i.next
+}
+
+// firstAvailableBlock returns the number of the first block whose receipts
+// are available locally. State synced nodes do not have the blocks before the
+// last state synced block, and history expiry deletes the oldest receipts.
+func firstAvailableBlock(db ethdb.Database) uint64 {
+	first := rawdb.GetLatestSyncPerformed(db)
+	if tail := rawdb.ReadHistoryTail(db); tail != nil {
+		first = max(first, *tail)
+	}
+	return first
+}
+
+// Stop terminates the indexer, waiting for the block being indexed to finish.
+func (i *Indexer) Stop() {
+	i.sub.Unsubscribe()
+	close(i.quit)
+	i.wg.Wait()
+}
+
+// eventLoop advances the indexing target as blocks are accepted.
+func (i *Indexer) eventLoop() {
+	defer i.wg.Done()
+
+	for {
+		select {
+		case ev := <-i.acceptedCh:
+			i.lock.Lock()
+			i.target = max(i.target, ev.Block.NumberU64())
+			i.lock.Unlock()
+
+			select {
+			case i.notify <- struct{}{}:
+			default:
+			}
+		case <-i.sub.Err():
+			return
+		case <-i.quit:
+			return
+		}
+	}
+}
+
+// indexLoop indexes blocks up to the indexing target.
+func (i *Indexer) indexLoop() {
+	defer i.wg.Done()
+
+	for {
+		var retry <-chan time.Time
+		if err := i.indexPending(); err != nil {
+			log.Error("Failed to index token transfers, retrying", "err", err, "retryIn", indexRetryDelay)
+			retry = time.After(indexRetryDelay)
+		}
+		select {
+		case <-i.notify:
+		case <-retry:
+		case <-i.quit:
+			return
+		}
+	}
+}
+
+// indexPending indexes all blocks after the indexed range up to and including
+// the indexing target. It stops at the first block that fails to be indexed,
+// leaving the indexed range ending right before it.
+func (i *Indexer) indexPending() error {
+	for {
+		i.lock.RLock()
+		first, number, target := i.first, i.next, i.target
+		i.lock.RUnlock()
+
+		if number > target {
+			return nil
+		}
+		select {
+		case <-i.quit:
+			return nil
+		default:
+		}
+		batch := i.db.NewBatch()
+		if err := i.indexBlock(batch, number); err != nil {
+			return fmt.Errorf("failed to index block %d: %w", number, err)
+		}
+		rawdb.WriteTokenIndexRange(batch, first, number+1)
+		if err := batch.Write(); err != nil {
+			log.Crit("Failed to write token transfer index", "number", number, "err", err)
+		}
+		i.lock.Lock()
+		i.next = number + 1
+		i.lock.Unlock()
+	}
+}
+
+// holding identifies the balance of a holder in a token.
+type holding struct {
+	holder, token common.Address
+}
+
+// ownership identifies the ownership of an ERC-721 token by a holder.
+type ownership struct {
+	holding
+	id common.Hash
+}
+
+// indexBlock writes the token transfers of the block with [number], along
+// with the updated balances of the parties, to [batch].
+func (i *Indexer) indexBlock(batch ethdb.KeyValueWriter, number uint64) error {
+	hash := rawdb.ReadCanonicalHash(i.db, number)
+	if hash == (common.Hash{}) {
+		return errors.New("canonical hash missing")
+	}
+	receipts, err := i.backend.GetReceipts(context.Background(), hash)
+	if err != nil {
+		return err
+	}
+	var (
+		standards = make(map[holding]rawdb.TokenStandard)
+		balances  = make(map[holding]*big.Int)
+		owned     = make(map[ownership]bool)
+	)
+	balance := func(h holding, standard rawdb.TokenStandard) *big.Int {
+		if _, ok := balances[h]; !ok {
+			_, balances[h] = rawdb.ReadTokenBalance(i.db, h.holder, h.token)
+		}
+		standards[h] = standard
+		return balances[h]
+	}
+	for _, receipt := range receipts {
+		for _, l := range receipt.Logs {
+			transfer, ok := parseTransfer(l)
+			if !ok {
+				continue
+			}
+			rawdb.WriteTokenTransfer(batch, number, uint32(l.Index), transfer)
+
+			// An ERC-721 transfer moves a single token, so the balance of
+			// each party changes by one.
+			amount := transfer.Value
+			if transfer.Standard == rawdb.TokenERC721 {
+				amount = common.Big1
+			}
+			if transfer.From != (common.Address{}) {
+				from := holding{holder: transfer.From, token: transfer.Token}
+				b := balance(from, transfer.Standard)
+				if b.Sub(b, amount).Sign() < 0 {
+					// The index does not cover the whole history of the token
+					b.SetUint64(0)
+				}
+				if transfer.Standard == rawdb.TokenERC721 {
+					owned[ownership{holding: from, id: common.BigToHash(transfer.Value)}] = false
+				}
+			}
+			if transfer.To != (common.Address{}) {
+				to := holding{holder: transfer.To, token: transfer.Token}
+				b := balance(to, transfer.Standard)
+				b.Add(b, amount)
+				if transfer.Standard == rawdb.TokenERC721 {
+					owned[ownership{holding: to, id: common.BigToHash(transfer.Value)}] = true
+				}
+			}
+		}
+	}
+	for h, b := range balances {
+		rawdb.WriteTokenBalance(batch, h.holder, h.token, standards[h], b)
+	}
+	for o, ok := range owned {
+		rawdb.WriteTokenOwned(batch, o.holder, o.token, o.id, ok)
+	}
+	return nil
+}
+
+// parseTransfer returns the token transfer of [l], if it is an ERC-20 or an
+// ERC-721 Transfer event.
+func parseTransfer(l *types.Log) (*rawdb.TokenTransfer, bool) {
+	if len(l.Topics) < 3 || l.Topics[0] != transferTopic {
+		return nil, false
+	}
+	transfer := &rawdb.TokenTransfer{
+		Token:  l.Address,
+		From:   common.BytesToAddress(l.Topics[1].Bytes()),
+		To:     common.BytesToAddress(l.Topics[2].Bytes()),
+		TxHash: l.TxHash,
+	}
+	switch {
+	case len(l.Topics) == 3 && len(l.Data) == 32:
+		transfer.Standard = rawdb.TokenERC20
+		transfer.Value = new(big.Int).SetBytes(l.Data)
+	case len(l.Topics) == 4 && len(l.Data) == 0:
+		transfer.Standard = rawdb.TokenERC721
+		transfer.Value = l.Topics[3].Big()
+	default:
+		return nil, false
+	}
+	return transfer, true
+}
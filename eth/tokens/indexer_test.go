@@ -0,0 +1,250 @@
+// (c) 2024, Ava Labs, Inc. All rights reserved.
+// See the file LICENSE for licensing terms.
+
+package tokens
+
+import (
+	"context"
+	"errors"
+	"math/big"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/ava-labs/coreth/core"
+	"github.com/ava-labs/coreth/core/rawdb"
+	"github.com/ava-labs/coreth/core/types"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/ethdb"
+	"github.com/ethereum/go-ethereum/event"
+	"github.com/stretchr/testify/require"
+)
+
+type testBackend struct {
+	db           ethdb.Database
+	receipts     map[common.Hash]types.Receipts
+	failing      map[uint64]bool // Blocks whose receipts fail to be retrieved
+	lock         sync.Mutex
+	lastAccepted *types.Block
+	acceptedFeed event.Feed
+}
+
+func newTestBackend() *testBackend {
+	b := &testBackend{
+		db:       rawdb.NewMemoryDatabase(),
+		receipts: make(map[common.Hash]types.Receipts),
+		failing:  make(map[uint64]bool),
+	}
+	b.accept(nil)
+	return b
+}
+
+func (b *testBackend) ChainDb() ethdb.Database         { return b.db }
+func (b *testBackend) LastAcceptedBlock() *types.Block { return b.lastAccepted }
+
+func (b *testBackend) GetReceipts(ctx context.Context, hash common.Hash) (types.Receipts, error) {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+
+	if number := rawdb.ReadHeaderNumber(b.db, hash); number != nil && b.failing[*number] {
+		return nil, errors.New("receipts unavailable")
+	}
+	return b.receipts[hash], nil
+}
+
+// setFailing sets whether the receipts of the block with [number] fail to be
+// retrieved.
+func (b *testBackend) setFailing(number uint64, failing bool) {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+
+	b.failing[number] = failing
+}
+
+func (b *testBackend) SubscribeChainAcceptedEvent(ch chan<- core.ChainEvent) event.Subscription {
+	return b.acceptedFeed.Subscribe(ch)
+}
+
+// accept adds a block with a single receipt holding [logs] to the chain and
+// accepts it.
+func (b *testBackend) accept(logs []*types.Log) {
+	var number uint64
+	if b.lastAccepted != nil {
+		number = b.lastAccepted.NumberU64() + 1
+	}
+	block := types.NewBlockWithHeader(&types.Header{Number: new(big.Int).SetUint64(number)})
+	for i, l := range logs {
+		l.BlockNumber, l.BlockHash, l.Index = number, block.Hash(), uint(i)
+	}
+	rawdb.WriteCanonicalHash(b.db, block.Hash(), number)
+	rawdb.WriteHeaderNumber(b.db, block.Hash(), number)
+	b.lock.Lock()
+	b.receipts[block.Hash()] = types.Receipts{{Logs: logs}}
+	b.lock.Unlock()
+	b.lastAccepted = block
+	b.acceptedFeed.Send(core.ChainEvent{Block: block, Hash: block.Hash()})
+}
+
+func erc20Transfer(token, from, to common.Address, amount int64) *types.Log {
+	return &types.Log{
+		Address: token,
+		Topics:  []common.Hash{transferTopic, common.BytesToHash(from.Bytes()), common.BytesToHash(to.Bytes())},
+		Data:    common.BigToHash(big.NewInt(amount)).Bytes(),
+	}
+}
+
+func erc721Transfer(token, from, to common.Address, id int64) *types.Log {
+	return &types.Log{
+		Address: token,
+		Topics:  []common.Hash{transferTopic, common.BytesToHash(from.Bytes()), common.BytesToHash(to.Bytes()), common.BigToHash(big.NewInt(id))},
+	}
+}
+
+func TestIndexer(t *testing.T) {
+	var (
+		erc20  = common.HexToAddress("0x1000000000000000000000000000000000000000")
+		erc721 = common.HexToAddress("0x2000000000000000000000000000000000000000")
+		alice  = common.HexToAddress("0xa000000000000000000000000000000000000000")
+		bob    = common.HexToAddress("0xb000000000000000000000000000000000000000")
+		zero   = common.Address{}
+	)
+	backend := newTestBackend()
+
+	// Transfers accepted before the indexer starts are indexed on catch up.
+	backend.accept([]*types.Log{
+		erc20Transfer(erc20, zero, alice, 100),
+		erc721Transfer(erc721, zero, alice, 1),
+		erc721Transfer(erc721, zero, alice, 2),
+		erc721Transfer(erc721, zero, alice, 3),
+	})
+	indexer := NewIndexer(backend)
+	defer indexer.Stop()
+
+	backend.accept([]*types.Log{
+		erc20Transfer(erc20, alice, bob, 30),
+		erc721Transfer(erc721, alice, bob, 1),
+		// Events with the Transfer topic but neither layout are ignored
+		{Address: erc20, Topics: []common.Hash{transferTopic, common.BytesToHash(alice.Bytes()), common.BytesToHash(bob.Bytes())}},
+	})
+	backend.accept([]*types.Log{
+		erc20Transfer(erc20, bob, zero, 30),
+	})
+	require.Eventually(t, func() bool { _, next := indexer.Range(); return next == 4 }, 5*time.Second, 10*time.Millisecond)
+
+	api := NewAPI(indexer)
+	ctx := context.Background()
+	indexed, err := api.IndexedRange(ctx)
+	require.NoError(t, err)
+	require.Equal(t, &IndexedRange{First: 0, Last: 3}, indexed)
+
+	balances, err := api.GetBalances(ctx, alice, nil)
+	require.NoError(t, err)
+	require.Equal(t, &BalancesPage{Balances: []TokenBalance{
+		{Token: erc20, Standard: "ERC20", Balance: (*hexutil.Big)(big.NewInt(70))},
+		{Token: erc721, Standard: "ERC721", Balance: (*hexutil.Big)(big.NewInt(2))},
+	}}, balances)
+
+	// Page through the ERC-721 tokens of alice
+	ids, err := api.GetTokenIDs(ctx, alice, erc721, &PageOptions{Limit: 1})
+	require.NoError(t, err)
+	require.Equal(t, []*hexutil.Big{(*hexutil.Big)(big.NewInt(2))}, ids.TokenIDs)
+	require.Equal(t, hexutil.Bytes(common.BigToHash(big.NewInt(3)).Bytes()), ids.Next)
+	ids, err = api.GetTokenIDs(ctx, alice, erc721, &PageOptions{Limit: 1, Cursor: ids.Next})
+	require.NoError(t, err)
+	require.Equal(t, []*hexutil.Big{(*hexutil.Big)(big.NewInt(3))}, ids.TokenIDs)
+	require.Nil(t, ids.Next)
+
+	// Bob burned all of his ERC-20 balance
+	balances, err = api.GetBalances(ctx, bob, nil)
+	require.NoError(t, err)
+	require.Equal(t, &BalancesPage{Balances: []TokenBalance{
+		{Token: erc721, Standard: "ERC721", Balance: (*hexutil.Big)(big.NewInt(1))},
+	}}, balances)
+	ids, err = api.GetTokenIDs(ctx, bob, erc721, nil)
+	require.NoError(t, err)
+	require.Equal(t, &TokenIDsPage{TokenIDs: []*hexutil.Big{(*hexutil.Big)(big.NewInt(1))}}, ids)
+
+	// Page through the holders of the ERC-721 token
+	holders, err := api.GetHolders(ctx, erc721, &PageOptions{Limit: 1})
+	require.NoError(t, err)
+	require.Equal(t, []TokenHolder{{Holder: alice, Standard: "ERC721", Balance: (*hexutil.Big)(big.NewInt(2))}}, holders.Holders)
+	require.Equal(t, hexutil.Bytes(bob.Bytes()), holders.Next)
+	holders, err = api.GetHolders(ctx, erc721, &PageOptions{Limit: 1, Cursor: holders.Next})
+	require.NoError(t, err)
+	require.Equal(t, []TokenHolder{{Holder: bob, Standard: "ERC721", Balance: (*hexutil.Big)(big.NewInt(1))}}, holders.Holders)
+	require.Nil(t, holders.Next)
+
+	// Page through the transfers of bob
+	transfers, err := api.GetTransfers(ctx, bob, &PageOptions{Limit: 2})
+	require.NoError(t, err)
+	require.Equal(t, []TokenTransfer{
+		{Token: erc20, Standard: "ERC20", From: alice, To: bob, Value: (*hexutil.Big)(big.NewInt(30)), BlockNumber: 2, LogIndex: 0},
+		{Token: erc721, Standard: "ERC721", From: alice, To: bob, TokenID: (*hexutil.Big)(big.NewInt(1)), BlockNumber: 2, LogIndex: 1},
+	}, transfers.Transfers)
+	require.NotNil(t, transfers.Next)
+	transfers, err = api.GetTransfers(ctx, bob, &PageOptions{Limit: 2, Cursor: transfers.Next})
+	require.NoError(t, err)
+	require.Equal(t, []TokenTransfer{
+		{Token: erc20, Standard: "ERC20", From: bob, To: zero, Value: (*hexutil.Big)(big.NewInt(30)), BlockNumber: 3, LogIndex: 0},
+	}, transfers.Transfers)
+	require.Nil(t, transfers.Next)
+
+	// Transfers are also indexed by token
+	transfers, err = api.GetTransfers(ctx, erc721, nil)
+	require.NoError(t, err)
+	require.Len(t, transfers.Transfers, 4)
+
+	_, err = api.GetTransfers(ctx, bob, &PageOptions{Cursor: hexutil.Bytes{1}})
+	require.ErrorIs(t, err, errInvalidCursor)
+	_, err = api.GetBalances(ctx, bob, &PageOptions{Limit: maxPageLimit + 1})
+	require.Error(t, err)
+}
+
+func TestIndexerRetry(t *testing.T) {
+	var (
+		token = common.HexToAddress("0x1000000000000000000000000000000000000000")
+		alice = common.HexToAddress("0xa000000000000000000000000000000000000000")
+	)
+	backend := newTestBackend()
+	indexer := NewIndexer(backend)
+	defer indexer.Stop()
+
+	// The index does not advance past a block failing to be indexed, so the
+	// mint is not lost.
+	backend.setFailing(1, true)
+	backend.accept([]*types.Log{erc20Transfer(token, common.Address{}, alice, 100)})
+	backend.accept(nil)
+	require.Eventually(t, func() bool { _, next := indexer.Range(); return next == 1 }, 5*time.Second, 10*time.Millisecond)
+	require.Never(t, func() bool { _, next := indexer.Range(); return next > 1 }, 100*time.Millisecond, 10*time.Millisecond)
+
+	// The failed block is indexed once a newly accepted block triggers a retry
+	backend.setFailing(1, false)
+	backend.accept(nil)
+	require.Eventually(t, func() bool { _, next := indexer.Range(); return next == 4 }, 5*time.Second, 10*time.Millisecond)
+
+	balances, err := NewAPI(indexer).GetBalances(context.Background(), alice, nil)
+	require.NoError(t, err)
+	require.Equal(t, []TokenBalance{{Token: token, Standard: "ERC20", Balance: (*hexutil.Big)(big.NewInt(100))}}, balances.Balances)
+}
+
+func TestIndexerStateSynced(t *testing.T) {
+	backend := newTestBackend()
+	for i := 0; i < 5; i++ {
+		backend.accept(nil)
+	}
+	// Simulate a node state synced to block 4, missing the blocks before it
+	for number := uint64(0); number < 4; number++ {
+		rawdb.DeleteCanonicalHash(backend.db, number)
+	}
+	require.NoError(t, rawdb.WriteSyncPerformed(backend.db, 4))
+
+	indexer := NewIndexer(backend)
+	defer indexer.Stop()
+	backend.accept(nil)
+	require.Eventually(t, func() bool { _, next := indexer.Range(); return next == 7 }, 5*time.Second, 10*time.Millisecond)
+
+	indexed, err := NewAPI(indexer).IndexedRange(context.Background())
+	require.NoError(t, err)
+	require.Equal(t, &IndexedRange{First: 4, Last: 6}, indexed)
+}
@@ -32,12 +32,15 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
+	"math"
+	"math/big"
 	"os"
 	"runtime"
 	"sync"
 	"time"
 
 	"github.com/ava-labs/coreth/consensus"
+	"github.com/ava-labs/coreth/consensus/dummy"
 	"github.com/ava-labs/coreth/core"
 	"github.com/ava-labs/coreth/core/state"
 	"github.com/ava-labs/coreth/core/types"
@@ -76,7 +79,17 @@ const (
 	maximumPendingTraceStates = 128
 )
 
-var errTxNotFound = errors.New("transaction not found")
+var (
+	errTxNotFound = errors.New("transaction not found")
+
+	// errAtomicTxsUnsupported is returned if the atomic transactions of a
+	// block are traced with a tracer not implementing [AtomicTxTracer].
+	errAtomicTxsUnsupported = errors.New("tracer does not support atomic transactions")
+
+	// errGasCapExhausted is returned if the calls traced by a single request
+	// used up the RPC gas cap.
+	errGasCapExhausted = errors.New("RPC gas cap exhausted")
+)
 
 // StateReleaseFunc is used to deallocate resources held by constructing a
 // historical state for tracing purposes.
@@ -205,6 +218,7 @@ type StdTraceConfig struct {
 // txTraceResult is the result of a single transaction trace.
 type txTraceResult struct {
 	TxHash common.Hash `json:"txHash"`           // transaction hash
+	Atomic bool        `json:"atomic,omitempty"` // Whether the transaction is an atomic transaction
 	Result interface{} `json:"result,omitempty"` // Trace results produced by the tracer
 	Error  string      `json:"error,omitempty"`  // Trace failure produced by the tracer
 }
@@ -481,6 +495,50 @@ func (api *API) TraceBlockByHash(ctx context.Context, hash common.Hash, config *
 	return api.traceBlock(ctx, block, config)
 }
 
+// TraceBlockAggregate traces the block with the tracer in [config] and merges
+// the results of its transactions into a single result for the block. Only
+// tracers supporting aggregation, such as the gasProfiler, can be used.
+func (api *API) TraceBlockAggregate(ctx context.Context, blockNrOrHash rpc.BlockNumberOrHash, config *TraceConfig) (json.RawMessage, error) {
+	if config == nil || config.Tracer == nil {
+		return nil, errors.New("tracer not specified")
+	}
+	var (
+		block *types.Block
+		err   error
+	)
+	if hash, ok := blockNrOrHash.Hash(); ok {
+		block, err = api.blockByHash(ctx, hash)
+	} else if number, ok := blockNrOrHash.Number(); ok {
+		block, err = api.blockByNumber(ctx, number)
+	} else {
+		return nil, errors.New("invalid arguments; neither block nor hash specified")
+	}
+	if err != nil {
+		return nil, err
+	}
+	results, err := api.traceBlock(ctx, block, config)
+	if err != nil {
+		return nil, err
+	}
+	txResults := make([]json.RawMessage, 0, len(results))
+	for _, result := range results {
+		// Atomic transactions do not execute code, leave them out of the
+		// aggregate rather than requiring aggregators to tell them apart.
+		if result.Atomic {
+			continue
+		}
+		if result.Error != "" {
+			return nil, fmt.Errorf("failed to trace transaction %s: %s", result.TxHash.Hex(), result.Error)
+		}
+		raw, ok := result.Result.(json.RawMessage)
+		if !ok {
+			return nil, fmt.Errorf("tracer %q does not support block aggregation", *config.Tracer)
+		}
+		txResults = append(txResults, raw)
+	}
+	return DefaultDirectory.Aggregate(*config.Tracer, txResults)
+}
+
 // TraceBlock returns the structured logs created during the execution of EVM
 // and returns them as a JSON object.
 func (api *baseAPI) TraceBlock(ctx context.Context, blob hexutil.Bytes, config *TraceConfig) ([]*txTraceResult, error) {
@@ -669,6 +727,69 @@ func (api *baseAPI) traceBlock(ctx context.Context, block *types.Block, config *
 		// Only delete empty objects if EIP158/161 (a.k.a Spurious Dragon) is in effect
 		statedb.Finalise(is158)
 	}
+	atomicResults, err := api.traceAtomicTxs(block, blockCtx, statedb, config)
+	if err != nil {
+		return nil, err
+	}
+	return append(results, atomicResults...), nil
+}
+
+// traceAtomicTxs traces the atomic transactions of [block] on top of [statedb],
+// the state after executing its transactions. Atomic transactions can only be
+// traced by tracers implementing [AtomicTxTracer], other tracers get an error
+// result for each of them rather than leaving them out.
+func (api *baseAPI) traceAtomicTxs(block *types.Block, blockCtx vm.BlockContext, statedb *state.StateDB, config *TraceConfig) ([]*txTraceResult, error) {
+	if config == nil || config.Tracer == nil {
+		return nil, nil
+	}
+	engine, ok := api.backend.Engine().(interface {
+		ExtraStateTxs(block *types.Block) ([]*dummy.ExtraStateTx, error)
+	})
+	if !ok {
+		return nil, nil
+	}
+	txs, err := engine.ExtraStateTxs(block)
+	if err != nil {
+		return nil, err
+	}
+	var (
+		results     []*txTraceResult
+		chainConfig = api.backend.ChainConfig()
+	)
+	for i, tx := range txs {
+		txctx := &Context{
+			BlockHash:   block.Hash(),
+			BlockNumber: block.Number(),
+			TxIndex:     len(block.Transactions()) + i,
+			TxHash:      tx.ID,
+		}
+		tracer, err := DefaultDirectory.New(*config.Tracer, txctx, config.TracerConfig)
+		if err != nil {
+			return nil, err
+		}
+		atomicTracer, ok := tracer.(AtomicTxTracer)
+		if !ok {
+			if err := tx.Apply(statedb); err != nil {
+				return nil, fmt.Errorf("failed to apply atomic transaction %s: %w", tx.ID.Hex(), err)
+			}
+			statedb.Finalise(chainConfig.IsEIP158(block.Number()))
+			results = append(results, &txTraceResult{TxHash: tx.ID, Atomic: true, Error: fmt.Sprintf("%v: %s", errAtomicTxsUnsupported, *config.Tracer)})
+			continue
+		}
+		vmenv := vm.NewEVM(blockCtx, vm.TxContext{GasPrice: new(big.Int)}, statedb, chainConfig, vm.Config{Tracer: tracer, NoBaseFee: true})
+		atomicTracer.CaptureAtomicTxStart(vmenv, tx.Transfers)
+		if err := tx.Apply(statedb); err != nil {
+			return nil, fmt.Errorf("failed to apply atomic transaction %s: %w", tx.ID.Hex(), err)
+		}
+		atomicTracer.CaptureAtomicTxEnd()
+		statedb.Finalise(chainConfig.IsEIP158(block.Number()))
+
+		res, err := tracer.GetResult()
+		if err != nil {
+			return nil, err
+		}
+		results = append(results, &txTraceResult{TxHash: tx.ID, Atomic: true, Result: res})
+	}
 	return results, nil
 }
 
@@ -746,7 +867,11 @@ txloop:
 	if failed != nil {
 		return nil, failed
 	}
-	return results, nil
+	atomicResults, err := api.traceAtomicTxs(block, blockCtx, statedb, config)
+	if err != nil {
+		return nil, err
+	}
+	return append(results, atomicResults...), nil
 }
 
 // standardTraceBlockToFile configures a new tracer which uses standard JSON output,
@@ -917,6 +1042,125 @@ func (api *API) TraceTransaction(ctx context.Context, hash common.Hash, config *
 // the trace will be conducted on the state after executing the specified transaction
 // within the specified block.
 func (api *API) TraceCall(ctx context.Context, args ethapi.TransactionArgs, blockNrOrHash rpc.BlockNumberOrHash, config *TraceCallConfig) (interface{}, error) {
+	block, statedb, release, err := api.traceCallState(ctx, blockNrOrHash, config)
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+
+	vmctx := core.NewEVMBlockContext(block.Header(), api.chainContext(ctx), nil)
+	// Apply the customization rules if required.
+	if config != nil {
+		if err := api.applyBlockOverrides(config.BlockOverrides, block.Time(), &vmctx, statedb); err != nil {
+			return nil, err
+		}
+		if err := config.StateOverrides.Apply(statedb); err != nil {
+			return nil, err
+		}
+	}
+	// Execute the trace
+	msg, err := args.ToMessage(api.backend.RPCGasCap(), block.BaseFee())
+	if err != nil {
+		return nil, err
+	}
+
+	var traceConfig *TraceConfig
+	if config != nil {
+		traceConfig = &config.TraceConfig
+	}
+	return api.traceTx(ctx, msg, new(Context), vmctx, statedb, traceConfig)
+}
+
+// TraceCallArgs is a call of a bundle traced by traceCallMany, along with
+// the configuration of its tracer.
+type TraceCallArgs struct {
+	ethapi.TransactionArgs
+	TraceConfig *TraceConfig `json:"traceConfig"` // Overrides the default tracer of the request
+}
+
+// TraceCallBundle is an ordered list of calls traced by traceCallMany within
+// the same simulated block.
+type TraceCallBundle struct {
+	Calls          []TraceCallArgs        `json:"calls"`
+	BlockOverrides *ethapi.BlockOverrides `json:"blockOverrides"`
+}
+
+// TraceCallMany traces bundles of calls on top of the provided block, each
+// call observing the state changes of the calls preceding it. Each bundle is
+// executed in its own simulated block, following the block of the previous
+// bundle by one in number and timestamp unless overridden by the bundle.
+//
+// The state and block overrides of [config] are applied before the first
+// bundle, and its trace config is used for the calls without their own. The
+// RPC gas cap applies to the gas used by all the calls of the bundles.
+func (api *API) TraceCallMany(ctx context.Context, bundles []TraceCallBundle, blockNrOrHash rpc.BlockNumberOrHash, config *TraceCallConfig) ([][]interface{}, error) {
+	if len(bundles) == 0 {
+		return nil, errors.New("empty bundles")
+	}
+	block, statedb, release, err := api.traceCallState(ctx, blockNrOrHash, config)
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+
+	var defaultConfig *TraceConfig
+	vmctx := core.NewEVMBlockContext(block.Header(), api.chainContext(ctx), nil)
+	if config != nil {
+		defaultConfig = &config.TraceConfig
+		if err := api.applyBlockOverrides(config.BlockOverrides, block.Time(), &vmctx, statedb); err != nil {
+			return nil, err
+		}
+		if err := config.StateOverrides.Apply(statedb); err != nil {
+			return nil, err
+		}
+	}
+	var (
+		gasCap  = api.backend.RPCGasCap()
+		gasPool = new(core.GasPool).AddGas(gasCap)
+		results = make([][]interface{}, len(bundles))
+	)
+	if gasCap == 0 {
+		gasPool.SetGas(math.MaxUint64)
+	}
+	for i, bundle := range bundles {
+		parentTime := vmctx.Time
+		if i > 0 {
+			vmctx.BlockNumber = new(big.Int).Add(vmctx.BlockNumber, common.Big1)
+			vmctx.Time++
+		}
+		if err := api.applyBlockOverrides(bundle.BlockOverrides, parentTime, &vmctx, statedb); err != nil {
+			return nil, fmt.Errorf("bundle %d: %w", i, err)
+		}
+		results[i] = make([]interface{}, len(bundle.Calls))
+		for j, call := range bundle.Calls {
+			// Cap every call to the gas left by the calls preceding it. A cap of
+			// 0 would lift the cap altogether, so bail out instead.
+			if gasPool.Gas() == 0 {
+				return nil, fmt.Errorf("bundle %d, call %d: %w", i, j, errGasCapExhausted)
+			}
+			msg, err := call.ToMessage(gasPool.Gas(), vmctx.BaseFee)
+			if err != nil {
+				return nil, fmt.Errorf("bundle %d, call %d: %w", i, j, err)
+			}
+			traceConfig := defaultConfig
+			if call.TraceConfig != nil {
+				traceConfig = call.TraceConfig
+			}
+			txctx := &Context{BlockNumber: vmctx.BlockNumber, TxIndex: j}
+			if results[i][j], err = api.traceTxWithGasPool(ctx, msg, txctx, vmctx, statedb, traceConfig, gasPool); err != nil {
+				return nil, fmt.Errorf("bundle %d, call %d: %w", i, j, err)
+			}
+			// Make the state changes of the call visible to the next one
+			statedb.Finalise(api.backend.ChainConfig().IsEIP158(vmctx.BlockNumber))
+		}
+	}
+	return results, nil
+}
+
+// traceCallState retrieves the block specified by [blockNrOrHash] along with
+// the state to trace calls on, being the state after the block or after the
+// transaction at the index of [config] within the block.
+func (api *API) traceCallState(ctx context.Context, blockNrOrHash rpc.BlockNumberOrHash, config *TraceCallConfig) (*types.Block, *state.StateDB, StateReleaseFunc, error) {
 	// Try to retrieve the specified block
 	var (
 		err     error
@@ -933,14 +1177,14 @@ func (api *API) TraceCall(ctx context.Context, args ethapi.TransactionArgs, bloc
 			// more flexibility and stability than trying to trace on 'pending', since
 			// the contents of 'pending' is unstable and probably not a true representation
 			// of what the next actual block is likely to contain.
-			return nil, errors.New("tracing on top of pending is not supported")
+			return nil, nil, nil, errors.New("tracing on top of pending is not supported")
 		}
 		block, err = api.blockByNumber(ctx, number)
 	} else {
-		return nil, errors.New("invalid arguments; neither block nor hash specified")
+		return nil, nil, nil, errors.New("invalid arguments; neither block nor hash specified")
 	}
 	if err != nil {
-		return nil, err
+		return nil, nil, nil, err
 	}
 	// try to recompute the state
 	reexec := defaultTraceReexec
@@ -954,43 +1198,29 @@ func (api *API) TraceCall(ctx context.Context, args ethapi.TransactionArgs, bloc
 		statedb, release, err = api.backend.StateAtBlock(ctx, block, reexec, nil, true, false)
 	}
 	if err != nil {
-		return nil, err
-	}
-	defer release()
-
-	vmctx := core.NewEVMBlockContext(block.Header(), api.chainContext(ctx), nil)
-	// Apply the customization rules if required.
-	if config != nil {
-		originalTime := block.Time()
-		config.BlockOverrides.Apply(&vmctx)
-		// Apply all relevant upgrades from [originalTime] to the block time set in the override.
-		// Should be applied before the state overrides.
-		err = core.ApplyUpgrades(api.backend.ChainConfig(), &originalTime, &vmctx, statedb)
-		if err != nil {
-			return nil, err
-		}
-
-		if err := config.StateOverrides.Apply(statedb); err != nil {
-			return nil, err
-		}
-	}
-	// Execute the trace
-	msg, err := args.ToMessage(api.backend.RPCGasCap(), block.BaseFee())
-	if err != nil {
-		return nil, err
+		return nil, nil, nil, err
 	}
+	return block, statedb, release, nil
+}
 
-	var traceConfig *TraceConfig
-	if config != nil {
-		traceConfig = &config.TraceConfig
-	}
-	return api.traceTx(ctx, msg, new(Context), vmctx, statedb, traceConfig)
+// applyBlockOverrides applies [overrides] to [vmctx], along with all network
+// upgrades activated between [parentTime] and the resulting block time.
+// Must be applied before any state overrides.
+func (api *API) applyBlockOverrides(overrides *ethapi.BlockOverrides, parentTime uint64, vmctx *vm.BlockContext, statedb *state.StateDB) error {
+	overrides.Apply(vmctx)
+	return core.ApplyUpgrades(api.backend.ChainConfig(), &parentTime, vmctx, statedb)
 }
 
 // traceTx configures a new tracer according to the provided configuration, and
 // executes the given message in the provided environment. The return value will
 // be tracer dependent.
 func (api *baseAPI) traceTx(ctx context.Context, message *core.Message, txctx *Context, vmctx vm.BlockContext, statedb *state.StateDB, config *TraceConfig) (interface{}, error) {
+	return api.traceTxWithGasPool(ctx, message, txctx, vmctx, statedb, config, new(core.GasPool).AddGas(message.GasLimit))
+}
+
+// traceTxWithGasPool is traceTx drawing the gas of the message from [gp], which
+// is left holding the gas not used by the message.
+func (api *baseAPI) traceTxWithGasPool(ctx context.Context, message *core.Message, txctx *Context, vmctx vm.BlockContext, statedb *state.StateDB, config *TraceConfig, gp *core.GasPool) (interface{}, error) {
 	var (
 		tracer    Tracer
 		err       error
@@ -1029,14 +1259,15 @@ func (api *baseAPI) traceTx(ctx context.Context, message *core.Message, txctx *C
 
 	// Call Prepare to clear out the statedb access list
 	statedb.SetTxContext(txctx.TxHash, txctx.TxIndex)
-	if _, err = core.ApplyMessage(vmenv, message, new(core.GasPool).AddGas(message.GasLimit)); err != nil {
+	if _, err = core.ApplyMessage(vmenv, message, gp); err != nil {
 		return nil, fmt.Errorf("tracing failed: %w", err)
 	}
 	return tracer.GetResult()
 }
 
 // APIs return the collection of RPC services the tracer package offers.
-func APIs(backend Backend) []rpc.API {
+// [indexer] is optional and used by the trace namespace to serve trace_filter.
+func APIs(backend Backend, indexer *TraceIndexer) []rpc.API {
 	// Append all the local APIs and return
 	return []rpc.API{
 		{
@@ -1049,6 +1280,11 @@ func APIs(backend Backend) []rpc.API {
 			Service:   NewFileTracerAPI(backend),
 			Name:      "debug-file-tracer",
 		},
+		{
+			Namespace: "trace",
+			Service:   NewTraceAPI(backend, indexer),
+			Name:      "trace",
+		},
 	}
 }
 
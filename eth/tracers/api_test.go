@@ -34,6 +34,7 @@ import (
 	"fmt"
 	"math/big"
 	"reflect"
+	"strings"
 	"sync/atomic"
 	"testing"
 
@@ -438,6 +439,137 @@ func TestTraceCall(t *testing.T) {
 	}
 }
 
+func TestTraceCallMany(t *testing.T) {
+	t.Parallel()
+
+	accounts := newAccounts(1)
+	genesis := &core.Genesis{
+		Config: params.TestBanffChainConfig,
+		Alloc: core.GenesisAlloc{
+			accounts[0].addr: {Balance: big.NewInt(params.Ether)},
+		},
+	}
+	genBlocks := 2
+	backend := newTestBackend(t, genBlocks, genesis, func(i int, b *core.BlockGen) {})
+	defer backend.teardown()
+	api := NewAPI(backend)
+
+	var (
+		// counter increments slot 0 and returns the new value
+		counter     = common.HexToAddress("0x1111111111111111111111111111111111111111")
+		counterCode = hexutil.Bytes(common.FromHex("0x6000546001018060005560005260206000f3"))
+		// number returns the block number
+		number     = common.HexToAddress("0x2222222222222222222222222222222222222222")
+		numberCode = hexutil.Bytes(common.FromHex("0x4360005260206000f3"))
+		limit      = &TraceConfig{Config: &logger.Config{Limit: 1}}
+		latest     = rpc.BlockNumber(genBlocks)
+	)
+	call := func(to common.Address, config *TraceConfig) TraceCallArgs {
+		return TraceCallArgs{
+			TransactionArgs: ethapi.TransactionArgs{From: &accounts[0].addr, To: &to},
+			TraceConfig:     config,
+		}
+	}
+	bundles := []TraceCallBundle{
+		{Calls: []TraceCallArgs{call(counter, nil), call(counter, limit), call(number, nil)}},
+		{Calls: []TraceCallArgs{call(counter, nil), call(number, nil)}},
+		{
+			Calls:          []TraceCallArgs{call(number, nil)},
+			BlockOverrides: &ethapi.BlockOverrides{Number: (*hexutil.Big)(big.NewInt(0x1337))},
+		},
+	}
+	config := &TraceCallConfig{
+		StateOverrides: &ethapi.StateOverride{
+			counter: ethapi.OverrideAccount{Code: &counterCode},
+			number:  ethapi.OverrideAccount{Code: &numberCode},
+		},
+	}
+	results, err := api.TraceCallMany(context.Background(), bundles, rpc.BlockNumberOrHash{BlockNumber: &latest}, config)
+	if err != nil {
+		t.Fatalf("failed to trace call bundles: %v", err)
+	}
+	want := [][]uint64{{1, 2, 2}, {3, 3}, {0x1337}}
+	if len(results) != len(want) {
+		t.Fatalf("bundle count mismatch: have %d, want %d", len(results), len(want))
+	}
+	for i := range want {
+		if len(results[i]) != len(want[i]) {
+			t.Fatalf("bundle %d: call count mismatch: have %d, want %d", i, len(results[i]), len(want[i]))
+		}
+		for j, result := range results[i] {
+			var have logger.ExecutionResult
+			if err := json.Unmarshal(result.(json.RawMessage), &have); err != nil {
+				t.Fatalf("bundle %d, call %d: failed to unmarshal result: %v", i, j, err)
+			}
+			if have.Failed {
+				t.Fatalf("bundle %d, call %d: call failed", i, j)
+			}
+			if ret := new(big.Int).SetBytes(common.FromHex(have.ReturnValue)).Uint64(); ret != want[i][j] {
+				t.Errorf("bundle %d, call %d: return value mismatch: have %d, want %d", i, j, ret, want[i][j])
+			}
+			// The second call of the first bundle uses its own tracer config
+			if i == 0 && j == 1 {
+				if len(have.StructLogs) != 1 {
+					t.Errorf("bundle %d, call %d: struct log count mismatch: have %d, want 1", i, j, len(have.StructLogs))
+				}
+			} else if len(have.StructLogs) <= 1 {
+				t.Errorf("bundle %d, call %d: missing struct logs", i, j)
+			}
+		}
+	}
+}
+
+func TestTraceCallManyGasCap(t *testing.T) {
+	t.Parallel()
+
+	accounts := newAccounts(1)
+	genesis := &core.Genesis{
+		Config: params.TestBanffChainConfig,
+		Alloc: core.GenesisAlloc{
+			accounts[0].addr: {Balance: big.NewInt(params.Ether)},
+		},
+	}
+	genBlocks := 1
+	backend := newTestBackend(t, genBlocks, genesis, func(i int, b *core.BlockGen) {})
+	defer backend.teardown()
+	api := NewAPI(backend)
+
+	var (
+		// loop jumps back to its start until it runs out of gas
+		loop     = common.HexToAddress("0x1111111111111111111111111111111111111111")
+		loopCode = hexutil.Bytes(common.FromHex("0x5b600056"))
+		gas      = hexutil.Uint64(backend.RPCGasCap() / 2)
+		limit    = &TraceConfig{Config: &logger.Config{Limit: 1}}
+		latest   = rpc.BlockNumber(genBlocks)
+	)
+	call := TraceCallArgs{
+		TransactionArgs: ethapi.TransactionArgs{From: &accounts[0].addr, To: &loop, Gas: &gas},
+		TraceConfig:     limit,
+	}
+	config := &TraceCallConfig{
+		StateOverrides: &ethapi.StateOverride{
+			loop: ethapi.OverrideAccount{Code: &loopCode},
+		},
+	}
+	// Each call burns half of the gas cap, so the calls of the bundles use it
+	// up after two calls even though every call fits within the cap.
+	bundles := []TraceCallBundle{
+		{Calls: []TraceCallArgs{call}},
+		{Calls: []TraceCallArgs{call, call}},
+	}
+	_, err := api.TraceCallMany(context.Background(), bundles, rpc.BlockNumberOrHash{BlockNumber: &latest}, config)
+	if !errors.Is(err, errGasCapExhausted) {
+		t.Fatalf("error mismatch: have %v, want %v", err, errGasCapExhausted)
+	}
+	if want := "bundle 1, call 1"; !strings.Contains(err.Error(), want) {
+		t.Fatalf("error %q does not mention %q", err, want)
+	}
+	// Two calls fit within the gas cap.
+	if _, err := api.TraceCallMany(context.Background(), bundles[1:], rpc.BlockNumberOrHash{BlockNumber: &latest}, config); err != nil {
+		t.Fatalf("failed to trace call bundles: %v", err)
+	}
+}
+
 func TestTraceTransaction(t *testing.T) {
 	t.Parallel()
 
@@ -584,6 +716,112 @@ func TestTraceBlock(t *testing.T) {
 	}
 }
 
+// testAtomicTracer reports the balances of the accounts credited and debited
+// by an atomic transaction before and after its transfers are applied.
+type testAtomicTracer struct {
+	testFlatTracer
+	env       *vm.EVM
+	transfers []dummy.ExtraStateTransfer
+	Pre       map[common.Address]*big.Int `json:"pre"`
+	Post      map[common.Address]*big.Int `json:"post"`
+}
+
+func newTestAtomicTracer(ctx *Context, _ json.RawMessage) (Tracer, error) {
+	return &testAtomicTracer{
+		testFlatTracer: testFlatTracer{ctx: ctx},
+		Pre:            make(map[common.Address]*big.Int),
+		Post:           make(map[common.Address]*big.Int),
+	}, nil
+}
+
+func (t *testAtomicTracer) CaptureAtomicTxStart(env *vm.EVM, transfers []dummy.ExtraStateTransfer) {
+	t.env, t.transfers = env, transfers
+	for _, transfer := range transfers {
+		t.Pre[transfer.Address] = env.StateDB.GetBalance(transfer.Address)
+	}
+}
+
+func (t *testAtomicTracer) CaptureAtomicTxEnd() {
+	for _, transfer := range t.transfers {
+		t.Post[transfer.Address] = t.env.StateDB.GetBalance(transfer.Address)
+	}
+}
+
+func (t *testAtomicTracer) GetResult() (json.RawMessage, error) {
+	return json.Marshal(t)
+}
+
+func TestTraceBlockAtomicTxs(t *testing.T) {
+	t.Parallel()
+
+	accounts := newAccounts(2)
+	genesis := &core.Genesis{
+		Config: params.TestChainConfig,
+		Alloc: core.GenesisAlloc{
+			accounts[0].addr: {Balance: big.NewInt(params.Ether)},
+		},
+	}
+	signer := types.HomesteadSigner{}
+	var txHash common.Hash
+	backend := newTestBackend(t, 1, genesis, func(i int, b *core.BlockGen) {
+		tx, _ := types.SignTx(types.NewTransaction(0, accounts[1].addr, big.NewInt(1000), params.TxGas, b.BaseFee(), nil), signer, accounts[0].key)
+		b.AddTx(tx)
+		txHash = tx.Hash()
+	})
+	defer backend.teardown()
+
+	// The atomic transaction credits account[1] on top of the transfer
+	atomicTxID := common.HexToHash("0x01")
+	backend.engine = dummy.NewFakerWithCallbacks(dummy.ConsensusCallbacks{
+		OnExtraStateTxs: func(block *types.Block) ([]*dummy.ExtraStateTx, error) {
+			return []*dummy.ExtraStateTx{{
+				ID:        atomicTxID,
+				Transfers: []dummy.ExtraStateTransfer{{Address: accounts[1].addr, Amount: big.NewInt(5)}},
+				Apply: func(statedb *state.StateDB) error {
+					statedb.AddBalance(accounts[1].addr, big.NewInt(5))
+					return nil
+				},
+			}}, nil
+		},
+	})
+	api := NewAPI(backend)
+
+	// Tracers not tracing atomic transactions, native or JS, get an error
+	// result for them rather than leaving them out
+	for _, name := range []string{flatCallTracerName, "testJSFlatTracer"} {
+		results, err := api.TraceBlockByNumber(context.Background(), 1, &TraceConfig{Tracer: &name})
+		if err != nil {
+			t.Fatalf("failed to trace block with %s: %v", name, err)
+		}
+		if len(results) != 2 || results[0].Error != "" {
+			t.Fatalf("unexpected trace results with %s: %v", name, results)
+		}
+		if results[1].TxHash != atomicTxID || !results[1].Atomic || !strings.Contains(results[1].Error, errAtomicTxsUnsupported.Error()) {
+			t.Fatalf("unexpected atomic trace result with %s: %v", name, results[1])
+		}
+	}
+
+	tracer := "testAtomicTracer"
+	results, err := api.TraceBlockByNumber(context.Background(), 1, &TraceConfig{Tracer: &tracer})
+	if err != nil {
+		t.Fatalf("failed to trace block: %v", err)
+	}
+	if len(results) != 2 {
+		t.Fatalf("trace result count mismatch: have %d, want 2", len(results))
+	}
+	if results[0].TxHash != txHash || results[0].Atomic {
+		t.Fatalf("unexpected trace result: %v", results[0])
+	}
+	if results[1].TxHash != atomicTxID || !results[1].Atomic {
+		t.Fatalf("unexpected atomic trace result: %v", results[1])
+	}
+	have := string(results[1].Result.(json.RawMessage))
+	want := fmt.Sprintf(`{"pre":{"%s":1000},"post":{"%s":1005}}`, strings.ToLower(accounts[1].addr.Hex()), strings.ToLower(accounts[1].addr.Hex()))
+	if have != want {
+		t.Fatalf("atomic trace result mismatch: have %s, want %s", have, want)
+	}
+}
+
 func TestTracingWithOverrides(t *testing.T) {
 	t.Parallel()
 	// Initialize test accounts
@@ -0,0 +1,94 @@
+// (c) 2024, Ava Labs, Inc. All rights reserved.
+// See the file LICENSE for licensing terms.
+
+package tracetest
+
+import (
+	"encoding/json"
+	"math/big"
+	"testing"
+
+	"github.com/ava-labs/coreth/consensus/dummy"
+	"github.com/ava-labs/coreth/core"
+	"github.com/ava-labs/coreth/core/rawdb"
+	"github.com/ava-labs/coreth/core/vm"
+	"github.com/ava-labs/coreth/eth/tracers"
+	"github.com/ava-labs/coreth/params"
+	"github.com/ava-labs/coreth/tests"
+	"github.com/ethereum/go-ethereum/common"
+)
+
+// TestAtomicTxTracers checks that the state and call tracers report the credits
+// and debits of an atomic export of AVAX and a multicoin asset.
+func TestAtomicTxTracers(t *testing.T) {
+	var (
+		sender  = common.HexToAddress("0x71562b71999873db5b286df957af199ec94617f7")
+		assetID = common.HexToHash("0x3d9bdac0ed1d761330cf680efdeb1a42159eb387d6d2950c96f7d28f61bbe2aa")
+		alloc   = core.GenesisAlloc{
+			sender: {
+				Balance:   big.NewInt(params.Ether),
+				MCBalance: core.GenesisMultiCoinBalance{assetID: big.NewInt(100)},
+			},
+		}
+		transfers = []dummy.ExtraStateTransfer{
+			{Address: sender, Amount: big.NewInt(params.GWei), Debit: true},
+			{Address: sender, AssetID: assetID, Amount: big.NewInt(40), Debit: true},
+		}
+	)
+	testSuite := []struct {
+		tracer string
+		config string
+		want   string
+	}{
+		{
+			tracer: "stateDiffTracer",
+			want:   `{"0x71562b71999873db5b286df957af199ec94617f7":{"balance":{"*":{"from":"0xde0b6b3a7640000","to":"0xde0b6b36bc93600"}},"nonce":{"*":{"from":"0x0","to":"0x1"}},"code":"=","storage":{},"multicoin":{"0x3d9bdac0ed1d761330cf680efdeb1a42159eb387d6d2950c96f7d28f61bbe2aa":{"*":{"from":"0x64","to":"0x3c"}}}}}`,
+		},
+		{
+			tracer: "prestateTracer",
+			config: `{"diffMode": true}`,
+			want:   `{"post":{"0x71562b71999873db5b286df957af199ec94617f7":{"balance":"0xde0b6b36bc93600","nonce":1}},"pre":{"0x71562b71999873db5b286df957af199ec94617f7":{"balance":"0xde0b6b3a7640000"}}}`,
+		},
+		{
+			tracer: "callTracer",
+			want:   `{"from":"0x0000000000000000000000000000000000000000","gas":"0x0","gasUsed":"0x0","to":"0x0000000000000000000000000000000000000000","input":"0x","calls":[{"from":"0x71562b71999873db5b286df957af199ec94617f7","gas":"0x0","gasUsed":"0x0","to":"0x0000000000000000000000000000000000000000","input":"0x","value":"0x3b9aca00","type":"CALL"},{"from":"0x71562b71999873db5b286df957af199ec94617f7","gas":"0x0","gasUsed":"0x0","to":"0x0000000000000000000000000000000000000000","input":"0x3d9bdac0ed1d761330cf680efdeb1a42159eb387d6d2950c96f7d28f61bbe2aa0000000000000000000000000000000000000000000000000000000000000028","value":"0x0","type":"CALL"}],"value":"0x0","type":"CALL"}`,
+		},
+		{
+			tracer: "flatCallTracer",
+			want:   `[{"action":{"callType":"call","from":"0x0000000000000000000000000000000000000000","gas":"0x0","input":"0x","to":"0x0000000000000000000000000000000000000000","value":"0x0"},"blockHash":null,"blockNumber":0,"result":{"gasUsed":"0x0","output":"0x"},"subtraces":2,"traceAddress":[],"transactionHash":null,"transactionPosition":0,"type":"call"},{"action":{"callType":"call","from":"0x71562b71999873db5b286df957af199ec94617f7","gas":"0x0","input":"0x","to":"0x0000000000000000000000000000000000000000","value":"0x3b9aca00"},"blockHash":null,"blockNumber":0,"result":{"gasUsed":"0x0","output":"0x"},"subtraces":0,"traceAddress":[0],"transactionHash":null,"transactionPosition":0,"type":"call"},{"action":{"callType":"call","from":"0x71562b71999873db5b286df957af199ec94617f7","gas":"0x0","input":"0x3d9bdac0ed1d761330cf680efdeb1a42159eb387d6d2950c96f7d28f61bbe2aa0000000000000000000000000000000000000000000000000000000000000028","to":"0x0000000000000000000000000000000000000000","value":"0x0"},"blockHash":null,"blockNumber":0,"result":{"gasUsed":"0x0","output":"0x"},"subtraces":0,"traceAddress":[1],"transactionHash":null,"transactionPosition":0,"type":"call"}]`,
+		},
+	}
+	for _, tc := range testSuite {
+		t.Run(tc.tracer, func(t *testing.T) {
+			triedb, _, statedb := tests.MakePreState(rawdb.NewMemoryDatabase(), alloc, false, rawdb.HashScheme)
+			defer triedb.Close()
+
+			var config json.RawMessage
+			if tc.config != "" {
+				config = json.RawMessage(tc.config)
+			}
+			tracer, err := tracers.DefaultDirectory.New(tc.tracer, new(tracers.Context), config)
+			if err != nil {
+				t.Fatalf("failed to create tracer: %v", err)
+			}
+			atomicTracer, ok := tracer.(tracers.AtomicTxTracer)
+			if !ok {
+				t.Fatalf("tracer %s does not trace atomic transactions", tc.tracer)
+			}
+			evm := vm.NewEVM(vm.BlockContext{BlockNumber: common.Big1}, vm.TxContext{GasPrice: common.Big0}, statedb, params.TestChainConfig, vm.Config{Tracer: tracer})
+			atomicTracer.CaptureAtomicTxStart(evm, transfers)
+			statedb.SubBalance(sender, transfers[0].Amount)
+			statedb.SubBalanceMultiCoin(sender, assetID, transfers[1].Amount)
+			statedb.SetNonce(sender, 1)
+			atomicTracer.CaptureAtomicTxEnd()
+
+			res, err := tracer.GetResult()
+			if err != nil {
+				t.Fatalf("failed to retrieve trace result: %v", err)
+			}
+			if string(res) != tc.want {
+				t.Fatalf("trace mismatch\n have: %s\n want: %s", res, tc.want)
+			}
+		})
+	}
+}
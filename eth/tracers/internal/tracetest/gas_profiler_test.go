@@ -0,0 +1,187 @@
+// (c) 2024, Ava Labs, Inc. All rights reserved.
+// See the file LICENSE for licensing terms.
+
+package tracetest
+
+import (
+	"encoding/json"
+	"math/big"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/ava-labs/coreth/core"
+	"github.com/ava-labs/coreth/core/rawdb"
+	"github.com/ava-labs/coreth/core/types"
+	"github.com/ava-labs/coreth/core/vm"
+	"github.com/ava-labs/coreth/eth/tracers"
+	"github.com/ava-labs/coreth/tests"
+	"github.com/ethereum/go-ethereum/common"
+)
+
+type gasProfile struct {
+	GasUsed      uint64 `json:"gasUsed"`
+	IntrinsicGas uint64 `json:"intrinsicGas"`
+	Refund       uint64 `json:"refund"`
+	Entries      []struct {
+		Address  common.Address `json:"address"`
+		Selector string         `json:"selector"`
+		Op       string         `json:"op"`
+		Gas      uint64         `json:"gas"`
+		Count    uint64         `json:"count"`
+	} `json:"entries"`
+	Collapsed string `json:"collapsed"`
+}
+
+// readGasProfilerTests reads the gas profiler test cases from disk.
+func readGasProfilerTests(t *testing.T) map[string]*testcase {
+	dir := filepath.Join("testdata", "gas_profiler")
+	files, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatalf("failed to retrieve tracer test suite: %v", err)
+	}
+	tests := make(map[string]*testcase)
+	for _, file := range files {
+		if !strings.HasSuffix(file.Name(), ".json") {
+			continue
+		}
+		test := new(testcase)
+		if blob, err := os.ReadFile(filepath.Join(dir, file.Name())); err != nil {
+			t.Fatalf("failed to read testcase: %v", err)
+		} else if err := json.Unmarshal(blob, test); err != nil {
+			t.Fatalf("failed to parse testcase: %v", err)
+		}
+		tests[camel(strings.TrimSuffix(file.Name(), ".json"))] = test
+	}
+	return tests
+}
+
+// runGasProfiler executes the transaction of [test] on its prestate and
+// returns the gas profile of the transaction.
+func runGasProfiler(t *testing.T, test *testcase) json.RawMessage {
+	tx := new(types.Transaction)
+	if err := tx.UnmarshalBinary(common.FromHex(test.Input)); err != nil {
+		t.Fatalf("failed to parse testcase input: %v", err)
+	}
+	var (
+		signer    = types.MakeSigner(test.Genesis.Config, new(big.Int).SetUint64(uint64(test.Context.Number)), uint64(test.Context.Time))
+		origin, _ = signer.Sender(tx)
+		txContext = vm.TxContext{
+			Origin:   origin,
+			GasPrice: tx.GasPrice(),
+		}
+		context = vm.BlockContext{
+			CanTransfer:       core.CanTransfer,
+			CanTransferMC:     core.CanTransferMC,
+			Transfer:          core.Transfer,
+			TransferMultiCoin: core.TransferMultiCoin,
+			Coinbase:          test.Context.Miner,
+			BlockNumber:       new(big.Int).SetUint64(uint64(test.Context.Number)),
+			Time:              uint64(test.Context.Time),
+			Difficulty:        (*big.Int)(test.Context.Difficulty),
+			GasLimit:          uint64(test.Context.GasLimit),
+			BaseFee:           test.Genesis.BaseFee,
+		}
+		triedb, _, statedb = tests.MakePreState(rawdb.NewMemoryDatabase(), test.Genesis.Alloc, false, rawdb.HashScheme)
+	)
+	defer triedb.Close()
+
+	tracer, err := tracers.DefaultDirectory.New("gasProfiler", new(tracers.Context), test.TracerConfig)
+	if err != nil {
+		t.Fatalf("failed to create gas profiler: %v", err)
+	}
+	evm := vm.NewEVM(context, txContext, statedb, test.Genesis.Config, vm.Config{Tracer: tracer})
+	msg, err := core.TransactionToMessage(tx, signer, nil)
+	if err != nil {
+		t.Fatalf("failed to prepare transaction for tracing: %v", err)
+	}
+	st := core.NewStateTransition(evm, msg, new(core.GasPool).AddGas(tx.Gas()))
+	if _, err = st.TransitionDb(); err != nil {
+		t.Fatalf("failed to execute transaction: %v", err)
+	}
+	res, err := tracer.GetResult()
+	if err != nil {
+		t.Fatalf("failed to retrieve trace result: %v", err)
+	}
+	return res
+}
+
+func TestGasProfiler(t *testing.T) {
+	for name, test := range readGasProfilerTests(t) {
+		test := test // capture range variable
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			// The expected result has its fields sorted, so re-encode the
+			// profile through a generic value before comparing.
+			var x interface{}
+			if err := json.Unmarshal(runGasProfiler(t, test), &x); err != nil {
+				t.Fatalf("failed to decode trace result: %v", err)
+			}
+			have, _ := json.Marshal(x)
+			want, err := json.Marshal(test.Result)
+			if err != nil {
+				t.Fatalf("failed to marshal test: %v", err)
+			}
+			if string(want) != string(have) {
+				t.Fatalf("trace mismatch\n have: %v\n want: %v\n", string(have), string(want))
+			}
+		})
+	}
+}
+
+func TestGasProfilerAggregate(t *testing.T) {
+	for name, test := range readGasProfilerTests(t) {
+		test := test // capture range variable
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			res := runGasProfiler(t, test)
+			// A block executing the transaction twice spends twice the gas of
+			// every row of the profile.
+			aggregate, err := tracers.DefaultDirectory.Aggregate("gasProfiler", []json.RawMessage{res, res})
+			if err != nil {
+				t.Fatalf("failed to aggregate gas profiles: %v", err)
+			}
+			var tx, block gasProfile
+			if err := json.Unmarshal(res, &tx); err != nil {
+				t.Fatalf("failed to decode gas profile: %v", err)
+			}
+			if err := json.Unmarshal(aggregate, &block); err != nil {
+				t.Fatalf("failed to decode aggregated gas profile: %v", err)
+			}
+			if block.GasUsed != 2*tx.GasUsed || block.IntrinsicGas != 2*tx.IntrinsicGas || block.Refund != 2*tx.Refund {
+				t.Fatalf("aggregated totals mismatch: have %d/%d/%d, want %d/%d/%d", block.GasUsed, block.IntrinsicGas, block.Refund, 2*tx.GasUsed, 2*tx.IntrinsicGas, 2*tx.Refund)
+			}
+			if len(block.Entries) != len(tx.Entries) {
+				t.Fatalf("aggregated entries mismatch: have %d, want %d", len(block.Entries), len(tx.Entries))
+			}
+			for i, entry := range tx.Entries {
+				merged := block.Entries[i]
+				if merged.Address != entry.Address || merged.Selector != entry.Selector || merged.Op != entry.Op {
+					t.Fatalf("aggregated entry %d mismatch: have %v, want %v", i, merged, entry)
+				}
+				if merged.Gas != 2*entry.Gas || merged.Count != 2*entry.Count {
+					t.Fatalf("aggregated entry %d mismatch: have gas %d count %d, want gas %d count %d", i, merged.Gas, merged.Count, 2*entry.Gas, 2*entry.Count)
+				}
+			}
+			if tx.Collapsed == "" {
+				if block.Collapsed != "" {
+					t.Fatalf("unexpected collapsed stacks in aggregated profile: %q", block.Collapsed)
+				}
+				return
+			}
+			var stacks []string
+			for _, line := range strings.Split(strings.TrimSuffix(tx.Collapsed, "\n"), "\n") {
+				sep := strings.LastIndexByte(line, ' ')
+				gas := new(big.Int)
+				gas.SetString(line[sep+1:], 10)
+				stacks = append(stacks, line[:sep]+" "+gas.Lsh(gas, 1).String())
+			}
+			if want := strings.Join(stacks, "\n") + "\n"; block.Collapsed != want {
+				t.Fatalf("aggregated collapsed stacks mismatch\n have: %q\n want: %q", block.Collapsed, want)
+			}
+		})
+	}
+}
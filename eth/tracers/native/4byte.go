@@ -32,6 +32,7 @@ import (
 	"strconv"
 	"sync/atomic"
 
+	"github.com/ava-labs/coreth/consensus/dummy"
 	"github.com/ava-labs/coreth/core/vm"
 	"github.com/ava-labs/coreth/eth/tracers"
 	"github.com/ethereum/go-ethereum/common"
@@ -121,6 +122,13 @@ func (t *fourByteTracer) CaptureEnter(op vm.OpCode, from common.Address, to comm
 	t.store(input[0:4], len(input)-4)
 }
 
+// CaptureAtomicTxStart implements the AtomicTxTracer interface. Atomic
+// transactions call no functions, so they add no selectors.
+func (t *fourByteTracer) CaptureAtomicTxStart(env *vm.EVM, transfers []dummy.ExtraStateTransfer) {}
+
+// CaptureAtomicTxEnd implements the AtomicTxTracer interface.
+func (t *fourByteTracer) CaptureAtomicTxEnd() {}
+
 // GetResult returns the json-encoded nested list of call traces, and any
 // error arising from the encoding or forceful termination (via `Stop`).
 func (t *fourByteTracer) GetResult() (json.RawMessage, error) {
@@ -33,6 +33,7 @@ import (
 	"sync/atomic"
 
 	"github.com/ava-labs/coreth/accounts/abi"
+	"github.com/ava-labs/coreth/consensus/dummy"
 	"github.com/ava-labs/coreth/core/vm"
 	"github.com/ava-labs/coreth/eth/tracers"
 	"github.com/ava-labs/coreth/vmerrs"
@@ -254,6 +255,47 @@ func (t *callTracer) CaptureExit(output []byte, gasUsed uint64, err error) {
 	t.callstack[size-1].Calls = append(t.callstack[size-1].Calls, call)
 }
 
+// CaptureAtomicTxStart implements the AtomicTxTracer interface to trace an
+// atomic transaction as a call from the zero address, standing for the shared
+// memory, with a subcall for every credit and debit. Credits are calls from the
+// zero address to the credited account and debits are calls from the debited
+// account to the zero address. AVAX amounts are the value of the subcall, while
+// multicoin subcalls take the asset ID followed by the amount as input.
+func (t *callTracer) CaptureAtomicTxStart(env *vm.EVM, transfers []dummy.ExtraStateTransfer) {
+	t.callstack[0] = callFrame{
+		Type:  vm.CALL,
+		To:    &common.Address{},
+		Input: []byte{},
+		Value: new(big.Int),
+	}
+	if t.config.OnlyTopCall {
+		return
+	}
+	for _, transfer := range transfers {
+		call := callFrame{
+			Type:  vm.CALL,
+			Input: []byte{},
+			Value: new(big.Int),
+		}
+		address := transfer.Address
+		if transfer.Debit {
+			call.From, call.To = address, &common.Address{}
+		} else {
+			call.To = &address
+		}
+		if transfer.AssetID == (common.Hash{}) {
+			call.Value = new(big.Int).Set(transfer.Amount)
+		} else {
+			call.Input = append(transfer.AssetID.Bytes(), common.BigToHash(transfer.Amount).Bytes()...)
+		}
+		t.callstack[0].Calls = append(t.callstack[0].Calls, call)
+	}
+}
+
+// CaptureAtomicTxEnd implements the AtomicTxTracer interface to finalize the
+// tracing of an atomic transaction. Atomic transactions use no gas.
+func (t *callTracer) CaptureAtomicTxEnd() {}
+
 func (t *callTracer) CaptureTxStart(gasLimit uint64) {
 	t.gasLimit = gasLimit
 }
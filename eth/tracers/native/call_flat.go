@@ -33,6 +33,7 @@ import (
 	"math/big"
 	"strings"
 
+	"github.com/ava-labs/coreth/consensus/dummy"
 	"github.com/ava-labs/coreth/core/vm"
 	"github.com/ava-labs/coreth/eth/tracers"
 	"github.com/ava-labs/coreth/vmerrs"
@@ -212,6 +213,18 @@ func (t *flatCallTracer) CaptureExit(output []byte, gasUsed uint64, err error) {
 	}
 }
 
+// CaptureAtomicTxStart implements the AtomicTxTracer interface to trace an
+// atomic transaction, reported in the same way as by the call tracer.
+func (t *flatCallTracer) CaptureAtomicTxStart(env *vm.EVM, transfers []dummy.ExtraStateTransfer) {
+	t.tracer.CaptureAtomicTxStart(env, transfers)
+}
+
+// CaptureAtomicTxEnd implements the AtomicTxTracer interface to finalize the
+// tracing of an atomic transaction.
+func (t *flatCallTracer) CaptureAtomicTxEnd() {
+	t.tracer.CaptureAtomicTxEnd()
+}
+
 func (t *flatCallTracer) CaptureTxStart(gasLimit uint64) {
 	t.tracer.CaptureTxStart(gasLimit)
 }
@@ -0,0 +1,374 @@
+// (c) 2024, Ava Labs, Inc. All rights reserved.
+// See the file LICENSE for licensing terms.
+
+package native
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"sort"
+	"strconv"
+	"strings"
+	"sync/atomic"
+
+	"github.com/ava-labs/coreth/consensus/dummy"
+	"github.com/ava-labs/coreth/core/vm"
+	"github.com/ava-labs/coreth/eth/tracers"
+	"github.com/ethereum/go-ethereum/common"
+)
+
+func init() {
+	tracers.DefaultDirectory.Register("gasProfiler", newGasProfiler, false)
+	tracers.DefaultDirectory.RegisterAggregator("gasProfiler", aggregateGasProfiles)
+}
+
+// Pseudo opcodes of the gas profile for gas not spent on executing opcodes.
+const (
+	profileIntrinsic  = "INTRINSIC"  // Intrinsic gas of the transaction
+	profilePrecompile = "PRECOMPILE" // Gas spent inside a (stateful) precompile
+)
+
+// Pseudo selectors of the gas profile for calls without a function selector.
+const (
+	profileConstructor = "constructor"
+	profileFallback    = "fallback"
+)
+
+// profileKey identifies a row of the gas profile.
+type profileKey struct {
+	address  common.Address
+	selector string
+	op       string
+}
+
+// profileEntry is a row of the gas profile, holding the gas spent on an
+// opcode executed by a contract function and the number of executions.
+type profileEntry struct {
+	Address  common.Address `json:"address"`
+	Selector string         `json:"selector"`
+	Op       string         `json:"op"`
+	Gas      uint64         `json:"gas"`
+	Count    uint64         `json:"count"`
+}
+
+// profileFrame is a call frame being profiled. The gas spent on an opcode is
+// only known once the next opcode of the frame executes, or the frame exits,
+// so the last executed opcode is kept pending until then.
+type profileFrame struct {
+	address  common.Address
+	selector string
+	stack    string // Collapsed call stack leading to the frame
+	gas      uint64 // Gas available on entering the frame
+	childGas uint64 // Gas used by the calls of the pending opcode
+
+	pendingOp  string
+	pendingGas uint64 // Gas available before executing the pending opcode
+}
+
+type gasProfilerConfig struct {
+	Collapsed bool `json:"collapsed"` // If true, the profile also includes collapsed stacks
+}
+
+type gasProfilerResult struct {
+	GasUsed      uint64         `json:"gasUsed"`
+	IntrinsicGas uint64         `json:"intrinsicGas"`
+	Refund       uint64         `json:"refund"`
+	Entries      []profileEntry `json:"entries"`
+	Collapsed    string         `json:"collapsed,omitempty"`
+}
+
+// gasProfiler aggregates the gas spent by a transaction by contract address,
+// function selector and opcode. Gas spent in calls is attributed to the
+// opcodes of the callee rather than to the calling opcode, and gas spent
+// inside precompiles, including the native asset call and warp precompiles,
+// to the PRECOMPILE pseudo opcode of the precompile.
+//
+// Optionally, the profile also includes the gas spent by call stack in the
+// collapsed stack format consumed by flamegraph tools, with each frame of the
+// stack labeled as address:selector.
+//
+// Example:
+//
+//	> debug.traceTransaction("0x...", {tracer: "gasProfiler", tracerConfig: {collapsed: true}})
+//	{
+//	  gasUsed: 43470,
+//	  intrinsicGas: 21432,
+//	  refund: 0,
+//	  entries: [{address: "0x...", selector: "0xa9059cbb", op: "SSTORE", gas: 20000, count: 1}, ...],
+//	  collapsed: "INTRINSIC 21432\n0x...:0xa9059cbb;SSTORE 20000\n..."
+//	}
+type gasProfiler struct {
+	noopTracer
+	config    gasProfilerConfig
+	gasLimit  uint64
+	result    gasProfilerResult
+	executed  uint64 // Gas spent executing the transaction, before refunds
+	entries   map[profileKey]*profileEntry
+	stacks    map[string]uint64
+	callstack []*profileFrame
+	interrupt atomic.Bool // Atomic flag to signal execution interruption
+	reason    error       // Textual reason for the interruption
+}
+
+// newGasProfiler returns a native go tracer which profiles the gas spent by a
+// transaction, and implements vm.EVMLogger.
+func newGasProfiler(ctx *tracers.Context, cfg json.RawMessage) (tracers.Tracer, error) {
+	var config gasProfilerConfig
+	if cfg != nil {
+		if err := json.Unmarshal(cfg, &config); err != nil {
+			return nil, err
+		}
+	}
+	return &gasProfiler{
+		config:  config,
+		entries: make(map[profileKey]*profileEntry),
+		stacks:  make(map[string]uint64),
+	}, nil
+}
+
+// CaptureTxStart implements the EVMLogger interface to initialize the tracing operation.
+func (t *gasProfiler) CaptureTxStart(gasLimit uint64) {
+	t.gasLimit = gasLimit
+}
+
+// CaptureStart implements the EVMLogger interface to initialize the tracing operation.
+func (t *gasProfiler) CaptureStart(env *vm.EVM, from common.Address, to common.Address, create bool, input []byte, gas uint64, value *big.Int) {
+	t.result.IntrinsicGas = t.gasLimit - gas
+	if t.result.IntrinsicGas > 0 {
+		t.stacks[profileIntrinsic] += t.result.IntrinsicGas
+	}
+	t.enter(to, profileSelector(to, create, input), gas)
+}
+
+// CaptureEnd is called after the call finishes to finalize the tracing.
+func (t *gasProfiler) CaptureEnd(output []byte, gasUsed uint64, err error) {
+	t.exit(gasUsed)
+}
+
+// CaptureState implements the EVMLogger interface to trace a single step of VM execution.
+func (t *gasProfiler) CaptureState(pc uint64, op vm.OpCode, gas, cost uint64, scope *vm.ScopeContext, rData []byte, depth int, err error) {
+	// Skip if tracing was interrupted
+	if t.interrupt.Load() || len(t.callstack) == 0 {
+		return
+	}
+	frame := t.callstack[len(t.callstack)-1]
+	t.settle(frame, gas)
+	frame.pendingOp, frame.pendingGas = op.String(), gas
+}
+
+// CaptureEnter is called when EVM enters a new scope (via call, create or selfdestruct).
+func (t *gasProfiler) CaptureEnter(typ vm.OpCode, from common.Address, to common.Address, input []byte, gas uint64, value *big.Int) {
+	if t.interrupt.Load() {
+		return
+	}
+	create := typ == vm.CREATE || typ == vm.CREATE2
+	t.enter(to, profileSelector(to, create, input), gas)
+}
+
+// CaptureExit is called when EVM exits a scope, even if the scope didn't
+// execute any code.
+func (t *gasProfiler) CaptureExit(output []byte, gasUsed uint64, err error) {
+	if t.interrupt.Load() {
+		return
+	}
+	t.exit(gasUsed)
+}
+
+// CaptureTxEnd implements the EVMLogger interface to finalize the tracing operation.
+func (t *gasProfiler) CaptureTxEnd(restGas uint64) {
+	t.result.GasUsed = t.gasLimit - restGas
+	if spent := t.result.IntrinsicGas + t.executed; spent > t.result.GasUsed {
+		t.result.Refund = spent - t.result.GasUsed
+	}
+}
+
+// CaptureAtomicTxStart implements the AtomicTxTracer interface. Atomic
+// transactions execute no code, so their gas profile is empty.
+func (t *gasProfiler) CaptureAtomicTxStart(env *vm.EVM, transfers []dummy.ExtraStateTransfer) {}
+
+// CaptureAtomicTxEnd implements the AtomicTxTracer interface.
+func (t *gasProfiler) CaptureAtomicTxEnd() {}
+
+// enter pushes a call frame of the contract at [address] onto the call stack.
+func (t *gasProfiler) enter(address common.Address, selector string, gas uint64) {
+	stack := bytesToHex(address[:]) + ":" + selector
+	if len(t.callstack) > 0 {
+		stack = t.callstack[len(t.callstack)-1].stack + ";" + stack
+	}
+	t.callstack = append(t.callstack, &profileFrame{
+		address:  address,
+		selector: selector,
+		stack:    stack,
+		gas:      gas,
+	})
+}
+
+// exit pops the top call frame off the call stack, attributing the gas it
+// used to its last opcode, or to the precompile if it did not execute any.
+func (t *gasProfiler) exit(gasUsed uint64) {
+	if len(t.callstack) == 0 {
+		return
+	}
+	frame := t.callstack[len(t.callstack)-1]
+	t.callstack = t.callstack[:len(t.callstack)-1]
+
+	if frame.pendingOp != "" {
+		t.settle(frame, frame.gas-gasUsed)
+	} else if gasUsed > frame.childGas {
+		// Only precompiles use gas without executing opcodes. Stateful
+		// precompiles may also call into other contracts themselves.
+		t.record(frame, profilePrecompile, gasUsed-frame.childGas)
+	}
+	if len(t.callstack) > 0 {
+		t.callstack[len(t.callstack)-1].childGas += gasUsed
+	} else {
+		t.executed = gasUsed
+	}
+}
+
+// settle attributes the gas spent by the pending opcode of [frame], given the
+// gas [remaining] after its execution, excluding the gas used by its calls.
+func (t *gasProfiler) settle(frame *profileFrame, remaining uint64) {
+	if frame.pendingOp == "" {
+		return
+	}
+	var gas uint64
+	if spent := frame.pendingGas - remaining; spent > frame.childGas {
+		gas = spent - frame.childGas
+	}
+	t.record(frame, frame.pendingOp, gas)
+	frame.pendingOp, frame.childGas = "", 0
+}
+
+// record adds an execution of [op] using [gas] by [frame] to the profile.
+func (t *gasProfiler) record(frame *profileFrame, op string, gas uint64) {
+	key := profileKey{address: frame.address, selector: frame.selector, op: op}
+	entry, ok := t.entries[key]
+	if !ok {
+		entry = &profileEntry{Address: frame.address, Selector: frame.selector, Op: op}
+		t.entries[key] = entry
+	}
+	entry.Gas += gas
+	entry.Count++
+	if gas > 0 {
+		t.stacks[frame.stack+";"+op] += gas
+	}
+}
+
+// GetResult returns the gas profile, with the rows sorted by descending gas,
+// and any error arising from the encoding or forceful termination (via `Stop`).
+func (t *gasProfiler) GetResult() (json.RawMessage, error) {
+	res, err := marshalGasProfile(t.result, t.entries, t.stacks, t.config.Collapsed)
+	if err != nil {
+		return nil, err
+	}
+	return res, t.reason
+}
+
+// marshalGasProfile encodes [result] with the rows in [entries] sorted by
+// descending gas, and if [collapsed] is set, the collapsed [stacks].
+func marshalGasProfile(result gasProfilerResult, entries map[profileKey]*profileEntry, stacks map[string]uint64, collapsed bool) (json.RawMessage, error) {
+	result.Entries = make([]profileEntry, 0, len(entries))
+	for _, entry := range entries {
+		result.Entries = append(result.Entries, *entry)
+	}
+	sort.Slice(result.Entries, func(i, j int) bool {
+		a, b := result.Entries[i], result.Entries[j]
+		if a.Gas != b.Gas {
+			return a.Gas > b.Gas
+		}
+		if c := bytes.Compare(a.Address[:], b.Address[:]); c != 0 {
+			return c < 0
+		}
+		if a.Selector != b.Selector {
+			return a.Selector < b.Selector
+		}
+		return a.Op < b.Op
+	})
+	result.Collapsed = ""
+	if collapsed {
+		names := make([]string, 0, len(stacks))
+		for stack := range stacks {
+			names = append(names, stack)
+		}
+		sort.Strings(names)
+		var buf bytes.Buffer
+		for _, stack := range names {
+			fmt.Fprintf(&buf, "%s %d\n", stack, stacks[stack])
+		}
+		result.Collapsed = buf.String()
+	}
+	res, err := json.Marshal(result)
+	if err != nil {
+		return nil, err
+	}
+	return json.RawMessage(res), nil
+}
+
+// aggregateGasProfiles merges the gas profiles of the transactions of a block
+// into the gas profile of the block. The collapsed stacks are only included
+// if all the profiles include them.
+func aggregateGasProfiles(results []json.RawMessage) (json.RawMessage, error) {
+	var (
+		block     gasProfilerResult
+		entries   = make(map[profileKey]*profileEntry)
+		stacks    = make(map[string]uint64)
+		collapsed = len(results) > 0
+	)
+	for i, raw := range results {
+		var result gasProfilerResult
+		if err := json.Unmarshal(raw, &result); err != nil {
+			return nil, fmt.Errorf("failed to decode gas profile %d: %w", i, err)
+		}
+		block.GasUsed += result.GasUsed
+		block.IntrinsicGas += result.IntrinsicGas
+		block.Refund += result.Refund
+		for _, entry := range result.Entries {
+			key := profileKey{entry.Address, entry.Selector, entry.Op}
+			if merged, ok := entries[key]; ok {
+				merged.Gas += entry.Gas
+				merged.Count += entry.Count
+				continue
+			}
+			entry := entry
+			entries[key] = &entry
+		}
+		if result.Collapsed == "" {
+			collapsed = false
+			continue
+		}
+		for _, line := range strings.Split(strings.TrimSuffix(result.Collapsed, "\n"), "\n") {
+			sep := strings.LastIndexByte(line, ' ')
+			if sep < 0 {
+				return nil, fmt.Errorf("invalid collapsed stack %q in gas profile %d", line, i)
+			}
+			gas, err := strconv.ParseUint(line[sep+1:], 10, 64)
+			if err != nil {
+				return nil, fmt.Errorf("invalid collapsed stack %q in gas profile %d: %w", line, i, err)
+			}
+			stacks[line[:sep]] += gas
+		}
+	}
+	return marshalGasProfile(block, entries, stacks, collapsed)
+}
+
+// Stop terminates execution of the tracer at the first opportune moment.
+func (t *gasProfiler) Stop(err error) {
+	t.reason = err
+	t.interrupt.Store(true)
+}
+
+// profileSelector returns the function selector called on [to] with [input].
+// The native asset call precompile takes packed arguments without a selector.
+func profileSelector(to common.Address, create bool, input []byte) string {
+	switch {
+	case create:
+		return profileConstructor
+	case len(input) < 4 || to == vm.NativeAssetCallAddr:
+		return profileFallback
+	default:
+		return bytesToHex(input[:4])
+	}
+}
@@ -30,6 +30,7 @@ import (
 	"encoding/json"
 	"math/big"
 
+	"github.com/ava-labs/coreth/consensus/dummy"
 	"github.com/ava-labs/coreth/core/vm"
 	"github.com/ava-labs/coreth/eth/tracers"
 	"github.com/ethereum/go-ethereum/common"
@@ -68,6 +69,26 @@ func newMuxTracer(ctx *tracers.Context, cfg json.RawMessage) (tracers.Tracer, er
 	return &muxTracer{names: names, tracers: objects}, nil
 }
 
+// CaptureAtomicTxStart implements the AtomicTxTracer interface, forwarding the
+// atomic transaction to the tracers tracing atomic transactions.
+func (t *muxTracer) CaptureAtomicTxStart(env *vm.EVM, transfers []dummy.ExtraStateTransfer) {
+	for _, t := range t.tracers {
+		if t, ok := t.(tracers.AtomicTxTracer); ok {
+			t.CaptureAtomicTxStart(env, transfers)
+		}
+	}
+}
+
+// CaptureAtomicTxEnd implements the AtomicTxTracer interface to finalize the
+// tracing of an atomic transaction.
+func (t *muxTracer) CaptureAtomicTxEnd() {
+	for _, t := range t.tracers {
+		if t, ok := t.(tracers.AtomicTxTracer); ok {
+			t.CaptureAtomicTxEnd()
+		}
+	}
+}
+
 // CaptureStart implements the EVMLogger interface to initialize the tracing operation.
 func (t *muxTracer) CaptureStart(env *vm.EVM, from common.Address, to common.Address, create bool, input []byte, gas uint64, value *big.Int) {
 	for _, t := range t.tracers {
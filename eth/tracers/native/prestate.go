@@ -32,6 +32,7 @@ import (
 	"math/big"
 	"sync/atomic"
 
+	"github.com/ava-labs/coreth/consensus/dummy"
 	"github.com/ava-labs/coreth/core/vm"
 	"github.com/ava-labs/coreth/eth/tracers"
 	"github.com/ethereum/go-ethereum/common"
@@ -189,6 +190,21 @@ func (t *prestateTracer) CaptureState(pc uint64, op vm.OpCode, gas, cost uint64,
 	}
 }
 
+// CaptureAtomicTxStart implements the AtomicTxTracer interface to trace the
+// accounts credited and debited by an atomic transaction.
+func (t *prestateTracer) CaptureAtomicTxStart(env *vm.EVM, transfers []dummy.ExtraStateTransfer) {
+	t.env = env
+	for _, transfer := range transfers {
+		t.lookupAccount(transfer.Address)
+	}
+}
+
+// CaptureAtomicTxEnd implements the AtomicTxTracer interface to finalize the
+// tracing of an atomic transaction.
+func (t *prestateTracer) CaptureAtomicTxEnd() {
+	t.CaptureTxEnd(0)
+}
+
 func (t *prestateTracer) CaptureTxStart(gasLimit uint64) {
 	t.gasLimit = gasLimit
 }
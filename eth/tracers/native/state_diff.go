@@ -0,0 +1,467 @@
+// (c) 2024, Ava Labs, Inc. All rights reserved.
+// See the file LICENSE for licensing terms.
+
+package native
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"sync/atomic"
+
+	"github.com/ava-labs/coreth/consensus/dummy"
+	"github.com/ava-labs/coreth/core/vm"
+	"github.com/ava-labs/coreth/eth/tracers"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/log"
+)
+
+func init() {
+	tracers.DefaultDirectory.Register("stateDiffTracer", newStateDiffTracer, false)
+}
+
+// Markers of the parity-style state diff.
+const (
+	diffSame    = "="
+	diffBorn    = "+"
+	diffDied    = "-"
+	diffChanged = "*"
+)
+
+// fieldDiff is the parity-style change of a single field of an account.
+type fieldDiff struct {
+	marker   string
+	from, to interface{}
+}
+
+// MarshalJSON encodes an unchanged field as "=", a field of a created account
+// as {"+": to}, a field of a deleted account as {"-": from} and a changed
+// field as {"*": {"from": from, "to": to}}.
+func (d fieldDiff) MarshalJSON() ([]byte, error) {
+	switch d.marker {
+	case diffSame:
+		return json.Marshal(diffSame)
+	case diffBorn:
+		return json.Marshal(map[string]interface{}{diffBorn: d.to})
+	case diffDied:
+		return json.Marshal(map[string]interface{}{diffDied: d.from})
+	default:
+		return json.Marshal(map[string]interface{}{
+			diffChanged: map[string]interface{}{"from": d.from, "to": d.to},
+		})
+	}
+}
+
+// accountDiff is the parity-style change of an account, extended with the
+// changes of its multicoin balances and optionally the calls changing it.
+type accountDiff struct {
+	Balance   fieldDiff                 `json:"balance"`
+	Nonce     fieldDiff                 `json:"nonce"`
+	Code      fieldDiff                 `json:"code"`
+	Storage   map[common.Hash]fieldDiff `json:"storage"`
+	MultiCoin map[common.Hash]fieldDiff `json:"multicoin,omitempty"`
+	Calls     [][]int                   `json:"calls,omitempty"` // Trace addresses of the calls changing the account
+}
+
+// diffAccount is the state of an account prior to the traced transaction.
+type diffAccount struct {
+	exists    bool
+	balance   *big.Int
+	nonce     uint64
+	code      []byte
+	storage   map[common.Hash]common.Hash
+	multiCoin map[common.Hash]*big.Int
+}
+
+// diffCallFrame tracks the accounts changed by a call frame and its successful
+// sub-calls, discarded if the frame reverts.
+type diffCallFrame struct {
+	traceAddress []int
+	children     int
+	touched      []diffTouch
+}
+
+type diffTouch struct {
+	addr         common.Address
+	traceAddress []int
+}
+
+// stateDiffTracer reports the changes a transaction makes to the state in the
+// parity stateDiff format, including the multicoin balances of accounts.
+type stateDiffTracer struct {
+	noopTracer
+	env       *vm.EVM
+	pre       map[common.Address]*diffAccount
+	from      common.Address
+	gasLimit  uint64 // Amount of gas bought for the whole tx
+	callstack []*diffCallFrame
+	result    map[common.Address]*accountDiff
+	config    stateDiffTracerConfig
+	interrupt atomic.Bool // Atomic flag to signal execution interruption
+	reason    error       // Textual reason for the interruption
+}
+
+type stateDiffTracerConfig struct {
+	WithCalls bool `json:"withCalls"` // If true, the trace addresses of the calls changing each account are included
+}
+
+func newStateDiffTracer(ctx *tracers.Context, cfg json.RawMessage) (tracers.Tracer, error) {
+	var config stateDiffTracerConfig
+	if cfg != nil {
+		if err := json.Unmarshal(cfg, &config); err != nil {
+			return nil, err
+		}
+	}
+	return &stateDiffTracer{
+		pre:    make(map[common.Address]*diffAccount),
+		config: config,
+	}, nil
+}
+
+// CaptureStart implements the EVMLogger interface to initialize the tracing operation.
+func (t *stateDiffTracer) CaptureStart(env *vm.EVM, from common.Address, to common.Address, create bool, input []byte, gas uint64, value *big.Int) {
+	t.env = env
+	t.from = from
+
+	t.lookupAccount(from)
+	t.lookupAccount(to)
+	t.lookupAccount(env.Context.Coinbase)
+
+	// The recipient balance includes the value transferred.
+	t.pre[to].balance = new(big.Int).Sub(t.pre[to].balance, value)
+
+	// The sender balance is after reducing: value and gasLimit.
+	// We need to re-add them to get the pre-tx balance.
+	consumedGas := new(big.Int).Mul(env.TxContext.GasPrice, new(big.Int).SetUint64(t.gasLimit))
+	t.pre[from].balance = new(big.Int).Add(t.pre[from].balance, new(big.Int).Add(value, consumedGas))
+	t.pre[from].nonce--
+
+	// A created contract is already initialized, unless it was funded before
+	// the transaction it did not exist.
+	if create {
+		t.pre[to].nonce = 0
+		t.pre[to].exists = t.pre[to].balance.Sign() != 0
+	}
+	t.callstack = []*diffCallFrame{{traceAddress: []int{}}}
+	t.touch(from, to, env.Context.Coinbase)
+	if to == vm.NativeAssetCallAddr {
+		t.lookupNativeAssetCall(from, input)
+	}
+}
+
+// CaptureEnd is called after the call finishes to finalize the tracing.
+func (t *stateDiffTracer) CaptureEnd(output []byte, gasUsed uint64, err error) {
+	if err != nil && len(t.callstack) > 0 {
+		// Only the gas payment and nonce of the sender and the fee paid to the
+		// coinbase survive a failed transaction.
+		t.callstack[0].touched = nil
+		t.touch(t.from, t.env.Context.Coinbase)
+	}
+}
+
+// CaptureState implements the EVMLogger interface to trace a single step of VM execution.
+func (t *stateDiffTracer) CaptureState(pc uint64, op vm.OpCode, gas, cost uint64, scope *vm.ScopeContext, rData []byte, depth int, err error) {
+	if err != nil {
+		return
+	}
+	// Skip if tracing was interrupted
+	if t.interrupt.Load() {
+		return
+	}
+	stackData := scope.Stack.Data()
+	stackLen := len(stackData)
+	caller := scope.Contract.Address()
+	switch {
+	case stackLen >= 1 && op == vm.SSTORE:
+		t.lookupStorage(caller, common.Hash(stackData[stackLen-1].Bytes32()))
+		t.touch(caller)
+	case stackLen >= 1 && (op == vm.BALANCE || op == vm.SELFDESTRUCT):
+		t.lookupAccount(common.Address(stackData[stackLen-1].Bytes20()))
+	case stackLen >= 2 && op == vm.BALANCEMC:
+		t.lookupMultiCoin(common.Address(stackData[stackLen-1].Bytes20()), common.Hash(stackData[stackLen-2].Bytes32()))
+	case stackLen >= 5 && op == vm.CALLEX:
+		addr, coinID := common.Address(stackData[stackLen-2].Bytes20()), common.Hash(stackData[stackLen-4].Bytes32())
+		t.lookupAccount(addr)
+		t.lookupMultiCoin(caller, coinID)
+		t.lookupMultiCoin(addr, coinID)
+	case stackLen >= 5 && (op == vm.DELEGATECALL || op == vm.CALL || op == vm.STATICCALL || op == vm.CALLCODE):
+		t.lookupAccount(common.Address(stackData[stackLen-2].Bytes20()))
+	case op == vm.CREATE:
+		t.lookupAccount(crypto.CreateAddress(caller, t.env.StateDB.GetNonce(caller)))
+	case stackLen >= 4 && op == vm.CREATE2:
+		offset := stackData[stackLen-2]
+		size := stackData[stackLen-3]
+		init, err := tracers.GetMemoryCopyPadded(scope.Memory, int64(offset.Uint64()), int64(size.Uint64()))
+		if err != nil {
+			log.Warn("failed to copy CREATE2 input", "err", err, "tracer", "stateDiffTracer", "offset", offset, "size", size)
+			return
+		}
+		salt := stackData[stackLen-4]
+		t.lookupAccount(crypto.CreateAddress2(caller, salt.Bytes32(), crypto.Keccak256(init)))
+	}
+}
+
+// CaptureEnter is called when EVM enters a new scope (via call, create or selfdestruct).
+func (t *stateDiffTracer) CaptureEnter(typ vm.OpCode, from common.Address, to common.Address, input []byte, gas uint64, value *big.Int) {
+	if t.interrupt.Load() || len(t.callstack) == 0 {
+		return
+	}
+	parent := t.callstack[len(t.callstack)-1]
+	traceAddress := make([]int, len(parent.traceAddress)+1)
+	copy(traceAddress, parent.traceAddress)
+	traceAddress[len(parent.traceAddress)] = parent.children
+	parent.children++
+	t.callstack = append(t.callstack, &diffCallFrame{traceAddress: traceAddress})
+
+	if (value != nil && value.Sign() != 0) || typ == vm.CREATE || typ == vm.CREATE2 || typ == vm.SELFDESTRUCT {
+		t.touch(from, to)
+	}
+	if to == vm.NativeAssetCallAddr {
+		t.lookupNativeAssetCall(from, input)
+	}
+}
+
+// CaptureExit is called when EVM exits a scope, even if the scope didn't
+// execute any code.
+func (t *stateDiffTracer) CaptureExit(output []byte, gasUsed uint64, err error) {
+	if len(t.callstack) <= 1 {
+		return
+	}
+	frame := t.callstack[len(t.callstack)-1]
+	t.callstack = t.callstack[:len(t.callstack)-1]
+	if err == nil {
+		parent := t.callstack[len(t.callstack)-1]
+		parent.touched = append(parent.touched, frame.touched...)
+	}
+}
+
+// CaptureAtomicTxStart implements the AtomicTxTracer interface to trace the
+// AVAX and multicoin balances credited and debited by an atomic transaction.
+func (t *stateDiffTracer) CaptureAtomicTxStart(env *vm.EVM, transfers []dummy.ExtraStateTransfer) {
+	t.env = env
+	t.callstack = []*diffCallFrame{{traceAddress: []int{}}}
+	for _, transfer := range transfers {
+		t.lookupAccount(transfer.Address)
+		if transfer.AssetID != (common.Hash{}) {
+			t.lookupMultiCoin(transfer.Address, transfer.AssetID)
+		}
+		t.touch(transfer.Address)
+	}
+}
+
+// CaptureAtomicTxEnd implements the AtomicTxTracer interface to finalize the
+// tracing of an atomic transaction.
+func (t *stateDiffTracer) CaptureAtomicTxEnd() {
+	t.CaptureTxEnd(0)
+}
+
+func (t *stateDiffTracer) CaptureTxStart(gasLimit uint64) {
+	t.gasLimit = gasLimit
+}
+
+func (t *stateDiffTracer) CaptureTxEnd(restGas uint64) {
+	t.result = make(map[common.Address]*accountDiff)
+	for addr, pre := range t.pre {
+		if diff := t.diffAccount(addr, pre); diff != nil {
+			t.result[addr] = diff
+		}
+	}
+	if !t.config.WithCalls || len(t.callstack) == 0 {
+		return
+	}
+	seen := make(map[string]struct{})
+	for _, touch := range t.callstack[0].touched {
+		diff, ok := t.result[touch.addr]
+		if !ok {
+			continue
+		}
+		key := fmt.Sprint(touch.addr, touch.traceAddress)
+		if _, ok := seen[key]; ok {
+			continue
+		}
+		seen[key] = struct{}{}
+		diff.Calls = append(diff.Calls, touch.traceAddress)
+	}
+}
+
+// diffAccount returns the changes made to the account at [addr] since [pre],
+// or nil if it is unchanged.
+func (t *stateDiffTracer) diffAccount(addr common.Address, pre *diffAccount) *accountDiff {
+	db := t.env.StateDB
+	var (
+		died = db.HasSelfDestructed(addr)
+		born = !pre.exists && db.Exist(addr) && (!db.Empty(addr) || t.holdsMultiCoin(addr))
+	)
+	if died && !pre.exists {
+		// Created and destroyed within the transaction
+		return nil
+	}
+	diff := &accountDiff{
+		Storage:   make(map[common.Hash]fieldDiff),
+		MultiCoin: make(map[common.Hash]fieldDiff),
+	}
+	switch {
+	case died:
+		diff.Balance = fieldDiff{marker: diffDied, from: (*hexutil.Big)(pre.balance)}
+		diff.Nonce = fieldDiff{marker: diffDied, from: hexutil.Uint64(pre.nonce)}
+		diff.Code = fieldDiff{marker: diffDied, from: hexutil.Bytes(pre.code)}
+		for key, val := range pre.storage {
+			if val != (common.Hash{}) {
+				diff.Storage[key] = fieldDiff{marker: diffDied, from: val}
+			}
+		}
+		for coinID, val := range pre.multiCoin {
+			if val.Sign() != 0 {
+				diff.MultiCoin[coinID] = fieldDiff{marker: diffDied, from: (*hexutil.Big)(val)}
+			}
+		}
+		return diff
+	case born:
+		diff.Balance = fieldDiff{marker: diffBorn, to: (*hexutil.Big)(db.GetBalance(addr))}
+		diff.Nonce = fieldDiff{marker: diffBorn, to: hexutil.Uint64(db.GetNonce(addr))}
+		diff.Code = fieldDiff{marker: diffBorn, to: hexutil.Bytes(db.GetCode(addr))}
+		for key := range pre.storage {
+			if val := db.GetState(addr, key); val != (common.Hash{}) {
+				diff.Storage[key] = fieldDiff{marker: diffBorn, to: val}
+			}
+		}
+		for coinID := range pre.multiCoin {
+			if val := db.GetBalanceMultiCoin(addr, coinID); val.Sign() != 0 {
+				diff.MultiCoin[coinID] = fieldDiff{marker: diffBorn, to: (*hexutil.Big)(val)}
+			}
+		}
+		return diff
+	case !pre.exists:
+		// Touched but never brought into existence
+		return nil
+	}
+	var (
+		modified bool
+		balance  = db.GetBalance(addr)
+		nonce    = db.GetNonce(addr)
+		code     = db.GetCode(addr)
+	)
+	diff.Balance = fieldDiff{marker: diffSame}
+	if balance.Cmp(pre.balance) != 0 {
+		modified = true
+		diff.Balance = fieldDiff{marker: diffChanged, from: (*hexutil.Big)(pre.balance), to: (*hexutil.Big)(balance)}
+	}
+	diff.Nonce = fieldDiff{marker: diffSame}
+	if nonce != pre.nonce {
+		modified = true
+		diff.Nonce = fieldDiff{marker: diffChanged, from: hexutil.Uint64(pre.nonce), to: hexutil.Uint64(nonce)}
+	}
+	diff.Code = fieldDiff{marker: diffSame}
+	if !bytes.Equal(code, pre.code) {
+		modified = true
+		diff.Code = fieldDiff{marker: diffChanged, from: hexutil.Bytes(pre.code), to: hexutil.Bytes(code)}
+	}
+	for key, val := range pre.storage {
+		if newVal := db.GetState(addr, key); newVal != val {
+			modified = true
+			diff.Storage[key] = fieldDiff{marker: diffChanged, from: val, to: newVal}
+		}
+	}
+	for coinID, val := range pre.multiCoin {
+		if newVal := db.GetBalanceMultiCoin(addr, coinID); newVal.Cmp(val) != 0 {
+			modified = true
+			diff.MultiCoin[coinID] = fieldDiff{marker: diffChanged, from: (*hexutil.Big)(val), to: (*hexutil.Big)(newVal)}
+		}
+	}
+	if !modified {
+		return nil
+	}
+	return diff
+}
+
+// holdsMultiCoin reports whether [addr] holds a balance of any of the coins
+// looked up for it.
+func (t *stateDiffTracer) holdsMultiCoin(addr common.Address) bool {
+	for coinID := range t.pre[addr].multiCoin {
+		if t.env.StateDB.GetBalanceMultiCoin(addr, coinID).Sign() != 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// GetResult returns the json-encoded state diff, and any error arising from
+// the encoding or forceful termination (via `Stop`).
+func (t *stateDiffTracer) GetResult() (json.RawMessage, error) {
+	res, err := json.Marshal(t.result)
+	if err != nil {
+		return nil, err
+	}
+	return json.RawMessage(res), t.reason
+}
+
+// Stop terminates execution of the tracer at the first opportune moment.
+func (t *stateDiffTracer) Stop(err error) {
+	t.reason = err
+	t.interrupt.Store(true)
+}
+
+// touch records that the current call frame changes [addrs].
+func (t *stateDiffTracer) touch(addrs ...common.Address) {
+	frame := t.callstack[len(t.callstack)-1]
+	for _, addr := range addrs {
+		frame.touched = append(frame.touched, diffTouch{addr: addr, traceAddress: frame.traceAddress})
+	}
+}
+
+// lookupAccount fetches details of an account and adds it to the prestate
+// if it doesn't exist there.
+func (t *stateDiffTracer) lookupAccount(addr common.Address) {
+	if _, ok := t.pre[addr]; ok {
+		return
+	}
+	db := t.env.StateDB
+	t.pre[addr] = &diffAccount{
+		exists:    db.Exist(addr) && !db.Empty(addr),
+		balance:   db.GetBalance(addr),
+		nonce:     db.GetNonce(addr),
+		code:      db.GetCode(addr),
+		storage:   make(map[common.Hash]common.Hash),
+		multiCoin: make(map[common.Hash]*big.Int),
+	}
+}
+
+// lookupStorage fetches the requested storage slot and adds it to the prestate
+// of the given contract.
+func (t *stateDiffTracer) lookupStorage(addr common.Address, key common.Hash) {
+	t.lookupAccount(addr)
+	if _, ok := t.pre[addr].storage[key]; ok {
+		return
+	}
+	t.pre[addr].storage[key] = t.env.StateDB.GetState(addr, key)
+}
+
+// lookupMultiCoin fetches the balance of [coinID] held by [addr] and adds it
+// to the prestate of the given account.
+func (t *stateDiffTracer) lookupMultiCoin(addr common.Address, coinID common.Hash) {
+	t.lookupAccount(addr)
+	if _, ok := t.pre[addr].multiCoin[coinID]; ok {
+		return
+	}
+	balance := t.env.StateDB.GetBalanceMultiCoin(addr, coinID)
+	t.pre[addr].multiCoin[coinID] = balance
+	if balance.Sign() != 0 {
+		t.pre[addr].exists = true
+	}
+}
+
+// lookupNativeAssetCall fetches the multicoin balances transferred by a call
+// from [caller] to the native asset call precompile with [input].
+func (t *stateDiffTracer) lookupNativeAssetCall(caller common.Address, input []byte) {
+	to, assetID, _, _, err := vm.UnpackNativeAssetCallInput(input)
+	if err != nil {
+		return
+	}
+	t.lookupAccount(to)
+	t.lookupMultiCoin(caller, assetID)
+	t.lookupMultiCoin(to, assetID)
+	t.touch(caller, to)
+}
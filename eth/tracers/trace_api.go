@@ -0,0 +1,325 @@
+// (c) 2024, Ava Labs, Inc. All rights reserved.
+// See the file LICENSE for licensing terms.
+
+package tracers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"github.com/ava-labs/coreth/core/rawdb"
+	"github.com/ava-labs/coreth/core/types"
+	"github.com/ava-labs/coreth/rpc"
+	"github.com/ethereum/go-ethereum/common"
+	"golang.org/x/exp/slices"
+)
+
+const (
+	// flatCallTracerName is the name of the native tracer producing the
+	// parity-style call traces served by the trace namespace.
+	flatCallTracerName = "flatCallTracer"
+
+	// maxTraceFilterBlocks is the maximum number of blocks trace_filter will
+	// execute in a single request.
+	maxTraceFilterBlocks = 1000
+)
+
+var errTraceFilterRange = errors.New("invalid block range")
+
+// flatCallFrame holds the fields of a flat call trace needed to filter it by
+// address. The frame itself is passed along to the caller unmodified.
+type flatCallFrame struct {
+	Action struct {
+		From           *common.Address `json:"from"`
+		To             *common.Address `json:"to"`
+		SelfDestructed *common.Address `json:"address"`
+		RefundAddress  *common.Address `json:"refundAddress"`
+	} `json:"action"`
+	Result *struct {
+		Address *common.Address `json:"address"`
+	} `json:"result"`
+}
+
+// addresses returns the addresses initiating and receiving the traced call.
+func (f *flatCallFrame) addresses() (from, to []common.Address) {
+	for _, addr := range []*common.Address{f.Action.From, f.Action.SelfDestructed} {
+		if addr != nil {
+			from = append(from, *addr)
+		}
+	}
+	for _, addr := range []*common.Address{f.Action.To, f.Action.RefundAddress} {
+		if addr != nil {
+			to = append(to, *addr)
+		}
+	}
+	if f.Result != nil && f.Result.Address != nil {
+		to = append(to, *f.Result.Address)
+	}
+	return from, to
+}
+
+// TraceFilterArgs are the arguments of trace_filter.
+type TraceFilterArgs struct {
+	FromBlock   *rpc.BlockNumber `json:"fromBlock"`
+	ToBlock     *rpc.BlockNumber `json:"toBlock"`
+	FromAddress []common.Address `json:"fromAddress"`
+	ToAddress   []common.Address `json:"toAddress"`
+	After       *uint64          `json:"after"` // Number of matching traces to skip
+	Count       *uint64          `json:"count"` // Maximum number of traces to return
+}
+
+// matches reports whether a call trace initiated by [from] and received by [to]
+// passes the address filters. Empty filters match any address.
+func (args *TraceFilterArgs) matches(from, to []common.Address) bool {
+	return matchesAny(args.FromAddress, from) && matchesAny(args.ToAddress, to)
+}
+
+func matchesAny(filter, addrs []common.Address) bool {
+	if len(filter) == 0 {
+		return true
+	}
+	for _, want := range filter {
+		for _, addr := range addrs {
+			if addr == want {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// TraceAPI is the collection of parity-style tracing APIs, serving flat call
+// traces of accepted blocks and transactions.
+type TraceAPI struct {
+	baseAPI
+	indexer *TraceIndexer // Optional index of call trace addresses, may be nil
+}
+
+// NewTraceAPI creates a new API definition for the parity-style tracing methods
+// of the Ethereum service. If [indexer] is non-nil, it is used to narrow down
+// the blocks trace_filter executes.
+func NewTraceAPI(backend Backend, indexer *TraceIndexer) *TraceAPI {
+	return &TraceAPI{baseAPI: baseAPI{backend: backend}, indexer: indexer}
+}
+
+// Block returns the flat call traces of all transactions in the block.
+func (api *TraceAPI) Block(ctx context.Context, number rpc.BlockNumber) ([]json.RawMessage, error) {
+	block, err := api.blockByNumber(ctx, number)
+	if err != nil {
+		return nil, err
+	}
+	return api.blockFrames(ctx, block)
+}
+
+// Transaction returns the flat call traces of the transaction.
+func (api *TraceAPI) Transaction(ctx context.Context, hash common.Hash) ([]json.RawMessage, error) {
+	result, err := (&API{api.baseAPI}).TraceTransaction(ctx, hash, flatTraceConfig())
+	if err != nil {
+		return nil, err
+	}
+	return decodeFrames(result)
+}
+
+// Filter returns the flat call traces within a range of blocks matching the
+// given from and to addresses. Blocks covered by the call trace address index
+// are only executed if they contain a matching address, all other blocks are
+// executed in full, up to [maxTraceFilterBlocks] per request.
+func (api *TraceAPI) Filter(ctx context.Context, args TraceFilterArgs) ([]json.RawMessage, error) {
+	from, to, err := api.filterRange(ctx, args)
+	if err != nil {
+		return nil, err
+	}
+	blocks := api.filterBlocks(args, from, to)
+
+	var (
+		skip    uint64
+		results = []json.RawMessage{}
+		traced  int
+	)
+	if args.After != nil {
+		skip = *args.After
+	}
+	for _, number := range blocks {
+		if args.Count != nil && uint64(len(results)) >= *args.Count {
+			break
+		}
+		if traced++; traced > maxTraceFilterBlocks {
+			return nil, fmt.Errorf("exceeded the maximum of %d executed blocks, narrow the block range or use pagination", maxTraceFilterBlocks)
+		}
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		block, err := api.blockByNumber(ctx, rpc.BlockNumber(number))
+		if err != nil {
+			return nil, err
+		}
+		frames, err := api.blockFrames(ctx, block)
+		if err != nil {
+			return nil, err
+		}
+		for _, raw := range frames {
+			var frame flatCallFrame
+			if err := json.Unmarshal(raw, &frame); err != nil {
+				return nil, err
+			}
+			if !args.matches(frame.addresses()) {
+				continue
+			}
+			if skip > 0 {
+				skip--
+				continue
+			}
+			if args.Count != nil && uint64(len(results)) >= *args.Count {
+				break
+			}
+			results = append(results, raw)
+		}
+	}
+	return results, nil
+}
+
+// filterRange resolves the block range of a trace_filter request, defaulting
+// to the last accepted block.
+func (api *TraceAPI) filterRange(ctx context.Context, args TraceFilterArgs) (uint64, uint64, error) {
+	resolve := func(number *rpc.BlockNumber) (uint64, error) {
+		if number == nil {
+			latest := rpc.LatestBlockNumber
+			number = &latest
+		}
+		header, err := api.backend.HeaderByNumber(ctx, *number)
+		if err != nil {
+			return 0, err
+		}
+		if header == nil {
+			return 0, fmt.Errorf("block #%d not found", *number)
+		}
+		return header.Number.Uint64(), nil
+	}
+	from, err := resolve(args.FromBlock)
+	if err != nil {
+		return 0, 0, err
+	}
+	to, err := resolve(args.ToBlock)
+	if err != nil {
+		return 0, 0, err
+	}
+	if from > to {
+		return 0, 0, fmt.Errorf("%w: from block %d after to block %d", errTraceFilterRange, from, to)
+	}
+	// The genesis block has no transactions to trace
+	if from == 0 {
+		from = 1
+	}
+	return from, to, nil
+}
+
+// filterBlocks returns the blocks within [from, to] that may contain call
+// traces matching [args], in ascending order. At most one block more than
+// [maxTraceFilterBlocks] is returned, so that exceeding it can be detected.
+func (api *TraceAPI) filterBlocks(args TraceFilterArgs, from, to uint64) []uint64 {
+	var (
+		blocks      []uint64
+		first, next uint64
+		appendRange = func(from, to uint64) {
+			for number := from; number <= to && len(blocks) <= maxTraceFilterBlocks; number++ {
+				blocks = append(blocks, number)
+			}
+		}
+	)
+	if api.indexer != nil && (len(args.FromAddress) > 0 || len(args.ToAddress) > 0) {
+		first, next = api.indexer.Range()
+	}
+	if first >= next || first > to || next <= from {
+		appendRange(from, to)
+		return blocks
+	}
+	// Execute the blocks outside the indexed range in full and look up the
+	// candidates within it in the index.
+	if from < first {
+		appendRange(from, first-1)
+	}
+	blocks = append(blocks, api.indexer.candidates(args, max(from, first), min(to, next-1))...)
+	if next <= to {
+		appendRange(next, to)
+	}
+	return blocks
+}
+
+// blockFrames traces [block] with the flat call tracer and returns the call
+// traces of all of its transactions.
+func (api *baseAPI) blockFrames(ctx context.Context, block *types.Block) ([]json.RawMessage, error) {
+	if block.NumberU64() == 0 {
+		return []json.RawMessage{}, nil
+	}
+	results, err := api.traceBlock(ctx, block, flatTraceConfig())
+	if err != nil {
+		return nil, err
+	}
+	frames := []json.RawMessage{}
+	for _, result := range results {
+		if result.Error != "" {
+			return nil, fmt.Errorf("failed to trace transaction %s: %s", result.TxHash.Hex(), result.Error)
+		}
+		txFrames, err := decodeFrames(result.Result)
+		if err != nil {
+			return nil, err
+		}
+		frames = append(frames, txFrames...)
+	}
+	return frames, nil
+}
+
+// decodeFrames splits the result of the flat call tracer into its frames.
+func decodeFrames(result interface{}) ([]json.RawMessage, error) {
+	raw, ok := result.(json.RawMessage)
+	if !ok {
+		return nil, fmt.Errorf("unexpected trace result type %T", result)
+	}
+	var frames []json.RawMessage
+	if err := json.Unmarshal(raw, &frames); err != nil {
+		return nil, err
+	}
+	return frames, nil
+}
+
+// flatTraceConfig returns the trace configuration running the flat call tracer.
+func flatTraceConfig() *TraceConfig {
+	tracer := flatCallTracerName
+	return &TraceConfig{Tracer: &tracer}
+}
+
+// candidates returns the blocks within [from, to] whose call traces contain
+// addresses matching the filters of [args], in ascending order.
+func (t *TraceIndexer) candidates(args TraceFilterArgs, from, to uint64) []uint64 {
+	lookup := func(addrs []common.Address, flags rawdb.TraceAddressFlags) map[uint64]struct{} {
+		if len(addrs) == 0 {
+			return nil
+		}
+		set := make(map[uint64]struct{})
+		for _, addr := range addrs {
+			for _, number := range rawdb.ReadTraceAddressBlocks(t.db, addr, flags, from, to) {
+				set[number] = struct{}{}
+			}
+		}
+		return set
+	}
+	var (
+		fromSet = lookup(args.FromAddress, rawdb.TraceAddressFrom)
+		toSet   = lookup(args.ToAddress, rawdb.TraceAddressTo)
+		blocks  []uint64
+	)
+	for number := range fromSet {
+		if _, ok := toSet[number]; ok || toSet == nil {
+			blocks = append(blocks, number)
+		}
+	}
+	if fromSet == nil {
+		for number := range toSet {
+			blocks = append(blocks, number)
+		}
+	}
+	slices.Sort(blocks)
+	return blocks
+}
@@ -0,0 +1,291 @@
+// (c) 2024, Ava Labs, Inc. All rights reserved.
+// See the file LICENSE for licensing terms.
+
+package tracers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"math/big"
+	"reflect"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/ava-labs/coreth/core"
+	"github.com/ava-labs/coreth/core/rawdb"
+	"github.com/ava-labs/coreth/core/types"
+	"github.com/ava-labs/coreth/core/vm"
+	"github.com/ava-labs/coreth/params"
+	"github.com/ava-labs/coreth/rpc"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/event"
+)
+
+func init() {
+	// The native tracers cannot be imported by this package, so a minimal
+	// tracer emitting flat call frames stands in for the flat call tracer.
+	if _, ok := DefaultDirectory.elems[flatCallTracerName]; !ok {
+		DefaultDirectory.Register(flatCallTracerName, newTestFlatTracer, false)
+	}
+	DefaultDirectory.Register("testAtomicTracer", newTestAtomicTracer, false)
+	// Tracers flagged as JS are run by the parallel block tracer.
+	DefaultDirectory.Register("testJSFlatTracer", newTestFlatTracer, true)
+}
+
+type testFlatFrame struct {
+	Action struct {
+		From common.Address `json:"from"`
+		To   common.Address `json:"to"`
+	} `json:"action"`
+	TransactionHash common.Hash `json:"transactionHash"`
+}
+
+// testFlatTracer emits a flat call frame for each call of a transaction.
+type testFlatTracer struct {
+	ctx    *Context
+	frames []testFlatFrame
+}
+
+func newTestFlatTracer(ctx *Context, _ json.RawMessage) (Tracer, error) {
+	return &testFlatTracer{ctx: ctx}, nil
+}
+
+func (t *testFlatTracer) capture(from, to common.Address) {
+	frame := testFlatFrame{TransactionHash: t.ctx.TxHash}
+	frame.Action.From, frame.Action.To = from, to
+	t.frames = append(t.frames, frame)
+}
+
+func (t *testFlatTracer) CaptureStart(env *vm.EVM, from common.Address, to common.Address, create bool, input []byte, gas uint64, value *big.Int) {
+	t.capture(from, to)
+}
+
+func (t *testFlatTracer) CaptureEnter(typ vm.OpCode, from common.Address, to common.Address, input []byte, gas uint64, value *big.Int) {
+	t.capture(from, to)
+}
+
+func (*testFlatTracer) CaptureEnd(output []byte, gasUsed uint64, err error)  {}
+func (*testFlatTracer) CaptureExit(output []byte, gasUsed uint64, err error) {}
+func (*testFlatTracer) CaptureState(pc uint64, op vm.OpCode, gas, cost uint64, scope *vm.ScopeContext, rData []byte, depth int, err error) {
+}
+func (*testFlatTracer) CaptureFault(pc uint64, op vm.OpCode, gas, cost uint64, scope *vm.ScopeContext, depth int, err error) {
+}
+func (*testFlatTracer) CaptureTxStart(gasLimit uint64) {}
+func (*testFlatTracer) CaptureTxEnd(restGas uint64)    {}
+func (*testFlatTracer) Stop(err error)                 {}
+
+func (t *testFlatTracer) GetResult() (json.RawMessage, error) {
+	return json.Marshal(t.frames)
+}
+
+// indexTestBackend reports the genesis block as last accepted and delivers
+// accepted events from [acceptedFeed], so that tests control which blocks the
+// trace indexer observes as accepted.
+type indexTestBackend struct {
+	*testBackend
+	acceptedFeed event.Feed
+
+	failing atomic.Uint64 // Number of the block failing to be retrieved, 0 if none
+	failed  chan uint64   // Receives the numbers of the blocks failed to be retrieved, if set
+}
+
+func (b *indexTestBackend) BlockByNumber(ctx context.Context, number rpc.BlockNumber) (*types.Block, error) {
+	if number > 0 && uint64(number) == b.failing.Load() {
+		if b.failed != nil {
+			b.failed <- uint64(number)
+		}
+		return nil, errors.New("block unavailable")
+	}
+	return b.testBackend.BlockByNumber(ctx, number)
+}
+
+func (b *indexTestBackend) LastAcceptedBlock() *types.Block {
+	return b.chain.Genesis()
+}
+
+func (b *indexTestBackend) SubscribeChainAcceptedEvent(ch chan<- core.ChainEvent) event.Subscription {
+	return b.acceptedFeed.Subscribe(ch)
+}
+
+func TestTraceFilter(t *testing.T) {
+	t.Parallel()
+
+	accounts := newAccounts(3)
+	genesis := &core.Genesis{
+		Config: params.TestChainConfig,
+		Alloc: core.GenesisAlloc{
+			accounts[0].addr: {Balance: big.NewInt(params.Ether)},
+			accounts[1].addr: {Balance: big.NewInt(params.Ether)},
+		},
+	}
+	// Transfers: 0 -> 1 in block 1, 0 -> 2 in block 2 and 1 -> 2 in block 3
+	transfers := [][2]int{{0, 1}, {0, 2}, {1, 2}}
+	nonces := make(map[int]uint64)
+	signer := types.HomesteadSigner{}
+	txHashes := make([]common.Hash, len(transfers))
+	backend := newTestBackend(t, len(transfers), genesis, func(i int, b *core.BlockGen) {
+		from, to := transfers[i][0], transfers[i][1]
+		tx, _ := types.SignTx(types.NewTransaction(nonces[from], accounts[to].addr, big.NewInt(1000), params.TxGas, b.BaseFee(), nil), signer, accounts[from].key)
+		nonces[from]++
+		b.AddTx(tx)
+		txHashes[i] = tx.Hash()
+	})
+	defer backend.teardown()
+
+	indexBackend := &indexTestBackend{testBackend: backend}
+	indexer := NewTraceIndexer(indexBackend)
+	defer indexer.Stop()
+	if first, next := indexer.Range(); first != 1 || next != 1 {
+		t.Fatalf("initial index range mismatch: have [%d, %d), want [1, 1)", first, next)
+	}
+	indexBackend.acceptedFeed.Send(core.ChainEvent{Block: backend.chain.GetBlockByNumber(uint64(len(transfers)))})
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		if _, next := indexer.Range(); next == uint64(len(transfers))+1 {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("timed out waiting for trace index")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	if have := rawdb.ReadTraceAddressBlocks(backend.chaindb, accounts[2].addr, rawdb.TraceAddressTo, 0, 3); !reflect.DeepEqual(have, []uint64{2, 3}) {
+		t.Fatalf("indexed blocks mismatch: have %v, want [2 3]", have)
+	}
+	if have := rawdb.ReadTraceAddressBlocks(backend.chaindb, accounts[2].addr, rawdb.TraceAddressFrom, 0, 3); len(have) != 0 {
+		t.Fatalf("indexed blocks mismatch: have %v, want none", have)
+	}
+
+	decode := func(t *testing.T, raw []json.RawMessage) []common.Hash {
+		hashes := make([]common.Hash, len(raw))
+		for i, r := range raw {
+			var frame testFlatFrame
+			if err := json.Unmarshal(r, &frame); err != nil {
+				t.Fatalf("failed to decode frame: %v", err)
+			}
+			hashes[i] = frame.TransactionHash
+		}
+		return hashes
+	}
+	// Both APIs must return the same results, with and without the index
+	for _, api := range []*TraceAPI{NewTraceAPI(backend, nil), NewTraceAPI(backend, indexer)} {
+		frames, err := api.Block(context.Background(), 2)
+		if err != nil {
+			t.Fatalf("failed to trace block: %v", err)
+		}
+		if have := decode(t, frames); !reflect.DeepEqual(have, []common.Hash{txHashes[1]}) {
+			t.Fatalf("block traces mismatch: have %v", have)
+		}
+		frames, err = api.Transaction(context.Background(), txHashes[2])
+		if err != nil {
+			t.Fatalf("failed to trace transaction: %v", err)
+		}
+		if have := decode(t, frames); !reflect.DeepEqual(have, []common.Hash{txHashes[2]}) {
+			t.Fatalf("transaction traces mismatch: have %v", have)
+		}
+
+		var (
+			one    = uint64(1)
+			first  = rpc.BlockNumber(1)
+			latest = rpc.LatestBlockNumber
+		)
+		tests := []struct {
+			args TraceFilterArgs
+			want []common.Hash
+		}{
+			{
+				args: TraceFilterArgs{FromBlock: &first, ToBlock: &latest},
+				want: txHashes,
+			},
+			{
+				args: TraceFilterArgs{FromBlock: &first, FromAddress: []common.Address{accounts[0].addr}},
+				want: []common.Hash{txHashes[0], txHashes[1]},
+			},
+			{
+				args: TraceFilterArgs{FromBlock: &first, ToAddress: []common.Address{accounts[2].addr}},
+				want: []common.Hash{txHashes[1], txHashes[2]},
+			},
+			{
+				args: TraceFilterArgs{FromBlock: &first, FromAddress: []common.Address{accounts[1].addr}, ToAddress: []common.Address{accounts[2].addr}},
+				want: []common.Hash{txHashes[2]},
+			},
+			{
+				args: TraceFilterArgs{FromBlock: &first, ToAddress: []common.Address{accounts[2].addr}, After: &one, Count: &one},
+				want: []common.Hash{txHashes[2]},
+			},
+			{
+				args: TraceFilterArgs{FromBlock: &first, FromAddress: []common.Address{accounts[2].addr}},
+				want: []common.Hash{},
+			},
+		}
+		for i, tc := range tests {
+			frames, err := api.Filter(context.Background(), tc.args)
+			if err != nil {
+				t.Fatalf("test %d: failed to filter traces: %v", i, err)
+			}
+			if have := decode(t, frames); !reflect.DeepEqual(have, tc.want) {
+				t.Fatalf("test %d: filtered traces mismatch: have %v, want %v", i, have, tc.want)
+			}
+		}
+	}
+}
+
+func TestTraceIndexRetry(t *testing.T) {
+	t.Parallel()
+
+	accounts := newAccounts(2)
+	genesis := &core.Genesis{
+		Config: params.TestChainConfig,
+		Alloc:  core.GenesisAlloc{accounts[0].addr: {Balance: big.NewInt(params.Ether)}},
+	}
+	signer := types.HomesteadSigner{}
+	backend := newTestBackend(t, 3, genesis, func(i int, b *core.BlockGen) {
+		tx, _ := types.SignTx(types.NewTransaction(uint64(i), accounts[1].addr, big.NewInt(1000), params.TxGas, b.BaseFee(), nil), signer, accounts[0].key)
+		b.AddTx(tx)
+	})
+	defer backend.teardown()
+
+	indexBackend := &indexTestBackend{testBackend: backend, failed: make(chan uint64, 1)}
+	indexBackend.failing.Store(2)
+	indexer := NewTraceIndexer(indexBackend)
+	defer indexer.Stop()
+
+	// The index stops right before the block that cannot be traced
+	indexBackend.acceptedFeed.Send(core.ChainEvent{Block: backend.chain.GetBlockByNumber(3)})
+	select {
+	case number := <-indexBackend.failed:
+		if number != 2 {
+			t.Fatalf("failed block mismatch: have %d, want 2", number)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("timed out waiting for the trace failure")
+	}
+	if first, next := indexer.Range(); first != 1 || next != 2 {
+		t.Fatalf("index range mismatch: have [%d, %d), want [1, 2)", first, next)
+	}
+	if first, next, _ := rawdb.ReadTraceIndexRange(backend.chaindb); first != 1 || next != 2 {
+		t.Fatalf("stored index range mismatch: have [%d, %d), want [1, 2)", first, next)
+	}
+
+	// The same block is retried, here once another block is accepted
+	indexBackend.failing.Store(0)
+	indexBackend.acceptedFeed.Send(core.ChainEvent{Block: backend.chain.GetBlockByNumber(3)})
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		if _, next := indexer.Range(); next == 4 {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("timed out waiting for trace index")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	if first, _ := indexer.Range(); first != 1 {
+		t.Fatalf("index range start mismatch: have %d, want 1", first)
+	}
+	if have := rawdb.ReadTraceAddressBlocks(backend.chaindb, accounts[1].addr, rawdb.TraceAddressTo, 0, 3); !reflect.DeepEqual(have, []uint64{1, 2, 3}) {
+		t.Fatalf("indexed blocks mismatch: have %v, want [1 2 3]", have)
+	}
+}
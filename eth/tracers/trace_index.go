@@ -0,0 +1,212 @@
+// (c) 2024, Ava Labs, Inc. All rights reserved.
+// See the file LICENSE for licensing terms.
+
+package tracers
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/ava-labs/coreth/core"
+	"github.com/ava-labs/coreth/core/rawdb"
+	"github.com/ava-labs/coreth/core/types"
+	"github.com/ava-labs/coreth/rpc"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/ethdb"
+	"github.com/ethereum/go-ethereum/event"
+	"github.com/ethereum/go-ethereum/log"
+)
+
+// traceIndexRetryDelay is the time after which indexing a block that could not
+// be traced is retried, unless a newly accepted block triggers it earlier.
+const traceIndexRetryDelay = 30 * time.Second
+
+// IndexBackend is the backend required to maintain the call trace address
+// index as blocks are accepted.
+type IndexBackend interface {
+	Backend
+	LastAcceptedBlock() *types.Block
+	SubscribeChainAcceptedEvent(ch chan<- core.ChainEvent) event.Subscription
+}
+
+// TraceIndexer maintains an on-disk index of the addresses appearing in the
+// call traces of accepted blocks, allowing trace_filter to skip the blocks
+// not involving the requested addresses.
+//
+// The index covers a contiguous range of blocks, starting at the block accepted
+// after the index was first enabled. The range only advances past a block once
+// it is traced, a block failing to be traced is retried until it succeeds.
+type TraceIndexer struct {
+	api baseAPI
+	db  ethdb.Database
+
+	first, next uint64 // Range of indexed blocks [first, next)
+	target      uint64 // Last accepted block to be indexed
+	lock        sync.RWMutex
+
+	acceptedCh chan core.ChainEvent
+	notify     chan struct{} // Signals the indexing loop that the target advanced
+	sub        event.Subscription
+	quit       chan struct{}
+	wg         sync.WaitGroup
+}
+
+// NewTraceIndexer creates the call trace address indexer and starts indexing
+// accepted blocks in the background.
+func NewTraceIndexer(backend IndexBackend) *TraceIndexer {
+	db := backend.ChainDb()
+	lastAccepted := backend.LastAcceptedBlock().NumberU64()
+	first, next, ok := rawdb.ReadTraceIndexRange(db)
+	if !ok || next > lastAccepted+1 {
+		first, next = lastAccepted+1, lastAccepted+1
+		rawdb.WriteTraceIndexRange(db, first, next)
+	}
+	t := &TraceIndexer{
+		api:        baseAPI{backend: backend},
+		db:         db,
+		first:      first,
+		next:       next,
+		target:     lastAccepted,
+		acceptedCh: make(chan core.ChainEvent, 1),
+		notify:     make(chan struct{}, 1),
+		quit:       make(chan struct{}),
+	}
+	t.sub = backend.SubscribeChainAcceptedEvent(t.acceptedCh)
+
+	log.Info("Initialized call trace indexer", "first", first, "next", next, "lastAccepted", lastAccepted)
+	t.wg.Add(2)
+	go t.eventLoop()
+	go t.indexLoop()
+	return t
+}
+
+// Range returns the range of blocks [first, next) covered by the index.
+func (t *TraceIndexer) Range() (uint64, uint64) {
+	t.lock.RLock()
+	defer t.lock.RUnlock()
+
+	return t.first, t.next
+}
+
+// Stop terminates the indexer, waiting for the block being indexed to finish.
+func (t *TraceIndexer) Stop() {
+	t.sub.Unsubscribe()
+	close(t.quit)
+	t.wg.Wait()
+}
+
+// eventLoop advances the indexing target as blocks are accepted. Indexing
+// happens on a separate goroutine so that block acceptance is never blocked
+// on tracing.
+func (t *TraceIndexer) eventLoop() {
+	defer t.wg.Done()
+
+	for {
+		select {
+		case ev := <-t.acceptedCh:
+			t.lock.Lock()
+			t.target = max(t.target, ev.Block.NumberU64())
+			indexed := ev.Block.NumberU64() < t.next
+			t.lock.Unlock()
+
+			// Blocks accepted again, e.g. while bootstrapping, are indexed already
+			if indexed {
+				continue
+			}
+
+			select {
+			case t.notify <- struct{}{}:
+			default:
+			}
+		case <-t.sub.Err():
+			return
+		case <-t.quit:
+			return
+		}
+	}
+}
+
+// indexLoop indexes blocks up to the indexing target, catching up with the
+// last accepted block on startup.
+func (t *TraceIndexer) indexLoop() {
+	defer t.wg.Done()
+
+	for {
+		var retry <-chan time.Time
+		if err := t.indexPending(); err != nil {
+			log.Error("Failed to index call traces, retrying", "err", err, "retryIn", traceIndexRetryDelay)
+			retry = time.After(traceIndexRetryDelay)
+		}
+		select {
+		case <-t.notify:
+		case <-retry:
+		case <-t.quit:
+			return
+		}
+	}
+}
+
+// indexPending indexes all blocks after the indexed range up to and including
+// the indexing target. It stops at the first block that cannot be traced,
+// leaving the indexed range ending right before it.
+func (t *TraceIndexer) indexPending() error {
+	for {
+		t.lock.RLock()
+		first, number, target := t.first, t.next, t.target
+		t.lock.RUnlock()
+
+		if number > target {
+			return nil
+		}
+		select {
+		case <-t.quit:
+			return nil
+		default:
+		}
+		addresses, err := t.blockAddresses(number)
+		if err != nil {
+			return fmt.Errorf("failed to trace block %d: %w", number, err)
+		}
+		batch := t.db.NewBatch()
+		rawdb.WriteTraceAddresses(batch, number, addresses)
+		rawdb.WriteTraceIndexRange(batch, first, number+1)
+		if err := batch.Write(); err != nil {
+			log.Crit("Failed to write call trace index", "number", number, "err", err)
+		}
+		t.lock.Lock()
+		t.next = number + 1
+		t.lock.Unlock()
+	}
+}
+
+// blockAddresses traces the block with [number] and returns the addresses
+// appearing in its call traces, along with the roles they take in them.
+func (t *TraceIndexer) blockAddresses(number uint64) (map[common.Address]rawdb.TraceAddressFlags, error) {
+	ctx := context.Background()
+	block, err := t.api.blockByNumber(ctx, rpc.BlockNumber(number))
+	if err != nil {
+		return nil, err
+	}
+	frames, err := t.api.blockFrames(ctx, block)
+	if err != nil {
+		return nil, err
+	}
+	addresses := make(map[common.Address]rawdb.TraceAddressFlags)
+	for _, raw := range frames {
+		var frame flatCallFrame
+		if err := json.Unmarshal(raw, &frame); err != nil {
+			return nil, err
+		}
+		from, to := frame.addresses()
+		for _, addr := range from {
+			addresses[addr] |= rawdb.TraceAddressFrom
+		}
+		for _, addr := range to {
+			addresses[addr] |= rawdb.TraceAddressTo
+		}
+	}
+	return addresses, nil
+}
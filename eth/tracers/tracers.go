@@ -23,6 +23,7 @@ import (
 	"fmt"
 	"math/big"
 
+	"github.com/ava-labs/coreth/consensus/dummy"
 	"github.com/ava-labs/coreth/core/vm"
 	"github.com/ethereum/go-ethereum/common"
 )
@@ -45,23 +46,40 @@ type Tracer interface {
 	Stop(err error)
 }
 
+// AtomicTxTracer is implemented by tracers also tracing the atomic transactions
+// of a block, whose credits and debits are applied to the EVM state outside of
+// the EVM after the transactions of the block are executed.
+type AtomicTxTracer interface {
+	Tracer
+	// CaptureAtomicTxStart is called before [transfers] are applied to the
+	// state of [env].
+	CaptureAtomicTxStart(env *vm.EVM, transfers []dummy.ExtraStateTransfer)
+	// CaptureAtomicTxEnd is called after the transfers are applied.
+	CaptureAtomicTxEnd()
+}
+
 type ctorFn func(*Context, json.RawMessage) (Tracer, error)
 type jsCtorFn func(string, *Context, json.RawMessage) (Tracer, error)
 
+// aggregateFn merges the results of a tracer for the transactions of a block
+// into a single result for the block.
+type aggregateFn func([]json.RawMessage) (json.RawMessage, error)
+
 type elem struct {
 	ctor ctorFn
 	isJS bool
 }
 
 // DefaultDirectory is the collection of tracers bundled by default.
-var DefaultDirectory = directory{elems: make(map[string]elem)}
+var DefaultDirectory = directory{elems: make(map[string]elem), aggregators: make(map[string]aggregateFn)}
 
 // directory provides functionality to lookup a tracer by name
 // and a function to instantiate it. It falls back to a JS code evaluator
 // if no tracer of the given name exists.
 type directory struct {
-	elems  map[string]elem
-	jsEval jsCtorFn
+	elems       map[string]elem
+	aggregators map[string]aggregateFn
+	jsEval      jsCtorFn
 }
 
 // Register registers a method as a lookup for tracers, meaning that
@@ -70,6 +88,22 @@ func (d *directory) Register(name string, f ctorFn, isJS bool) {
 	d.elems[name] = elem{ctor: f, isJS: isJS}
 }
 
+// RegisterAggregator registers a method merging the results of the tracer
+// [name] for the transactions of a block into a result for the whole block.
+func (d *directory) RegisterAggregator(name string, f aggregateFn) {
+	d.aggregators[name] = f
+}
+
+// Aggregate merges the [results] of the tracer [name] for the transactions of
+// a block into a result for the whole block.
+func (d *directory) Aggregate(name string, results []json.RawMessage) (json.RawMessage, error) {
+	f, ok := d.aggregators[name]
+	if !ok {
+		return nil, fmt.Errorf("tracer %q does not support block aggregation", name)
+	}
+	return f(results)
+}
+
 // RegisterJSEval registers a tracer that is able to parse
 // dynamic user-provided JS code.
 func (d *directory) RegisterJSEval(f jsCtorFn) {
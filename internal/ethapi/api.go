@@ -37,6 +37,7 @@ import (
 
 	"github.com/ava-labs/avalanchego/ids"
 	"github.com/ava-labs/coreth/accounts"
+	"github.com/ava-labs/coreth/accounts/abi"
 	"github.com/ava-labs/coreth/accounts/keystore"
 	"github.com/ava-labs/coreth/accounts/scwallet"
 	"github.com/ava-labs/coreth/consensus"
@@ -647,6 +648,64 @@ func (s *BlockChainAPI) GetAssetBalance(ctx context.Context, address common.Addr
 	return (*hexutil.Big)(state.GetBalanceMultiCoin(address, common.Hash(assetID))), state.Error()
 }
 
+// maxGetAccountsAddresses is the maximum number of addresses eth_getAccounts
+// queries in a single request.
+const maxGetAccountsAddresses = 1024
+
+// AccountQueryResult is the state of an account returned by GetAccount, along
+// with its balances of the requested multicoin assets.
+type AccountQueryResult struct {
+	Address       common.Address          `json:"address"`
+	Nonce         hexutil.Uint64          `json:"nonce"`
+	Balance       *hexutil.Big            `json:"balance"`
+	CodeHash      common.Hash             `json:"codeHash"`
+	StorageRoot   common.Hash             `json:"storageRoot"`
+	AssetBalances map[ids.ID]*hexutil.Big `json:"assetBalances,omitempty"`
+}
+
+// GetAccount returns the nonce, balance, code hash and storage root of the given
+// address, along with its balances of [assetIDs], in the state of the given block.
+func (s *BlockChainAPI) GetAccount(ctx context.Context, address common.Address, blockNrOrHash rpc.BlockNumberOrHash, assetIDs *[]ids.ID) (*AccountQueryResult, error) {
+	results, err := s.GetAccounts(ctx, []common.Address{address}, blockNrOrHash, assetIDs)
+	if err != nil {
+		return nil, err
+	}
+	return results[0], nil
+}
+
+// GetAccounts returns the accounts of the given addresses in the same format as
+// GetAccount, reading all of them from the state of the given block.
+func (s *BlockChainAPI) GetAccounts(ctx context.Context, addresses []common.Address, blockNrOrHash rpc.BlockNumberOrHash, assetIDs *[]ids.ID) ([]*AccountQueryResult, error) {
+	if len(addresses) == 0 {
+		return nil, errors.New("no addresses specified")
+	}
+	if len(addresses) > maxGetAccountsAddresses {
+		return nil, fmt.Errorf("too many addresses: %d > %d", len(addresses), maxGetAccountsAddresses)
+	}
+	state, _, err := s.b.StateAndHeaderByNumberOrHash(ctx, blockNrOrHash)
+	if state == nil || err != nil {
+		return nil, err
+	}
+	results := make([]*AccountQueryResult, len(addresses))
+	for i, address := range addresses {
+		result := &AccountQueryResult{
+			Address:     address,
+			Nonce:       hexutil.Uint64(state.GetNonce(address)),
+			Balance:     (*hexutil.Big)(state.GetBalance(address)),
+			CodeHash:    state.GetCodeHash(address),
+			StorageRoot: state.GetStorageRoot(address),
+		}
+		if assetIDs != nil {
+			result.AssetBalances = make(map[ids.ID]*hexutil.Big, len(*assetIDs))
+			for _, assetID := range *assetIDs {
+				result.AssetBalances[assetID] = (*hexutil.Big)(state.GetBalanceMultiCoin(address, common.Hash(assetID)))
+			}
+		}
+		results[i] = result
+	}
+	return results, state.Error()
+}
+
 // Result structs for GetProof
 type AccountResult struct {
 	Address      common.Address  `json:"address"`
@@ -809,6 +868,8 @@ func (s *BlockChainAPI) GetHeaderByHash(ctx context.Context, hash common.Hash) m
 //   - When blockNr is -4 the chain safe block is returned.
 //   - When fullTx is true all transactions in the block are returned, otherwise
 //     only the transaction hash is returned.
+//   - When the body of the block expired, only its header is returned unless
+//     fullTx is true.
 func (s *BlockChainAPI) GetBlockByNumber(ctx context.Context, number rpc.BlockNumber, fullTx bool) (map[string]interface{}, error) {
 	block, err := s.b.BlockByNumber(ctx, number)
 	if block != nil && err == nil {
@@ -822,16 +883,23 @@ func (s *BlockChainAPI) GetBlockByNumber(ctx context.Context, number rpc.BlockNu
 		// }
 		return response, err
 	}
+	if errors.Is(err, core.ErrHistoryPruned) && !fullTx {
+		return s.GetHeaderByNumber(ctx, number)
+	}
 	return nil, err
 }
 
 // GetBlockByHash returns the requested block. When fullTx is true all transactions in the block are returned in full
-// detail, otherwise only the transaction hash is returned.
+// detail, otherwise only the transaction hash is returned. When the body of the block expired, only its header is
+// returned unless fullTx is true.
 func (s *BlockChainAPI) GetBlockByHash(ctx context.Context, hash common.Hash, fullTx bool) (map[string]interface{}, error) {
 	block, err := s.b.BlockByHash(ctx, hash)
 	if block != nil {
 		return s.rpcMarshalBlock(ctx, block, true, fullTx)
 	}
+	if errors.Is(err, core.ErrHistoryPruned) && !fullTx {
+		return s.GetHeaderByHash(ctx, hash), nil
+	}
 	return nil, err
 }
 
@@ -912,6 +980,9 @@ func (s *BlockChainAPI) GetStorageAt(ctx context.Context, address common.Address
 // GetBlockReceipts returns the block receipts for the given block hash or number or tag.
 func (s *BlockChainAPI) GetBlockReceipts(ctx context.Context, blockNrOrHash rpc.BlockNumberOrHash) ([]map[string]interface{}, error) {
 	block, err := s.b.BlockByNumberOrHash(ctx, blockNrOrHash)
+	if errors.Is(err, core.ErrHistoryPruned) {
+		return nil, err
+	}
 	if block == nil || err != nil {
 		// When the block doesn't exist, the RPC method should return JSON null
 		// as per specification.
@@ -1032,6 +1103,34 @@ func (diff *BlockOverrides) Apply(blockCtx *vm.BlockContext) {
 	}
 }
 
+// MakeHeader returns a copy of the given header with the overridden fields.
+// Note, the blob base fee override is ignored as the header has no such field.
+func (diff *BlockOverrides) MakeHeader(header *types.Header) *types.Header {
+	if diff == nil {
+		return header
+	}
+	h := types.CopyHeader(header)
+	if diff.Number != nil {
+		h.Number = diff.Number.ToInt()
+	}
+	if diff.Difficulty != nil {
+		h.Difficulty = diff.Difficulty.ToInt()
+	}
+	if diff.Time != nil {
+		h.Time = uint64(*diff.Time)
+	}
+	if diff.GasLimit != nil {
+		h.GasLimit = uint64(*diff.GasLimit)
+	}
+	if diff.Coinbase != nil {
+		h.Coinbase = *diff.Coinbase
+	}
+	if diff.BaseFee != nil {
+		h.BaseFee = diff.BaseFee.ToInt()
+	}
+	return h
+}
+
 // ChainContextBackend provides methods required to implement ChainContext.
 type ChainContextBackend interface {
 	Engine() consensus.Engine
@@ -1171,22 +1270,45 @@ func (s *BlockChainAPI) Call(ctx context.Context, args TransactionArgs, blockNrO
 // successfully at block `blockNrOrHash`. It returns error if the transaction would revert, or if
 // there are unexpected failures. The gas limit is capped by both `args.Gas` (if non-nil &
 // non-zero) and `gasCap` (if non-zero).
-func DoEstimateGas(ctx context.Context, b Backend, args TransactionArgs, blockNrOrHash rpc.BlockNumberOrHash, overrides *StateOverride, gasCap uint64) (hexutil.Uint64, error) {
+func DoEstimateGas(ctx context.Context, b Backend, args TransactionArgs, blockNrOrHash rpc.BlockNumberOrHash, overrides *StateOverride, blockOverrides *BlockOverrides, gasCap uint64) (hexutil.Uint64, error) {
+	estimate, revert, err := doEstimateGas(ctx, b, args, blockNrOrHash, overrides, blockOverrides, gasCap, nil)
+	if err != nil {
+		if len(revert) > 0 {
+			return 0, newRevertError(revert)
+		}
+		return 0, err
+	}
+	return hexutil.Uint64(estimate), nil
+}
+
+// doEstimateGas runs the gas estimation, collecting its course into [report]
+// if non-nil. It returns the estimate, or the revert data and error of the
+// failed estimation.
+func doEstimateGas(ctx context.Context, b Backend, args TransactionArgs, blockNrOrHash rpc.BlockNumberOrHash, overrides *StateOverride, blockOverrides *BlockOverrides, gasCap uint64, report *gasestimator.Report) (uint64, []byte, error) {
 	// Retrieve the base state and mutate it with any overrides
 	state, header, err := b.StateAndHeaderByNumberOrHash(ctx, blockNrOrHash)
 	if state == nil || err != nil {
-		return 0, err
+		return 0, nil, err
 	}
 	if err = overrides.Apply(state); err != nil {
-		return 0, err
+		return 0, nil, err
 	}
-	// Construct the gas estimator option from the user input
+	// Construct the gas estimator option from the user input, with the header
+	// reflecting the block overrides so that the fields derived from it, such
+	// as the gas price of the call, use the overridden values
+	header = blockOverrides.MakeHeader(header)
 	opts := &gasestimator.Options{
 		Config:     b.ChainConfig(),
 		Chain:      NewChainContext(ctx, b),
 		Header:     header,
 		State:      state,
 		ErrorRatio: estimateGasErrorRatio,
+		Report:     report,
+	}
+	if blockOverrides != nil {
+		blockCtx := core.NewEVMBlockContext(header, opts.Chain, nil)
+		blockOverrides.Apply(&blockCtx)
+		opts.BlockContext = &blockCtx
 	}
 
 	// If the user has not specified a gas limit, use the block gas limit
@@ -1197,16 +1319,9 @@ func DoEstimateGas(ctx context.Context, b Backend, args TransactionArgs, blockNr
 	// Run the gas estimation andwrap any revertals into a custom return
 	call, err := args.ToMessage(gasCap, header.BaseFee)
 	if err != nil {
-		return 0, err
+		return 0, nil, err
 	}
-	estimate, revert, err := gasestimator.Estimate(ctx, call, opts, gasCap)
-	if err != nil {
-		if len(revert) > 0 {
-			return 0, newRevertError(revert)
-		}
-		return 0, err
-	}
-	return hexutil.Uint64(estimate), nil
+	return gasestimator.Estimate(ctx, call, opts, gasCap)
 }
 
 // EstimateGas returns the lowest possible gas limit that allows the transaction to run
@@ -1214,12 +1329,102 @@ func DoEstimateGas(ctx context.Context, b Backend, args TransactionArgs, blockNr
 // returns error if the transaction would revert or if there are unexpected failures. The returned
 // value is capped by both `args.Gas` (if non-nil & non-zero) and the backend's RPCGasCap
 // configuration (if non-zero).
-func (s *BlockChainAPI) EstimateGas(ctx context.Context, args TransactionArgs, blockNrOrHash *rpc.BlockNumberOrHash, overrides *StateOverride) (hexutil.Uint64, error) {
+func (s *BlockChainAPI) EstimateGas(ctx context.Context, args TransactionArgs, blockNrOrHash *rpc.BlockNumberOrHash, overrides *StateOverride, blockOverrides *BlockOverrides) (hexutil.Uint64, error) {
 	bNrOrHash := rpc.BlockNumberOrHashWithNumber(rpc.LatestBlockNumber)
 	if blockNrOrHash != nil {
 		bNrOrHash = *blockNrOrHash
 	}
-	return DoEstimateGas(ctx, s.b, args, bNrOrHash, overrides, s.b.RPCGasCap())
+	return DoEstimateGas(ctx, s.b, args, bNrOrHash, overrides, blockOverrides, s.b.RPCGasCap())
+}
+
+// estimateGasIteration is a single execution performed during gas estimation.
+type estimateGasIteration struct {
+	Gas    hexutil.Uint64 `json:"gas"`
+	Failed bool           `json:"failed"`
+}
+
+// estimateGasResult is the result of eth_estimateGasVerbose, explaining the
+// outcome of the gas estimation.
+type estimateGasResult struct {
+	Gas        *hexutil.Uint64        `json:"gas,omitempty"`
+	Error      string                 `json:"error,omitempty"`
+	Cause      string                 `json:"cause,omitempty"`
+	Iterations []estimateGasIteration `json:"iterations"`
+
+	Allowance     hexutil.Uint64  `json:"allowance"`
+	CappedBy      string          `json:"cappedBy"`
+	FailingGas    *hexutil.Uint64 `json:"failingGas,omitempty"`
+	SucceedingGas *hexutil.Uint64 `json:"succeedingGas,omitempty"`
+
+	Revert        hexutil.Bytes `json:"revert,omitempty"`
+	RevertReason  string        `json:"revertReason,omitempty"`
+	ErrorSelector hexutil.Bytes `json:"errorSelector,omitempty"`
+}
+
+// Causes of a failed gas estimation, in addition to the gasestimator caps.
+const (
+	estimateCauseRevert = "revert" // The call reverted regardless of the gas limit
+	estimateCauseError  = "error"  // The call could not be executed
+)
+
+// EstimateGasVerbose estimates the gas like EstimateGas, but instead of only
+// returning the estimate or an error, it explains the estimation: the executed
+// gas limits, the bounds found, the decoded revert reason or custom error
+// selector and whether a failure is caused by the RPC gas cap, the balance of
+// the caller or the gas limit.
+func (s *BlockChainAPI) EstimateGasVerbose(ctx context.Context, args TransactionArgs, blockNrOrHash *rpc.BlockNumberOrHash, overrides *StateOverride, blockOverrides *BlockOverrides) (*estimateGasResult, error) {
+	bNrOrHash := rpc.BlockNumberOrHashWithNumber(rpc.LatestBlockNumber)
+	if blockNrOrHash != nil {
+		bNrOrHash = *blockNrOrHash
+	}
+	var (
+		report                gasestimator.Report
+		estimate, revert, err = doEstimateGas(ctx, s.b, args, bNrOrHash, overrides, blockOverrides, s.b.RPCGasCap(), &report)
+	)
+	// Errors preventing the estimation from starting are returned as is, unless
+	// caused by the balance of the caller
+	insufficientFunds := errors.Is(err, core.ErrInsufficientFunds) || errors.Is(err, core.ErrInsufficientFundsForTransfer)
+	if err != nil && len(report.Iterations) == 0 && len(revert) == 0 && !insufficientFunds {
+		return nil, err
+	}
+	result := &estimateGasResult{
+		Iterations: make([]estimateGasIteration, len(report.Iterations)),
+		Allowance:  hexutil.Uint64(report.Allowance),
+		CappedBy:   report.CappedBy,
+	}
+	for i, iteration := range report.Iterations {
+		result.Iterations[i] = estimateGasIteration{Gas: hexutil.Uint64(iteration.Gas), Failed: iteration.Failed}
+	}
+	if report.Failing != 0 {
+		result.FailingGas = (*hexutil.Uint64)(&report.Failing)
+	}
+	if report.Succeeding != 0 {
+		result.SucceedingGas = (*hexutil.Uint64)(&report.Succeeding)
+	}
+	switch {
+	case err == nil:
+		result.Gas = (*hexutil.Uint64)(&estimate)
+	case len(revert) > 0:
+		result.Error = newRevertError(revert).Error()
+		result.Cause = estimateCauseRevert
+		result.Revert = revert
+		if reason, errUnpack := abi.UnpackRevert(revert); errUnpack == nil {
+			result.RevertReason = reason
+		} else if len(revert) >= 4 {
+			result.ErrorSelector = revert[:4]
+		}
+	case insufficientFunds:
+		result.Error = err.Error()
+		result.Cause = gasestimator.CapBalance
+	case report.Succeeding == 0 && len(report.Iterations) > 0 && report.Iterations[len(report.Iterations)-1].Failed:
+		// The call ran out of gas even with the full allowance
+		result.Error = err.Error()
+		result.Cause = report.CappedBy
+	default:
+		result.Error = err.Error()
+		result.Cause = estimateCauseError
+	}
+	return result, nil
 }
 
 // RPCMarshalHeader converts the given header to the RPC output .
@@ -1684,6 +1889,9 @@ func (s *TransactionAPI) GetRawTransactionByHash(ctx context.Context, hash commo
 // GetTransactionReceipt returns the transaction receipt for the given transaction hash.
 func (s *TransactionAPI) GetTransactionReceipt(ctx context.Context, hash common.Hash) (map[string]interface{}, error) {
 	tx, blockHash, blockNumber, index, err := s.b.GetTransaction(ctx, hash)
+	if errors.Is(err, core.ErrHistoryPruned) {
+		return nil, err
+	}
 	if tx == nil || err != nil {
 		// When the transaction doesn't exist, the RPC method should return JSON null
 		// as per specification.
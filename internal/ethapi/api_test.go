@@ -27,18 +27,22 @@
 package ethapi
 
 import (
+	"bytes"
 	"context"
 	"crypto/ecdsa"
 	"encoding/json"
 	"errors"
 	"fmt"
+	"math"
 	"math/big"
 	"os"
 	"path/filepath"
 	"reflect"
+	"strings"
 	"testing"
 	"time"
 
+	"github.com/ava-labs/avalanchego/ids"
 	"github.com/ava-labs/coreth/accounts"
 	"github.com/ava-labs/coreth/consensus"
 	"github.com/ava-labs/coreth/consensus/dummy"
@@ -48,6 +52,7 @@ import (
 	"github.com/ava-labs/coreth/core/state"
 	"github.com/ava-labs/coreth/core/types"
 	"github.com/ava-labs/coreth/core/vm"
+	"github.com/ava-labs/coreth/eth/gasestimator"
 	"github.com/ava-labs/coreth/internal/blocktest"
 	"github.com/ava-labs/coreth/params"
 	"github.com/ava-labs/coreth/rpc"
@@ -722,7 +727,7 @@ func TestEstimateGas(t *testing.T) {
 		},
 	}
 	for i, tc := range testSuite {
-		result, err := api.EstimateGas(context.Background(), tc.call, &rpc.BlockNumberOrHash{BlockNumber: &tc.blockNumber}, &tc.overrides)
+		result, err := api.EstimateGas(context.Background(), tc.call, &rpc.BlockNumberOrHash{BlockNumber: &tc.blockNumber}, &tc.overrides, nil)
 		if tc.expectErr != nil {
 			if err == nil {
 				t.Errorf("test %d: want error %v, have nothing", i, tc.expectErr)
@@ -743,6 +748,310 @@ func TestEstimateGas(t *testing.T) {
 	}
 }
 
+func TestEstimateGasVerbose(t *testing.T) {
+	t.Parallel()
+	// Initialize test accounts
+	var (
+		accounts = newAccounts(2)
+		genesis  = &core.Genesis{
+			Config: params.TestChainConfig,
+			Alloc: core.GenesisAlloc{
+				accounts[0].addr: {Balance: big.NewInt(params.Ether)},
+			},
+		}
+		latest = rpc.BlockNumberOrHashWithNumber(rpc.LatestBlockNumber)
+	)
+	api := NewBlockChainAPI(newTestBackend(t, 1, genesis, dummy.NewCoinbaseFaker(), func(i int, b *core.BlockGen) {}))
+
+	// Successful estimation reports the bounds it converged on
+	result, err := api.EstimateGasVerbose(context.Background(), TransactionArgs{From: &accounts[0].addr}, &latest, nil, nil)
+	if err != nil {
+		t.Fatalf("failed to estimate gas: %v", err)
+	}
+	if result.Gas == nil || result.Error != "" {
+		t.Fatalf("estimation failed: %s", result.Error)
+	}
+	if result.SucceedingGas == nil || *result.SucceedingGas != *result.Gas {
+		t.Fatalf("succeeding gas mismatch: have %v, want %d", result.SucceedingGas, *result.Gas)
+	}
+	if len(result.Iterations) == 0 || result.CappedBy != gasestimator.CapGasLimit {
+		t.Fatalf("unexpected estimation report: iterations %d, capped by %q", len(result.Iterations), result.CappedBy)
+	}
+
+	// A block gas limit override too low for the call exhausts the allowance
+	gasLimit := hexutil.Uint64(30000)
+	result, err = api.EstimateGasVerbose(context.Background(), TransactionArgs{From: &accounts[0].addr}, &latest, nil, &BlockOverrides{GasLimit: &gasLimit})
+	if err != nil {
+		t.Fatalf("failed to estimate gas: %v", err)
+	}
+	if result.Gas != nil || result.Cause != gasestimator.CapGasLimit || result.Allowance != gasLimit {
+		t.Fatalf("unexpected estimation result: gas %v, cause %q, allowance %d", result.Gas, result.Cause, result.Allowance)
+	}
+	if result.FailingGas == nil || *result.FailingGas != gasLimit {
+		t.Fatalf("failing gas mismatch: have %v, want %d", result.FailingGas, gasLimit)
+	}
+
+	// A caller without funds for the transferred value fails due to its balance
+	result, err = api.EstimateGasVerbose(context.Background(), TransactionArgs{From: &accounts[1].addr, To: &accounts[0].addr, Value: (*hexutil.Big)(big.NewInt(1000)), GasPrice: (*hexutil.Big)(big.NewInt(1))}, &latest, nil, nil)
+	if err != nil {
+		t.Fatalf("failed to estimate gas: %v", err)
+	}
+	if result.Cause != gasestimator.CapBalance {
+		t.Fatalf("cause mismatch: have %q, want %q", result.Cause, gasestimator.CapBalance)
+	}
+
+	// A create reverting with a custom error reports its selector
+	//
+	// PUSH4 0xdeadbeef PUSH1 0xe0 SHL PUSH1 0 MSTORE PUSH1 4 PUSH1 0 REVERT
+	result, err = api.EstimateGasVerbose(context.Background(), TransactionArgs{From: &accounts[0].addr, Input: hex2Bytes("63deadbeef60e01b60005260046000fd")}, &latest, nil, nil)
+	if err != nil {
+		t.Fatalf("failed to estimate gas: %v", err)
+	}
+	if result.Cause != estimateCauseRevert || result.RevertReason != "" {
+		t.Fatalf("unexpected revert result: cause %q, reason %q", result.Cause, result.RevertReason)
+	}
+	if want := *hex2Bytes("deadbeef"); !bytes.Equal(result.ErrorSelector, want) {
+		t.Fatalf("error selector mismatch: have %x, want %x", result.ErrorSelector, want)
+	}
+
+	// A base fee override applies to the gas price of the call, here required
+	// by a create reverting below a gas price of 1000 gwei
+	//
+	// PUSH5 1000gwei GASPRICE LT PUSH1 0x0c JUMPI STOP JUMPDEST PUSH1 0 DUP1 REVERT
+	args := TransactionArgs{
+		From:                 &accounts[0].addr,
+		Input:                hex2Bytes("64e8d4a510003a10600c57005b600080fd"),
+		MaxFeePerGas:         (*hexutil.Big)(big.NewInt(5000 * params.GWei)),
+		MaxPriorityFeePerGas: (*hexutil.Big)(new(big.Int)),
+	}
+	result, err = api.EstimateGasVerbose(context.Background(), args, &latest, nil, nil)
+	if err != nil {
+		t.Fatalf("failed to estimate gas: %v", err)
+	}
+	if result.Gas != nil {
+		t.Fatalf("estimation below the required gas price succeeded: gas %d", *result.Gas)
+	}
+	baseFee := (*hexutil.Big)(big.NewInt(2000 * params.GWei))
+	result, err = api.EstimateGasVerbose(context.Background(), args, &latest, nil, &BlockOverrides{BaseFee: baseFee})
+	if err != nil {
+		t.Fatalf("failed to estimate gas: %v", err)
+	}
+	if result.Gas == nil || result.Error != "" {
+		t.Fatalf("estimation with base fee override failed: %s", result.Error)
+	}
+}
+
+func TestGetAccount(t *testing.T) {
+	t.Parallel()
+
+	var (
+		accounts = newAccounts(2)
+		assetID  = ids.GenerateTestID()
+		contract = common.HexToAddress("0x1111111111111111111111111111111111111111")
+		code     = []byte{0x60, 0x00}
+		genesis  = &core.Genesis{
+			Config: params.TestChainConfig,
+			Alloc: core.GenesisAlloc{
+				accounts[0].addr: {
+					Balance:   big.NewInt(params.Ether),
+					MCBalance: core.GenesisMultiCoinBalance{common.Hash(assetID): big.NewInt(100)},
+				},
+				contract: {
+					Balance: common.Big0,
+					Code:    code,
+					Storage: map[common.Hash]common.Hash{{}: common.HexToHash("0x01")},
+				},
+			},
+		}
+		signer = types.HomesteadSigner{}
+		latest = rpc.BlockNumberOrHashWithNumber(rpc.LatestBlockNumber)
+	)
+	api := NewBlockChainAPI(newTestBackend(t, 1, genesis, dummy.NewCoinbaseFaker(), func(i int, b *core.BlockGen) {
+		tx, _ := types.SignTx(types.NewTx(&types.LegacyTx{Nonce: uint64(i), To: &accounts[1].addr, Value: big.NewInt(1000), Gas: params.TxGas, GasPrice: b.BaseFee()}), signer, accounts[0].key)
+		b.AddTx(tx)
+	}))
+
+	statedb, _, err := api.b.StateAndHeaderByNumberOrHash(context.Background(), latest)
+	if err != nil {
+		t.Fatalf("failed to get state: %v", err)
+	}
+	assetIDs := []ids.ID{assetID}
+	results, err := api.GetAccounts(context.Background(), []common.Address{accounts[0].addr, accounts[1].addr, contract}, latest, &assetIDs)
+	if err != nil {
+		t.Fatalf("failed to get accounts: %v", err)
+	}
+	want := []*AccountQueryResult{
+		{
+			Address:       accounts[0].addr,
+			Nonce:         1,
+			Balance:       (*hexutil.Big)(statedb.GetBalance(accounts[0].addr)),
+			CodeHash:      types.EmptyCodeHash,
+			StorageRoot:   statedb.GetStorageRoot(accounts[0].addr), // Multicoin balances are kept in storage
+			AssetBalances: map[ids.ID]*hexutil.Big{assetID: (*hexutil.Big)(big.NewInt(100))},
+		},
+		{
+			Address:       accounts[1].addr,
+			Balance:       (*hexutil.Big)(big.NewInt(1000)),
+			CodeHash:      types.EmptyCodeHash,
+			StorageRoot:   types.EmptyRootHash,
+			AssetBalances: map[ids.ID]*hexutil.Big{assetID: (*hexutil.Big)(new(big.Int))},
+		},
+		{
+			Address:       contract,
+			Balance:       (*hexutil.Big)(new(big.Int)),
+			CodeHash:      crypto.Keccak256Hash(code),
+			StorageRoot:   statedb.GetStorageRoot(contract),
+			AssetBalances: map[ids.ID]*hexutil.Big{assetID: (*hexutil.Big)(new(big.Int))},
+		},
+	}
+	have, _ := json.Marshal(results)
+	wantJSON, _ := json.Marshal(want)
+	if string(have) != string(wantJSON) {
+		t.Fatalf("accounts mismatch\nhave: %s\nwant: %s", have, wantJSON)
+	}
+	if want[0].StorageRoot == types.EmptyRootHash || want[2].StorageRoot == types.EmptyRootHash {
+		t.Fatal("expected non-empty storage roots")
+	}
+
+	// The single account variant omits asset balances unless requested
+	result, err := api.GetAccount(context.Background(), accounts[0].addr, latest, nil)
+	if err != nil {
+		t.Fatalf("failed to get account: %v", err)
+	}
+	if result.AssetBalances != nil || result.Nonce != 1 {
+		t.Fatalf("unexpected account: %+v", result)
+	}
+	if _, err := api.GetAccounts(context.Background(), make([]common.Address, maxGetAccountsAddresses+1), latest, nil); err == nil {
+		t.Fatal("expected error querying too many addresses")
+	}
+}
+
+func TestSimulateV1(t *testing.T) {
+	t.Parallel()
+	// Initialize test accounts
+	var (
+		accounts = newAccounts(2)
+		genesis  = &core.Genesis{
+			Config: params.TestChainConfig,
+			Alloc: core.GenesisAlloc{
+				accounts[0].addr: {Balance: big.NewInt(params.Ether)},
+			},
+		}
+		contract = common.HexToAddress("0xc0de")
+		// Stores the first calldata word and emits an empty log if called with
+		// calldata, otherwise returns the stored word.
+		code = hex2Bytes("36600f5760005460005260206000f35b60003560005560006000a000")
+		gas  = hexutil.Uint64(100000)
+	)
+	api := NewBlockChainAPI(newTestBackend(t, 1, genesis, dummy.NewCoinbaseFaker(), func(i int, b *core.BlockGen) {}))
+
+	stored := hexutil.Bytes(common.HexToHash("0x2a").Bytes())
+	newOpts := func(nonce *hexutil.Uint64, validation bool) simOpts {
+		return simOpts{
+			BlockStateCalls: []simBlock{
+				{
+					StateOverrides: &StateOverride{contract: OverrideAccount{Code: code}},
+					Calls:          []TransactionArgs{{From: &accounts[0].addr, To: &contract, Input: &stored, Gas: &gas}},
+				},
+				{
+					Calls: []TransactionArgs{{From: &accounts[0].addr, To: &contract, Gas: &gas, Nonce: nonce}},
+				},
+			},
+			Validation: validation,
+		}
+	}
+	results, err := api.SimulateV1(context.Background(), newOpts(nil, true), nil)
+	if err != nil {
+		t.Fatalf("failed to simulate: %v", err)
+	}
+	if len(results) != 2 {
+		t.Fatalf("block count mismatch: have %d, want 2", len(results))
+	}
+	first, second := results[0], results[1]
+	if have := first["number"].(*hexutil.Big).ToInt().Uint64(); have != 2 {
+		t.Fatalf("first block number mismatch: have %d, want 2", have)
+	}
+	if second["parentHash"] != first["hash"] {
+		t.Fatalf("simulated blocks are not chained: have %v, want %v", second["parentHash"], first["hash"])
+	}
+	if first["baseFeePerGas"] == nil {
+		t.Fatal("simulated block is missing a base fee")
+	}
+
+	// The first call stores the word and emits a log, the second one, in the
+	// next block, reads the stored word back.
+	store := first["calls"].([]simCallResult)[0]
+	if store.Status != hexutil.Uint64(types.ReceiptStatusSuccessful) || store.Error != nil {
+		t.Fatalf("store call failed: %+v", store.Error)
+	}
+	if len(store.Logs) != 1 || store.Logs[0].Address != contract || store.Logs[0].BlockHash != first["hash"] {
+		t.Fatalf("unexpected store call logs: %+v", store.Logs)
+	}
+	load := second["calls"].([]simCallResult)[0]
+	if !bytes.Equal(load.ReturnValue, stored) {
+		t.Fatalf("return value mismatch: have %x, want %x", load.ReturnValue, stored)
+	}
+	if load.GasUsed == 0 {
+		t.Fatal("load call used no gas")
+	}
+
+	// With validation enabled, the nonce of the sender is checked and carried
+	// over from the previous calls
+	nonce := hexutil.Uint64(0)
+	if _, err := api.SimulateV1(context.Background(), newOpts(&nonce, true), nil); !errors.Is(err, core.ErrNonceTooLow) {
+		t.Fatalf("error mismatch: have %v, want %v", err, core.ErrNonceTooLow)
+	}
+	// Without validation the nonce is ignored
+	if _, err := api.SimulateV1(context.Background(), newOpts(&nonce, false), nil); err != nil {
+		t.Fatalf("failed to simulate without validation: %v", err)
+	}
+}
+
+func TestSimulateV1Limits(t *testing.T) {
+	t.Parallel()
+	var (
+		accounts = newAccounts(2)
+		genesis  = &core.Genesis{
+			Config: params.TestChainConfig,
+			Alloc: core.GenesisAlloc{
+				accounts[0].addr: {Balance: big.NewInt(params.Ether)},
+			},
+		}
+		latest = rpc.BlockNumberOrHashWithNumber(rpc.LatestBlockNumber)
+	)
+	backend := newTestBackend(t, 1, genesis, dummy.NewCoinbaseFaker(), func(i int, b *core.BlockGen) {})
+	api := NewBlockChainAPI(backend)
+
+	// Gaps in block numbers close to the maximum do not overflow the limit
+	far := (*hexutil.Big)(new(big.Int).SetUint64(math.MaxUint64))
+	opts := simOpts{BlockStateCalls: []simBlock{{BlockOverrides: &BlockOverrides{Number: far}}}}
+	if _, err := api.SimulateV1(context.Background(), opts, &latest); err == nil || !strings.Contains(err.Error(), "too many blocks") {
+		t.Fatalf("error mismatch: have %v, want too many blocks", err)
+	}
+
+	// The RPC gas cap is shared by all the calls, the second transfer finding
+	// it exhausted
+	state, base, err := backend.StateAndHeaderByNumberOrHash(context.Background(), latest)
+	if err != nil {
+		t.Fatalf("failed to get state: %v", err)
+	}
+	sim := &simulator{
+		b:      backend,
+		state:  state,
+		base:   base,
+		config: backend.ChainConfig(),
+		gasCap: params.TxGas,
+	}
+	transfer := TransactionArgs{From: &accounts[0].addr, To: &accounts[1].addr, Value: (*hexutil.Big)(big.NewInt(1))}
+	blocks := []simBlock{{Calls: []TransactionArgs{transfer}}, {Calls: []TransactionArgs{transfer}}}
+	if _, err := sim.execute(context.Background(), blocks); !errors.Is(err, errSimulateGasCapExhausted) {
+		t.Fatalf("error mismatch: have %v, want %v", err, errSimulateGasCapExhausted)
+	}
+	if sim.gasUsed != params.TxGas {
+		t.Fatalf("gas used mismatch: have %d, want %d", sim.gasUsed, params.TxGas)
+	}
+}
+
 func TestCall(t *testing.T) {
 	t.Parallel()
 	// Initialize test accounts
@@ -1494,6 +1803,60 @@ func setupReceiptBackend(t *testing.T, genBlocks int) (*testBackend, []common.Ha
 	return backend, txHashes
 }
 
+// historyPrunedBackend is a testBackend whose blocks before [tail] have had
+// their bodies expired.
+type historyPrunedBackend struct {
+	*testBackend
+	tail uint64
+}
+
+func (b historyPrunedBackend) BlockByNumber(ctx context.Context, number rpc.BlockNumber) (*types.Block, error) {
+	if number >= 0 && uint64(number) < b.tail {
+		return nil, core.ErrHistoryPruned
+	}
+	return b.testBackend.BlockByNumber(ctx, number)
+}
+
+func (b historyPrunedBackend) BlockByHash(ctx context.Context, hash common.Hash) (*types.Block, error) {
+	if header := b.chain.GetHeaderByHash(hash); header != nil && header.Number.Uint64() < b.tail {
+		return nil, core.ErrHistoryPruned
+	}
+	return b.testBackend.BlockByHash(ctx, hash)
+}
+
+func TestRPCGetBlockHistoryPruned(t *testing.T) {
+	t.Parallel()
+
+	var (
+		genBlocks  = 6
+		backend, _ = setupReceiptBackend(t, genBlocks)
+		api        = NewBlockChainAPI(historyPrunedBackend{testBackend: backend, tail: 3})
+		ctx        = context.Background()
+	)
+	for i := 0; i <= genBlocks; i++ {
+		header := backend.chain.GetHeaderByNumber(uint64(i))
+		byNumber, err := api.GetBlockByNumber(ctx, rpc.BlockNumber(i), false)
+		require.NoError(t, err)
+		byHash, err := api.GetBlockByHash(ctx, header.Hash(), false)
+		require.NoError(t, err)
+		require.Equal(t, byNumber, byHash)
+		require.Equal(t, header.Hash(), byNumber["hash"])
+
+		_, err = api.GetBlockByNumber(ctx, rpc.BlockNumber(i), true)
+		_, hashErr := api.GetBlockByHash(ctx, header.Hash(), true)
+		if i < 3 {
+			// Only the header is available for the expired blocks.
+			require.NotContains(t, byNumber, "transactions")
+			require.ErrorIs(t, err, core.ErrHistoryPruned)
+			require.ErrorIs(t, hashErr, core.ErrHistoryPruned)
+		} else {
+			require.Contains(t, byNumber, "transactions")
+			require.NoError(t, err)
+			require.NoError(t, hashErr)
+		}
+	}
+}
+
 func TestRPCGetTransactionReceipt(t *testing.T) {
 	t.Parallel()
 
@@ -34,6 +34,10 @@ import (
 	"github.com/ethereum/go-ethereum/common/hexutil"
 )
 
+// errCodeVMError is the JSON error code of a call that failed with an EVM
+// error other than a revert.
+const errCodeVMError = -32015
+
 // revertError is an API error that encompasses an EVM revert with JSON error
 // code and a binary data blob.
 type revertError struct {
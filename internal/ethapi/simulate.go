@@ -0,0 +1,449 @@
+// (c) 2024, Ava Labs, Inc. All rights reserved.
+// See the file LICENSE for licensing terms.
+
+package ethapi
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"math/big"
+	"time"
+
+	"github.com/ava-labs/coreth/consensus/dummy"
+	"github.com/ava-labs/coreth/core"
+	"github.com/ava-labs/coreth/core/state"
+	"github.com/ava-labs/coreth/core/types"
+	"github.com/ava-labs/coreth/core/vm"
+	"github.com/ava-labs/coreth/params"
+	"github.com/ava-labs/coreth/rpc"
+	"github.com/ava-labs/coreth/trie"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/crypto"
+)
+
+const (
+	// maxSimulateBlocks is the maximum number of blocks that can be simulated
+	// in a single eth_simulateV1 request, including the ones filling gaps.
+	maxSimulateBlocks = 256
+
+	// simulateTimestampIncrement is the default increment between the
+	// timestamps of consecutive simulated blocks.
+	simulateTimestampIncrement = 2
+)
+
+// errSimulateGasCapExhausted is returned if the calls of an eth_simulateV1
+// request use up the RPC gas cap before all of them are run.
+var errSimulateGasCapExhausted = errors.New("RPC gas cap exhausted")
+
+// simBlock is a batch of calls to be simulated within a single block.
+type simBlock struct {
+	BlockOverrides *BlockOverrides   `json:"blockOverrides"`
+	StateOverrides *StateOverride    `json:"stateOverrides"`
+	Calls          []TransactionArgs `json:"calls"`
+}
+
+// simOpts are the inputs to eth_simulateV1.
+type simOpts struct {
+	BlockStateCalls        []simBlock `json:"blockStateCalls"`
+	Validation             bool       `json:"validation"`
+	ReturnFullTransactions bool       `json:"returnFullTransactions"`
+}
+
+// simCallError is the error of a failed simulated call.
+type simCallError struct {
+	Message string `json:"message"`
+	Code    int    `json:"code"`
+	Data    string `json:"data,omitempty"`
+}
+
+// simCallResult is the result of a single simulated call.
+type simCallResult struct {
+	ReturnValue hexutil.Bytes  `json:"returnData"`
+	Logs        []*types.Log   `json:"logs"`
+	GasUsed     hexutil.Uint64 `json:"gasUsed"`
+	Status      hexutil.Uint64 `json:"status"`
+	Error       *simCallError  `json:"error,omitempty"`
+}
+
+// simulator executes batches of calls in a sequence of blocks built on top of
+// a base block, carrying the state over between calls and blocks.
+type simulator struct {
+	b        Backend
+	state    *state.StateDB
+	base     *types.Header
+	config   *params.ChainConfig
+	gasCap   uint64 // RPC gas cap shared by all calls, 0 if unlimited
+	gasUsed  uint64 // Gas used by the calls simulated so far
+	timeout  time.Duration
+	validate bool
+	fullTx   bool
+
+	headers []*types.Header // Headers of the blocks simulated so far
+}
+
+// SimulateV1 executes a series of blocks of calls on top of the given block,
+// carrying the state over between calls and blocks. Each block may override
+// header fields and state before its calls are run.
+//
+// If validation is requested, calls are subject to nonce and balance checks
+// and blocks derive their base fee from their parent under the dynamic fee
+// rules, as they would on chain.
+func (s *BlockChainAPI) SimulateV1(ctx context.Context, opts simOpts, blockNrOrHash *rpc.BlockNumberOrHash) ([]map[string]interface{}, error) {
+	if len(opts.BlockStateCalls) == 0 {
+		return nil, errors.New("empty input")
+	} else if len(opts.BlockStateCalls) > maxSimulateBlocks {
+		return nil, fmt.Errorf("too many blocks: %d > %d", len(opts.BlockStateCalls), maxSimulateBlocks)
+	}
+	if blockNrOrHash == nil {
+		latest := rpc.BlockNumberOrHashWithNumber(rpc.LatestBlockNumber)
+		blockNrOrHash = &latest
+	}
+	state, base, err := s.b.StateAndHeaderByNumberOrHash(ctx, *blockNrOrHash)
+	if state == nil || err != nil {
+		return nil, err
+	}
+	var (
+		cancel  context.CancelFunc
+		timeout = s.b.RPCEVMTimeout()
+	)
+	if timeout > 0 {
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+	} else {
+		ctx, cancel = context.WithCancel(ctx)
+	}
+	defer cancel()
+
+	sim := &simulator{
+		b:        s.b,
+		state:    state,
+		base:     base,
+		config:   s.b.ChainConfig(),
+		gasCap:   s.b.RPCGasCap(),
+		timeout:  timeout,
+		validate: opts.Validation,
+		fullTx:   opts.ReturnFullTransactions,
+	}
+	return sim.execute(ctx, opts.BlockStateCalls)
+}
+
+// execute runs the requested blocks, filling any gaps in block numbers with
+// empty blocks, and returns the marshalled simulated blocks.
+func (sim *simulator) execute(ctx context.Context, blocks []simBlock) ([]map[string]interface{}, error) {
+	blocks, err := sim.sanitizeChain(blocks)
+	if err != nil {
+		return nil, err
+	}
+	results := make([]map[string]interface{}, 0, len(blocks))
+	for i := range blocks {
+		result, err := sim.processBlock(ctx, &blocks[i])
+		if err != nil {
+			return nil, err
+		}
+		results = append(results, result)
+	}
+	return results, nil
+}
+
+// sanitizeChain assigns block numbers and timestamps to the blocks that do not
+// override them, checks that both are increasing and inserts empty blocks into
+// gaps between block numbers.
+func (sim *simulator) sanitizeChain(blocks []simBlock) ([]simBlock, error) {
+	var (
+		res      = make([]simBlock, 0, len(blocks))
+		prevNum  = sim.base.Number.Uint64()
+		prevTime = sim.base.Time
+	)
+	for _, block := range blocks {
+		if block.BlockOverrides == nil {
+			block.BlockOverrides = new(BlockOverrides)
+		}
+		if block.BlockOverrides.Number == nil {
+			n := new(big.Int).SetUint64(prevNum + 1)
+			block.BlockOverrides.Number = (*hexutil.Big)(n)
+		}
+		number := block.BlockOverrides.Number.ToInt()
+		if !number.IsUint64() || number.Uint64() <= prevNum {
+			return nil, fmt.Errorf("block numbers must be in order: %d <= %d", number, prevNum)
+		}
+		// Fill the gap between the previous and this block with empty blocks,
+		// bounding it before computing the number of blocks to avoid overflows
+		if gap := number.Uint64() - prevNum - 1; gap > 0 {
+			if used := uint64(len(res) + len(blocks)); used >= maxSimulateBlocks || gap > maxSimulateBlocks-used {
+				return nil, fmt.Errorf("too many blocks: gap of %d blocks before block %d exceeds the limit of %d", gap, number, maxSimulateBlocks)
+			}
+			if prevTime > math.MaxUint64-(gap+1)*simulateTimestampIncrement {
+				return nil, fmt.Errorf("block timestamp overflow before block %d", number)
+			}
+			for i := uint64(1); i <= gap; i++ {
+				n := new(big.Int).SetUint64(prevNum + i)
+				t := hexutil.Uint64(prevTime + i*simulateTimestampIncrement)
+				res = append(res, simBlock{BlockOverrides: &BlockOverrides{Number: (*hexutil.Big)(n), Time: &t}})
+			}
+			prevTime += gap * simulateTimestampIncrement
+		}
+		if block.BlockOverrides.Time == nil {
+			if prevTime > math.MaxUint64-simulateTimestampIncrement {
+				return nil, fmt.Errorf("block timestamp overflow at block %d", number)
+			}
+			t := hexutil.Uint64(prevTime + simulateTimestampIncrement)
+			block.BlockOverrides.Time = &t
+		}
+		if uint64(*block.BlockOverrides.Time) < prevTime {
+			return nil, fmt.Errorf("block timestamps must be in order: %d < %d", *block.BlockOverrides.Time, prevTime)
+		}
+		prevNum, prevTime = number.Uint64(), uint64(*block.BlockOverrides.Time)
+		res = append(res, block)
+	}
+	return res, nil
+}
+
+// makeHeader assembles the header of the next simulated block on top of the
+// previous one, applying the block overrides.
+func (sim *simulator) makeHeader(overrides *BlockOverrides) (*types.Header, error) {
+	parent := sim.base
+	if len(sim.headers) > 0 {
+		parent = sim.headers[len(sim.headers)-1]
+	}
+	header := &types.Header{
+		ParentHash: parent.Hash(),
+		Coinbase:   parent.Coinbase,
+		Difficulty: common.Big1,
+		Number:     overrides.Number.ToInt(),
+		GasLimit:   parent.GasLimit,
+		Time:       uint64(*overrides.Time),
+		UncleHash:  types.EmptyUncleHash,
+	}
+	if sim.config.IsApricotPhase3(header.Time) {
+		extra, baseFee, err := dummy.CalcBaseFee(sim.config, parent, header.Time)
+		if err != nil {
+			return nil, fmt.Errorf("failed to calculate base fee of block %d: %w", header.Number, err)
+		}
+		header.Extra, header.BaseFee = extra, baseFee
+	}
+	if sim.config.IsApricotPhase4(header.Time) {
+		header.ExtDataGasUsed = new(big.Int)
+		header.BlockGasCost = new(big.Int)
+	}
+	if overrides.Difficulty != nil {
+		header.Difficulty = overrides.Difficulty.ToInt()
+	}
+	if overrides.GasLimit != nil {
+		header.GasLimit = uint64(*overrides.GasLimit)
+	}
+	if overrides.Coinbase != nil {
+		header.Coinbase = *overrides.Coinbase
+	}
+	if overrides.BaseFee != nil {
+		if sim.validate {
+			return nil, fmt.Errorf("block %d: base fee cannot be overridden with validation enabled", header.Number)
+		}
+		header.BaseFee = overrides.BaseFee.ToInt()
+	}
+	return header, nil
+}
+
+// processBlock executes the calls of a single block and returns the resulting
+// block marshalled along with the results of its calls.
+func (sim *simulator) processBlock(ctx context.Context, block *simBlock) (map[string]interface{}, error) {
+	header, err := sim.makeHeader(block.BlockOverrides)
+	if err != nil {
+		return nil, err
+	}
+	if err := block.StateOverrides.Apply(sim.state); err != nil {
+		return nil, err
+	}
+	var (
+		gp       = new(core.GasPool).AddGas(header.GasLimit)
+		txs      = make([]*types.Transaction, len(block.Calls))
+		senders  = make(map[common.Hash]common.Address, len(block.Calls))
+		receipts = make([]*types.Receipt, len(block.Calls))
+		calls    = make([]simCallResult, len(block.Calls))
+		gasUsed  uint64
+	)
+	blockCtx := core.NewEVMBlockContext(header, NewChainContext(ctx, sim.b), nil)
+	blockCtx.GetHash = sim.getHash(ctx)
+	if block.BlockOverrides.BlobBaseFee != nil {
+		blockCtx.BlobBaseFee = block.BlockOverrides.BlobBaseFee.ToInt()
+	}
+	for i := range block.Calls {
+		call := &block.Calls[i]
+		if err := sim.sanitizeCall(call, header, gasUsed); err != nil {
+			return nil, fmt.Errorf("block %d call %d: %w", header.Number, i, err)
+		}
+		tx := call.ToTransaction()
+		txs[i], senders[tx.Hash()] = tx, call.from()
+
+		// The RPC gas cap is a budget for all the calls, ToMessage treating a
+		// zero cap as unlimited
+		var gasCap uint64
+		if sim.gasCap != 0 {
+			if sim.gasUsed >= sim.gasCap {
+				return nil, fmt.Errorf("block %d call %d: %w", header.Number, i, errSimulateGasCapExhausted)
+			}
+			gasCap = sim.gasCap - sim.gasUsed
+		}
+		msg, err := call.ToMessage(gasCap, header.BaseFee)
+		if err != nil {
+			return nil, fmt.Errorf("block %d call %d: %w", header.Number, i, err)
+		}
+		msg.Nonce, msg.SkipAccountChecks = uint64(*call.Nonce), !sim.validate
+
+		sim.state.SetTxContext(tx.Hash(), i)
+		evm := sim.b.GetEVM(ctx, msg, sim.state, header, &vm.Config{NoBaseFee: !sim.validate}, &blockCtx)
+		result, err := sim.applyMessage(ctx, evm, msg, gp)
+		if err != nil {
+			return nil, fmt.Errorf("block %d call %d: %w", header.Number, i, err)
+		}
+		if err := sim.state.Error(); err != nil {
+			return nil, err
+		}
+		sim.gasUsed += result.UsedGas
+		gasUsed += result.UsedGas
+		sim.state.Finalise(true)
+
+		receipt := &types.Receipt{
+			Type:              tx.Type(),
+			CumulativeGasUsed: gasUsed,
+			TxHash:            tx.Hash(),
+			GasUsed:           result.UsedGas,
+			TransactionIndex:  uint(i),
+		}
+		if result.Failed() {
+			receipt.Status = types.ReceiptStatusFailed
+		} else {
+			receipt.Status = types.ReceiptStatusSuccessful
+		}
+		if msg.To == nil {
+			receipt.ContractAddress = crypto.CreateAddress(msg.From, tx.Nonce())
+		}
+		receipt.Logs = sim.state.GetLogs(tx.Hash(), header.Number.Uint64(), common.Hash{})
+		receipt.Bloom = types.CreateBloom(types.Receipts{receipt})
+		receipts[i] = receipt
+
+		calls[i] = simCallResult{
+			ReturnValue: result.Return(),
+			Logs:        receipt.Logs,
+			GasUsed:     hexutil.Uint64(result.UsedGas),
+			Status:      hexutil.Uint64(receipt.Status),
+		}
+		if revert := result.Revert(); len(revert) > 0 {
+			err := newRevertError(revert)
+			calls[i].ReturnValue = nil
+			calls[i].Error = &simCallError{Message: err.Error(), Code: err.ErrorCode(), Data: err.reason}
+		} else if result.Err != nil {
+			calls[i].Error = &simCallError{Message: result.Err.Error(), Code: errCodeVMError}
+		}
+		if calls[i].Logs == nil {
+			calls[i].Logs = []*types.Log{}
+		}
+	}
+	header.GasUsed = gasUsed
+	header.Root = sim.state.IntermediateRoot(sim.config.IsEIP158(header.Number))
+
+	simulated := types.NewBlock(header, txs, nil, receipts, trie.NewStackTrie(nil))
+	sim.headers = append(sim.headers, simulated.Header())
+
+	// Now that the block hash is known, fill it into the emitted logs
+	for _, receipt := range receipts {
+		for _, log := range receipt.Logs {
+			log.BlockHash = simulated.Hash()
+		}
+	}
+	fields := RPCMarshalBlock(simulated, true, sim.fullTx, sim.config)
+	if sim.fullTx {
+		// Simulated transactions are unsigned, fill in the senders of the calls
+		for _, tx := range fields["transactions"].([]interface{}) {
+			tx := tx.(*RPCTransaction)
+			tx.From = senders[tx.Hash]
+		}
+	}
+	fields["calls"] = calls
+	return fields, nil
+}
+
+// sanitizeCall fills in the defaults of a simulated call from the current
+// state and the gas remaining in the block.
+func (sim *simulator) sanitizeCall(call *TransactionArgs, header *types.Header, gasUsed uint64) error {
+	if call.Nonce == nil {
+		nonce := hexutil.Uint64(sim.state.GetNonce(call.from()))
+		call.Nonce = &nonce
+	}
+	if call.Gas == nil {
+		remaining := hexutil.Uint64(header.GasLimit - gasUsed)
+		call.Gas = &remaining
+	}
+	if gasUsed+uint64(*call.Gas) > header.GasLimit {
+		return fmt.Errorf("block gas limit reached: %d >= %d", gasUsed, header.GasLimit)
+	}
+	if call.ChainID == nil {
+		call.ChainID = (*hexutil.Big)(sim.config.ChainID)
+	}
+	if call.Value == nil {
+		call.Value = new(hexutil.Big)
+	}
+	switch {
+	case call.GasPrice != nil && (call.MaxFeePerGas != nil || call.MaxPriorityFeePerGas != nil):
+		return errors.New("both gasPrice and (maxFeePerGas or maxPriorityFeePerGas) specified")
+	case call.GasPrice != nil:
+	case header.BaseFee == nil:
+		call.GasPrice = new(hexutil.Big)
+	default:
+		// Pay exactly the base fee when validating, nothing otherwise
+		if call.MaxFeePerGas == nil {
+			call.MaxFeePerGas = new(hexutil.Big)
+			if sim.validate {
+				call.MaxFeePerGas = (*hexutil.Big)(new(big.Int).Set(header.BaseFee))
+			}
+		}
+		if call.MaxPriorityFeePerGas == nil {
+			call.MaxPriorityFeePerGas = new(hexutil.Big)
+		}
+	}
+	return nil
+}
+
+// getHash returns a block hash resolver that resolves the simulated blocks as
+// well as the canonical chain up to the base block.
+func (sim *simulator) getHash(ctx context.Context) vm.GetHashFunc {
+	return func(number uint64) common.Hash {
+		base := sim.base.Number.Uint64()
+		if number == base {
+			return sim.base.Hash()
+		}
+		if number > base {
+			for _, header := range sim.headers {
+				if header.Number.Uint64() == number {
+					return header.Hash()
+				}
+			}
+			return common.Hash{}
+		}
+		header, err := sim.b.HeaderByNumber(ctx, rpc.BlockNumber(number))
+		if err != nil || header == nil {
+			return common.Hash{}
+		}
+		return header.Hash()
+	}
+}
+
+// applyMessage executes [msg] in [evm], aborting the execution if the context
+// is cancelled.
+func (sim *simulator) applyMessage(ctx context.Context, evm *vm.EVM, msg *core.Message, gp *core.GasPool) (*core.ExecutionResult, error) {
+	done := make(chan struct{})
+	defer close(done)
+	go func() {
+		select {
+		case <-ctx.Done():
+			evm.Cancel()
+		case <-done:
+		}
+	}()
+	result, err := core.ApplyMessage(evm, msg, gp)
+	if evm.Cancelled() {
+		return nil, fmt.Errorf("execution aborted (timeout = %v)", sim.timeout)
+	}
+	return result, err
+}
@@ -4,11 +4,14 @@
 package evm
 
 import (
+	"errors"
 	"fmt"
 	"net/http"
 
 	"github.com/ava-labs/avalanchego/api"
 	"github.com/ava-labs/avalanchego/utils/profiler"
+	"github.com/ava-labs/coreth/core/state/verifier"
+	"github.com/ethereum/go-ethereum/common"
 	"github.com/ethereum/go-ethereum/log"
 )
 
@@ -89,3 +92,68 @@ func (p *Admin) GetVMConfig(_ *http.Request, _ *struct{}, reply *ConfigReply) er
 	reply.Config = &p.vm.config
 	return nil
 }
+
+type ExportStateArgs struct {
+	Path string `json:"path"`
+}
+
+// ExportState starts exporting the state at the last height committed by the
+// atomic trie to the file at [args.Path]. The export runs in the background
+// and its progress is reported by ExportStateStatus. It fails while online
+// pruning is running.
+func (p *Admin) ExportState(_ *http.Request, args *ExportStateArgs, _ *api.EmptyReply) error {
+	log.Info("EVM: ExportState called", "path", args.Path)
+
+	p.vm.ctx.Lock.Lock()
+	defer p.vm.ctx.Lock.Unlock()
+
+	if args.Path == "" {
+		return errors.New("path must be set")
+	}
+	return p.vm.startStateExport(args.Path)
+}
+
+type ExportStateStatusReply struct {
+	Status StateExportStatus `json:"status"`
+}
+
+// ExportStateStatus returns the status of the last state export.
+func (p *Admin) ExportStateStatus(_ *http.Request, _ *struct{}, reply *ExportStateStatusReply) error {
+	reply.Status = p.vm.stateExportStatus()
+	return nil
+}
+
+type VerifyAtomicTrieArgs struct {
+	Root common.Hash `json:"root"`
+}
+
+// VerifyAtomicTrie starts verifying in the background that every node of the
+// atomic trie with [args.Root] is present and hashes correctly. If the root is
+// empty, the last committed atomic trie is verified. The progress and the
+// missing or corrupt nodes found are reported by VerifyAtomicTrieStatus.
+func (p *Admin) VerifyAtomicTrie(_ *http.Request, args *VerifyAtomicTrieArgs, _ *api.EmptyReply) error {
+	log.Info("EVM: VerifyAtomicTrie called", "root", args.Root)
+
+	p.vm.ctx.Lock.Lock()
+	defer p.vm.ctx.Lock.Unlock()
+
+	return p.vm.startAtomicTrieVerification(args.Root)
+}
+
+type VerifyAtomicTrieStatusReply struct {
+	Status verifier.Status `json:"status"`
+}
+
+// VerifyAtomicTrieStatus returns the progress of the current or last atomic
+// trie verification.
+func (p *Admin) VerifyAtomicTrieStatus(_ *http.Request, _ *struct{}, reply *VerifyAtomicTrieStatusReply) error {
+	reply.Status = p.vm.atomicTrieVerifier.Status()
+	return nil
+}
+
+// CancelVerifyAtomicTrie interrupts the running atomic trie verification.
+func (p *Admin) CancelVerifyAtomicTrie(_ *http.Request, _ *struct{}, _ *api.EmptyReply) error {
+	log.Info("EVM: CancelVerifyAtomicTrie called")
+
+	return p.vm.atomicTrieVerifier.Cancel()
+}
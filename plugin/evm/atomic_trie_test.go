@@ -6,6 +6,7 @@ package evm
 import (
 	"encoding/binary"
 	"testing"
+	"time"
 
 	"github.com/prometheus/client_golang/prometheus"
 
@@ -20,6 +21,8 @@ import (
 	"github.com/ava-labs/avalanchego/utils/logging"
 	"github.com/ava-labs/avalanchego/utils/wrappers"
 
+	"github.com/ava-labs/coreth/core/state/verifier"
+
 	"github.com/ethereum/go-ethereum/common"
 )
 
@@ -410,6 +413,32 @@ func TestIndexingNilShouldNotImpactTrie(t *testing.T) {
 	assert.Equal(t, root1, root2)
 }
 
+func TestVerifyAtomicTrie(t *testing.T) {
+	vm := &VM{atomicTrie: newTestAtomicTrie(t), atomicTrieVerifier: verifier.New()}
+	defer vm.atomicTrieVerifier.Stop()
+	for height := uint64(1); height <= testCommitInterval+5; height++ {
+		if err := indexAtomicTxs(vm.atomicTrie, height, testDataImportTx().mustAtomicOps()); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// An empty root verifies the last committed atomic trie.
+	if err := vm.startAtomicTrieVerification(common.Hash{}); err != nil {
+		t.Fatal(err)
+	}
+	var status verifier.Status
+	assert.Eventually(t, func() bool {
+		status = vm.atomicTrieVerifier.Status()
+		return !status.Running
+	}, 10*time.Second, time.Millisecond)
+	root, _ := vm.atomicTrie.LastCommitted()
+	assert.Equal(t, "atomic", status.Trie)
+	assert.Equal(t, root, status.Root)
+	assert.Equal(t, uint64(testCommitInterval), status.Leaves)
+	assert.Empty(t, status.Problems)
+	assert.Empty(t, status.Error)
+}
+
 type sharedMemories struct {
 	thisChain   atomic.SharedMemory
 	peerChain   atomic.SharedMemory
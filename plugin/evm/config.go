@@ -8,6 +8,7 @@ import (
 	"fmt"
 	"time"
 
+	"github.com/ava-labs/coreth/core/rawdb"
 	"github.com/ava-labs/coreth/core/txpool/legacypool"
 	"github.com/ava-labs/coreth/eth"
 	"github.com/ethereum/go-ethereum/common"
@@ -43,14 +44,20 @@ const (
 	defaultPushGossipFrequency                        = 100 * time.Millisecond
 	defaultPullGossipFrequency                        = 1 * time.Second
 	defaultTxRegossipFrequency                        = 30 * time.Second
+	defaultTxPoolRejournal                            = time.Minute
 	defaultOfflinePruningBloomFilterSize       uint64 = 512 // Default size (MB) for the offline pruner to use
+	defaultOnlinePruningBloomFilterSize               = defaultOfflinePruningBloomFilterSize
+	defaultOnlinePruningSweepRate                     = 10_000 // trie nodes per second
 	defaultLogLevel                                   = "info"
 	defaultLogJSONFormat                              = false
 	defaultMaxOutboundActiveRequests                  = 16
 	defaultMaxOutboundActiveCrossChainRequests        = 64
 	defaultPopulateMissingTriesParallelism            = 1024
-	defaultStateSyncServerTrieCache                   = 64 // MB
-	defaultAcceptedCacheSize                          = 32 // blocks
+	defaultStateSyncServerTrieCache                   = 64      // MB
+	defaultAcceptedCacheSize                          = 32      // blocks
+	defaultAncientStoreDistance                       = 90_000  // blocks
+	defaultStateSizeStatsRetention                    = 100_000 // blocks
+	defaultHistoricalStateCache                       = 256     // MB
 
 	// defaultStateSyncMinBlocks is the minimum number of blocks the blockchain
 	// should be ahead of local last accepted to perform state sync.
@@ -126,6 +133,13 @@ type Config struct {
 	PopulateMissingTriesParallelism int     `json:"populate-missing-tries-parallelism"` // Number of concurrent readers to use when re-populating missing tries on startup.
 	PruneWarpDB                     bool    `json:"prune-warp-db-enabled"`              // Determines if the warpDB should be cleared on startup
 
+	// StateScheme selects how state trie nodes are stored on disk, either
+	// keyed by hash ("hash") or by their path in the trie ("path"). The path
+	// scheme only keeps the recent states, so disk usage does not grow with
+	// the chain. Defaults to the scheme of the existing database, or "hash"
+	// for an empty one. The scheme of an existing database cannot be changed.
+	StateScheme string `json:"state-scheme"`
+
 	// Metric Settings
 	MetricsExpensiveEnabled bool `json:"metrics-expensive-enabled"` // Debug-level metrics that might impact runtime performance
 
@@ -140,6 +154,19 @@ type Config struct {
 	TxPoolGlobalQueue  uint64   `json:"tx-pool-global-queue"`
 	TxPoolLifetime     Duration `json:"tx-pool-lifetime"`
 
+	// Tx Pool Journal Settings
+	TxPoolRemoteJournal             bool     `json:"tx-pool-remote-journal-enabled"`       // If enabled, remote and atomic mempool txs are periodically snapshotted to disk and reloaded on startup
+	TxPoolRemoteJournalSlots        uint64   `json:"tx-pool-remote-journal-slots"`         // Maximum number of remote txs snapshotted to disk
+	TxPoolRemoteJournalAccountSlots uint64   `json:"tx-pool-remote-journal-account-slots"` // Maximum number of remote txs snapshotted to disk per account
+	TxPoolRejournal                 Duration `json:"tx-pool-rejournal"`                    // Time interval to regenerate the tx pool journals
+
+	// Tx Pool Admission Control Settings
+	TxPoolSenderRateLimit  float64  `json:"tx-pool-sender-rate-limit"` // Transactions per second accepted from a single sender (0 = unlimited)
+	TxPoolPeerRateLimit    float64  `json:"tx-pool-peer-rate-limit"`   // Transactions per second accepted from a single gossiping peer (0 = unlimited)
+	TxPoolRateLimitBurst   uint64   `json:"tx-pool-rate-limit-burst"`  // Number of transactions a sender or peer may submit in a burst
+	TxPoolSpamThreshold    float64  `json:"tx-pool-spam-threshold"`    // Spam score at which a sender or peer gets throttled
+	TxPoolThrottleDuration Duration `json:"tx-pool-throttle-duration"` // Amount of time a sender or peer remains throttled
+
 	APIMaxDuration           Duration      `json:"api-max-duration"`
 	WSCPURefillRate          Duration      `json:"ws-cpu-refill-rate"`
 	WSCPUMaxStored           Duration      `json:"ws-cpu-max-stored"`
@@ -173,6 +200,15 @@ type Config struct {
 	OfflinePruningBloomFilterSize uint64 `json:"offline-pruning-bloom-filter-size"`
 	OfflinePruningDataDirectory   string `json:"offline-pruning-data-directory"`
 
+	// Online Pruning Settings
+	// OnlinePruning enables pruning the stale state in the background while
+	// the node keeps accepting blocks. Pruning is started with the
+	// admin_startOnlinePruning API, and an interrupted run resumes on startup.
+	OnlinePruning                bool    `json:"online-pruning-enabled"`
+	OnlinePruningBloomFilterSize uint64  `json:"online-pruning-bloom-filter-size"`
+	OnlinePruningDataDirectory   string  `json:"online-pruning-data-directory"`
+	OnlinePruningSweepRate       float64 `json:"online-pruning-sweep-rate"` // Maximum trie nodes deleted per second, unlimited if 0
+
 	// VM2VM network
 	MaxOutboundActiveRequests           int64 `json:"max-outbound-active-requests"`
 	MaxOutboundActiveCrossChainRequests int64 `json:"max-outbound-active-cross-chain-requests"`
@@ -186,9 +222,21 @@ type Config struct {
 	StateSyncMinBlocks       uint64 `json:"state-sync-min-blocks"`
 	StateSyncRequestSize     uint16 `json:"state-sync-request-size"`
 
+	// StateImportFile is the path of a state file written by the ExportState
+	// admin API or offline export, which is imported on startup if the chain
+	// has not accepted any block after genesis.
+	StateImportFile string `json:"state-import-file"`
+
 	// Database Settings
 	InspectDatabase bool `json:"inspect-database"` // Inspects the database on startup if enabled.
 
+	// AncientStore enables moving the headers, bodies and receipts of accepted
+	// blocks more than AncientStoreDistance blocks behind the last accepted
+	// block out of the key-value store into an append-only ancient store in the
+	// chain data directory.
+	AncientStore         bool   `json:"ancient-store-enabled"`
+	AncientStoreDistance uint64 `json:"ancient-store-distance"`
+
 	// SkipUpgradeCheck disables checking that upgrades must take place before the last
 	// accepted block. Skipping this check is useful when a node operator does not update
 	// their node before the network upgrade and their node accepts blocks that have
@@ -210,11 +258,50 @@ type Config struct {
 	// Deprecated, use 'TransactionHistory' instead.
 	TxLookupLimit uint64 `json:"tx-lookup-limit"`
 
+	// HistoryExpiry is the maximum number of blocks from last accepted whose
+	// bodies and receipts are reserved. Headers and canonical hashes are
+	// always kept, and the transaction indices are left to TransactionHistory:
+	//  * 0:   means no limit
+	//  * N:   means N block limit [LAST-N+1, LAST] and delete older bodies and receipts
+	HistoryExpiry uint64 `json:"history-expiry"`
+
 	// SkipTxIndexing skips indexing transactions.
 	// This is useful for validators that don't need to index transactions.
 	// TxLookupLimit can be still used to control unindexing old transactions.
 	SkipTxIndexing bool `json:"skip-tx-indexing"`
 
+	// TraceIndexEnabled enables indexing the addresses appearing in the call
+	// traces of accepted blocks, allowing trace_filter to skip unrelated blocks.
+	TraceIndexEnabled bool `json:"trace-index-enabled"`
+
+	// TokenIndexEnabled enables indexing the ERC-20 and ERC-721 token transfers
+	// of accepted blocks, which serves the tokens API.
+	TokenIndexEnabled bool `json:"token-index-enabled"`
+
+	// LogIndexEnabled enables indexing the log addresses and topics of accepted
+	// blocks, allowing eth_getLogs to serve long ranges without bloom scanning.
+	// Ranges covered by the index are not limited by MaxBlocksPerRequest.
+	LogIndexEnabled bool `json:"log-index-enabled"`
+
+	// StateSizeStatsEnabled enables maintaining the number of storage slots and
+	// the approximate storage size of every account, served by
+	// debug_stateSizeStats. The statistics are backfilled from the snapshot.
+	StateSizeStatsEnabled bool `json:"state-size-stats-enabled"`
+	// StateSizeStatsRetention is the number of recent blocks whose storage
+	// changes are kept to report the state growth over a block range, 0 keeps all.
+	StateSizeStatsRetention uint64 `json:"state-size-stats-retention"`
+
+	// HistoricalStateRegenLimit is the maximum number of blocks re-executed on
+	// top of the nearest committed state to regenerate the historical state
+	// requested by eth_call, tracing and the other state APIs, 0 disables the
+	// regeneration. The regenerated states are kept in memory, up to
+	// HistoricalStateCache MB, so queries for nearby heights re-execute fewer
+	// blocks. A regenerated state is kept in memory for at most one minute
+	// while a request uses it, so longer requests may fail with missing trie
+	// nodes.
+	HistoricalStateRegenLimit uint64 `json:"historical-state-regen-limit"`
+	HistoricalStateCache      int    `json:"historical-state-cache"`
+
 	// WarpOffChainMessages encodes off-chain messages (unrelated to any on-chain event ie. block or AddressedCall)
 	// that the node should be willing to sign.
 	// Note: only supports AddressedCall payloads as defined here:
@@ -244,6 +331,12 @@ func (c *Config) SetDefaults() {
 	c.TxPoolAccountQueue = legacypool.DefaultConfig.AccountQueue
 	c.TxPoolGlobalQueue = legacypool.DefaultConfig.GlobalQueue
 	c.TxPoolLifetime.Duration = legacypool.DefaultConfig.Lifetime
+	c.TxPoolRemoteJournalSlots = legacypool.DefaultConfig.RemoteJournalSlots
+	c.TxPoolRemoteJournalAccountSlots = legacypool.DefaultConfig.RemoteJournalAccountSlots
+	c.TxPoolRejournal.Duration = defaultTxPoolRejournal
+	c.TxPoolRateLimitBurst = legacypool.DefaultConfig.RateLimitBurst
+	c.TxPoolSpamThreshold = legacypool.DefaultConfig.SpamThreshold
+	c.TxPoolThrottleDuration.Duration = legacypool.DefaultConfig.ThrottleDuration
 
 	c.APIMaxDuration.Duration = defaultApiMaxDuration
 	c.WSCPURefillRate.Duration = defaultWsCpuRefillRate
@@ -269,6 +362,8 @@ func (c *Config) SetDefaults() {
 	c.PullGossipFrequency.Duration = defaultPullGossipFrequency
 	c.RegossipFrequency.Duration = defaultTxRegossipFrequency
 	c.OfflinePruningBloomFilterSize = defaultOfflinePruningBloomFilterSize
+	c.OnlinePruningBloomFilterSize = defaultOnlinePruningBloomFilterSize
+	c.OnlinePruningSweepRate = defaultOnlinePruningSweepRate
 	c.LogLevel = defaultLogLevel
 	c.LogJSONFormat = defaultLogJSONFormat
 	c.MaxOutboundActiveRequests = defaultMaxOutboundActiveRequests
@@ -280,6 +375,9 @@ func (c *Config) SetDefaults() {
 	c.StateSyncRequestSize = defaultStateSyncRequestSize
 	c.AllowUnprotectedTxHashes = defaultAllowUnprotectedTxHashes
 	c.AcceptedCacheSize = defaultAcceptedCacheSize
+	c.AncientStoreDistance = defaultAncientStoreDistance
+	c.StateSizeStatsRetention = defaultStateSizeStatsRetention
+	c.HistoricalStateCache = defaultHistoricalStateCache
 }
 
 func (d *Duration) UnmarshalJSON(data []byte) (err error) {
@@ -313,11 +411,74 @@ func (c *Config) Validate() error {
 	if !c.Pruning && c.OfflinePruning {
 		return fmt.Errorf("cannot run offline pruning while pruning is disabled")
 	}
+	if c.OnlinePruning {
+		if !c.Pruning {
+			return fmt.Errorf("cannot run online pruning while pruning is disabled")
+		}
+		if c.OfflinePruning {
+			return fmt.Errorf("cannot enable online pruning while offline pruning is enabled")
+		}
+		if c.OnlinePruningBloomFilterSize < 256 {
+			return fmt.Errorf("online-pruning-bloom-filter-size (%d) must be at least 256 MB", c.OnlinePruningBloomFilterSize)
+		}
+		if c.OnlinePruningDataDirectory == "" {
+			return fmt.Errorf("online-pruning-data-directory must be set when online pruning is enabled")
+		}
+		if c.OnlinePruningSweepRate < 0 {
+			return fmt.Errorf("online-pruning-sweep-rate (%f) must be non-negative", c.OnlinePruningSweepRate)
+		}
+	}
 	// If pruning is enabled, the commit interval must be non-zero so the node commits state tries every CommitInterval blocks.
 	if c.Pruning && c.CommitInterval == 0 {
 		return fmt.Errorf("cannot use commit interval of 0 with pruning enabled")
 	}
 
+	switch c.StateScheme {
+	case "", rawdb.HashScheme:
+	case rawdb.PathScheme:
+		// The path scheme only keeps the recent states and cannot be pruned
+		// offline, since it holds no stale trie nodes.
+		if !c.Pruning {
+			return fmt.Errorf("cannot use the %s state scheme while pruning is disabled", rawdb.PathScheme)
+		}
+		if c.OfflinePruning || c.OnlinePruning {
+			return fmt.Errorf("cannot run offline or online pruning with the %s state scheme", rawdb.PathScheme)
+		}
+	default:
+		return fmt.Errorf("state-scheme must be %q or %q, got %q", rawdb.HashScheme, rawdb.PathScheme, c.StateScheme)
+	}
+
+	if c.HistoryExpiry != 0 {
+		if c.AncientStore {
+			return fmt.Errorf("cannot enable history-expiry while ancient-store-enabled is set")
+		}
+		// Blocks since the last committed state are re-executed on startup, and
+		// blocks since the last state summary are served to syncing peers.
+		if c.HistoryExpiry < c.CommitInterval || c.HistoryExpiry < c.StateSyncCommitInterval {
+			return fmt.Errorf("history-expiry (%d) must be at least commit-interval (%d) and state-sync-commit-interval (%d)", c.HistoryExpiry, c.CommitInterval, c.StateSyncCommitInterval)
+		}
+	}
+
+	if c.AncientStore && c.AncientStoreDistance == 0 {
+		return fmt.Errorf("ancient-store-distance must be non-zero when ancient-store-enabled is set")
+	}
+
+	if c.StateSizeStatsEnabled && c.SnapshotCache <= 0 {
+		return fmt.Errorf("cannot enable state-size-stats-enabled while snapshots are disabled")
+	}
+
+	if c.HistoricalStateRegenLimit != 0 && c.HistoricalStateCache <= 0 {
+		return fmt.Errorf("historical-state-cache (%d) must be positive when historical-state-regen-limit is set", c.HistoricalStateCache)
+	}
+
+	if c.TxPoolRemoteJournal && c.TxPoolRejournal.Duration <= 0 {
+		return fmt.Errorf("tx-pool-rejournal must be positive when tx-pool-remote-journal-enabled is set, got %s", c.TxPoolRejournal)
+	}
+
+	if c.TxPoolSenderRateLimit < 0 || c.TxPoolPeerRateLimit < 0 {
+		return fmt.Errorf("tx-pool-sender-rate-limit (%f) and tx-pool-peer-rate-limit (%f) must be non-negative", c.TxPoolSenderRateLimit, c.TxPoolPeerRateLimit)
+	}
+
 	if c.PushGossipPercentStake < 0 || c.PushGossipPercentStake > 1 {
 		return fmt.Errorf("push-gossip-percent-stake is %f but must be in the range [0, 1]", c.PushGossipPercentStake)
 	}
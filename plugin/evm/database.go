@@ -7,6 +7,8 @@ import (
 	"errors"
 
 	"github.com/ava-labs/avalanchego/database"
+	"github.com/ava-labs/avalanchego/database/prefixdb"
+	"github.com/ethereum/go-ethereum/common"
 	"github.com/ethereum/go-ethereum/ethdb"
 )
 
@@ -62,3 +64,14 @@ func (batch Batch) ValueSize() int { return batch.Batch.Size() }
 
 // Replay implements ethdb.Batch
 func (batch Batch) Replay(w ethdb.KeyValueWriter) error { return batch.Batch.Replay(w) }
+
+// ReadLastAccepted returns the hash of the last accepted block stored in the
+// VM database [db] of a stopped node, or database.ErrNotFound if no block was
+// accepted after genesis.
+func ReadLastAccepted(db database.Database) (common.Hash, error) {
+	lastAcceptedBytes, err := prefixdb.New(acceptedPrefix, db).Get(lastAcceptedKey)
+	if err != nil {
+		return common.Hash{}, err
+	}
+	return common.BytesToHash(lastAcceptedBytes), nil
+}
@@ -30,12 +30,14 @@ const pendingTxsBuffer = 10
 
 var (
 	_ p2p.Handler = (*txGossipHandler)(nil)
+	_ p2p.Handler = (*peerGossipHandler[*GossipEthTx])(nil)
 
 	_ gossip.Gossipable                  = (*GossipEthTx)(nil)
 	_ gossip.Gossipable                  = (*GossipAtomicTx)(nil)
 	_ gossip.Marshaller[*GossipAtomicTx] = (*GossipAtomicTxMarshaller)(nil)
 	_ gossip.Marshaller[*GossipEthTx]    = (*GossipEthTxMarshaller)(nil)
 	_ gossip.Set[*GossipEthTx]           = (*GossipEthTxPool)(nil)
+	_ peerSet[*GossipEthTx]              = (*GossipEthTxPool)(nil)
 
 	_ eth.PushGossiper = (*EthPushGossiper)(nil)
 )
@@ -58,6 +60,16 @@ func newTxGossipHandler[T gossip.Gossipable](
 		metrics,
 		maxMessageSize,
 	)
+	var pushHandler p2p.Handler = handler
+	if mempool, ok := mempool.(peerSet[T]); ok {
+		pushHandler = peerGossipHandler[T]{
+			log:            log,
+			marshaller:     marshaller,
+			set:            mempool,
+			metrics:        metrics,
+			maxMessageSize: maxMessageSize,
+		}
+	}
 
 	// pull gossip requests are filtered by validators and are throttled
 	// to prevent spamming
@@ -72,11 +84,37 @@ func newTxGossipHandler[T gossip.Gossipable](
 	)
 
 	return txGossipHandler{
-		appGossipHandler:  handler,
+		appGossipHandler:  pushHandler,
 		appRequestHandler: validatorHandler,
 	}
 }
 
+// peerSet is a gossip set attributing the gossip added to it to the peer it
+// was received from.
+type peerSet[T gossip.Gossipable] interface {
+	gossip.Set[T]
+
+	// ForPeer returns a view of the set adding gossip on behalf of [nodeID].
+	ForPeer(nodeID ids.NodeID) gossip.Set[T]
+}
+
+// peerGossipHandler handles push gossip messages by adding their content to
+// the set on behalf of the sending peer, so that the admission limits of the
+// set apply per peer.
+type peerGossipHandler[T gossip.Gossipable] struct {
+	p2p.NoOpHandler
+
+	log            logging.Logger
+	marshaller     gossip.Marshaller[T]
+	set            peerSet[T]
+	metrics        gossip.Metrics
+	maxMessageSize int
+}
+
+func (h peerGossipHandler[T]) AppGossip(ctx context.Context, nodeID ids.NodeID, gossipBytes []byte) {
+	gossip.NewHandler(h.log, h.marshaller, h.set.ForPeer(nodeID), h.metrics, h.maxMessageSize).AppGossip(ctx, nodeID, gossipBytes)
+}
+
 type txGossipHandler struct {
 	appGossipHandler  p2p.Handler
 	appRequestHandler p2p.Handler
@@ -194,6 +232,23 @@ func (g *GossipEthTxPool) Add(tx *GossipEthTx) error {
 	return g.mempool.Add([]*types.Transaction{tx.Tx}, false, false)[0]
 }
 
+// ForPeer returns a view of the pool adding transactions on behalf of [nodeID],
+// subject to the per-peer admission limits of the mempool.
+func (g *GossipEthTxPool) ForPeer(nodeID ids.NodeID) gossip.Set[*GossipEthTx] {
+	return &peerGossipEthTxPool{GossipEthTxPool: g, nodeID: nodeID}
+}
+
+// peerGossipEthTxPool is a view of a GossipEthTxPool adding the transactions
+// gossiped by a single peer.
+type peerGossipEthTxPool struct {
+	*GossipEthTxPool
+	nodeID ids.NodeID
+}
+
+func (p *peerGossipEthTxPool) Add(tx *GossipEthTx) error {
+	return p.mempool.AddFromPeer([]*types.Transaction{tx.Tx}, p.nodeID.String(), false)[0]
+}
+
 // Has should just return whether or not the [txID] is still in the mempool,
 // not whether it is in the mempool AND pending.
 func (g *GossipEthTxPool) Has(txID ids.ID) bool {
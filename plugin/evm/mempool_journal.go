@@ -0,0 +1,122 @@
+// (c) 2024, Ava Labs, Inc. All rights reserved.
+// See the file LICENSE for licensing terms.
+
+package evm
+
+import (
+	"errors"
+	"io"
+	"io/fs"
+	"os"
+	"sort"
+
+	"github.com/ava-labs/avalanchego/codec"
+	"github.com/ethereum/go-ethereum/log"
+	"github.com/ethereum/go-ethereum/rlp"
+)
+
+// atomicTxJournal is a periodically regenerated snapshot of the pending atomic
+// transactions in the mempool, allowing them to survive node restarts.
+type atomicTxJournal struct {
+	path  string        // Filesystem path to store the transactions at
+	codec codec.Manager // Codec used to parse the journaled transactions
+}
+
+// newAtomicTxJournal creates a new atomic transaction journal stored at [path].
+func newAtomicTxJournal(path string, codec codec.Manager) *atomicTxJournal {
+	return &atomicTxJournal{
+		path:  path,
+		codec: codec,
+	}
+}
+
+// load parses the journal from disk and passes each transaction to [add].
+// Transactions that fail to parse or are rejected by [add] are dropped.
+func (j *atomicTxJournal) load(add func(tx *Tx) error) error {
+	input, err := os.Open(j.path)
+	if errors.Is(err, fs.ErrNotExist) {
+		// Skip the parsing if the journal file doesn't exist at all
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	defer input.Close()
+
+	var (
+		stream         = rlp.NewStream(input, 0)
+		total, dropped int
+	)
+	for {
+		txBytes, err := stream.Bytes()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return err
+		}
+		total++
+
+		tx, err := ExtractAtomicTx(txBytes, j.codec)
+		if err == nil {
+			err = add(tx)
+		}
+		if err != nil {
+			log.Debug("Failed to add journaled atomic transaction", "err", err)
+			dropped++
+		}
+	}
+	log.Info("Loaded atomic transaction journal", "path", j.path, "transactions", total, "dropped", dropped)
+	return nil
+}
+
+// rotate replaces the journal on disk with [txs].
+func (j *atomicTxJournal) rotate(txs []*Tx) error {
+	replacement, err := os.OpenFile(j.path+".new", os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0644)
+	if err != nil {
+		return err
+	}
+	for _, tx := range txs {
+		if err := rlp.Encode(replacement, tx.SignedBytes()); err != nil {
+			replacement.Close()
+			return err
+		}
+	}
+	if err := replacement.Close(); err != nil {
+		return err
+	}
+	if err := os.Rename(j.path+".new", j.path); err != nil {
+		return err
+	}
+	log.Debug("Regenerated atomic transaction journal", "path", j.path, "transactions", len(txs))
+	return nil
+}
+
+// PendingTxs returns up to [limit] of the transactions waiting to be issued
+// into a block, sorted by decreasing gas price. Transactions currently being
+// built into a block are included, since their inclusion is not yet certain.
+func (m *Mempool) PendingTxs(limit int) []*Tx {
+	m.lock.RLock()
+	defer m.lock.RUnlock()
+
+	entries := make([]*txEntry, 0, m.txHeap.Len()+len(m.currentTxs))
+	entries = append(entries, m.txHeap.maxHeap.items...)
+	for _, tx := range m.currentTxs {
+		gasPrice, err := m.atomicTxGasPrice(tx)
+		if err != nil {
+			continue
+		}
+		entries = append(entries, &txEntry{id: tx.ID(), gasPrice: gasPrice, tx: tx})
+	}
+	sort.Slice(entries, func(i, j int) bool {
+		return entries[i].gasPrice > entries[j].gasPrice
+	})
+	if limit < len(entries) {
+		entries = entries[:limit]
+	}
+	txs := make([]*Tx, len(entries))
+	for i, entry := range entries {
+		txs[i] = entry.tx
+	}
+	return txs
+}
@@ -4,6 +4,7 @@
 package evm
 
 import (
+	"path/filepath"
 	"testing"
 
 	"github.com/ava-labs/avalanchego/ids"
@@ -54,3 +55,34 @@ func TestMempoolAdd(t *testing.T) {
 	err = m.Add(tx)
 	require.ErrorIs(err, errTxAlreadyKnown)
 }
+
+func TestMempoolJournal(t *testing.T) {
+	require := require.New(t)
+	codec := testTxCodec()
+
+	m, err := NewMempool(&snow.Context{}, prometheus.NewRegistry(), 5_000, nil)
+	require.NoError(err)
+
+	txs := make(map[ids.ID]*Tx)
+	for i := 0; i < 3; i++ {
+		tx := testDataImportTx()
+		signedBytes, err := codec.Marshal(codecVersion, tx)
+		require.NoError(err)
+		tx.UnsignedAtomicTx.(*TestUnsignedTx).SignedBytesV = signedBytes
+
+		require.NoError(m.AddLocalTx(tx))
+		txs[tx.ID()] = tx
+	}
+
+	journal := newAtomicTxJournal(filepath.Join(t.TempDir(), atomicTxJournalFile), codec)
+	require.NoError(journal.rotate(m.PendingTxs(defaultMempoolSize)))
+
+	// Reload the journal into a fresh mempool and ensure all txs survive
+	reloaded, err := NewMempool(&snow.Context{}, prometheus.NewRegistry(), 5_000, nil)
+	require.NoError(err)
+	require.NoError(journal.load(reloaded.AddLocalTx))
+	require.Equal(len(txs), reloaded.Len())
+	for txID := range txs {
+		require.True(reloaded.Has(txID))
+	}
+}
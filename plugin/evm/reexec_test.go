@@ -0,0 +1,114 @@
+// (c) 2024, Ava Labs, Inc. All rights reserved.
+// See the file LICENSE for licensing terms.
+
+package evm
+
+import (
+	"context"
+	"math/big"
+	"testing"
+
+	"github.com/ava-labs/avalanchego/ids"
+	commonEng "github.com/ava-labs/avalanchego/snow/engine/common"
+	"github.com/ava-labs/avalanchego/utils/crypto/secp256k1"
+	"github.com/ava-labs/coreth/consensus/dummy"
+	"github.com/ava-labs/coreth/core"
+	"github.com/ava-labs/coreth/core/rawdb"
+	"github.com/ava-labs/coreth/core/types"
+	"github.com/ava-labs/coreth/trie"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/stretchr/testify/require"
+)
+
+// acceptImportBlock builds and accepts a block holding an import tx of the
+// UTXO funded to [testShortIDAddrs[0]] by GenesisVMWithUTXOs.
+func acceptImportBlock(t *testing.T, vm *VM, issuer <-chan commonEng.Message) *types.Block {
+	require := require.New(t)
+	importTx, err := vm.newImportTx(vm.ctx.XChainID, testEthAddrs[0], initialBaseFee, []*secp256k1.PrivateKey{testKeys[0]})
+	require.NoError(err)
+	require.NoError(vm.mempool.AddLocalTx(importTx))
+	<-issuer
+
+	blk, err := vm.BuildBlock(context.Background())
+	require.NoError(err)
+	require.NoError(blk.Verify(context.Background()))
+	require.NoError(vm.SetPreference(context.Background(), blk.ID()))
+	require.NoError(blk.Accept(context.Background()))
+	vm.blockChain.DrainAcceptorQueue()
+	return vm.blockChain.GetBlockByHash(common.Hash(blk.ID()))
+}
+
+func TestAcceptedBlockCallbacks(t *testing.T) {
+	require := require.New(t)
+	importAmount := uint64(50000000)
+	issuer, vm, _, _, _ := GenesisVMWithUTXOs(t, true, genesisJSONApricotPhase2, "", "", map[ids.ShortID]uint64{
+		testShortIDAddrs[0]: importAmount,
+	})
+	defer func() {
+		require.NoError(vm.Shutdown(context.Background()))
+	}()
+
+	block := acceptImportBlock(t, vm, issuer)
+	parent := vm.blockChain.GetHeaderByHash(block.ParentHash())
+	reexecRoot := func(callbacks dummy.ConsensusCallbacks) common.Hash {
+		engine := dummy.NewFakerWithCallbacks(callbacks)
+		chain, err := core.NewHeaderChain(vm.chaindb, vm.chainConfig, core.DefaultCacheConfig, engine)
+		require.NoError(err)
+		statedb, err := vm.blockChain.StateAt(parent.Root)
+		require.NoError(err)
+		receipts, _, _, err := core.NewStateProcessor(vm.chainConfig, chain, engine).Process(block, parent, statedb, *vm.blockChain.GetVMConfig())
+		require.NoError(err)
+		require.Equal(block.ReceiptHash(), types.DeriveSha(receipts, trie.NewStackTrie(nil)))
+		return statedb.IntermediateRoot(vm.chainConfig.IsEIP158(block.Number()))
+	}
+	// The block only holds the import tx, so its state changes are only
+	// reproduced with the callbacks applying the atomic txs.
+	require.Equal(block.Root(), reexecRoot(AcceptedBlockCallbacks(vm.ctx, vm.chainConfig)))
+	require.NotEqual(block.Root(), reexecRoot(dummy.ConsensusCallbacks{}))
+}
+
+func TestBackfillPreimagesAtomicTxs(t *testing.T) {
+	require := require.New(t)
+	importAmount := uint64(50000000)
+	issuer, vm, _, _, _ := GenesisVMWithUTXOs(t, true, genesisJSONApricotPhase2, "", "", map[ids.ShortID]uint64{
+		testShortIDAddrs[0]: importAmount,
+	})
+	defer func() {
+		require.NoError(vm.Shutdown(context.Background()))
+	}()
+
+	// The import tx credits an account whose preimage is not recorded.
+	block := acceptImportBlock(t, vm, issuer)
+	hash := crypto.Keccak256Hash(testEthAddrs[0][:])
+	require.Empty(rawdb.ReadPreimage(vm.chaindb, hash))
+
+	// The UTXOs of the import tx are already consumed, so the block is only
+	// re-executed by applying its atomic txs without verifying them.
+	count, err := vm.blockChain.BackfillPreimages(block.NumberU64(), block.NumberU64(), nil, nil)
+	require.NoError(err)
+	require.NotZero(count)
+	require.Equal(testEthAddrs[0][:], rawdb.ReadPreimage(vm.chaindb, hash))
+}
+
+func TestRegenStateAtomicTxs(t *testing.T) {
+	require := require.New(t)
+	importAmount := uint64(50000000)
+	issuer, vm, _, _, _ := GenesisVMWithUTXOs(t, true, genesisJSONApricotPhase2, `{"historical-state-regen-limit": 16}`, "", map[ids.ShortID]uint64{
+		testShortIDAddrs[0]: importAmount,
+	})
+	defer func() {
+		require.NoError(vm.Shutdown(context.Background()))
+	}()
+
+	// The state of the block is not committed to disk, so it is regenerated
+	// from the genesis state by applying the atomic txs of the block without
+	// verifying them.
+	block := acceptImportBlock(t, vm, issuer)
+	next := types.NewBlockWithHeader(&types.Header{Number: new(big.Int).Add(block.Number(), common.Big1), Time: block.Time()})
+	statedb, release, err := vm.eth.StateAtNextBlock(context.Background(), block, next, 16, nil, false, false)
+	require.NoError(err)
+	defer release()
+	require.Equal(block.Root(), statedb.IntermediateRoot(vm.chainConfig.IsEIP158(block.Number())))
+	require.NotZero(statedb.GetBalance(testEthAddrs[0]).Sign())
+}
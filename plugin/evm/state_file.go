@@ -0,0 +1,376 @@
+// (c) 2024, Ava Labs, Inc. All rights reserved.
+// See the file LICENSE for licensing terms.
+
+package evm
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"time"
+
+	"github.com/ava-labs/avalanchego/database"
+	"github.com/ava-labs/avalanchego/database/prefixdb"
+	"github.com/ava-labs/coreth/core/rawdb"
+	"github.com/ava-labs/coreth/core/state/snapshot"
+	"github.com/ava-labs/coreth/core/types"
+	"github.com/ava-labs/coreth/plugin/evm/message"
+	"github.com/ava-labs/coreth/sync/statefile"
+	"github.com/ava-labs/coreth/trie"
+	"github.com/ava-labs/coreth/trie/triedb/pathdb"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/ethdb"
+	"github.com/ethereum/go-ethereum/log"
+	"github.com/ethereum/go-ethereum/rlp"
+)
+
+var (
+	errNoCommittedState   = errors.New("no state committed since genesis")
+	errStateExportRunning = errors.New("state export already running")
+	errExportInterrupted  = errors.New("state export interrupted")
+	errUnexpectedBlock    = errors.New("unexpected block in state file")
+)
+
+// StateExportStatus describes the last state export started by the
+// ExportState admin API.
+type StateExportStatus struct {
+	Path        string      `json:"path"`
+	Running     bool        `json:"running"`
+	BlockNumber uint64      `json:"blockNumber"`
+	BlockHash   common.Hash `json:"blockHash"`
+	Root        common.Hash `json:"root"`
+	AtomicRoot  common.Hash `json:"atomicRoot"`
+	Error       string      `json:"error,omitempty"`
+}
+
+// exportState writes the state of the chain at [height], at which the atomic
+// trie was committed with [atomicRoot], to [w]. Along with the accounts,
+// storage, code and atomic trie, the file holds the block at that height and
+// up to [parentsToGet] of its parents, which is what state sync fetches from
+// peers.
+func exportState(chaindb ethdb.Database, triedb *trie.Database, atomicTrie AtomicTrie, atomicRoot common.Hash, height uint64, w io.Writer) (statefile.Header, error) {
+	if height == 0 {
+		return statefile.Header{}, errNoCommittedState
+	}
+	hash := rawdb.ReadCanonicalHash(chaindb, height)
+	block := rawdb.ReadBlock(chaindb, hash, height)
+	if block == nil {
+		return statefile.Header{}, fmt.Errorf("could not find block at committed height %d", height)
+	}
+	header := statefile.Header{
+		BlockNumber: height,
+		BlockHash:   block.Hash(),
+		Root:        block.Root(),
+		AtomicRoot:  atomicRoot,
+	}
+	sw, err := statefile.NewWriter(w, header)
+	if err != nil {
+		return statefile.Header{}, err
+	}
+	for i := 0; i <= parentsToGet && block != nil; i++ {
+		blockBytes, err := rlp.EncodeToBytes(block)
+		if err != nil {
+			return statefile.Header{}, err
+		}
+		if err := sw.Write(statefile.BlockChunk, block.Hash().Bytes(), blockBytes); err != nil {
+			return statefile.Header{}, err
+		}
+		if block.NumberU64() == 0 {
+			break
+		}
+		block = rawdb.ReadBlock(chaindb, block.ParentHash(), block.NumberU64()-1)
+	}
+	if err := statefile.ExportState(chaindb, triedb, header.Root, sw); err != nil {
+		return statefile.Header{}, fmt.Errorf("failed to export state of block %d: %w", height, err)
+	}
+
+	tr, err := atomicTrie.OpenTrie(atomicRoot)
+	if err != nil {
+		return statefile.Header{}, err
+	}
+	nodeIt, err := tr.NodeIterator(nil)
+	if err != nil {
+		return statefile.Header{}, err
+	}
+	it := trie.NewIterator(nodeIt)
+	for it.Next() {
+		if err := sw.Write(statefile.AtomicChunk, it.Key, it.Value); err != nil {
+			return statefile.Header{}, err
+		}
+	}
+	if it.Err != nil {
+		return statefile.Header{}, fmt.Errorf("failed to export atomic trie at height %d: %w", height, it.Err)
+	}
+	return header, sw.Close()
+}
+
+// ExportState writes the state at the last height committed by the atomic
+// trie to [w], reading it from the VM database [db] of a stopped node.
+// [commitInterval] must match the commit interval used by the node.
+func ExportState(db database.Database, commitInterval uint64, w io.Writer) (statefile.Header, error) {
+	chaindb := rawdb.NewDatabase(Database{prefixdb.NewNested(ethDBPrefix, db)})
+	var lastAcceptedHeight uint64
+	lastAccepted, err := ReadLastAccepted(db)
+	switch {
+	case err == database.ErrNotFound:
+		return statefile.Header{}, errNoCommittedState
+	case err != nil:
+		return statefile.Header{}, err
+	default:
+		height := rawdb.ReadHeaderNumber(chaindb, lastAccepted)
+		if height == nil {
+			return statefile.Header{}, fmt.Errorf("failed to retrieve header number of last accepted block: %x", lastAccepted)
+		}
+		lastAcceptedHeight = *height
+	}
+	atomicTrie, err := newAtomicTrie(
+		prefixdb.New(atomicTrieDBPrefix, db), prefixdb.New(atomicTrieMetaDBPrefix, db),
+		Codec, lastAcceptedHeight, commitInterval,
+	)
+	if err != nil {
+		return statefile.Header{}, err
+	}
+
+	config := &trie.Config{}
+	if rawdb.ReadStateScheme(chaindb) == rawdb.PathScheme {
+		config.PathDB = pathdb.ReadOnly
+	}
+	triedb := trie.NewDatabase(chaindb, config)
+	defer triedb.Close()
+	atomicRoot, height := atomicTrie.LastCommitted()
+	return exportState(chaindb, triedb, atomicTrie, atomicRoot, height, w)
+}
+
+// interruptibleWriter fails writes once [quit] is closed.
+type interruptibleWriter struct {
+	w    io.Writer
+	quit <-chan struct{}
+}
+
+func (w *interruptibleWriter) Write(p []byte) (int, error) {
+	select {
+	case <-w.quit:
+		return 0, errExportInterrupted
+	default:
+		return w.w.Write(p)
+	}
+}
+
+// startStateExport exports the state at the last height committed by the
+// atomic trie to [path] in the background. The file is written to a
+// temporary file first, which is renamed to [path] once the export succeeds.
+// The exported state may be stale, so online pruning is held until the export
+// completes, and the export fails if a pruning session is running.
+// Assumes [vm.ctx.Lock] is held.
+func (vm *VM) startStateExport(path string) error {
+	vm.stateExportLock.Lock()
+	defer vm.stateExportLock.Unlock()
+
+	if vm.stateExport.Running {
+		return errStateExportRunning
+	}
+	releasePruning := func() {}
+	if onlinePruner := vm.eth.OnlinePruner(); onlinePruner != nil {
+		release, err := onlinePruner.Hold()
+		if err != nil {
+			return fmt.Errorf("cannot export state: %w", err)
+		}
+		releasePruning = release
+	}
+	tmp := path + ".tmp"
+	f, err := os.OpenFile(tmp, os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0o600)
+	if err != nil {
+		releasePruning()
+		return err
+	}
+	atomicRoot, height := vm.atomicTrie.LastCommitted()
+	vm.stateExport = StateExportStatus{Path: path, Running: true, BlockNumber: height, AtomicRoot: atomicRoot}
+
+	vm.shutdownWg.Add(1)
+	go func() {
+		defer vm.shutdownWg.Done()
+
+		start := time.Now()
+		w := &interruptibleWriter{w: f, quit: vm.shutdownChan}
+		header, err := exportState(vm.chaindb, vm.blockChain.TrieDB(), vm.atomicTrie, atomicRoot, height, w)
+		if closeErr := f.Close(); err == nil {
+			err = closeErr
+		}
+		if err == nil {
+			err = os.Rename(tmp, path)
+		}
+		if err != nil {
+			os.Remove(tmp)
+			log.Error("State export failed", "path", path, "err", err)
+		} else {
+			log.Info("State export complete", "path", path, "height", header.BlockNumber, "root", header.Root, "elapsed", time.Since(start))
+		}
+
+		vm.stateExportLock.Lock()
+		defer vm.stateExportLock.Unlock()
+		releasePruning()
+		vm.stateExport = StateExportStatus{
+			Path:        path,
+			BlockNumber: height,
+			BlockHash:   header.BlockHash,
+			Root:        header.Root,
+			AtomicRoot:  atomicRoot,
+		}
+		if err != nil {
+			vm.stateExport.Error = err.Error()
+		}
+	}()
+	return nil
+}
+
+// stateExportStatus returns the status of the last state export.
+func (vm *VM) stateExportStatus() StateExportStatus {
+	vm.stateExportLock.Lock()
+	defer vm.stateExportLock.Unlock()
+
+	return vm.stateExport
+}
+
+// importState imports the state file at [path] if the chain has not accepted
+// any block after genesis, and returns the last accepted height afterwards.
+// The imported data is verified against the roots recorded in the file, and
+// the VM is left in the same state as after a completed state sync.
+func (vm *VM) importState(path string, lastAcceptedHeight uint64) (uint64, error) {
+	if lastAcceptedHeight != 0 {
+		log.Info("Skipping state import since the chain is already initialized", "path", path, "lastAccepted", lastAcceptedHeight)
+		return lastAcceptedHeight, nil
+	}
+	f, err := os.Open(path)
+	if err != nil {
+		return 0, err
+	}
+	defer f.Close()
+	r, err := statefile.NewReader(f)
+	if err != nil {
+		return 0, err
+	}
+
+	// The atomic trie is only committed at multiples of the commit interval,
+	// so it must be committed at the imported height.
+	if height := r.Header().BlockNumber; height%vm.config.CommitInterval != 0 {
+		return 0, fmt.Errorf("state file height %d is not a multiple of the commit interval %d", height, vm.config.CommitInterval)
+	}
+
+	client := &stateSyncerClient{
+		stateSyncClientConfig: &stateSyncClientConfig{
+			chain:              vm.eth,
+			state:              vm.State,
+			lastAcceptedHeight: lastAcceptedHeight,
+			chaindb:            vm.chaindb,
+			metadataDB:         vm.metadataDB,
+			acceptedBlockDB:    vm.acceptedBlockDB,
+			db:                 vm.db,
+			atomicBackend:      vm.atomicBackend,
+		},
+	}
+	start := time.Now()
+	if err := client.importState(r, vm.blockChain.TrieDB().Scheme()); err != nil {
+		return 0, err
+	}
+	header := r.Header()
+	log.Info("State import complete", "path", path, "height", header.BlockNumber, "root", header.Root, "elapsed", time.Since(start))
+	return header.BlockNumber, nil
+}
+
+// importState writes the blocks, state and atomic trie read from [r] to disk
+// and calls finishSync, as if they were fetched from peers by state sync.
+func (client *stateSyncerClient) importState(r *statefile.Reader, scheme string) error {
+	header := r.Header()
+	summary, err := message.NewSyncSummary(header.BlockHash, header.BlockNumber, header.Root, header.AtomicRoot)
+	if err != nil {
+		return err
+	}
+	client.syncSummary = summary
+	log.Info("Importing state", "summary", summary)
+
+	// Wipe the snapshot the same way state sync does before writing the
+	// imported one.
+	<-snapshot.WipeSnapshot(client.chaindb, true)
+	snapshot.ResetSnapshotGeneration(client.chaindb)
+
+	syncer, err := client.atomicBackend.Syncer(nil, header.AtomicRoot, header.BlockNumber, client.stateSyncRequestSize)
+	if err != nil {
+		return err
+	}
+	atomicSyncer, ok := syncer.(*atomicSyncer)
+	if !ok {
+		return fmt.Errorf("unexpected atomic syncer type %T", syncer)
+	}
+	var (
+		stateImporter = statefile.NewStateImporter(client.chaindb, scheme, header.Root, ethdb.IdealBatchSize)
+		blocks        = client.chaindb.NewBatch()
+		nextHash      = header.BlockHash
+		numBlocks     int
+		lastAtomicKey []byte
+	)
+	for {
+		kind, records, err := r.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return err
+		}
+		switch kind {
+		case statefile.BlockChunk:
+			for _, record := range records {
+				block := new(types.Block)
+				if err := rlp.DecodeBytes(record.Value, block); err != nil {
+					return fmt.Errorf("failed to decode block %x: %w", record.Key, err)
+				}
+				// Blocks must start at the exported block and link to their parents.
+				if block.Hash() != nextHash || !bytes.Equal(record.Key, nextHash[:]) {
+					return fmt.Errorf("%w: %s, expected %s", errUnexpectedBlock, block.Hash(), nextHash)
+				}
+				if numBlocks == 0 && (block.NumberU64() != header.BlockNumber || block.Root() != header.Root) {
+					return fmt.Errorf("%w: block %d with root %s does not match the header", errUnexpectedBlock, block.NumberU64(), block.Root())
+				}
+				rawdb.WriteBlock(blocks, block)
+				rawdb.WriteCanonicalHash(blocks, block.Hash(), block.NumberU64())
+				nextHash = block.ParentHash()
+				numBlocks++
+			}
+		case statefile.StateChunk, statefile.CodeChunk:
+			if err := stateImporter.Import(kind, records); err != nil {
+				return err
+			}
+		case statefile.AtomicChunk:
+			keys := make([][]byte, len(records))
+			values := make([][]byte, len(records))
+			for i, record := range records {
+				// The atomic trie is rebuilt one height at a time, so the
+				// leaves must be sorted.
+				if bytes.Compare(lastAtomicKey, record.Key) >= 0 {
+					return fmt.Errorf("atomic trie leaf %x is out of order", record.Key)
+				}
+				lastAtomicKey = record.Key
+				keys[i], values[i] = record.Key, record.Value
+			}
+			if err := atomicSyncer.onLeafs(keys, values); err != nil {
+				return err
+			}
+		default:
+			return fmt.Errorf("unexpected %s chunk in state file", kind)
+		}
+	}
+	if numBlocks == 0 {
+		return fmt.Errorf("state file does not contain block %s", header.BlockHash)
+	}
+	if err := stateImporter.Finish(); err != nil {
+		return err
+	}
+	if err := atomicSyncer.onFinish(); err != nil {
+		return err
+	}
+	if err := blocks.Write(); err != nil {
+		return err
+	}
+	return client.finishSync()
+}
@@ -0,0 +1,149 @@
+// (c) 2024, Ava Labs, Inc. All rights reserved.
+// See the file LICENSE for licensing terms.
+
+package evm
+
+import (
+	"context"
+	"fmt"
+	"math/big"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/ava-labs/avalanchego/ids"
+	"github.com/ava-labs/avalanchego/snow"
+	"github.com/ava-labs/avalanchego/utils/units"
+	"github.com/ava-labs/coreth/core"
+	"github.com/ava-labs/coreth/core/rawdb"
+	"github.com/ava-labs/coreth/core/types"
+	"github.com/ava-labs/coreth/predicate"
+	"github.com/ava-labs/coreth/sync/statefile"
+	"github.com/stretchr/testify/require"
+)
+
+func TestExportImportState(t *testing.T) {
+	require := require.New(t)
+	// The atomic trie of the importing VM is committed at the default commit
+	// interval, which must divide the exported height.
+	test := syncTest{syncableInterval: defaultCommitInterval, stateSyncMinBlocks: 50}
+	vmSetup := createSyncServerAndClientVMs(t, test, int(test.syncableInterval))
+	serverVM := vmSetup.serverVM
+
+	// Export the state of the server VM in the background.
+	path := filepath.Join(t.TempDir(), "state")
+	require.NoError(serverVM.startStateExport(path))
+	require.ErrorIs(serverVM.startStateExport(path), errStateExportRunning)
+	var status StateExportStatus
+	require.Eventually(func() bool {
+		status = serverVM.stateExportStatus()
+		return !status.Running
+	}, 30*time.Second, 10*time.Millisecond)
+	require.Empty(status.Error)
+	// The last accepted block of the server VM is patched to hold the test
+	// state, so it is read from the database.
+	height := serverVM.LastAcceptedBlock().Height()
+	lastAccepted := rawdb.ReadBlock(serverVM.chaindb, rawdb.ReadCanonicalHash(serverVM.chaindb, height), height)
+	require.Equal(lastAccepted.NumberU64(), status.BlockNumber)
+	require.Equal(lastAccepted.Hash(), status.BlockHash)
+	require.Equal(lastAccepted.Root(), status.Root)
+	_, err := os.Stat(path + ".tmp")
+	require.ErrorIs(err, os.ErrNotExist)
+
+	// Import the state into a new VM on startup.
+	configJSON := fmt.Sprintf(`{"state-import-file": %q, "tx-lookup-limit": 4}`, path)
+	alloc := map[ids.ShortID]uint64{testShortIDAddrs[0]: 2000000 * units.Avax}
+	_, importerVM, _, importerAtomicMemory, _ := GenesisVMWithUTXOs(t, false, "", configJSON, "", alloc)
+	t.Cleanup(func() {
+		require.NoError(importerVM.Shutdown(context.Background()))
+	})
+
+	require.Equal(serverVM.LastAcceptedBlock().ID(), importerVM.LastAcceptedBlock().ID())
+	require.True(importerVM.blockChain.HasState(lastAccepted.Root()))
+	assertSyncPerformedHeights(t, importerVM.chaindb, map[uint64]struct{}{lastAccepted.NumberU64(): {}})
+	atomicRoot, atomicHeight := importerVM.atomicTrie.LastCommitted()
+	require.Equal(status.AtomicRoot, atomicRoot)
+	require.Equal(lastAccepted.NumberU64(), atomicHeight)
+	for i := uint64(0); i <= parentsToGet; i++ {
+		number := lastAccepted.NumberU64() - i
+		require.Equal(rawdb.ReadCanonicalHash(serverVM.chaindb, number), rawdb.ReadCanonicalHash(importerVM.chaindb, number))
+	}
+	importerSharedMemories := newSharedMemories(importerAtomicMemory, importerVM.ctx.ChainID, importerVM.ctx.XChainID)
+	for _, tx := range vmSetup.includedAtomicTxs {
+		importerSharedMemories.assertOpsApplied(t, tx.mustAtomicOps())
+	}
+
+	// The imported VM continues to process blocks.
+	require.NoError(importerVM.SetState(context.Background(), snow.Bootstrapping))
+	require.NoError(importerVM.SetState(context.Background(), snow.NormalOp))
+	generateAndAcceptBlocks(t, importerVM, 5, func(_ int, gen *core.BlockGen) {
+		b, err := predicate.NewResults().Bytes()
+		require.NoError(err)
+		gen.AppendExtra(b)
+		for k := range vmSetup.fundedAccounts {
+			tx := types.NewTransaction(gen.TxNonce(k.Address), testEthAddrs[1], big.NewInt(1), 21000, initialBaseFee, nil)
+			signedTx, err := types.SignTx(tx, types.NewEIP155Signer(importerVM.chainConfig.ChainID), k.PrivateKey)
+			require.NoError(err)
+			gen.AddTx(signedTx)
+			break
+		}
+	}, nil)
+	require.Equal(lastAccepted.NumberU64()+5, importerVM.blockChain.LastAcceptedBlock().NumberU64())
+}
+
+func TestImportStateRequiresCommitHeight(t *testing.T) {
+	require := require.New(t)
+
+	path := filepath.Join(t.TempDir(), "state")
+	f, err := os.Create(path)
+	require.NoError(err)
+	w, err := statefile.NewWriter(f, statefile.Header{BlockNumber: 256})
+	require.NoError(err)
+	require.NoError(w.Close())
+	require.NoError(f.Close())
+
+	// The commit interval does not divide the exported height.
+	vm := &VM{}
+	vm.config.CommitInterval = 4096
+	_, err = vm.importState(path, 0)
+	require.ErrorContains(err, "not a multiple of the commit interval")
+
+	// The state file is ignored once the chain is initialized.
+	height, err := vm.importState(path, 1)
+	require.NoError(err)
+	require.Equal(uint64(1), height)
+}
+
+func TestStateExportHoldsOnlinePruning(t *testing.T) {
+	require := require.New(t)
+
+	configJSON := fmt.Sprintf(`{"online-pruning-enabled": true, "online-pruning-data-directory": %q}`, t.TempDir())
+	_, vm, _, _, _ := GenesisVM(t, false, "", configJSON, "")
+	t.Cleanup(func() {
+		require.NoError(vm.Shutdown(context.Background()))
+	})
+	onlinePruner := vm.eth.OnlinePruner()
+	require.NotNil(onlinePruner)
+
+	// The pruner may resume a session once started, so the state cannot be
+	// exported before.
+	path := filepath.Join(t.TempDir(), "state")
+	require.ErrorContains(vm.startStateExport(path), "online pruner is not started")
+	require.NoError(vm.SetState(context.Background(), snow.Bootstrapping))
+	require.NoError(vm.SetState(context.Background(), snow.NormalOp))
+
+	// Holding the status lock keeps the export from completing, and pruning
+	// cannot start until it does.
+	require.NoError(vm.startStateExport(path))
+	vm.stateExportLock.Lock()
+	require.ErrorContains(onlinePruner.Prune(), "online pruning is held")
+	vm.stateExportLock.Unlock()
+
+	require.Eventually(func() bool {
+		return !vm.stateExportStatus().Running
+	}, 30*time.Second, 10*time.Millisecond)
+	release, err := onlinePruner.Hold()
+	require.NoError(err)
+	release()
+}
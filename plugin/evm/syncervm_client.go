@@ -298,6 +298,7 @@ func (client *stateSyncerClient) syncStateTrie(ctx context.Context) error {
 		MaxOutstandingCodeHashes: statesync.DefaultMaxOutstandingCodeHashes,
 		NumCodeFetchingWorkers:   statesync.DefaultNumCodeFetchingWorkers,
 		RequestSize:              client.stateSyncRequestSize,
+		Scheme:                   client.chain.BlockChain().TrieDB().Scheme(),
 	})
 	if err != nil {
 		return err
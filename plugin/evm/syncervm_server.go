@@ -11,6 +11,7 @@ import (
 	"github.com/ava-labs/avalanchego/snow/engine/snowman/block"
 
 	"github.com/ava-labs/coreth/core"
+	"github.com/ava-labs/coreth/core/rawdb"
 	"github.com/ava-labs/coreth/plugin/evm/message"
 	"github.com/ethereum/go-ethereum/common"
 	"github.com/ethereum/go-ethereum/log"
@@ -46,6 +47,11 @@ func NewStateSyncServer(config *stateSyncServerConfig) StateSyncServer {
 
 // stateSummaryAtHeight returns the SyncSummary at [height] if valid and available.
 func (server *stateSyncServer) stateSummaryAtHeight(height uint64) (message.SyncSummary, error) {
+	// The path scheme only keeps the most recent states, which are discarded
+	// long before syncing peers could fetch them, so no summary is served.
+	if scheme := server.chain.TrieDB().Scheme(); scheme == rawdb.PathScheme {
+		return message.SyncSummary{}, fmt.Errorf("cannot serve state summaries with the %s state scheme", scheme)
+	}
 	atomicRoot, err := server.atomicTrie.Root(height)
 	if err != nil {
 		return message.SyncSummary{}, fmt.Errorf("error getting atomic trie root for height (%d): %w", height, err)
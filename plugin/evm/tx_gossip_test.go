@@ -432,6 +432,76 @@ func TestEthTxPushGossipInbound(t *testing.T) {
 	require.True(vm.txPool.Has(signedTx.Hash()))
 }
 
+// Tests that the per-peer admission limits apply to txs pushed over the sdk
+// gossip protocol
+func TestEthTxPushGossipInboundPeerLimit(t *testing.T) {
+	require := require.New(t)
+	ctx := context.Background()
+	snowCtx := utils.TestSnowContext()
+
+	sender := &common.SenderTest{}
+	vm := &VM{
+		p2pSender:            sender,
+		ethTxPullGossiper:    gossip.NoOpGossiper{},
+		atomicTxPullGossiper: gossip.NoOpGossiper{},
+	}
+
+	pk, err := secp256k1.NewPrivateKey()
+	require.NoError(err)
+	address := GetEthAddress(pk)
+	genesis := newPrefundedGenesis(100_000_000_000_000_000, address)
+	genesisBytes, err := genesis.MarshalJSON()
+	require.NoError(err)
+
+	// Each peer may only submit a single tx
+	config := []byte(`{"tx-pool-peer-rate-limit": 0.0001, "tx-pool-rate-limit-burst": 1}`)
+	require.NoError(vm.Initialize(
+		ctx,
+		snowCtx,
+		memdb.New(),
+		genesisBytes,
+		nil,
+		config,
+		make(chan common.Message),
+		nil,
+		sender,
+	))
+	require.NoError(vm.SetState(ctx, snow.NormalOp))
+
+	defer func() {
+		require.NoError(vm.Shutdown(ctx))
+	}()
+
+	marshaller := GossipEthTxMarshaller{}
+	gossipMsg := func(txs ...*types.Transaction) []byte {
+		inboundGossip := &sdk.PushGossip{}
+		for _, tx := range txs {
+			txBytes, err := marshaller.MarshalGossip(&GossipEthTx{Tx: tx})
+			require.NoError(err)
+			inboundGossip.Gossip = append(inboundGossip.Gossip, txBytes)
+		}
+		inboundGossipBytes, err := proto.Marshal(inboundGossip)
+		require.NoError(err)
+		return append(binary.AppendUvarint(nil, ethTxGossipProtocol), inboundGossipBytes...)
+	}
+	txs := make([]*types.Transaction, 2)
+	for i := range txs {
+		tx := types.NewTransaction(uint64(i), address, big.NewInt(10), 100_000, big.NewInt(params.LaunchMinGasPrice), nil)
+		txs[i], err = types.SignTx(tx, types.NewEIP155Signer(vm.chainID), pk.ToECDSA())
+		require.NoError(err)
+	}
+
+	// The second tx exceeds the limit of the gossiping peer
+	nodeID := ids.GenerateTestNodeID()
+	require.NoError(vm.AppGossip(ctx, nodeID, gossipMsg(txs...)))
+	require.True(vm.txPool.Has(txs[0].Hash()))
+	require.False(vm.txPool.Has(txs[1].Hash()))
+
+	// Another peer may still gossip it
+	require.NoError(vm.AppGossip(ctx, ids.GenerateTestNodeID(), gossipMsg(txs[1])))
+	require.True(vm.txPool.Has(txs[1].Hash()))
+}
+
 // Tests that a tx is gossiped when it is issued
 func TestAtomicTxPushGossipOutbound(t *testing.T) {
 	require := require.New(t)
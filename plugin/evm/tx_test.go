@@ -13,6 +13,7 @@ import (
 
 	"github.com/ethereum/go-ethereum/common"
 
+	"github.com/ava-labs/coreth/consensus/dummy"
 	"github.com/ava-labs/coreth/params"
 
 	"github.com/ava-labs/avalanchego/chains/atomic"
@@ -20,6 +21,30 @@ import (
 	"github.com/ava-labs/avalanchego/snow"
 )
 
+func TestAtomicTxTransfers(t *testing.T) {
+	var (
+		addr    = common.HexToAddress("0x01")
+		assetID = ids.GenerateTestID()
+	)
+	importTx := &UnsignedImportTx{
+		Outs: []EVMOutput{
+			{Address: addr, Amount: 1, AssetID: testAvaxAssetID},
+			{Address: addr, Amount: 2, AssetID: assetID},
+		},
+	}
+	require.Equal(t, []dummy.ExtraStateTransfer{
+		{Address: addr, Amount: x2cRate},
+		{Address: addr, AssetID: common.Hash(assetID), Amount: big.NewInt(2)},
+	}, atomicTxTransfers(importTx, testAvaxAssetID))
+
+	exportTx := &UnsignedExportTx{
+		Ins: []EVMInput{{Address: addr, Amount: 3, AssetID: testAvaxAssetID}},
+	}
+	require.Equal(t, []dummy.ExtraStateTransfer{
+		{Address: addr, Amount: new(big.Int).Mul(big.NewInt(3), x2cRate), Debit: true},
+	}, atomicTxTransfers(exportTx, testAvaxAssetID))
+}
+
 func TestCalculateDynamicFee(t *testing.T) {
 	type test struct {
 		gas           uint64
@@ -27,6 +27,7 @@ import (
 	"github.com/ava-labs/coreth/core"
 	"github.com/ava-labs/coreth/core/rawdb"
 	"github.com/ava-labs/coreth/core/state"
+	"github.com/ava-labs/coreth/core/state/verifier"
 	"github.com/ava-labs/coreth/core/txpool"
 	"github.com/ava-labs/coreth/core/types"
 	"github.com/ava-labs/coreth/eth"
@@ -156,6 +157,13 @@ const (
 	txGossipThrottlingPeriod             = 10 * time.Second
 	txGossipThrottlingLimit              = 2
 	txGossipPollSize                     = 1
+
+	// tx pool journal files, relative to the chain data directory
+	remoteTxJournalFile = "remote_transactions.rlp"
+	atomicTxJournalFile = "atomic_transactions.rlp"
+
+	// ancient store directory, relative to the chain data directory
+	ancientStoreDir = "ancient"
 )
 
 // Define the API endpoints for the VM
@@ -299,10 +307,21 @@ type VM struct {
 	codec     codec.Manager
 	clock     mockable.Clock
 	mempool   *Mempool
+	// [atomicTxJournal] snapshots the mempool to disk if remote tx journaling is enabled
+	atomicTxJournal *atomicTxJournal
 
 	shutdownChan chan struct{}
 	shutdownWg   sync.WaitGroup
 
+	// [stateExport] is the status of the last state export started by the
+	// ExportState admin API.
+	stateExportLock sync.Mutex
+	stateExport     StateExportStatus
+
+	// [atomicTrieVerifier] verifies the atomic trie for the VerifyAtomicTrie
+	// admin API.
+	atomicTrieVerifier *verifier.Verifier
+
 	fx        secp256k1fx.Fx
 	secpCache secp256k1.RecoverCache
 
@@ -423,7 +442,18 @@ func (vm *VM) Initialize(
 	vm.shutdownChan = make(chan struct{}, 1)
 	// Use NewNested rather than New so that the structure of the database
 	// remains the same regardless of the provided baseDB type.
-	vm.chaindb = rawdb.NewDatabase(Database{prefixdb.NewNested(ethDBPrefix, db)})
+	chaindb := Database{prefixdb.NewNested(ethDBPrefix, db)}
+	vm.chaindb = rawdb.NewDatabase(chaindb)
+	if vm.config.AncientStore {
+		if len(chainCtx.ChainDataDir) == 0 {
+			return errors.New("cannot enable the ancient store without a chain data directory")
+		}
+		ancient := filepath.Join(chainCtx.ChainDataDir, ancientStoreDir)
+		vm.chaindb, err = rawdb.NewDatabaseWithFreezer(chaindb, ancient, "eth/db/chaindata/", false, vm.config.AncientStoreDistance)
+		if err != nil {
+			return fmt.Errorf("failed to open ancient store: %w", err)
+		}
+	}
 	vm.db = versiondb.New(db)
 	vm.acceptedBlockDB = prefixdb.New(acceptedPrefix, vm.db)
 	vm.metadataDB = prefixdb.New(metadataPrefix, vm.db)
@@ -514,6 +544,20 @@ func (vm *VM) Initialize(
 	vm.ethConfig.TxPool.AccountQueue = vm.config.TxPoolAccountQueue
 	vm.ethConfig.TxPool.GlobalQueue = vm.config.TxPoolGlobalQueue
 	vm.ethConfig.TxPool.Lifetime = vm.config.TxPoolLifetime.Duration
+	vm.ethConfig.TxPool.SenderRateLimit = vm.config.TxPoolSenderRateLimit
+	vm.ethConfig.TxPool.PeerRateLimit = vm.config.TxPoolPeerRateLimit
+	vm.ethConfig.TxPool.RateLimitBurst = vm.config.TxPoolRateLimitBurst
+	vm.ethConfig.TxPool.SpamThreshold = vm.config.TxPoolSpamThreshold
+	vm.ethConfig.TxPool.ThrottleDuration = vm.config.TxPoolThrottleDuration.Duration
+	if vm.config.TxPoolRemoteJournal {
+		if len(chainCtx.ChainDataDir) == 0 {
+			return errors.New("cannot enable tx pool remote journal without a chain data directory")
+		}
+		vm.ethConfig.TxPool.RemoteJournal = filepath.Join(chainCtx.ChainDataDir, remoteTxJournalFile)
+		vm.ethConfig.TxPool.RemoteJournalSlots = vm.config.TxPoolRemoteJournalSlots
+		vm.ethConfig.TxPool.RemoteJournalAccountSlots = vm.config.TxPoolRemoteJournalAccountSlots
+		vm.ethConfig.TxPool.Rejournal = vm.config.TxPoolRejournal.Duration
+	}
 
 	vm.ethConfig.AllowUnfinalizedQueries = vm.config.AllowUnfinalizedQueries
 	vm.ethConfig.AllowUnprotectedTxs = vm.config.AllowUnprotectedTxs
@@ -529,17 +573,39 @@ func (vm *VM) Initialize(
 	vm.ethConfig.PopulateMissingTries = vm.config.PopulateMissingTries
 	vm.ethConfig.PopulateMissingTriesParallelism = vm.config.PopulateMissingTriesParallelism
 	vm.ethConfig.AllowMissingTries = vm.config.AllowMissingTries
-	vm.ethConfig.SnapshotDelayInit = vm.stateSyncEnabled(lastAcceptedHeight)
+	// The snapshot is replaced when the state is synced or imported, so it
+	// is not generated on startup in these cases.
+	vm.ethConfig.SnapshotDelayInit = vm.stateSyncEnabled(lastAcceptedHeight) || (vm.config.StateImportFile != "" && lastAcceptedHeight == 0)
 	vm.ethConfig.SnapshotWait = vm.config.SnapshotWait
 	vm.ethConfig.SnapshotVerify = vm.config.SnapshotVerify
 	vm.ethConfig.OfflinePruning = vm.config.OfflinePruning
 	vm.ethConfig.OfflinePruningBloomFilterSize = vm.config.OfflinePruningBloomFilterSize
 	vm.ethConfig.OfflinePruningDataDirectory = vm.config.OfflinePruningDataDirectory
+	vm.ethConfig.OnlinePruning = vm.config.OnlinePruning
+	vm.ethConfig.OnlinePruningBloomFilterSize = vm.config.OnlinePruningBloomFilterSize
+	vm.ethConfig.OnlinePruningDataDirectory = vm.config.OnlinePruningDataDirectory
+	vm.ethConfig.OnlinePruningSweepRate = vm.config.OnlinePruningSweepRate
 	vm.ethConfig.CommitInterval = vm.config.CommitInterval
 	vm.ethConfig.SkipUpgradeCheck = vm.config.SkipUpgradeCheck
 	vm.ethConfig.AcceptedCacheSize = vm.config.AcceptedCacheSize
 	vm.ethConfig.TxLookupLimit = vm.config.TxLookupLimit
+	vm.ethConfig.HistoryExpiry = vm.config.HistoryExpiry
 	vm.ethConfig.SkipTxIndexing = vm.config.SkipTxIndexing
+	vm.ethConfig.TraceIndex = vm.config.TraceIndexEnabled
+	vm.ethConfig.TokenIndex = vm.config.TokenIndexEnabled
+	vm.ethConfig.LogIndex = vm.config.LogIndexEnabled
+	vm.ethConfig.StateSizeStats = vm.config.StateSizeStatsEnabled
+	vm.ethConfig.StateSizeStatsRetention = vm.config.StateSizeStatsRetention
+	vm.ethConfig.HistoricalStateRegenLimit = vm.config.HistoricalStateRegenLimit
+	vm.ethConfig.HistoricalStateCache = vm.config.HistoricalStateCache
+
+	// Trie nodes stored with one scheme cannot be read with the other, so
+	// refuse to start rather than fail on missing state.
+	scheme, err := rawdb.ParseStateScheme(vm.config.StateScheme, vm.chaindb)
+	if err != nil {
+		return fmt.Errorf("invalid state-scheme, the database must be resynced to change schemes: %w", err)
+	}
+	vm.ethConfig.StateScheme = scheme
 
 	// Create directory for offline pruning
 	if len(vm.ethConfig.OfflinePruningDataDirectory) != 0 {
@@ -548,6 +614,12 @@ func (vm *VM) Initialize(
 			return err
 		}
 	}
+	if vm.ethConfig.OnlinePruning {
+		if err := os.MkdirAll(vm.ethConfig.OnlinePruningDataDirectory, perms.ReadWriteExecute); err != nil {
+			log.Error("failed to create online pruning data directory", "error", err)
+			return err
+		}
+	}
 
 	vm.chainConfig = g.Config
 	vm.networkID = vm.ethConfig.NetworkId
@@ -572,6 +644,9 @@ func (vm *VM) Initialize(
 	if err != nil {
 		return fmt.Errorf("failed to initialize mempool: %w", err)
 	}
+	if vm.config.TxPoolRemoteJournal {
+		vm.atomicTxJournal = newAtomicTxJournal(filepath.Join(chainCtx.ChainDataDir, atomicTxJournalFile), vm.codec)
+	}
 
 	// initialize peer network
 	if vm.p2pSender == nil {
@@ -640,6 +715,7 @@ func (vm *VM) Initialize(
 		return fmt.Errorf("failed to create atomic backend: %w", err)
 	}
 	vm.atomicTrie = vm.atomicBackend.AtomicTrie()
+	vm.atomicTrieVerifier = verifier.New()
 
 	go vm.ctx.Log.RecoverAndPanic(vm.startContinuousProfiler)
 
@@ -654,6 +730,12 @@ func (vm *VM) Initialize(
 	}
 
 	vm.initializeStateSyncServer()
+	if vm.config.StateImportFile != "" {
+		lastAcceptedHeight, err = vm.importState(vm.config.StateImportFile, lastAcceptedHeight)
+		if err != nil {
+			return fmt.Errorf("failed to import state from %s: %w", vm.config.StateImportFile, err)
+		}
+	}
 	return vm.initializeStateSyncClient(lastAcceptedHeight)
 }
 
@@ -698,6 +780,9 @@ func (vm *VM) initializeChain(lastAcceptedHash common.Hash) error {
 	vm.eth.SetEtherbase(constants.BlackholeAddr)
 	vm.txPool = vm.eth.TxPool()
 	vm.blockChain = vm.eth.BlockChain()
+	// Accepted blocks are re-executed without verifying their atomic txs
+	// again, which would fail as their inputs are already consumed.
+	vm.blockChain.SetReplayEngine(dummy.NewFakerWithCallbacks(AcceptedBlockCallbacks(vm.ctx, vm.chainConfig)))
 	vm.miner = vm.eth.Miner()
 
 	// Set the gas parameters for the tx pool to the minimum gas price for the
@@ -840,6 +925,7 @@ func (vm *VM) createConsensusCallbacks() dummy.ConsensusCallbacks {
 	return dummy.ConsensusCallbacks{
 		OnFinalizeAndAssemble: vm.onFinalizeAndAssemble,
 		OnExtraStateChange:    vm.onExtraStateChange,
+		OnExtraStateTxs:       vm.onExtraStateTxs,
 	}
 }
 
@@ -997,12 +1083,58 @@ func (vm *VM) onFinalizeAndAssemble(header *types.Header, state *state.StateDB,
 	return vm.postBatchOnFinalizeAndAssemble(header, state, txs)
 }
 
+// onExtraStateTxs returns the atomic transactions of [block] along with the
+// credits and debits they apply to the EVM state, so that they can be traced.
+func (vm *VM) onExtraStateTxs(block *types.Block) ([]*dummy.ExtraStateTx, error) {
+	rules := vm.chainConfig.Rules(block.Number(), block.Time())
+	txs, err := ExtractAtomicTxs(block.ExtData(), rules.IsApricotPhase5, vm.codec)
+	if err != nil {
+		return nil, err
+	}
+	extraTxs := make([]*dummy.ExtraStateTx, len(txs))
+	for i, tx := range txs {
+		tx := tx // capture range variable
+		extraTxs[i] = &dummy.ExtraStateTx{
+			ID:        common.Hash(tx.ID()),
+			Transfers: atomicTxTransfers(tx.UnsignedAtomicTx, vm.ctx.AVAXAssetID),
+			Apply: func(statedb *state.StateDB) error {
+				return tx.UnsignedAtomicTx.EVMStateTransfer(vm.ctx, statedb)
+			},
+		}
+	}
+	return extraTxs, nil
+}
+
+// atomicTxTransfers returns the credits and debits [utx] applies to the EVM
+// state, converting AVAX amounts from nAVAX to wei.
+func atomicTxTransfers(utx UnsignedAtomicTx, avaxAssetID ids.ID) []dummy.ExtraStateTransfer {
+	transfer := func(addr common.Address, amount uint64, assetID ids.ID, debit bool) dummy.ExtraStateTransfer {
+		t := dummy.ExtraStateTransfer{Address: addr, Amount: new(big.Int).SetUint64(amount), Debit: debit}
+		if assetID == avaxAssetID {
+			t.Amount.Mul(t.Amount, x2cRate)
+		} else {
+			t.AssetID = common.Hash(assetID)
+		}
+		return t
+	}
+	var transfers []dummy.ExtraStateTransfer
+	switch utx := utx.(type) {
+	case *UnsignedImportTx:
+		for _, out := range utx.Outs {
+			transfers = append(transfers, transfer(out.Address, out.Amount, out.AssetID, false))
+		}
+	case *UnsignedExportTx:
+		for _, in := range utx.Ins {
+			transfers = append(transfers, transfer(in.Address, in.Amount, in.AssetID, true))
+		}
+	}
+	return transfers
+}
+
 func (vm *VM) onExtraStateChange(block *types.Block, state *state.StateDB) (*big.Int, *big.Int, error) {
 	var (
-		batchContribution *big.Int = big.NewInt(0)
-		batchGasUsed      *big.Int = big.NewInt(0)
-		header                     = block.Header()
-		rules                      = vm.chainConfig.Rules(header.Number, header.Time)
+		header = block.Header()
+		rules  = vm.chainConfig.Rules(header.Number, header.Time)
 	)
 
 	txs, err := ExtractAtomicTxs(block.ExtData(), rules.IsApricotPhase5, vm.codec)
@@ -1029,19 +1161,29 @@ func (vm *VM) onExtraStateChange(block *types.Block, state *state.StateDB) (*big
 			return nil, nil, err
 		}
 	}
+	return applyAtomicTxs(vm.ctx, rules, block, txs, state)
+}
 
+// applyAtomicTxs applies the EVM state changes of the atomic transactions
+// [txs] of [block] to [state], returning their block fee contribution and gas
+// used.
+func applyAtomicTxs(ctx *snow.Context, rules params.Rules, block *types.Block, txs []*Tx, state *state.StateDB) (*big.Int, *big.Int, error) {
 	// If there are no transactions, we can return early.
 	if len(txs) == 0 {
 		return nil, nil, nil
 	}
 
+	var (
+		batchContribution *big.Int = big.NewInt(0)
+		batchGasUsed      *big.Int = big.NewInt(0)
+	)
 	for _, tx := range txs {
-		if err := tx.UnsignedAtomicTx.EVMStateTransfer(vm.ctx, state); err != nil {
+		if err := tx.UnsignedAtomicTx.EVMStateTransfer(ctx, state); err != nil {
 			return nil, nil, err
 		}
 		// If ApricotPhase4 is enabled, calculate the block fee contribution
 		if rules.IsApricotPhase4 {
-			contribution, gasUsed, err := tx.BlockFeeContribution(rules.IsApricotPhase5, vm.ctx.AVAXAssetID, block.BaseFee())
+			contribution, gasUsed, err := tx.BlockFeeContribution(rules.IsApricotPhase5, ctx.AVAXAssetID, block.BaseFee())
 			if err != nil {
 				return nil, nil, err
 			}
@@ -1062,6 +1204,25 @@ func (vm *VM) onExtraStateChange(block *types.Block, state *state.StateDB) (*big
 	return batchContribution, batchGasUsed, nil
 }
 
+// AcceptedBlockCallbacks returns the consensus callbacks applying the atomic
+// transactions of accepted blocks to the EVM state, used to re-execute
+// accepted blocks outside of the VM. As when the VM reprocesses accepted
+// blocks on startup, the atomic transactions are not verified against their
+// ancestors and the atomic trie is left untouched. [ctx] must provide the
+// AVAX asset ID of the network.
+func AcceptedBlockCallbacks(ctx *snow.Context, chainConfig *params.ChainConfig) dummy.ConsensusCallbacks {
+	return dummy.ConsensusCallbacks{
+		OnExtraStateChange: func(block *types.Block, state *state.StateDB) (*big.Int, *big.Int, error) {
+			rules := chainConfig.Rules(block.Number(), block.Time())
+			txs, err := ExtractAtomicTxs(block.ExtData(), rules.IsApricotPhase5, Codec)
+			if err != nil {
+				return nil, nil, err
+			}
+			return applyAtomicTxs(ctx, rules, block, txs, state)
+		},
+	}
+}
+
 func (vm *VM) SetState(_ context.Context, state snow.State) error {
 	switch state {
 	case snow.StateSyncing:
@@ -1079,8 +1240,17 @@ func (vm *VM) SetState(_ context.Context, state snow.State) error {
 		// Ensure snapshots are initialized before bootstrapping (i.e., if state sync is skipped).
 		// Note calling this function has no effect if snapshots are already initialized.
 		vm.blockChain.InitializeSnapshots()
+		// Resume an interrupted online pruning run now that the state is complete.
+		if onlinePruner := vm.eth.OnlinePruner(); onlinePruner != nil {
+			if err := onlinePruner.Start(); err != nil {
+				return fmt.Errorf("failed to start online pruner: %w", err)
+			}
+		}
 		return vm.fx.Bootstrapping()
 	case snow.NormalOp:
+		// Reload the journaled atomic txs now that they can be verified against
+		// the current chain state.
+		vm.initAtomicTxJournal()
 		// Initialize goroutines related to block building once we enter normal operation as there is no need to handle mempool gossip before this point.
 		if err := vm.initBlockBuilding(); err != nil {
 			return fmt.Errorf("failed to initialize block building: %w", err)
@@ -1092,6 +1262,41 @@ func (vm *VM) SetState(_ context.Context, state snow.State) error {
 	}
 }
 
+// initAtomicTxJournal loads the journaled atomic txs into the mempool and starts
+// a goroutine that periodically snapshots the mempool back to the journal until
+// shutdown. It is a no-op if remote tx journaling is disabled.
+func (vm *VM) initAtomicTxJournal() {
+	if vm.atomicTxJournal == nil {
+		return
+	}
+	if err := vm.atomicTxJournal.load(vm.mempool.AddTx); err != nil {
+		log.Warn("Failed to load atomic transaction journal", "err", err)
+	}
+
+	rotate := func() {
+		if err := vm.atomicTxJournal.rotate(vm.mempool.PendingTxs(defaultMempoolSize)); err != nil {
+			log.Warn("Failed to rotate atomic transaction journal", "err", err)
+		}
+	}
+	vm.shutdownWg.Add(1)
+	go func() {
+		defer vm.shutdownWg.Done()
+
+		ticker := time.NewTicker(vm.config.TxPoolRejournal.Duration)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ticker.C:
+				rotate()
+			case <-vm.shutdownChan:
+				rotate()
+				return
+			}
+		}
+	}()
+}
+
 // initBlockBuilding starts goroutines to manage block building
 func (vm *VM) initBlockBuilding() error {
 	ctx, cancel := context.WithCancel(context.TODO())
@@ -1271,14 +1476,25 @@ func (vm *VM) setAppRequestHandlers() {
 	// Create separate EVM TrieDB (read only) for serving leafs requests.
 	// We create a separate TrieDB here, so that it has a separate cache from the one
 	// used by the node when processing blocks.
-	evmTrieDB := trie.NewDatabase(
-		vm.chaindb,
-		&trie.Config{
-			HashDB: &hashdb.Config{
-				CleanCacheSize: vm.config.StateSyncServerTrieCache * units.MiB,
+	//
+	// The path scheme keeps the recent states in memory, so they can only be
+	// served by the TrieDB processing blocks. Since these states do not outlive
+	// the summaries of the state sync server, it does not offer any with the
+	// path scheme.
+	evmTrieDB := vm.blockChain.TrieDB()
+	if evmTrieDB.Scheme() == rawdb.PathScheme && vm.config.StateSyncServerTrieCache != defaultStateSyncServerTrieCache {
+		log.Warn("Ignoring state-sync-server-trie-cache with the path state scheme", "cache", vm.config.StateSyncServerTrieCache)
+	}
+	if evmTrieDB.Scheme() == rawdb.HashScheme {
+		evmTrieDB = trie.NewDatabase(
+			vm.chaindb,
+			&trie.Config{
+				HashDB: &hashdb.Config{
+					CleanCacheSize: vm.config.StateSyncServerTrieCache * units.MiB,
+				},
 			},
-		},
-	)
+		)
+	}
 	networkHandler := newNetworkHandler(
 		vm.blockChain,
 		vm.chaindb,
@@ -1311,10 +1527,28 @@ func (vm *VM) Shutdown(context.Context) error {
 	}
 	close(vm.shutdownChan)
 	vm.eth.Stop()
+	if vm.atomicTrieVerifier != nil {
+		vm.atomicTrieVerifier.Stop()
+	}
 	vm.shutdownWg.Wait()
+	if vm.config.AncientStore {
+		// Closing the chain database stops migrating blocks to the ancient store.
+		if err := vm.chaindb.Close(); err != nil {
+			log.Error("error closing ancient store", "err", err)
+		}
+	}
 	return nil
 }
 
+// startAtomicTrieVerification starts verifying the atomic trie with [root] in
+// the background, or the last committed atomic trie if [root] is empty.
+func (vm *VM) startAtomicTrieVerification(root common.Hash) error {
+	if root == (common.Hash{}) {
+		root, _ = vm.atomicTrie.LastCommitted()
+	}
+	return vm.atomicTrieVerifier.VerifyTrie("atomic", vm.atomicTrie.TrieDB(), root)
+}
+
 // buildBlock builds a block to be wrapped by ChainState
 func (vm *VM) buildBlock(ctx context.Context) (snowman.Block, error) {
 	return vm.buildBlockWithContext(ctx, nil)
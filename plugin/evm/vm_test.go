@@ -58,6 +58,7 @@ import (
 
 	"github.com/ava-labs/coreth/consensus/dummy"
 	"github.com/ava-labs/coreth/core"
+	"github.com/ava-labs/coreth/core/rawdb"
 	"github.com/ava-labs/coreth/core/types"
 	"github.com/ava-labs/coreth/eth"
 	"github.com/ava-labs/coreth/params"
@@ -981,6 +982,66 @@ func TestBuildEthTxBlock(t *testing.T) {
 	}
 }
 
+func TestPathStateScheme(t *testing.T) {
+	require := require.New(t)
+	importAmount := uint64(20000000)
+	configJSON := `{"pruning-enabled":true,"state-scheme":"path"}`
+	issuer, vm, dbManager, _, _ := GenesisVMWithUTXOs(t, true, genesisJSONApricotPhase2, configJSON, "", map[ids.ShortID]uint64{
+		testShortIDAddrs[0]: importAmount,
+	})
+	require.Equal(rawdb.PathScheme, vm.blockChain.TrieDB().Scheme())
+
+	importTx, err := vm.newImportTx(vm.ctx.XChainID, testEthAddrs[0], initialBaseFee, []*secp256k1.PrivateKey{testKeys[0]})
+	require.NoError(err)
+	require.NoError(vm.mempool.AddLocalTx(importTx))
+	<-issuer
+
+	blk, err := vm.BuildBlock(context.Background())
+	require.NoError(err)
+	require.NoError(blk.Verify(context.Background()))
+	require.NoError(vm.SetPreference(context.Background(), blk.ID()))
+	require.NoError(blk.Accept(context.Background()))
+
+	// The recent states kept by the path scheme cannot back state summaries
+	_, err = vm.GetLastStateSummary(context.Background())
+	require.ErrorIs(err, database.ErrNotFound)
+	_, err = vm.GetStateSummary(context.Background(), 0)
+	require.ErrorIs(err, database.ErrNotFound)
+	require.NoError(vm.Shutdown(context.Background()))
+
+	// The accepted state is journaled on shutdown and reloaded on restart
+	restartedVM := &VM{}
+	require.NoError(restartedVM.Initialize(
+		context.Background(),
+		NewContext(),
+		dbManager,
+		[]byte(genesisJSONApricotPhase2),
+		[]byte(""),
+		[]byte(configJSON),
+		issuer,
+		[]*commonEng.Fx{},
+		nil,
+	))
+	ethBlk := blk.(*chain.BlockWrapper).Block.(*Block).ethBlock
+	require.True(restartedVM.blockChain.HasState(ethBlk.Root()))
+	require.NoError(restartedVM.Shutdown(context.Background()))
+
+	// The database cannot be opened with the hash scheme
+	hashVM := &VM{}
+	err = hashVM.Initialize(
+		context.Background(),
+		NewContext(),
+		dbManager,
+		[]byte(genesisJSONApricotPhase2),
+		[]byte(""),
+		[]byte(`{"pruning-enabled":true,"state-scheme":"hash"}`),
+		issuer,
+		[]*commonEng.Fx{},
+		nil,
+	)
+	require.ErrorContains(err, "state-scheme")
+}
+
 func testConflictingImportTxs(t *testing.T, genesis string) {
 	importAmount := uint64(10000000)
 	issuer, vm, _, _, _ := GenesisVMWithUTXOs(t, true, genesis, "", "", map[ids.ShortID]uint64{
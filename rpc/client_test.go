@@ -462,6 +462,47 @@ func TestClientSubscribe(t *testing.T) {
 	}
 }
 
+func TestClientSubscribeFail(t *testing.T) {
+	server := newTestServer()
+	service := &notificationTestService{unsubscribed: make(chan string, 1)}
+	server.RegisterName("nftest2", service)
+	defer server.Stop()
+	client := DialInProc(server)
+	defer client.Close()
+
+	nc := make(chan int)
+	count := 10
+	sub, err := client.Subscribe(context.Background(), "nftest2", nc, "failingSubscription", count, 0)
+	if err != nil {
+		t.Fatal("can't subscribe:", err)
+	}
+	// The notifications sent before the error are delivered, followed by the
+	// error, even if they are only read after the server ended the subscription.
+	select {
+	case id := <-service.unsubscribed:
+		if id != string(sub.subid) {
+			t.Fatalf("wrong subscription ended: got %s, want %s", id, sub.subid)
+		}
+	case <-time.After(1 * time.Second):
+		t.Fatal("subscription not ended within 1s")
+	}
+	for i := 0; i < count; i++ {
+		if val := <-nc; val != i {
+			t.Fatalf("value mismatch: got %d, want %d", val, i)
+		}
+	}
+	select {
+	case v := <-nc:
+		t.Fatal("received value after failure:", v)
+	case err := <-sub.Err():
+		if err == nil || err.Error() != "subscription failed" {
+			t.Fatalf("wrong error: got %v, want %q", err, "subscription failed")
+		}
+	case <-time.After(1 * time.Second):
+		t.Fatal("subscription not failed within 1s")
+	}
+}
+
 // In this test, the connection drops while Subscribe is waiting for a response.
 func TestClientSubscribeClose(t *testing.T) {
 	server := newTestServer()
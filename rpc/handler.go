@@ -543,9 +543,17 @@ func (h *handler) handleSubscriptionResult(msg *jsonrpcMessage) {
 		h.log.Debug("Dropping invalid subscription message")
 		return
 	}
-	if h.clientSubs[result.ID] != nil {
-		h.clientSubs[result.ID].deliver(result.Result)
+	sub := h.clientSubs[result.ID]
+	if sub == nil {
+		return
+	}
+	if result.Error != nil {
+		// The server ended the subscription
+		delete(h.clientSubs, result.ID)
+		sub.close(result.Error)
+		return
 	}
+	sub.deliver(result.Result)
 }
 
 // handleCallMsg executes a call message and returns the answer.
@@ -54,6 +54,7 @@ var null = json.RawMessage("null")
 type subscriptionResult struct {
 	ID     string          `json:"subscription"`
 	Result json.RawMessage `json:"result,omitempty"`
+	Error  *jsonError      `json:"error,omitempty"`
 }
 
 type subscriptionResultEnc struct {
@@ -61,10 +62,17 @@ type subscriptionResultEnc struct {
 	Result any    `json:"result"`
 }
 
+// subscriptionErrorEnc is the last notification of a subscription ended by the
+// server with an error.
+type subscriptionErrorEnc struct {
+	ID    string     `json:"subscription"`
+	Error *jsonError `json:"error"`
+}
+
 type jsonrpcSubscriptionNotification struct {
-	Version string                `json:"jsonrpc"`
-	Method  string                `json:"method"`
-	Params  subscriptionResultEnc `json:"params"`
+	Version string `json:"jsonrpc"`
+	Method  string `json:"method"`
+	Params  any    `json:"params"`
 }
 
 // A value of this type can a JSON-RPC request, notification, successful response or
@@ -131,19 +139,24 @@ func (msg *jsonrpcMessage) response(result interface{}) *jsonrpcMessage {
 }
 
 func errorMessage(err error) *jsonrpcMessage {
-	msg := &jsonrpcMessage{Version: vsn, ID: null, Error: &jsonError{
+	return &jsonrpcMessage{Version: vsn, ID: null, Error: newJSONError(err)}
+}
+
+// newJSONError converts [err] to its JSON-RPC representation.
+func newJSONError(err error) *jsonError {
+	jerr := &jsonError{
 		Code:    errcodeDefault,
 		Message: err.Error(),
-	}}
+	}
 	ec, ok := err.(Error)
 	if ok {
-		msg.Error.Code = ec.ErrorCode()
+		jerr.Code = ec.ErrorCode()
 	}
 	de, ok := err.(DataError)
 	if ok {
-		msg.Error.Data = de.ErrorData()
+		jerr.Data = de.ErrorData()
 	}
-	return msg
+	return jerr
 }
 
 type jsonError struct {
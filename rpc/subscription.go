@@ -118,6 +118,12 @@ type Notifier struct {
 	buffer       []any
 	callReturned bool
 	activated    bool
+	failed       bool
+}
+
+// subscriptionFailure is a buffered failure of a subscription.
+type subscriptionFailure struct {
+	err error
 }
 
 // CreateSubscription returns a new subscription that is coupled to the
@@ -148,6 +154,9 @@ func (n *Notifier) Notify(id ID, data any) error {
 	} else if n.sub.ID != id {
 		panic("Notify with wrong ID")
 	}
+	if n.failed {
+		return ErrSubscriptionNotFound
+	}
 	if n.activated {
 		return n.send(n.sub, data)
 	}
@@ -155,6 +164,45 @@ func (n *Notifier) Notify(id ID, data any) error {
 	return nil
 }
 
+// Fail ends the subscription, sending [err] to the client after the
+// notifications sent so far. The client reports the error on the error channel
+// of its subscription, and the error channel of the server side subscription
+// is closed as if the client unsubscribed.
+func (n *Notifier) Fail(id ID, err error) error {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	if n.sub == nil {
+		panic("can't Fail before subscription is created")
+	} else if n.sub.ID != id {
+		panic("Fail with wrong ID")
+	}
+	if n.failed {
+		return ErrSubscriptionNotFound
+	}
+	n.failed = true
+	if n.activated {
+		return n.fail(err)
+	}
+	n.buffer = append(n.buffer, subscriptionFailure{err})
+	return nil
+}
+
+// fail sends [err] to the client and removes the subscription.
+func (n *Notifier) fail(err error) error {
+	msg := jsonrpcSubscriptionNotification{
+		Version: vsn,
+		Method:  n.namespace + notificationMethodSuffix,
+		Params: subscriptionErrorEnc{
+			ID:    string(n.sub.ID),
+			Error: newJSONError(err),
+		},
+	}
+	sendErr := n.h.conn.writeJSON(context.Background(), &msg, false)
+	n.h.unsubscribe(context.Background(), n.sub.ID)
+	return sendErr
+}
+
 // Closed returns a channel that is closed when the RPC connection is closed.
 // Deprecated: use subscription error channel
 func (n *Notifier) Closed() <-chan interface{} {
@@ -178,6 +226,10 @@ func (n *Notifier) activate() error {
 	defer n.mu.Unlock()
 
 	for _, data := range n.buffer {
+		if f, ok := data.(subscriptionFailure); ok {
+			n.activated = true
+			return n.fail(f.err)
+		}
 		if err := n.send(n.sub, data); err != nil {
 			return err
 		}
@@ -360,6 +412,17 @@ func (sub *ClientSubscription) forward() (unsubscribeServer bool, err error) {
 				// Exiting because Unsubscribe was called, unsubscribe on server.
 				return true, nil
 			}
+			if _, ok := err.(*jsonError); ok {
+				// The server ended the subscription with an error, deliver the
+				// notifications it sent before the error first.
+				for buffer.Len() > 0 {
+					cases[2].Send = reflect.ValueOf(buffer.Front().Value)
+					if chosen, _, _ := reflect.Select([]reflect.SelectCase{cases[0], cases[2]}); chosen == 0 {
+						break
+					}
+					buffer.Remove(buffer.Front())
+				}
+			}
 			return false, err
 
 		case 1: // <-sub.in
@@ -0,0 +1,328 @@
+// (c) 2024, Ava Labs, Inc. All rights reserved.
+// See the file LICENSE for licensing terms.
+
+// Package statefile implements a file format for exporting the state of the
+// chain at an accepted block and importing it into another node.
+//
+// A state file starts with [magic] and the format [Version], followed by a
+// sequence of chunks. Each chunk is encoded as its kind (1 byte), the length
+// of its payload (4 bytes, big endian), the payload and the keccak256
+// checksum of the kind and payload. The first chunk holds the [Header], the
+// last chunk holds the number of chunks and records written before it, and
+// every chunk in between holds an RLP encoded list of [Record]s of a single
+// [Kind].
+package statefile
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/rlp"
+)
+
+// Version is the version of the state file format written by [Writer].
+const Version uint32 = 1
+
+const (
+	// ChunkSize is the payload size after which [Writer] starts a new chunk.
+	ChunkSize = 4 * 1024 * 1024
+	// maxChunkSize is the largest payload accepted by [Reader], so that a
+	// corrupt length does not cause a huge allocation.
+	maxChunkSize = 64 * 1024 * 1024
+
+	chunkPrefixLen   = 1 + 4
+	chunkChecksumLen = common.HashLength
+)
+
+var magic = []byte("CORETHSF")
+
+var (
+	errInvalidMagic    = errors.New("not a state file")
+	errChecksum        = errors.New("chunk checksum mismatch")
+	errChunkTooLarge   = errors.New("chunk too large")
+	errMissingEnd      = errors.New("state file truncated")
+	errWriterClosed    = errors.New("writer closed")
+	errUnexpectedChunk = errors.New("unexpected chunk")
+)
+
+// Kind is the type of the records held by a chunk.
+type Kind byte
+
+const (
+	headerChunk Kind = iota
+	// BlockChunk records map block hashes to RLP encoded blocks, starting
+	// with the exported block and followed by its parents.
+	BlockChunk
+	// StateChunk records map account hashes to RLP encoded accounts, each
+	// account being followed by the records of its storage slots, which map
+	// the account hash concatenated with the slot hash to the slot value.
+	StateChunk
+	// CodeChunk records map code hashes to contract code.
+	CodeChunk
+	// AtomicChunk records hold the leaves of the atomic trie.
+	AtomicChunk
+	endChunk
+)
+
+func (k Kind) String() string {
+	switch k {
+	case headerChunk:
+		return "header"
+	case BlockChunk:
+		return "block"
+	case StateChunk:
+		return "state"
+	case CodeChunk:
+		return "code"
+	case AtomicChunk:
+		return "atomic"
+	case endChunk:
+		return "end"
+	default:
+		return fmt.Sprintf("unknown(%d)", byte(k))
+	}
+}
+
+// Header describes the exported state.
+type Header struct {
+	BlockNumber uint64
+	BlockHash   common.Hash
+	Root        common.Hash
+	AtomicRoot  common.Hash
+}
+
+// Record is a key-value pair held by a chunk.
+type Record struct {
+	Key   []byte
+	Value []byte
+}
+
+// trailer is the payload of the last chunk of a state file.
+type trailer struct {
+	Chunks  uint64
+	Records uint64
+}
+
+// Writer writes a state file.
+type Writer struct {
+	w *bufio.Writer
+
+	kind    Kind
+	records []Record
+	size    int
+
+	chunks    uint64
+	total     uint64
+	closed    bool
+	buf       bytes.Buffer
+	lengthBuf [4]byte
+}
+
+// NewWriter writes the preamble of a state file describing [header] to [w]
+// and returns a [Writer] to append records to it.
+func NewWriter(w io.Writer, header Header) (*Writer, error) {
+	sw := &Writer{w: bufio.NewWriter(w)}
+	if _, err := sw.w.Write(magic); err != nil {
+		return nil, err
+	}
+	var version [4]byte
+	binary.BigEndian.PutUint32(version[:], Version)
+	if _, err := sw.w.Write(version[:]); err != nil {
+		return nil, err
+	}
+	if err := sw.writeChunk(headerChunk, header); err != nil {
+		return nil, err
+	}
+	return sw, nil
+}
+
+// Write appends a record of [kind] to the file. Records of the same kind
+// are grouped into chunks of up to [ChunkSize] bytes.
+func (w *Writer) Write(kind Kind, key, value []byte) error {
+	if w.closed {
+		return errWriterClosed
+	}
+	if kind == headerChunk || kind >= endChunk {
+		return fmt.Errorf("cannot write records of kind %s", kind)
+	}
+	if len(w.records) > 0 && (kind != w.kind || w.size >= ChunkSize) {
+		if err := w.flush(); err != nil {
+			return err
+		}
+	}
+	w.kind = kind
+	w.records = append(w.records, Record{
+		Key:   common.CopyBytes(key),
+		Value: common.CopyBytes(value),
+	})
+	w.size += len(key) + len(value)
+	return nil
+}
+
+// Close writes the pending records and the last chunk of the file. It does
+// not close the underlying writer.
+func (w *Writer) Close() error {
+	if w.closed {
+		return errWriterClosed
+	}
+	if err := w.flush(); err != nil {
+		return err
+	}
+	if err := w.writeChunk(endChunk, trailer{Chunks: w.chunks, Records: w.total}); err != nil {
+		return err
+	}
+	w.closed = true
+	return w.w.Flush()
+}
+
+func (w *Writer) flush() error {
+	if len(w.records) == 0 {
+		return nil
+	}
+	if err := w.writeChunk(w.kind, w.records); err != nil {
+		return err
+	}
+	w.total += uint64(len(w.records))
+	w.records = w.records[:0]
+	w.size = 0
+	return nil
+}
+
+func (w *Writer) writeChunk(kind Kind, payload interface{}) error {
+	w.buf.Reset()
+	if err := rlp.Encode(&w.buf, payload); err != nil {
+		return err
+	}
+	if w.buf.Len() > maxChunkSize {
+		return fmt.Errorf("%w: %s chunk of %d bytes", errChunkTooLarge, kind, w.buf.Len())
+	}
+	binary.BigEndian.PutUint32(w.lengthBuf[:], uint32(w.buf.Len()))
+	if err := w.w.WriteByte(byte(kind)); err != nil {
+		return err
+	}
+	if _, err := w.w.Write(w.lengthBuf[:]); err != nil {
+		return err
+	}
+	if _, err := w.w.Write(w.buf.Bytes()); err != nil {
+		return err
+	}
+	if _, err := w.w.Write(checksum(kind, w.buf.Bytes())); err != nil {
+		return err
+	}
+	if kind != headerChunk && kind != endChunk {
+		w.chunks++
+	}
+	return nil
+}
+
+// Reader reads a state file, verifying the checksum of each chunk.
+type Reader struct {
+	r      *bufio.Reader
+	header Header
+
+	chunks  uint64
+	records uint64
+	done    bool
+}
+
+// NewReader reads the preamble of the state file in [r].
+func NewReader(r io.Reader) (*Reader, error) {
+	sr := &Reader{r: bufio.NewReader(r)}
+	preamble := make([]byte, len(magic)+4)
+	if _, err := io.ReadFull(sr.r, preamble); err != nil {
+		return nil, fmt.Errorf("%w: %w", errInvalidMagic, err)
+	}
+	if !bytes.Equal(preamble[:len(magic)], magic) {
+		return nil, errInvalidMagic
+	}
+	if version := binary.BigEndian.Uint32(preamble[len(magic):]); version != Version {
+		return nil, fmt.Errorf("unsupported state file version %d, expected %d", version, Version)
+	}
+	kind, payload, err := sr.readChunk()
+	if err != nil {
+		return nil, err
+	}
+	if kind != headerChunk {
+		return nil, fmt.Errorf("%w: %s chunk, expected header", errUnexpectedChunk, kind)
+	}
+	if err := rlp.DecodeBytes(payload, &sr.header); err != nil {
+		return nil, fmt.Errorf("failed to decode header: %w", err)
+	}
+	return sr, nil
+}
+
+// Header returns the header of the state file.
+func (r *Reader) Header() Header {
+	return r.header
+}
+
+// Next returns the records of the next chunk of the file. It returns
+// [io.EOF] once the last chunk has been read and the number of chunks and
+// records read matches the one recorded by the writer.
+func (r *Reader) Next() (Kind, []Record, error) {
+	if r.done {
+		return 0, nil, io.EOF
+	}
+	kind, payload, err := r.readChunk()
+	if err != nil {
+		return 0, nil, err
+	}
+	switch {
+	case kind == endChunk:
+		var t trailer
+		if err := rlp.DecodeBytes(payload, &t); err != nil {
+			return 0, nil, fmt.Errorf("failed to decode trailer: %w", err)
+		}
+		if t.Chunks != r.chunks || t.Records != r.records {
+			return 0, nil, fmt.Errorf("read %d chunks with %d records, expected %d chunks with %d records", r.chunks, r.records, t.Chunks, t.Records)
+		}
+		r.done = true
+		return 0, nil, io.EOF
+	case kind == headerChunk || kind > endChunk:
+		return 0, nil, fmt.Errorf("%w: %s chunk", errUnexpectedChunk, kind)
+	}
+	var records []Record
+	if err := rlp.DecodeBytes(payload, &records); err != nil {
+		return 0, nil, fmt.Errorf("failed to decode %s chunk: %w", kind, err)
+	}
+	r.chunks++
+	r.records += uint64(len(records))
+	return kind, records, nil
+}
+
+func (r *Reader) readChunk() (Kind, []byte, error) {
+	var prefix [chunkPrefixLen]byte
+	if _, err := io.ReadFull(r.r, prefix[:]); err != nil {
+		if errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) {
+			return 0, nil, errMissingEnd
+		}
+		return 0, nil, err
+	}
+	kind := Kind(prefix[0])
+	length := binary.BigEndian.Uint32(prefix[1:])
+	if length > maxChunkSize {
+		return 0, nil, fmt.Errorf("%w: %s chunk of %d bytes", errChunkTooLarge, kind, length)
+	}
+	data := make([]byte, int(length)+chunkChecksumLen)
+	if _, err := io.ReadFull(r.r, data); err != nil {
+		if errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) {
+			return 0, nil, errMissingEnd
+		}
+		return 0, nil, err
+	}
+	payload := data[:length]
+	if !bytes.Equal(data[length:], checksum(kind, payload)) {
+		return 0, nil, fmt.Errorf("%w: %s chunk %d", errChecksum, kind, r.chunks)
+	}
+	return kind, payload, nil
+}
+
+func checksum(kind Kind, payload []byte) []byte {
+	return crypto.Keccak256([]byte{byte(kind)}, payload)
+}
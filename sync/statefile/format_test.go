@@ -0,0 +1,147 @@
+// (c) 2024, Ava Labs, Inc. All rights reserved.
+// See the file LICENSE for licensing terms.
+
+package statefile
+
+import (
+	"bytes"
+	"encoding/binary"
+	"io"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/stretchr/testify/require"
+)
+
+var testHeader = Header{
+	BlockNumber: 4096,
+	BlockHash:   common.Hash{1},
+	Root:        common.Hash{2},
+	AtomicRoot:  common.Hash{3},
+}
+
+type testChunk struct {
+	kind    Kind
+	records []Record
+}
+
+// writeTestFile writes a state file with [n] records of each kind.
+func writeTestFile(t *testing.T, n int) []byte {
+	var buf bytes.Buffer
+	w, err := NewWriter(&buf, testHeader)
+	require.NoError(t, err)
+	for _, kind := range []Kind{BlockChunk, StateChunk, CodeChunk, AtomicChunk} {
+		for i := 0; i < n; i++ {
+			require.NoError(t, w.Write(kind, []byte{byte(kind), byte(i)}, bytes.Repeat([]byte{byte(i)}, 1024)))
+		}
+	}
+	require.NoError(t, w.Close())
+	require.ErrorIs(t, w.Close(), errWriterClosed)
+	return buf.Bytes()
+}
+
+func readTestFile(data []byte) ([]testChunk, error) {
+	r, err := NewReader(bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+	var chunks []testChunk
+	for {
+		kind, records, err := r.Next()
+		if err == io.EOF {
+			return chunks, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		chunks = append(chunks, testChunk{kind, records})
+	}
+}
+
+func TestStateFileRoundTrip(t *testing.T) {
+	n := 2*ChunkSize/1024 + 10
+	data := writeTestFile(t, n)
+
+	r, err := NewReader(bytes.NewReader(data))
+	require.NoError(t, err)
+	require.Equal(t, testHeader, r.Header())
+
+	chunks, err := readTestFile(data)
+	require.NoError(t, err)
+	counts := make(map[Kind]int)
+	for _, chunk := range chunks {
+		for _, record := range chunk.records {
+			i := counts[chunk.kind]
+			require.Equal(t, []byte{byte(chunk.kind), byte(i)}, record.Key)
+			require.Equal(t, bytes.Repeat([]byte{byte(i)}, 1024), record.Value)
+			counts[chunk.kind]++
+		}
+	}
+	// Each kind is split over several chunks.
+	require.Len(t, chunks, 4*3)
+	for _, kind := range []Kind{BlockChunk, StateChunk, CodeChunk, AtomicChunk} {
+		require.Equal(t, n, counts[kind])
+	}
+}
+
+func TestStateFileCorruption(t *testing.T) {
+	data := writeTestFile(t, 10)
+
+	tests := map[string]struct {
+		modify func([]byte) []byte
+		err    error
+	}{
+		"invalid magic": {
+			modify: func(data []byte) []byte {
+				data[0]++
+				return data
+			},
+			err: errInvalidMagic,
+		},
+		"flipped payload byte": {
+			modify: func(data []byte) []byte {
+				data[len(data)-100]++
+				return data
+			},
+			err: errChecksum,
+		},
+		"truncated": {
+			modify: func(data []byte) []byte {
+				return data[:len(data)-1]
+			},
+			err: errMissingEnd,
+		},
+		"oversized chunk": {
+			modify: func(data []byte) []byte {
+				binary.BigEndian.PutUint32(data[len(magic)+4+1:], maxChunkSize+1)
+				return data
+			},
+			err: errChunkTooLarge,
+		},
+	}
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			_, err := readTestFile(test.modify(bytes.Clone(data)))
+			require.ErrorIs(t, err, test.err)
+		})
+	}
+
+	t.Run("unsupported version", func(t *testing.T) {
+		data := bytes.Clone(data)
+		binary.BigEndian.PutUint32(data[len(magic):], Version+1)
+		_, err := readTestFile(data)
+		require.ErrorContains(t, err, "unsupported state file version")
+	})
+
+	t.Run("dropped chunk", func(t *testing.T) {
+		// Remove the first block chunk, which directly follows the header.
+		chunkEnd := func(offset int) int {
+			length := binary.BigEndian.Uint32(data[offset+1:])
+			return offset + chunkPrefixLen + int(length) + chunkChecksumLen
+		}
+		offset := chunkEnd(len(magic) + 4)
+		dropped := append(bytes.Clone(data[:offset]), data[chunkEnd(offset):]...)
+		_, err := readTestFile(dropped)
+		require.ErrorContains(t, err, "expected 4 chunks")
+	})
+}
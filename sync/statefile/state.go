@@ -0,0 +1,271 @@
+// (c) 2024, Ava Labs, Inc. All rights reserved.
+// See the file LICENSE for licensing terms.
+
+package statefile
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"sort"
+
+	"github.com/ava-labs/coreth/core/rawdb"
+	"github.com/ava-labs/coreth/core/types"
+	"github.com/ava-labs/coreth/trie"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/ethdb"
+	"github.com/ethereum/go-ethereum/log"
+	"github.com/ethereum/go-ethereum/rlp"
+)
+
+const storageKeyLen = 2 * common.HashLength
+
+var (
+	errRootMismatch      = errors.New("state root mismatch")
+	errUnexpectedStorage = errors.New("storage slot does not belong to the last account")
+	errInvalidRecord     = errors.New("invalid state record")
+	errInvalidCode       = errors.New("code does not match its hash")
+	errMissingCode       = errors.New("missing code")
+)
+
+// ExportState writes the accounts and storage slots of the state with
+// [root] to [w] as [StateChunk] records, followed by the code of its
+// contracts as [CodeChunk] records. Code is read from [db].
+func ExportState(db ethdb.KeyValueReader, triedb *trie.Database, root common.Hash, w *Writer) error {
+	accTrie, err := trie.New(trie.StateTrieID(root), triedb)
+	if err != nil {
+		return err
+	}
+	nodeIt, err := accTrie.NodeIterator(nil)
+	if err != nil {
+		return err
+	}
+	var (
+		codeHashes = make(map[common.Hash]struct{})
+		accounts   int
+		slots      int
+		it         = trie.NewIterator(nodeIt)
+	)
+	for it.Next() {
+		var acc types.StateAccount
+		if err := rlp.DecodeBytes(it.Value, &acc); err != nil {
+			return fmt.Errorf("failed to decode account %x: %w", it.Key, err)
+		}
+		if err := w.Write(StateChunk, it.Key, it.Value); err != nil {
+			return err
+		}
+		accounts++
+		if codeHash := common.BytesToHash(acc.CodeHash); codeHash != types.EmptyCodeHash {
+			codeHashes[codeHash] = struct{}{}
+		}
+		if acc.Root == types.EmptyRootHash {
+			continue
+		}
+		accHash := common.BytesToHash(it.Key)
+		storageTrie, err := trie.New(trie.StorageTrieID(root, accHash, acc.Root), triedb)
+		if err != nil {
+			return err
+		}
+		storageNodeIt, err := storageTrie.NodeIterator(nil)
+		if err != nil {
+			return err
+		}
+		storageIt := trie.NewIterator(storageNodeIt)
+		for storageIt.Next() {
+			if err := w.Write(StateChunk, append(accHash[:], storageIt.Key...), storageIt.Value); err != nil {
+				return err
+			}
+			slots++
+		}
+		if storageIt.Err != nil {
+			return fmt.Errorf("failed to iterate storage of account %s: %w", accHash, storageIt.Err)
+		}
+	}
+	if it.Err != nil {
+		return it.Err
+	}
+
+	hashes := make([]common.Hash, 0, len(codeHashes))
+	for hash := range codeHashes {
+		hashes = append(hashes, hash)
+	}
+	sort.Slice(hashes, func(i, j int) bool { return bytes.Compare(hashes[i][:], hashes[j][:]) < 0 })
+	for _, hash := range hashes {
+		code := rawdb.ReadCode(db, hash)
+		if len(code) == 0 {
+			return fmt.Errorf("%w: %s", errMissingCode, hash)
+		}
+		if err := w.Write(CodeChunk, hash[:], code); err != nil {
+			return err
+		}
+	}
+	log.Info("Exported state", "root", root, "accounts", accounts, "slots", slots, "codes", len(hashes))
+	return nil
+}
+
+// StateImporter writes the state held by the [StateChunk] and [CodeChunk]
+// records of a state file to a database, along with its snapshot, the same
+// way state sync does. The tries are rebuilt from the records, so that the
+// imported state is verified against the expected root.
+type StateImporter struct {
+	db        ethdb.Database
+	batch     ethdb.Batch
+	batchSize int
+	scheme    string
+	root      common.Hash
+
+	accountTrie *trie.StackTrie
+
+	// account is the last imported account, whose storage slots are
+	// inserted into [storageTrie].
+	account     common.Hash
+	storageRoot common.Hash
+	storageTrie *trie.StackTrie
+
+	// codeHashes are the code hashes of the imported accounts whose code was
+	// not imported yet.
+	codeHashes map[common.Hash]struct{}
+
+	accounts, slots, codes int
+}
+
+// NewStateImporter returns a [StateImporter] which writes the state with
+// [root] to [db], storing trie nodes with [scheme].
+func NewStateImporter(db ethdb.Database, scheme string, root common.Hash, batchSize int) *StateImporter {
+	i := &StateImporter{
+		db:         db,
+		batch:      db.NewBatch(),
+		batchSize:  batchSize,
+		scheme:     scheme,
+		root:       root,
+		codeHashes: make(map[common.Hash]struct{}),
+	}
+	i.accountTrie = trie.NewStackTrie(&trie.StackTrieOptions{
+		Writer: func(path []byte, hash common.Hash, blob []byte) {
+			rawdb.WriteTrieNode(i.batch, common.Hash{}, path, hash, blob, i.scheme)
+		},
+	})
+	return i
+}
+
+// Import imports [records] of [kind], which must be either [StateChunk] or
+// [CodeChunk].
+func (i *StateImporter) Import(kind Kind, records []Record) error {
+	for _, record := range records {
+		var err error
+		switch kind {
+		case StateChunk:
+			err = i.importState(record)
+		case CodeChunk:
+			err = i.importCode(record)
+		default:
+			err = fmt.Errorf("%w: %s chunk", errUnexpectedChunk, kind)
+		}
+		if err != nil {
+			return err
+		}
+		if i.batch.ValueSize() > i.batchSize {
+			if err := i.batch.Write(); err != nil {
+				return err
+			}
+			i.batch.Reset()
+		}
+	}
+	return nil
+}
+
+func (i *StateImporter) importState(record Record) error {
+	switch len(record.Key) {
+	case common.HashLength:
+		if err := i.finishStorage(); err != nil {
+			return err
+		}
+		var acc types.StateAccount
+		if err := rlp.DecodeBytes(record.Value, &acc); err != nil {
+			return fmt.Errorf("failed to decode account %x: %w", record.Key, err)
+		}
+		if err := i.accountTrie.Update(record.Key, record.Value); err != nil {
+			return fmt.Errorf("failed to insert account %x: %w", record.Key, err)
+		}
+		i.account = common.BytesToHash(record.Key)
+		rawdb.WriteAccountSnapshot(i.batch, i.account, types.SlimAccountRLP(acc))
+		if codeHash := common.BytesToHash(acc.CodeHash); codeHash != types.EmptyCodeHash {
+			i.codeHashes[codeHash] = struct{}{}
+		}
+		if acc.Root != types.EmptyRootHash {
+			owner := i.account
+			i.storageRoot = acc.Root
+			i.storageTrie = trie.NewStackTrie(&trie.StackTrieOptions{
+				Writer: func(path []byte, hash common.Hash, blob []byte) {
+					if i.scheme != rawdb.PathScheme {
+						rawdb.WriteTrieNode(i.batch, common.Hash{}, path, hash, blob, rawdb.HashScheme)
+						return
+					}
+					rawdb.WriteTrieNode(i.batch, owner, path, hash, blob, rawdb.PathScheme)
+				},
+			})
+		}
+		i.accounts++
+		return nil
+
+	case storageKeyLen:
+		if i.storageTrie == nil || !bytes.Equal(record.Key[:common.HashLength], i.account[:]) {
+			return fmt.Errorf("%w: %x", errUnexpectedStorage, record.Key)
+		}
+		slot := record.Key[common.HashLength:]
+		if err := i.storageTrie.Update(slot, record.Value); err != nil {
+			return fmt.Errorf("failed to insert slot %x of account %s: %w", slot, i.account, err)
+		}
+		rawdb.WriteStorageSnapshot(i.batch, i.account, common.BytesToHash(slot), record.Value)
+		i.slots++
+		return nil
+
+	default:
+		return fmt.Errorf("%w: key %x", errInvalidRecord, record.Key)
+	}
+}
+
+// finishStorage verifies the storage trie of the last imported account.
+func (i *StateImporter) finishStorage() error {
+	if i.storageTrie == nil {
+		return nil
+	}
+	root := i.storageTrie.Commit()
+	i.storageTrie = nil
+	if root != i.storageRoot {
+		return fmt.Errorf("%w: storage of account %s has root %s, expected %s", errRootMismatch, i.account, root, i.storageRoot)
+	}
+	return nil
+}
+
+func (i *StateImporter) importCode(record Record) error {
+	hash := common.BytesToHash(record.Key)
+	if len(record.Key) != common.HashLength || crypto.Keccak256Hash(record.Value) != hash {
+		return fmt.Errorf("%w: %x", errInvalidCode, record.Key)
+	}
+	rawdb.WriteCode(i.batch, hash, record.Value)
+	delete(i.codeHashes, hash)
+	i.codes++
+	return nil
+}
+
+// Finish writes the remaining data to the database and verifies that the
+// imported state has the expected root and that the code of every account
+// was imported.
+func (i *StateImporter) Finish() error {
+	if err := i.finishStorage(); err != nil {
+		return err
+	}
+	if root := i.accountTrie.Commit(); root != i.root {
+		return fmt.Errorf("%w: imported state has root %s, expected %s", errRootMismatch, root, i.root)
+	}
+	for hash := range i.codeHashes {
+		return fmt.Errorf("%w: %s", errMissingCode, hash)
+	}
+	if err := i.batch.Write(); err != nil {
+		return err
+	}
+	log.Info("Imported state", "root", i.root, "accounts", i.accounts, "slots", i.slots, "codes", i.codes)
+	return nil
+}
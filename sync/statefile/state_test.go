@@ -0,0 +1,175 @@
+// (c) 2024, Ava Labs, Inc. All rights reserved.
+// See the file LICENSE for licensing terms.
+
+package statefile
+
+import (
+	"bytes"
+	"math/rand"
+	"testing"
+
+	"github.com/ava-labs/coreth/core/rawdb"
+	"github.com/ava-labs/coreth/core/types"
+	"github.com/ava-labs/coreth/sync/syncutils"
+	"github.com/ava-labs/coreth/trie"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/ethdb"
+	"github.com/ethereum/go-ethereum/rlp"
+	"github.com/stretchr/testify/require"
+)
+
+// newTestState creates a state in which every other account has storage
+// and every third account has code.
+func newTestState(t *testing.T, numAccounts int) (ethdb.Database, *trie.Database, common.Hash) {
+	db := rawdb.NewMemoryDatabase()
+	triedb := trie.NewDatabase(db, nil)
+	root, _ := syncutils.FillAccounts(t, triedb, common.Hash{}, numAccounts, func(t *testing.T, index int, account types.StateAccount) types.StateAccount {
+		if index%2 == 0 {
+			account.Root, _, _ = syncutils.GenerateTrie(t, triedb, 16, common.HashLength)
+		}
+		if index%3 == 0 {
+			code := make([]byte, 256)
+			_, err := rand.Read(code)
+			require.NoError(t, err)
+			codeHash := crypto.Keccak256Hash(code)
+			rawdb.WriteCode(db, codeHash, code)
+			account.CodeHash = codeHash[:]
+		}
+		return account
+	})
+	return db, triedb, root
+}
+
+// exportTestState returns the chunks of the state file exporting [root].
+func exportTestState(t *testing.T, db ethdb.Database, triedb *trie.Database, root common.Hash) []testChunk {
+	var buf bytes.Buffer
+	w, err := NewWriter(&buf, Header{Root: root})
+	require.NoError(t, err)
+	require.NoError(t, ExportState(db, triedb, root, w))
+	require.NoError(t, w.Close())
+
+	chunks, err := readTestFile(buf.Bytes())
+	require.NoError(t, err)
+	return chunks
+}
+
+func importTestState(db ethdb.Database, root common.Hash, chunks []testChunk) error {
+	importer := NewStateImporter(db, rawdb.HashScheme, root, 1024)
+	for _, chunk := range chunks {
+		if err := importer.Import(chunk.kind, chunk.records); err != nil {
+			return err
+		}
+	}
+	return importer.Finish()
+}
+
+func TestExportImportState(t *testing.T) {
+	serverDB, serverTrieDB, root := newTestState(t, 100)
+	chunks := exportTestState(t, serverDB, serverTrieDB, root)
+
+	clientDB := rawdb.NewMemoryDatabase()
+	require.NoError(t, importTestState(clientDB, root, chunks))
+
+	clientTrieDB := trie.NewDatabase(clientDB, nil)
+	var accounts int
+	syncutils.AssertTrieConsistency(t, root, serverTrieDB, clientTrieDB, func(key, val []byte) error {
+		accounts++
+		accHash := common.BytesToHash(key)
+		var acc types.StateAccount
+		require.NoError(t, rlp.DecodeBytes(val, &acc))
+		require.Equal(t, types.SlimAccountRLP(acc), rawdb.ReadAccountSnapshot(clientDB, accHash))
+		if codeHash := common.BytesToHash(acc.CodeHash); codeHash != types.EmptyCodeHash {
+			require.Equal(t, rawdb.ReadCode(serverDB, codeHash), rawdb.ReadCode(clientDB, codeHash))
+		}
+		if acc.Root == types.EmptyRootHash {
+			return nil
+		}
+		syncutils.AssertTrieConsistency(t, acc.Root, serverTrieDB, clientTrieDB, func(key, val []byte) error {
+			require.Equal(t, val, rawdb.ReadStorageSnapshot(clientDB, accHash, common.BytesToHash(key)))
+			return nil
+		})
+		return nil
+	})
+	require.Equal(t, 100, accounts)
+}
+
+func TestImportStateVerification(t *testing.T) {
+	serverDB, serverTrieDB, root := newTestState(t, 30)
+
+	tests := map[string]struct {
+		modify func(chunks []testChunk) []testChunk
+		err    error
+	}{
+		"modified account": {
+			modify: func(chunks []testChunk) []testChunk {
+				record := &chunks[0].records[0]
+				var acc types.StateAccount
+				require.NoError(t, rlp.DecodeBytes(record.Value, &acc))
+				acc.Nonce++
+				value, err := rlp.EncodeToBytes(&acc)
+				require.NoError(t, err)
+				record.Value = value
+				return chunks
+			},
+			err: errRootMismatch,
+		},
+		"modified storage slot": {
+			modify: func(chunks []testChunk) []testChunk {
+				for i, record := range chunks[0].records {
+					if len(record.Key) == storageKeyLen {
+						chunks[0].records[i].Value = []byte{0x01}
+						break
+					}
+				}
+				return chunks
+			},
+			err: errRootMismatch,
+		},
+		"misplaced storage slot": {
+			modify: func(chunks []testChunk) []testChunk {
+				// Move the first storage slot before its account.
+				records := chunks[0].records
+				for i, record := range records {
+					if len(record.Key) == storageKeyLen {
+						records[i-1], records[i] = record, records[i-1]
+						break
+					}
+				}
+				return chunks
+			},
+			err: errUnexpectedStorage,
+		},
+		"missing code": {
+			modify: func(chunks []testChunk) []testChunk {
+				return chunks[:len(chunks)-1]
+			},
+			err: errMissingCode,
+		},
+		"invalid code": {
+			modify: func(chunks []testChunk) []testChunk {
+				last := chunks[len(chunks)-1]
+				last.records[0].Value = append(last.records[0].Value, 0x00)
+				return chunks
+			},
+			err: errInvalidCode,
+		},
+	}
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			chunks := exportTestState(t, serverDB, serverTrieDB, root)
+			require.Len(t, chunks, 2)
+			require.Equal(t, StateChunk, chunks[0].kind)
+			require.Equal(t, CodeChunk, chunks[1].kind)
+
+			err := importTestState(rawdb.NewMemoryDatabase(), root, test.modify(chunks))
+			require.ErrorIs(t, err, test.err)
+		})
+	}
+
+	t.Run("unexpected chunk", func(t *testing.T) {
+		chunks := []testChunk{{kind: AtomicChunk, records: []Record{{Key: []byte{1}, Value: []byte{1}}}}}
+		err := importTestState(rawdb.NewMemoryDatabase(), root, chunks)
+		require.ErrorIs(t, err, errUnexpectedChunk)
+	})
+}
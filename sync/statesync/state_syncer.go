@@ -8,6 +8,7 @@ import (
 	"fmt"
 	"sync"
 
+	"github.com/ava-labs/coreth/core/rawdb"
 	"github.com/ava-labs/coreth/core/state/snapshot"
 	syncclient "github.com/ava-labs/coreth/sync/client"
 	"github.com/ava-labs/coreth/trie"
@@ -31,11 +32,13 @@ type StateSyncerConfig struct {
 	MaxOutstandingCodeHashes int    // Maximum number of code hashes in the code syncer queue
 	NumCodeFetchingWorkers   int    // Number of code syncing threads
 	RequestSize              uint16 // Number of leafs to request from a peer at a time
+	Scheme                   string // Scheme used to store the synced trie nodes, defaults to the hash scheme
 }
 
 // stateSync keeps the state of the entire state sync operation.
 type stateSync struct {
 	db        ethdb.Database    // database we are syncing
+	scheme    string            // scheme used to store the synced trie nodes
 	root      common.Hash       // root of the EVM state we are syncing to
 	trieDB    *trie.Database    // trieDB on top of db we are syncing. used to restore any existing tries.
 	snapshot  snapshot.Snapshot // used to access the database we are syncing as a snapshot.
@@ -63,9 +66,14 @@ type stateSync struct {
 }
 
 func NewStateSyncer(config *StateSyncerConfig) (*stateSync, error) {
+	scheme := config.Scheme
+	if scheme == "" {
+		scheme = rawdb.HashScheme
+	}
 	ss := &stateSync{
 		batchSize:       config.BatchSize,
 		db:              config.DB,
+		scheme:          scheme,
 		client:          config.Client,
 		root:            config.Root,
 		trieDB:          trie.NewDatabase(config.DB, nil),
@@ -109,6 +117,19 @@ func NewStateSyncer(config *StateSyncerConfig) (*stateSync, error) {
 	return ss, nil
 }
 
+// writeTrieNode writes a node of a synced trie to [db]. In the path scheme,
+// nodes are keyed by the account owning the trie, so the nodes of a storage
+// trie shared by several accounts are written for each of [owners].
+func (t *stateSync) writeTrieNode(db ethdb.KeyValueWriter, owners []common.Hash, path []byte, hash common.Hash, blob []byte) {
+	if t.scheme != rawdb.PathScheme {
+		rawdb.WriteTrieNode(db, common.Hash{}, path, hash, blob, rawdb.HashScheme)
+		return
+	}
+	for _, owner := range owners {
+		rawdb.WriteTrieNode(db, owner, path, hash, blob, rawdb.PathScheme)
+	}
+}
+
 // onStorageTrieFinished is called after a storage trie finishes syncing.
 func (t *stateSync) onStorageTrieFinished(root common.Hash) error {
 	<-t.triesInProgressSem // allow another trie to start (release the semaphore)
@@ -22,6 +22,7 @@ import (
 	handlerstats "github.com/ava-labs/coreth/sync/handlers/stats"
 	"github.com/ava-labs/coreth/sync/syncutils"
 	"github.com/ava-labs/coreth/trie"
+	"github.com/ava-labs/coreth/trie/triedb/pathdb"
 	"github.com/ethereum/go-ethereum/common"
 	"github.com/ethereum/go-ethereum/crypto"
 	"github.com/ethereum/go-ethereum/ethdb"
@@ -39,6 +40,7 @@ type syncTest struct {
 	expectedError     error
 	GetLeafsIntercept func(message.LeafsRequest, message.LeafsResponse) (message.LeafsResponse, error)
 	GetCodeIntercept  func([]common.Hash, [][]byte) ([][]byte, error)
+	scheme            string
 }
 
 func testSync(t *testing.T, test syncTest) {
@@ -63,6 +65,7 @@ func testSync(t *testing.T, test syncTest) {
 		NumCodeFetchingWorkers:   DefaultNumCodeFetchingWorkers,
 		MaxOutstandingCodeHashes: DefaultMaxOutstandingCodeHashes,
 		RequestSize:              1024,
+		Scheme:                   test.scheme,
 	})
 	if err != nil {
 		t.Fatal(err)
@@ -74,9 +77,62 @@ func testSync(t *testing.T, test syncTest) {
 		return
 	}
 
+	if test.scheme == rawdb.PathScheme {
+		assertPathDBConsistency(t, root, clientDB, serverTrieDB)
+		return
+	}
 	assertDBConsistency(t, root, clientDB, serverTrieDB, trie.NewDatabase(clientDB, nil))
 }
 
+// assertPathDBConsistency checks the state synced to [clientDB] in the path
+// scheme matches the state at [root] in [serverTrieDB], with the storage tries
+// stored under each of the accounts holding them.
+func assertPathDBConsistency(t *testing.T, root common.Hash, clientDB ethdb.Database, serverTrieDB *trie.Database) {
+	assert.Equal(t, rawdb.PathScheme, rawdb.ReadStateScheme(clientDB))
+	clientTrieDB := trie.NewDatabase(clientDB, &trie.Config{PathDB: pathdb.Defaults})
+	defer clientTrieDB.Close()
+
+	accounts := assertTrieLeafs(t, trie.TrieID(root), serverTrieDB, trie.StateTrieID(root), clientTrieDB)
+	for accHash, val := range accounts {
+		var acc types.StateAccount
+		if err := rlp.DecodeBytes(val, &acc); err != nil {
+			t.Fatal(err)
+		}
+		if acc.Root == types.EmptyRootHash {
+			continue
+		}
+		assertTrieLeafs(t, trie.TrieID(acc.Root), serverTrieDB, trie.StorageTrieID(root, accHash, acc.Root), clientTrieDB)
+	}
+}
+
+// assertTrieLeafs checks the trie [clientID] of [clientTrieDB] has the leafs of
+// the trie [serverID] of [serverTrieDB], returning them by key.
+func assertTrieLeafs(t *testing.T, serverID *trie.ID, serverTrieDB *trie.Database, clientID *trie.ID, clientTrieDB *trie.Database) map[common.Hash][]byte {
+	leafs := func(id *trie.ID, db *trie.Database) map[common.Hash][]byte {
+		tr, err := trie.New(id, db)
+		if err != nil {
+			t.Fatal(err)
+		}
+		nodeIt, err := tr.NodeIterator(nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		it := trie.NewIterator(nodeIt)
+		leafs := make(map[common.Hash][]byte)
+		for it.Next() {
+			leafs[common.BytesToHash(it.Key)] = it.Value
+		}
+		if err := it.Err; err != nil {
+			t.Fatal(err)
+		}
+		return leafs
+	}
+	expected := leafs(serverID, serverTrieDB)
+	assert.NotEmpty(t, expected)
+	assert.Equal(t, expected, leafs(clientID, clientTrieDB))
+	return expected
+}
+
 // testSyncResumes tests a series of syncTests work as expected, invoking a callback function after each
 // successive step.
 func testSyncResumes(t *testing.T, steps []syncTest, stepCallback func()) {
@@ -209,6 +265,35 @@ func TestSimpleSyncCases(t *testing.T) {
 	}
 }
 
+func TestSyncPathScheme(t *testing.T) {
+	tests := map[string]syncTest{
+		"accounts with code and storage": {
+			prepareForTest: func(t *testing.T) (ethdb.Database, ethdb.Database, *trie.Database, common.Hash) {
+				serverDB := rawdb.NewMemoryDatabase()
+				serverTrieDB := trie.NewDatabase(serverDB, nil)
+				root := fillAccountsWithStorage(t, serverDB, serverTrieDB, common.Hash{}, 250)
+				return rawdb.NewMemoryDatabase(), serverDB, serverTrieDB, root
+			},
+			scheme: rawdb.PathScheme,
+		},
+		"accounts with overlapping storage": {
+			prepareForTest: func(t *testing.T) (ethdb.Database, ethdb.Database, *trie.Database, common.Hash) {
+				serverDB := rawdb.NewMemoryDatabase()
+				serverTrieDB := trie.NewDatabase(serverDB, nil)
+				root, _ := FillAccountsWithOverlappingStorage(t, serverTrieDB, common.Hash{}, 250, 3)
+				return rawdb.NewMemoryDatabase(), serverDB, serverTrieDB, root
+			},
+			scheme: rawdb.PathScheme,
+		},
+	}
+	for name, test := range tests {
+		rand.Seed(1)
+		t.Run(name, func(t *testing.T) {
+			testSync(t, test)
+		})
+	}
+}
+
 func TestCancelSync(t *testing.T) {
 	serverDB := rawdb.NewMemoryDatabase()
 	serverTrieDB := trie.NewDatabase(serverDB, nil)
@@ -63,9 +63,10 @@ type trieToSync struct {
 
 // NewTrieToSync initializes a trieToSync and restores any previously started segments.
 func NewTrieToSync(sync *stateSync, root common.Hash, account common.Hash, syncTask syncTask) (*trieToSync, error) {
-	batch := sync.db.NewBatch() // TODO: migrate state sync to use database schemes.
+	batch := sync.db.NewBatch()
+	owners := syncTask.Owners()
 	writeFn := func(path []byte, hash common.Hash, blob []byte) {
-		rawdb.WriteTrieNode(batch, account, path, hash, blob, rawdb.HashScheme)
+		sync.writeTrieNode(batch, owners, path, hash, blob)
 	}
 	trieToSync := &trieToSync{
 		sync:         sync,
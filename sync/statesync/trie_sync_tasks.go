@@ -28,6 +28,10 @@ type syncTask interface {
 	// interrupted sync and for hashing segments.
 	IterateLeafs(seek common.Hash) ethdb.Iterator
 
+	// Owners returns the accounts owning the trie, which key its
+	// nodes in the path scheme. The main trie has no owner.
+	Owners() []common.Hash
+
 	// callbacks used to form a LeafSyncTask
 	OnStart() (bool, error)
 	OnLeafs(db ethdb.KeyValueWriter, keys, vals [][]byte) error
@@ -49,6 +53,10 @@ func (m *mainTrieTask) IterateLeafs(seek common.Hash) ethdb.Iterator {
 	return &syncutils.AccountIterator{AccountIterator: snapshot.AccountIterator(seek)}
 }
 
+func (m *mainTrieTask) Owners() []common.Hash {
+	return []common.Hash{{}}
+}
+
 // OnStart always returns false since the main trie task cannot be skipped.
 func (m *mainTrieTask) OnStart() (bool, error) {
 	return false, nil
@@ -108,7 +116,16 @@ func (s *storageTrieTask) IterateLeafs(seek common.Hash) ethdb.Iterator {
 	return &syncutils.StorageIterator{StorageIterator: it}
 }
 
+func (s *storageTrieTask) Owners() []common.Hash {
+	return s.accounts
+}
+
 func (s *storageTrieTask) OnStart() (bool, error) {
+	// In the path scheme, the nodes of an existing storage trie are keyed by
+	// the accounts it was synced for, so it cannot be reused.
+	if s.sync.scheme == rawdb.PathScheme {
+		return false, nil
+	}
 	// check if this storage root is on disk
 	var firstAccount common.Hash
 	if len(s.accounts) > 0 {
@@ -251,6 +251,18 @@ func (db *Database) Dereference(root common.Hash) error {
 	return nil
 }
 
+// SetWriteHook registers a callback that is invoked with the hash of every
+// trie node before it is flushed to disk. It's only supported by hash-based
+// database and will return an error for others.
+func (db *Database) SetWriteHook(hook func(common.Hash)) error {
+	hdb, ok := db.backend.(*hashdb.Database)
+	if !ok {
+		return errors.New("not supported")
+	}
+	hdb.SetWriteHook(hook)
+	return nil
+}
+
 // Recover rollbacks the database to a specified historical point. The state is
 // supported as the rollback destination only if it's canonical state and the
 // corresponding trie histories are existent. It's only supported by path-based
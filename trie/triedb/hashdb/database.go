@@ -136,6 +136,8 @@ type Database struct {
 	dirtiesSize  common.StorageSize // Storage size of the dirty node cache (exc. metadata)
 	childrenSize common.StorageSize // Storage size of the external children tracking
 
+	writeHook func(common.Hash) // Invoked for every node before it is flushed to disk, nil if unset
+
 	lock sync.RWMutex
 }
 
@@ -383,13 +385,17 @@ type flushItem struct {
 }
 
 // writeFlushItems writes all items in [toFlush] to disk in batches of
-// [ethdb.IdealBatchSize]. This function does not access any variables inside
-// of [Database] and does not need to be synchronized.
-func (db *Database) writeFlushItems(toFlush []*flushItem) error {
+// [ethdb.IdealBatchSize]. If [hook] is non-nil, it is invoked with the hash of
+// each item before the item is written. This function does not access any
+// variables inside of [Database] and does not need to be synchronized.
+func (db *Database) writeFlushItems(toFlush []*flushItem, hook func(common.Hash)) error {
 	batch := db.diskdb.NewBatch()
 	for _, item := range toFlush {
 		rlp := item.node.node
 		item.rlp = rlp
+		if hook != nil {
+			hook(item.hash)
+		}
 		rawdb.WriteLegacyTrieNode(batch, item.hash, rlp)
 
 		// If we exceeded the ideal batch size, commit and reset
@@ -451,11 +457,12 @@ func (db *Database) Cap(limit common.StorageSize) error {
 		}
 		oldest = node.flushNext
 	}
+	hook := db.writeHook
 	db.lock.RUnlock()
 	lockTime := time.Since(lockStart)
 
 	// Write nodes to disk
-	if err := db.writeFlushItems(toFlush); err != nil {
+	if err := db.writeFlushItems(toFlush, hook); err != nil {
 		return err
 	}
 
@@ -508,11 +515,12 @@ func (db *Database) Commit(node common.Hash, report bool) error {
 		log.Error("Failed to commit trie from trie database", "err", err)
 		return err
 	}
+	hook := db.writeHook
 	db.lock.RUnlock()
 	lockTime := time.Since(lockStart)
 
 	// Write nodes to disk
-	if err := db.writeFlushItems(toFlush); err != nil {
+	if err := db.writeFlushItems(toFlush, hook); err != nil {
 		return err
 	}
 
@@ -702,6 +710,16 @@ func (db *Database) update(root common.Hash, parent common.Hash, nodes *trienode
 	return nil
 }
 
+// SetWriteHook registers a callback that is invoked with the hash of every
+// node before it is flushed to disk by Commit or Cap. Passing nil removes the
+// hook.
+func (db *Database) SetWriteHook(hook func(common.Hash)) {
+	db.lock.Lock()
+	defer db.lock.Unlock()
+
+	db.writeHook = hook
+}
+
 // Size returns the current storage size of the memory cache in front of the
 // persistent database layer.
 //
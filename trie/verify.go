@@ -0,0 +1,134 @@
+// (c) 2024, Ava Labs, Inc. All rights reserved.
+// See the file LICENSE for licensing terms.
+
+package trie
+
+import (
+	"fmt"
+
+	"github.com/ava-labs/coreth/core/types"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/crypto"
+)
+
+// CorruptNodeError is reported by [Verify] for a trie node which does not
+// hash to the hash referencing it or cannot be decoded.
+type CorruptNodeError struct {
+	Owner    common.Hash // owner of the trie if it's 2-layered trie
+	NodeHash common.Hash // expected hash of the node
+	Path     []byte      // hex-encoded path to the node
+	err      error
+}
+
+func (err *CorruptNodeError) Unwrap() error {
+	return err.err
+}
+
+func (err *CorruptNodeError) Error() string {
+	if err.Owner == (common.Hash{}) {
+		return fmt.Sprintf("corrupt trie node %x (path %x): %v", err.NodeHash, err.Path, err.err)
+	}
+	return fmt.Sprintf("corrupt trie node %x (owner %x) (path %x): %v", err.NodeHash, err.Owner, err.Path, err.err)
+}
+
+// VerifyCallbacks are called by [Verify] while walking a trie.
+type VerifyCallbacks struct {
+	// OnNode is called with the path and size of each node read from the
+	// database. Returning an error stops the walk.
+	OnNode func(path []byte, size int) error
+	// OnLeaf is called with the key and value of each leaf. Returning an
+	// error stops the walk.
+	OnLeaf func(key, value []byte) error
+	// OnInvalid is called with a [*MissingNodeError] or [*CorruptNodeError]
+	// for each node which cannot be read or does not verify. The walk then
+	// continues after the subtrie under the node.
+	OnInvalid func(err error)
+}
+
+// Verify walks every node of the trie with [id] in [db], checking that each
+// node read from the database is present and hashes to the hash referencing
+// it. Unlike iterating the trie, the walk does not stop at the first invalid
+// node, so that all the invalid nodes are reported. An error is returned if
+// the state of [id] is no longer available in [db] before the walk ends.
+func Verify(id *ID, db *Database, callbacks VerifyCallbacks) error {
+	if id.Root == types.EmptyRootHash || id.Root == (common.Hash{}) {
+		return nil
+	}
+	reader, err := newTrieReader(id.StateRoot, id.Owner, db)
+	if err != nil {
+		return err
+	}
+	v := &trieVerifier{
+		db:        db,
+		stateRoot: id.StateRoot,
+		owner:     id.Owner,
+		reader:    reader,
+		callbacks: callbacks,
+	}
+	return v.walk(hashNode(id.Root[:]), nil)
+}
+
+type trieVerifier struct {
+	db        *Database
+	stateRoot common.Hash
+	owner     common.Hash
+	reader    *trieReader
+	callbacks VerifyCallbacks
+}
+
+func (v *trieVerifier) invalid(err error) {
+	if v.callbacks.OnInvalid != nil {
+		v.callbacks.OnInvalid(err)
+	}
+}
+
+// walk visits [n] found at [path] and its children.
+func (v *trieVerifier) walk(n node, path []byte) error {
+	switch n := n.(type) {
+	case nil:
+		return nil
+	case hashNode:
+		hash := common.BytesToHash(n)
+		blob, err := v.reader.node(path, hash)
+		if err != nil {
+			// The state may be dropped from the database during the walk,
+			// after which none of its nodes can be read.
+			if _, readerErr := v.db.Reader(v.stateRoot); readerErr != nil {
+				return readerErr
+			}
+			v.invalid(err)
+			return nil
+		}
+		if got := crypto.Keccak256Hash(blob); got != hash {
+			v.invalid(&CorruptNodeError{Owner: v.owner, NodeHash: hash, Path: common.CopyBytes(path), err: fmt.Errorf("hash mismatch, got %x", got)})
+			return nil
+		}
+		decoded, err := decodeNode(n, blob)
+		if err != nil {
+			v.invalid(&CorruptNodeError{Owner: v.owner, NodeHash: hash, Path: common.CopyBytes(path), err: err})
+			return nil
+		}
+		if v.callbacks.OnNode != nil {
+			if err := v.callbacks.OnNode(path, len(blob)); err != nil {
+				return err
+			}
+		}
+		return v.walk(decoded, path)
+	case *shortNode:
+		return v.walk(n.Val, append(path[:len(path):len(path)], n.Key...))
+	case *fullNode:
+		for i, child := range n.Children {
+			if err := v.walk(child, append(path[:len(path):len(path)], byte(i))); err != nil {
+				return err
+			}
+		}
+		return nil
+	case valueNode:
+		if v.callbacks.OnLeaf != nil {
+			return v.callbacks.OnLeaf(hexToKeybytes(path), n)
+		}
+		return nil
+	default:
+		panic(fmt.Sprintf("%T: invalid node: %v", n, n))
+	}
+}
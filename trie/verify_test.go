@@ -0,0 +1,128 @@
+// (c) 2024, Ava Labs, Inc. All rights reserved.
+// See the file LICENSE for licensing terms.
+
+package trie
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+
+	"github.com/ava-labs/coreth/core/rawdb"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/ethdb"
+	"github.com/stretchr/testify/require"
+)
+
+type verifyResult struct {
+	nodes   int
+	leaves  int
+	invalid []error
+}
+
+func verifyTestTrie(t *testing.T, db ethdb.Database, scheme string, root common.Hash) verifyResult {
+	var res verifyResult
+	err := Verify(TrieID(root), newTestDatabase(db, scheme), VerifyCallbacks{
+		OnNode: func(path []byte, size int) error {
+			res.nodes++
+			return nil
+		},
+		OnLeaf: func(key, value []byte) error {
+			res.leaves++
+			return nil
+		},
+		OnInvalid: func(err error) {
+			res.invalid = append(res.invalid, err)
+		},
+	})
+	require.NoError(t, err)
+	return res
+}
+
+// testTrieNodes returns the paths and hashes of the nodes of [trie] which are
+// stored in the database.
+func testTrieNodes(trie *StateTrie) ([][]byte, []common.Hash) {
+	var (
+		paths  [][]byte
+		hashes []common.Hash
+	)
+	it := trie.MustNodeIterator(nil)
+	for it.Next(true) {
+		if it.Hash() == (common.Hash{}) {
+			continue
+		}
+		paths = append(paths, common.CopyBytes(it.Path()))
+		hashes = append(hashes, it.Hash())
+	}
+	return paths, hashes
+}
+
+func TestVerify(t *testing.T) {
+	for _, scheme := range []string{rawdb.HashScheme, rawdb.PathScheme} {
+		t.Run(scheme, func(t *testing.T) {
+			db, _, trie, content := makeTestTrie(scheme)
+			root := trie.Hash()
+			paths, hashes := testTrieNodes(trie)
+
+			res := verifyTestTrie(t, db, scheme, root)
+			require.Empty(t, res.invalid)
+			require.Equal(t, len(paths), res.nodes)
+			require.Equal(t, len(content), res.leaves)
+
+			// Delete a node below the root. Its subtrie is reported as a
+			// single missing node while the rest of the trie is verified.
+			var missing int
+			for i, path := range paths {
+				if len(path) == 2 {
+					missing = i
+					break
+				}
+			}
+			if scheme == rawdb.HashScheme {
+				rawdb.DeleteLegacyTrieNode(db, hashes[missing])
+			} else {
+				rawdb.DeleteAccountTrieNode(db, paths[missing])
+			}
+			res = verifyTestTrie(t, db, scheme, root)
+			require.Len(t, res.invalid, 1)
+			var missingErr *MissingNodeError
+			require.True(t, errors.As(res.invalid[0], &missingErr))
+			require.Equal(t, paths[missing], missingErr.Path)
+			require.Equal(t, hashes[missing], missingErr.NodeHash)
+			require.Less(t, res.leaves, len(content))
+			require.Greater(t, res.leaves, 0)
+		})
+	}
+}
+
+func TestVerifyCorruptNode(t *testing.T) {
+	db, _, trie, _ := makeTestTrie(rawdb.HashScheme)
+	root := trie.Hash()
+	paths, hashes := testTrieNodes(trie)
+
+	// Overwrite a node with the blob of another node. The hash scheme
+	// reader does not check the hash of the nodes it reads, so the
+	// corruption is detected by the walk.
+	corrupt, other := -1, -1
+	for i, path := range paths {
+		if len(path) != 2 {
+			continue
+		}
+		if corrupt == -1 {
+			corrupt = i
+		} else if !bytes.Equal(path, paths[corrupt]) {
+			other = i
+			break
+		}
+	}
+	require.NotEqual(t, -1, other)
+	rawdb.WriteLegacyTrieNode(db, hashes[corrupt], rawdb.ReadLegacyTrieNode(db, hashes[other]))
+
+	res := verifyTestTrie(t, db, rawdb.HashScheme, root)
+	require.Len(t, res.invalid, 1)
+	var corruptErr *CorruptNodeError
+	require.True(t, errors.As(res.invalid[0], &corruptErr))
+	require.Equal(t, paths[corrupt], corruptErr.Path)
+	require.Equal(t, hashes[corrupt], corruptErr.NodeHash)
+	require.ErrorContains(t, corruptErr, "hash mismatch")
+}